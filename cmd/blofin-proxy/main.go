@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/joshthetrader/blofin-proxy/pkg/proxy"
+)
+
+func main() {
+	validate := flag.Bool("validate", false, "check the configuration and exit")
+	configFile := flag.String("config", "", "file of KEY=value settings, or KEY: value for .yaml/.yml; the environment takes precedence")
+	flag.Parse()
+
+	if *configFile != "" {
+		if err := proxy.LoadEnvFile(*configFile); err != nil {
+			log.Fatalf("Failed to read config file: %v", err)
+		}
+	}
+	// A missing .env is fine unless ENV_FILE asked for it
+	envFile, explicit := os.LookupEnv("ENV_FILE")
+	if !explicit {
+		envFile = proxy.DEFAULT_ENV_FILE
+	}
+	if err := proxy.LoadEnvFile(envFile); err == nil {
+		log.Printf("📄 Loaded settings from %s", envFile)
+	} else if explicit || !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Failed to read %s: %v", envFile, err)
+	}
+	cfg := proxy.LoadConfig()
+	if *validate {
+		if err := cfg.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Invalid configuration:\n%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("✅ Configuration is valid")
+		return
+	}
+	handler, err := proxy.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to start proxy: %v", err)
+	}
+
+	log.Printf("🚀 Blofin CORS Proxy starting on port %s", cfg.Port)
+	log.Printf("🌐 Health check: http://localhost:%s/health", cfg.Port)
+	docsPort := cfg.Port
+	if cfg.AdminPort != "" {
+		docsPort = cfg.AdminPort
+	}
+	log.Printf("📖 API explorer: http://localhost:%s/docs", docsPort)
+
+	// Listeners report here if they fail; closing them on shutdown isn't a failure
+	failed := make(chan error, 2)
+	var servers []*http.Server
+	if admin := handler.AdminHandler(); admin != nil {
+		host := ""
+		if cfg.AdminLocalOnly {
+			host = "127.0.0.1"
+		}
+		log.Printf("🔐 Admin API, metrics, local data and docs on %s:%s", host, cfg.AdminPort)
+		adminServer := cfg.HTTPServer(host+":"+cfg.AdminPort, admin)
+		servers = append(servers, adminServer)
+		go func() {
+			if err := adminServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				failed <- fmt.Errorf("Admin server failed to start: %v", err)
+			}
+		}()
+	}
+
+	server := cfg.HTTPServer(":"+cfg.Port, handler)
+	servers = append(servers, server)
+	go func() {
+		var err error
+		if server.TLSConfig = handler.TLSConfig(); server.TLSConfig != nil {
+			log.Printf("🔒 Serving HTTPS with %s", cfg.TLS.CertFile)
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			failed <- fmt.Errorf("Server failed to start: %v", err)
+		}
+	}()
+
+	stop, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	select {
+	case err := <-failed:
+		log.Fatal(err)
+	case <-stop.Done():
+	}
+
+	// Let in-flight requests finish; streams that outlast the timeout are cut
+	log.Printf("🛑 Shutting down, waiting up to %s for requests to finish", cfg.Server.ShutdownTimeout)
+	ctx, done := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer done()
+	for _, s := range servers {
+		if err := s.Shutdown(ctx); err != nil {
+			log.Printf("⚠️ Closing connections still open on %s: %v", s.Addr, err)
+			s.Close()
+		}
+	}
+	if err := handler.Close(); err != nil {
+		log.Printf("❌ %v", err)
+	}
+	log.Printf("👋 Stopped")
+}
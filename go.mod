@@ -1,4 +1,4 @@
-module blofin-proxy
+module github.com/joshthetrader/blofin-proxy
 
 go 1.21
 
@@ -0,0 +1,43 @@
+// Package cors holds the CORS handling shared by the proxy's endpoints.
+package cors
+
+import "net/http"
+
+// Request headers browsers may send; covers BloFin's auth headers plus the
+// proxy's own extensions
+const ALLOW_HEADERS = "Content-Type, Authorization, ACCESS-KEY, ACCESS-SIGN, ACCESS-TIMESTAMP, ACCESS-NONCE, ACCESS-PASSPHRASE, BROKER-ID, X-Dry-Run, X-Proxy-Token, X-Proxy-Debug, X-Proxy-Timeout-Ms, X-MBX-APIKEY, traceparent, tracestate, b3"
+
+// Response headers scripts may read: the echoed trace context
+const EXPOSE_HEADERS = "traceparent, tracestate, b3, X-Client-Order-Id"
+
+// Middleware sets the CORS headers on every response and answers preflight
+// requests itself
+func Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Set CORS headers
+		origin := r.Header.Get("Origin")
+		// Allow both HTTP and HTTPS localhost for development
+		if origin == "http://localhost:3000" || origin == "https://localhost:3000" || origin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		}
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", ALLOW_HEADERS)
+		w.Header().Set("Access-Control-Expose-Headers", EXPOSE_HEADERS)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		w.Header().Set("Access-Control-Max-Age", "86400")
+
+		// Additional headers to handle referrer policy issues
+		w.Header().Set("Referrer-Policy", "no-referrer-when-downgrade")
+		w.Header().Set("Cross-Origin-Resource-Policy", "cross-origin")
+
+		// Handle preflight requests
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,128 @@
+// Package middleware assembles handler wrappers (CORS, auth, logging,
+// recovery, ...) into ordered chains that can be applied per route.
+package middleware
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// Middleware wraps a handler with extra behaviour
+type Middleware func(http.HandlerFunc) http.HandlerFunc
+
+// A middleware with a name, so chains can be filtered from config
+type Named struct {
+	Name string
+	Wrap Middleware
+}
+
+// Chain is an ordered list of middleware; the first entry runs outermost
+type Chain []Named
+
+func New(items ...Named) Chain {
+	return append(Chain(nil), items...)
+}
+
+// Append returns a new chain with items added innermost, leaving c untouched
+func (c Chain) Append(items ...Named) Chain {
+	out := make(Chain, 0, len(c)+len(items))
+	return append(append(out, c...), items...)
+}
+
+// Only returns the entries whose name is in enabled, keeping their order
+func (c Chain) Only(enabled []string) Chain {
+	keep := map[string]bool{}
+	for _, name := range enabled {
+		keep[name] = true
+	}
+	var out Chain
+	for _, m := range c {
+		if keep[m.Name] {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// Names lists the chain's entries, outermost first
+func (c Chain) Names() []string {
+	names := make([]string, len(c))
+	for i, m := range c {
+		names[i] = m.Name
+	}
+	return names
+}
+
+// Then wraps h in every entry of the chain
+func (c Chain) Then(h http.HandlerFunc) http.HandlerFunc {
+	for i := len(c) - 1; i >= 0; i-- {
+		h = c[i].Wrap(h)
+	}
+	return h
+}
+
+// Recover turns a panicking handler into a 500 instead of a dropped connection
+func Recover(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+				log.Printf("💥 Panic serving %s %s: %v\n%s", r.Method, r.URL.Path, err, debug.Stack())
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+		}()
+		next(w, r)
+	}
+}
+
+// Logger writes one access log line per request
+func Logger(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+		log.Printf("📝 %s %s %d %dB %s", r.Method, r.URL.RequestURI(), rec.status, rec.bytes, time.Since(start).Round(time.Millisecond))
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Flush keeps streaming responses working behind the recorder
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets handlers behind the recorder take over the connection
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := r.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, fmt.Errorf("%T does not support hijacking", r.ResponseWriter)
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController
+func (r *statusRecorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
@@ -0,0 +1,32 @@
+package proxy
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Admin endpoints require "Authorization: Bearer <ADMIN_TOKEN>" and are
+// disabled entirely when no token is configured
+func (s *server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.cfg.AdminToken == "" {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "Admin API is disabled, set ADMIN_TOKEN to enable it"})
+			return
+		}
+		if !s.isAdmin(r) {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Invalid admin token"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Whether the request carries the admin token
+func (s *server) isAdmin(r *http.Request) bool {
+	if s.cfg.AdminToken == "" {
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.AdminToken)) == 1
+}
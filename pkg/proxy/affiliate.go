@@ -0,0 +1,183 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	AFFILIATE_PREFIX            = "/api/v1/affiliate/"
+	DEFAULT_AFFILIATE_CACHE_TTL = 5 * time.Minute
+	AFFILIATE_PAGE_SIZE         = 100
+	MAX_AFFILIATE_PAGES         = 500
+	MAX_AFFILIATE_SNAPSHOTS     = 30 // per tenant, one per day
+	AFFILIATE_DATE              = "2006-01-02"
+)
+
+// A day's copy of a tenant's invitee list
+type affiliateSnapshot struct {
+	TakenAt  string            `json:"takenAt"`
+	Invitees []json.RawMessage `json:"invitees"`
+}
+
+// Daily snapshots of each tenant's affiliate invitees, kept under
+// DATA_DIR/affiliate/<tenant>/ so dashboards read them locally instead of
+// paging through the full list on BloFin every time
+type affiliateStore struct {
+	client  *blofinClient
+	tenants *tenantRegistry
+	dir     string
+}
+
+func newAffiliateStore(client *blofinClient, tenants *tenantRegistry, dataDir string) *affiliateStore {
+	return &affiliateStore{client: client, tenants: tenants, dir: filepath.Join(dataDir, "affiliate")}
+}
+
+func (a *affiliateStore) tenantDir(t *tenant) string {
+	return filepath.Join(a.dir, t.fileName())
+}
+
+// Snapshot every tenant's invitees; used by the snapshot-affiliates job
+func (a *affiliateStore) snapshot(ctx context.Context) error {
+	if len(a.tenants.list) == 0 {
+		return fmt.Errorf("no tenants configured, see TENANTS_FILE")
+	}
+	var failed []string
+	for _, t := range a.tenants.list {
+		n, err := a.snapshotTenant(ctx, t)
+		if err != nil {
+			log.Printf("❌ Affiliate snapshot for %s failed: %v", t.Name, err)
+			failed = append(failed, t.Name)
+			continue
+		}
+		log.Printf("🤝 Saved %d affiliate invitees for %s", n, t.Name)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("snapshot failed for %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func (a *affiliateStore) snapshotTenant(ctx context.Context, t *tenant) (int, error) {
+	var invitees []json.RawMessage
+	after := ""
+	for page := 0; page < MAX_AFFILIATE_PAGES; page++ {
+		query := url.Values{"limit": {fmt.Sprint(AFFILIATE_PAGE_SIZE)}}
+		if after != "" {
+			query.Set("after", after)
+		}
+		var batch []json.RawMessage
+		if err := a.client.do(ctx, http.MethodGet, "/api/v1/affiliate/invitees", query, nil, t, &batch); err != nil {
+			return 0, err
+		}
+		invitees = append(invitees, batch...)
+		if len(batch) < AFFILIATE_PAGE_SIZE {
+			break
+		}
+		var last struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(batch[len(batch)-1], &last) != nil || last.ID == "" {
+			break
+		}
+		after = last.ID
+	}
+	if invitees == nil {
+		invitees = []json.RawMessage{}
+	}
+
+	now := time.Now().UTC()
+	data, err := json.Marshal(affiliateSnapshot{TakenAt: now.Format(time.RFC3339), Invitees: invitees})
+	if err != nil {
+		return 0, err
+	}
+	dir := a.tenantDir(t)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, err
+	}
+	file := filepath.Join(dir, "invitees-"+now.Format(AFFILIATE_DATE)+".json")
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return 0, err
+	}
+	a.prune(t)
+	return len(invitees), nil
+}
+
+// Dates with a snapshot for t, newest first
+func (a *affiliateStore) dates(t *tenant) []string {
+	matches, _ := filepath.Glob(filepath.Join(a.tenantDir(t), "invitees-*.json"))
+	dates := make([]string, 0, len(matches))
+	for _, match := range matches {
+		date := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(match), "invitees-"), ".json")
+		if _, err := time.Parse(AFFILIATE_DATE, date); err == nil {
+			dates = append(dates, date)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dates)))
+	return dates
+}
+
+func (a *affiliateStore) prune(t *tenant) {
+	dates := a.dates(t)
+	for _, date := range dates[min(len(dates), MAX_AFFILIATE_SNAPSHOTS):] {
+		os.Remove(filepath.Join(a.tenantDir(t), "invitees-"+date+".json"))
+	}
+}
+
+// GET /local/affiliate/invitees returns the tenant's latest invitee
+// snapshot, or the one for ?date=2024-06-01, in BloFin's response shape
+func (a *affiliateStore) handleLocal(w http.ResponseWriter, r *http.Request) {
+	t, err := a.tenants.fromRequest(r)
+	if err != nil || t == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Affiliate snapshots need the " + TENANT_HEADER + " of a configured tenant"})
+		return
+	}
+	dates := a.dates(t)
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		if len(dates) == 0 {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "No affiliate snapshot yet, schedule the snapshot-affiliates job"})
+			return
+		}
+		date = dates[0]
+	}
+	if _, err := time.Parse(AFFILIATE_DATE, date); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "date must look like 2024-06-01"})
+		return
+	}
+	data, err := os.ReadFile(filepath.Join(a.tenantDir(t), "invitees-"+date+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "No affiliate snapshot for " + date})
+		return
+	}
+	var snapshot affiliateSnapshot
+	if err == nil {
+		err = json.Unmarshal(data, &snapshot)
+	}
+	if err != nil {
+		log.Printf("❌ Failed to read affiliate snapshot %s for %s: %v", date, t.Name, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to read the snapshot"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"code":      "0",
+		"msg":       "",
+		"data":      snapshot.Invitees,
+		"takenAt":   snapshot.TakenAt,
+		"snapshots": dates,
+	})
+}
@@ -0,0 +1,349 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ALERT_ABOVE = "above"
+	ALERT_BELOW = "below"
+)
+
+// Ticker fields an alert can watch
+var alertFields = map[string]func(ticker) string{
+	"last":     func(t ticker) string { return t.Last },
+	"bidPrice": func(t ticker) string { return t.BidPrice },
+	"askPrice": func(t ticker) string { return t.AskPrice },
+}
+
+// A price condition and where to send the notification when it is met.
+// A rule fires once, then stays quiet until the price crosses back over
+// Rearm (the threshold by default) and at least Cooldown has passed.
+type alertRule struct {
+	ID             string `json:"id"`
+	InstID         string `json:"instId"`
+	Field          string `json:"field"`     // last, bidPrice or askPrice
+	Condition      string `json:"condition"` // above or below
+	Threshold      string `json:"threshold"`
+	Rearm          string `json:"rearm,omitempty"`
+	Cooldown       string `json:"cooldown,omitempty"`
+	Webhook        string `json:"webhook,omitempty"`
+	TelegramChatID string `json:"telegramChatId,omitempty"`
+	Note           string `json:"note,omitempty"`
+	Armed          bool   `json:"armed"`
+	LastFiredAt    string `json:"lastFiredAt,omitempty"`
+	CreatedAt      string `json:"createdAt"`
+
+	threshold *big.Rat
+	rearm     *big.Rat
+	cooldown  time.Duration
+}
+
+// Fill in defaults and parse the numeric fields
+func (a *alertRule) prepare(telegramEnabled bool) error {
+	if a.InstID == "" {
+		return fmt.Errorf("instId is required")
+	}
+	if a.Field == "" {
+		a.Field = "last"
+	}
+	if _, ok := alertFields[a.Field]; !ok {
+		return fmt.Errorf("unknown field %q, use last, bidPrice or askPrice", a.Field)
+	}
+	if a.Condition != ALERT_ABOVE && a.Condition != ALERT_BELOW {
+		return fmt.Errorf("condition must be %s or %s", ALERT_ABOVE, ALERT_BELOW)
+	}
+	var ok bool
+	if a.threshold, ok = parseDecimal(a.Threshold); !ok {
+		return fmt.Errorf("threshold must be a decimal string")
+	}
+	a.rearm = a.threshold
+	if a.Rearm != "" {
+		if a.rearm, ok = parseDecimal(a.Rearm); !ok {
+			return fmt.Errorf("rearm must be a decimal string")
+		}
+		if (a.Condition == ALERT_ABOVE && a.rearm.Cmp(a.threshold) > 0) || (a.Condition == ALERT_BELOW && a.rearm.Cmp(a.threshold) < 0) {
+			return fmt.Errorf("rearm must be on the other side of the threshold")
+		}
+	}
+	a.cooldown = 0
+	if a.Cooldown != "" {
+		d, err := time.ParseDuration(a.Cooldown)
+		if err != nil || d < 0 {
+			return fmt.Errorf("cooldown must be a duration such as 5m")
+		}
+		a.cooldown = d
+	}
+	if a.Webhook == "" && a.TelegramChatID == "" {
+		return fmt.Errorf("a webhook or telegramChatId target is required")
+	}
+	if a.Webhook != "" {
+		u, err := url.Parse(a.Webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook must be an http(s) URL")
+		}
+	}
+	if a.TelegramChatID != "" && !telegramEnabled {
+		return fmt.Errorf("Telegram targets need TELEGRAM_BOT_TOKEN to be set")
+	}
+	return nil
+}
+
+func (a *alertRule) triggered(price *big.Rat) bool {
+	if a.Condition == ALERT_ABOVE {
+		return price.Cmp(a.threshold) >= 0
+	}
+	return price.Cmp(a.threshold) <= 0
+}
+
+// Whether a fired rule's price has moved back far enough to arm it again
+func (a *alertRule) rearmed(price *big.Rat) bool {
+	if a.Condition == ALERT_ABOVE {
+		return price.Cmp(a.rearm) < 0
+	}
+	return price.Cmp(a.rearm) > 0
+}
+
+// Payload POSTed to webhook targets
+type alertEvent struct {
+	ID        string `json:"id"`
+	InstID    string `json:"instId"`
+	Field     string `json:"field"`
+	Condition string `json:"condition"`
+	Threshold string `json:"threshold"`
+	Price     string `json:"price"`
+	Note      string `json:"note,omitempty"`
+	FiredAt   string `json:"firedAt"`
+	Message   string `json:"message"`
+}
+
+// Evaluates alert rules against the ticker feed and delivers notifications.
+// Rules and their armed state are kept in DATA_DIR/alerts.json.
+type alertEngine struct {
+	feed          *tickerFeed
+	file          string
+	telegramToken string
+	client        *http.Client
+
+	subscribe sync.Once
+	mu        sync.Mutex
+	rules     map[string]*alertRule
+}
+
+func newAlertEngine(feed *tickerFeed, dataDir, telegramToken string) (*alertEngine, error) {
+	e := &alertEngine{
+		feed:          feed,
+		file:          filepath.Join(dataDir, "alerts.json"),
+		telegramToken: telegramToken,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		rules:         map[string]*alertRule{},
+	}
+	data, err := os.ReadFile(e.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return e, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rules []*alertRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("%s: %v", e.file, err)
+	}
+	for _, rule := range rules {
+		if err := rule.prepare(telegramToken != ""); err != nil {
+			return nil, fmt.Errorf("alert %s: %v", rule.ID, err)
+		}
+		e.rules[rule.ID] = rule
+	}
+	if len(e.rules) > 0 {
+		e.watch()
+	}
+	return e, nil
+}
+
+// Start receiving tickers; called once there is a rule to evaluate
+func (e *alertEngine) watch() {
+	e.subscribe.Do(func() { e.feed.subscribe(e.evaluate) })
+}
+
+func (e *alertEngine) evaluate(tickers map[string]ticker) {
+	var events []alertEvent
+	var targets []*alertRule
+
+	e.mu.Lock()
+	changed := false
+	now := time.Now().UTC()
+	for _, rule := range e.rules {
+		t, ok := tickers[rule.InstID]
+		if !ok {
+			continue
+		}
+		value := alertFields[rule.Field](t)
+		price, ok := parseDecimal(value)
+		if !ok {
+			continue
+		}
+		if !rule.Armed {
+			if rule.rearmed(price) {
+				rule.Armed, changed = true, true
+			}
+			continue
+		}
+		if !rule.triggered(price) {
+			continue
+		}
+		if last, err := time.Parse(time.RFC3339, rule.LastFiredAt); err == nil && now.Sub(last) < rule.cooldown {
+			continue
+		}
+		rule.Armed, rule.LastFiredAt, changed = false, now.Format(time.RFC3339), true
+		events = append(events, alertEvent{
+			ID:        rule.ID,
+			InstID:    rule.InstID,
+			Field:     rule.Field,
+			Condition: rule.Condition,
+			Threshold: rule.Threshold,
+			Price:     value,
+			Note:      rule.Note,
+			FiredAt:   rule.LastFiredAt,
+			Message:   fmt.Sprintf("%s %s is %s, %s %s", rule.InstID, rule.Field, value, rule.Condition, rule.Threshold),
+		})
+		copied := *rule
+		targets = append(targets, &copied)
+	}
+	if changed {
+		if err := e.saveLocked(); err != nil {
+			log.Printf("❌ Failed to save alerts: %v", err)
+		}
+	}
+	e.mu.Unlock()
+
+	for i, event := range events {
+		log.Printf("🔔 Alert %s: %s", event.ID, event.Message)
+		go e.notify(targets[i], event)
+	}
+}
+
+func (e *alertEngine) notify(rule *alertRule, event alertEvent) {
+	if rule.Webhook != "" {
+		body, _ := json.Marshal(event)
+		if err := e.post(rule.Webhook, "application/json", body); err != nil {
+			log.Printf("❌ Alert %s webhook failed: %v", rule.ID, err)
+		}
+	}
+	if rule.TelegramChatID != "" {
+		form := url.Values{"chat_id": {rule.TelegramChatID}, "text": {"🔔 " + event.Message}}
+		if event.Note != "" {
+			form.Set("text", form.Get("text")+"\n"+event.Note)
+		}
+		target := "https://api.telegram.org/bot" + e.telegramToken + "/sendMessage"
+		if err := e.post(target, "application/x-www-form-urlencoded", []byte(form.Encode())); err != nil {
+			// The error would include the URL, and with it the bot token
+			log.Printf("❌ Alert %s Telegram delivery failed", rule.ID)
+		}
+	}
+}
+
+func (e *alertEngine) post(target, contentType string, body []byte) error {
+	resp, err := e.client.Post(target, contentType, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (e *alertEngine) saveLocked() error {
+	rules := make([]*alertRule, 0, len(e.rules))
+	for _, rule := range e.rules {
+		rules = append(rules, rule)
+	}
+	data, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(e.file), 0o755); err != nil {
+		return err
+	}
+	tmp := e.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, e.file)
+}
+
+// GET/POST /admin/alerts lists or creates rules; GET/DELETE
+// /admin/alerts/{id} reads or removes one
+func (e *alertEngine) handleAdmin(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/alerts"), "/")
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if id == "" {
+		switch r.Method {
+		case http.MethodGet:
+			rules := make([]alertRule, 0, len(e.rules))
+			for _, rule := range e.rules {
+				rules = append(rules, *rule)
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"alerts": rules})
+		case http.MethodPost:
+			var rule alertRule
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MAX_INSPECT_BODY)).Decode(&rule); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON: " + err.Error()})
+				return
+			}
+			if err := rule.prepare(e.telegramToken != ""); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			rule.ID = newJobID()
+			rule.Armed = true
+			rule.LastFiredAt = ""
+			rule.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+			e.rules[rule.ID] = &rule
+			if err := e.saveLocked(); err != nil {
+				log.Printf("❌ Failed to save alerts: %v", err)
+			}
+			e.watch()
+			log.Printf("🔔 Alert %s added: %s %s %s %s", rule.ID, rule.InstID, rule.Field, rule.Condition, rule.Threshold)
+			writeJSON(w, http.StatusCreated, rule)
+		default:
+			methodNotAllowed(w, "GET", "POST")
+		}
+		return
+	}
+
+	rule, ok := e.rules[id]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Unknown alert"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, rule)
+	case http.MethodDelete:
+		delete(e.rules, id)
+		if err := e.saveLocked(); err != nil {
+			log.Printf("❌ Failed to save alerts: %v", err)
+		}
+		log.Printf("🔔 Alert %s removed", id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, "GET", "DELETE")
+	}
+}
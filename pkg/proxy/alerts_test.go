@@ -0,0 +1,155 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A feed that never polls; tests hand tickers to evaluate themselves
+func idleTickerFeed() *tickerFeed {
+	f := newTickerFeed(nil, time.Hour)
+	f.once.Do(func() {})
+	return f
+}
+
+func TestAlertRulePrepare(t *testing.T) {
+	tests := map[string]string{
+		`{"instId":"BTC-USDT","condition":"above","threshold":"100","webhook":"https://example.com/hook"}`:                   "",
+		`{"condition":"above","threshold":"100","webhook":"https://example.com/hook"}`:                                       "instId is required",
+		`{"instId":"BTC-USDT","field":"mark","condition":"above","threshold":"100","webhook":"https://example.com/hook"}`:    "unknown field",
+		`{"instId":"BTC-USDT","condition":"over","threshold":"100","webhook":"https://example.com/hook"}`:                    "condition must be",
+		`{"instId":"BTC-USDT","condition":"above","threshold":"lots","webhook":"https://example.com/hook"}`:                  "threshold must be",
+		`{"instId":"BTC-USDT","condition":"above","threshold":"100","rearm":"105","webhook":"https://example.com/hook"}`:     "other side of the threshold",
+		`{"instId":"BTC-USDT","condition":"below","threshold":"100","rearm":"95","webhook":"https://example.com/hook"}`:      "other side of the threshold",
+		`{"instId":"BTC-USDT","condition":"above","threshold":"100","cooldown":"soon","webhook":"https://example.com/hook"}`: "cooldown must be",
+		`{"instId":"BTC-USDT","condition":"above","threshold":"100"}`:                                                        "target is required",
+		`{"instId":"BTC-USDT","condition":"above","threshold":"100","webhook":"ftp://example.com"}`:                          "http(s) URL",
+		`{"instId":"BTC-USDT","condition":"above","threshold":"100","telegramChatId":"42"}`:                                  "TELEGRAM_BOT_TOKEN",
+	}
+	for body, want := range tests {
+		var rule alertRule
+		if err := json.Unmarshal([]byte(body), &rule); err != nil {
+			t.Fatal(err)
+		}
+		err := rule.prepare(false)
+		if want == "" && err != nil || want != "" && (err == nil || !strings.Contains(err.Error(), want)) {
+			t.Errorf("prepare(%s) = %v, want %q", body, err, want)
+		}
+	}
+}
+
+func TestAlertEngine(t *testing.T) {
+	fired := make(chan alertEvent, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event alertEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		fired <- event
+	}))
+	defer webhook.Close()
+
+	dir := t.TempDir()
+	newEngine := func() *alertEngine {
+		t.Helper()
+		e, err := newAlertEngine(idleTickerFeed(), dir, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+	e := newEngine()
+	call := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		e.handleAdmin(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+	rec := call(http.MethodPost, "/admin/alerts", `{"instId":"BTC-USDT","condition":"above","threshold":"100","rearm":"95","webhook":"`+webhook.URL+`","note":"breakout"}`)
+	var rule alertRule
+	json.Unmarshal(rec.Body.Bytes(), &rule)
+	if rec.Code != http.StatusCreated || rule.ID == "" || !rule.Armed {
+		t.Fatalf("create = %d %s", rec.Code, rec.Body)
+	}
+	if rec := call(http.MethodPost, "/admin/alerts", `{"instId":"BTC-USDT"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid rule = %d, want 400", rec.Code)
+	}
+
+	price := func(last string) {
+		e.evaluate(map[string]ticker{"BTC-USDT": {InstID: "BTC-USDT", Last: last}, "ETH-USDT": {InstID: "ETH-USDT", Last: "1"}})
+	}
+	expectFired := func(want string) {
+		t.Helper()
+		select {
+		case event := <-fired:
+			if want == "" {
+				t.Errorf("unexpected alert %+v", event)
+			} else if event.Price != want || event.ID != rule.ID || event.Note != "breakout" || event.Message != "BTC-USDT last is "+want+", above 100" {
+				t.Errorf("alert = %+v, want price %s", event, want)
+			}
+		case <-time.After(100 * time.Millisecond):
+			if want != "" {
+				t.Errorf("no alert at %s", want)
+			}
+		}
+	}
+
+	price("99.9")
+	expectFired("")
+	price("100")
+	expectFired("100")
+	// Fired rules stay quiet until the price falls back below rearm
+	price("101")
+	price("96")
+	price("102")
+	expectFired("")
+	price("94")
+	price("101.5")
+	expectFired("101.5")
+
+	// Armed state survives a restart
+	price("90")
+	e = newEngine()
+	if rec := call(http.MethodGet, "/admin/alerts/"+rule.ID, ""); !strings.Contains(rec.Body.String(), `"armed":true`) {
+		t.Errorf("reloaded rule = %s", rec.Body)
+	}
+	price("100.5")
+	expectFired("100.5")
+
+	if rec := call(http.MethodDelete, "/admin/alerts/"+rule.ID, ""); rec.Code != http.StatusNoContent {
+		t.Errorf("delete = %d", rec.Code)
+	}
+	if rec := call(http.MethodGet, "/admin/alerts/"+rule.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("deleted rule = %d", rec.Code)
+	}
+	if rec := call(http.MethodPut, "/admin/alerts", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT = %d, want 405", rec.Code)
+	}
+}
+
+func TestAlertCooldown(t *testing.T) {
+	fired := make(chan struct{}, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { fired <- struct{}{} }))
+	defer webhook.Close()
+	e, err := newAlertEngine(idleTickerFeed(), t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	e.handleAdmin(rec, httptest.NewRequest(http.MethodPost, "/admin/alerts", strings.NewReader(`{"instId":"BTC-USDT","field":"bidPrice","condition":"below","threshold":"50","cooldown":"1h","webhook":"`+webhook.URL+`"}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create = %d %s", rec.Code, rec.Body)
+	}
+
+	for _, bid := range []string{"49", "51", "48"} {
+		e.evaluate(map[string]ticker{"BTC-USDT": {InstID: "BTC-USDT", BidPrice: bid, Last: "1000"}})
+	}
+	<-fired
+	select {
+	case <-fired:
+		t.Error("fired again within the cooldown")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
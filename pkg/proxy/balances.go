@@ -0,0 +1,215 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_BALANCE_POLL_INTERVAL = 30 * time.Second
+	DEFAULT_BALANCE_THRESHOLD     = "1"
+)
+
+// Accounts whose balances are watched
+const (
+	ACCOUNT_FUTURES = "futures"
+	ACCOUNT_FUNDING = "funding"
+)
+
+// Payload POSTed to the balance webhook
+type balanceEvent struct {
+	Tenant   string `json:"tenant"`
+	Account  string `json:"account"` // futures or funding
+	Currency string `json:"currency"`
+	Previous string `json:"previous"`
+	Current  string `json:"current"`
+	Change   string `json:"change"`
+	Ts       int64  `json:"ts"`
+}
+
+// Polls every tenant's futures and funding balances and POSTs an event to
+// the webhook whenever a currency's balance has moved by at least the
+// threshold since the last event. Balances are compared with the last
+// reported value, so slow drift (fees, funding) is reported once it adds
+// up. The last reported values are kept in DATA_DIR/balances.json so
+// changes made while the proxy was down are still caught.
+type balanceWatcher struct {
+	client    *blofinClient
+	tenants   []*tenant
+	webhook   string
+	interval  time.Duration
+	threshold *big.Rat
+	http      *http.Client
+	file      string
+
+	mu   sync.Mutex
+	last map[string]string // tenant/account/currency -> balance
+}
+
+func newBalanceWatcher(client *blofinClient, tenants []*tenant, webhook string, interval time.Duration, threshold, dataDir string) (*balanceWatcher, error) {
+	limit, ok := new(big.Rat).SetString(threshold)
+	if !ok || limit.Sign() < 0 {
+		return nil, fmt.Errorf("threshold %q must be a non-negative number", threshold)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("poll interval must be positive")
+	}
+	w := &balanceWatcher{
+		client:    client,
+		tenants:   tenants,
+		webhook:   webhook,
+		interval:  interval,
+		threshold: limit,
+		http:      &http.Client{Timeout: 10 * time.Second},
+		file:      filepath.Join(dataDir, "balances.json"),
+		last:      map[string]string{},
+	}
+	data, err := os.ReadFile(w.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return w, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &w.last); err != nil {
+		return nil, fmt.Errorf("%s: %v", w.file, err)
+	}
+	return w, nil
+}
+
+func (w *balanceWatcher) start() {
+	log.Printf("💰 Watching balances of %d tenant(s) every %s", len(w.tenants), w.interval)
+	go func() {
+		for {
+			for _, t := range w.tenants {
+				if err := w.poll(t); err != nil {
+					log.Printf("❌ Balance poll for %s failed: %v", t.Name, err)
+				}
+			}
+			time.Sleep(w.interval)
+		}
+	}()
+}
+
+// Balances by account and currency for one tenant
+func (w *balanceWatcher) fetch(t *tenant) (map[string]map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	var futures struct {
+		Details []struct {
+			Currency string `json:"currency"`
+			Balance  string `json:"balance"`
+		} `json:"details"`
+	}
+	if err := w.client.do(ctx, http.MethodGet, "/api/v1/account/balance", nil, nil, t, &futures); err != nil {
+		return nil, err
+	}
+	var funding []struct {
+		Currency string `json:"currency"`
+		Balance  string `json:"balance"`
+	}
+	query := url.Values{"accountType": {ACCOUNT_FUNDING}}
+	if err := w.client.do(ctx, http.MethodGet, "/api/v1/asset/balances", query, nil, t, &funding); err != nil {
+		return nil, err
+	}
+	balances := map[string]map[string]string{ACCOUNT_FUTURES: {}, ACCOUNT_FUNDING: {}}
+	for _, d := range futures.Details {
+		balances[ACCOUNT_FUTURES][d.Currency] = d.Balance
+	}
+	for _, d := range funding {
+		balances[ACCOUNT_FUNDING][d.Currency] = d.Balance
+	}
+	return balances, nil
+}
+
+func (w *balanceWatcher) poll(t *tenant) error {
+	balances, err := w.fetch(t)
+	if err != nil {
+		return err
+	}
+	var events []balanceEvent
+	w.mu.Lock()
+	changed := false
+	for account, currencies := range balances {
+		for currency, value := range currencies {
+			key := t.Name + "/" + account + "/" + currency
+			current, ok := new(big.Rat).SetString(value)
+			if !ok {
+				continue
+			}
+			previous, seen := w.last[key]
+			if !seen {
+				// First sighting is the baseline
+				w.last[key], changed = value, true
+				continue
+			}
+			prev, ok := new(big.Rat).SetString(previous)
+			if !ok {
+				prev = new(big.Rat)
+			}
+			diff := new(big.Rat).Sub(current, prev)
+			if diff.Sign() == 0 || new(big.Rat).Abs(diff).Cmp(w.threshold) < 0 {
+				continue
+			}
+			w.last[key], changed = value, true
+			events = append(events, balanceEvent{
+				Tenant:   t.Name,
+				Account:  account,
+				Currency: currency,
+				Previous: previous,
+				Current:  value,
+				Change:   diff.FloatString(8),
+				Ts:       time.Now().UnixMilli(),
+			})
+		}
+	}
+	if changed {
+		w.saveLocked()
+	}
+	w.mu.Unlock()
+
+	for _, event := range events {
+		log.Printf("💰 %s %s %s balance %s -> %s", event.Tenant, event.Account, event.Currency, event.Previous, event.Current)
+		w.notify(event)
+	}
+	return nil
+}
+
+func (w *balanceWatcher) notify(event balanceEvent) {
+	body, _ := json.Marshal(event)
+	resp, err := w.http.Post(w.webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("❌ Balance webhook failed: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("❌ Balance webhook failed: HTTP %d", resp.StatusCode)
+	}
+}
+
+func (w *balanceWatcher) saveLocked() {
+	data, err := json.MarshalIndent(w.last, "", "  ")
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(w.file), 0o755); err == nil {
+			tmp := w.file + ".tmp"
+			if err = os.WriteFile(tmp, data, 0o600); err == nil {
+				err = os.Rename(tmp, w.file)
+			}
+		}
+	}
+	if err != nil {
+		log.Printf("❌ Failed to save balances: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBalanceWatcher(t *testing.T) {
+	var mu sync.Mutex
+	futures, funding := "100", "0.5"
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/api/v1/account/balance":
+			fmt.Fprintf(w, `{"code":"0","data":{"details":[{"currency":"USDT","balance":%q}]}}`, futures)
+		case "/api/v1/asset/balances":
+			fmt.Fprintf(w, `{"code":"0","data":[{"currency":"BTC","balance":%q},{"currency":"ETH","balance":"n/a"}]}`, funding)
+		default:
+			w.Write([]byte(`{"code":"0","data":[]}`))
+		}
+	})
+	set := func(f, b string) {
+		mu.Lock()
+		defer mu.Unlock()
+		futures, funding = f, b
+	}
+	p := newUpstreamProxy(t, upstream, `[{"name":"alice","token":"tok-a","apiKey":"k","secret":"s","passphrase":"p"}]`)
+	alice := p.srv.tenants.list[0]
+
+	var events []balanceEvent
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event balanceEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	}))
+	defer webhook.Close()
+	received := func() []balanceEvent {
+		mu.Lock()
+		defer mu.Unlock()
+		got := events
+		events = nil
+		return got
+	}
+
+	dir := t.TempDir()
+	w, err := newBalanceWatcher(p.srv.blofin, p.srv.tenants.list, webhook.URL, time.Minute, "1", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	poll := func() {
+		t.Helper()
+		if err := w.poll(alice); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first poll is the baseline
+	poll()
+	if got := received(); len(got) != 0 {
+		t.Fatalf("baseline events = %+v", got)
+	}
+	// Moves below the threshold add up until they reach it
+	set("100.6", "0.5")
+	poll()
+	if got := received(); len(got) != 0 {
+		t.Fatalf("events below the threshold = %+v", got)
+	}
+	set("101.2", "0.5")
+	poll()
+	if got := received(); len(got) != 1 || got[0] != (balanceEvent{Tenant: "alice", Account: ACCOUNT_FUTURES, Currency: "USDT", Previous: "100", Current: "101.2", Change: "1.20000000", Ts: got[0].Ts}) {
+		t.Fatalf("events = %+v", got)
+	}
+
+	// Reported values survive a restart, so a withdrawal made while the
+	// proxy was down is still caught
+	set("101.2", "-1.5")
+	w, err = newBalanceWatcher(p.srv.blofin, p.srv.tenants.list, webhook.URL, time.Minute, "1", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	poll()
+	if got := received(); len(got) != 1 || got[0].Account != ACCOUNT_FUNDING || got[0].Previous != "0.5" || got[0].Change != "-2.00000000" {
+		t.Fatalf("events after a restart = %+v", got)
+	}
+}
+
+func TestBalanceWatcherSettings(t *testing.T) {
+	for _, tt := range []struct {
+		threshold string
+		interval  time.Duration
+	}{{"-1", time.Minute}, {"lots", time.Minute}, {"1", 0}} {
+		if _, err := newBalanceWatcher(nil, nil, "", tt.interval, tt.threshold, t.TempDir()); err == nil {
+			t.Errorf("threshold %q and interval %v accepted", tt.threshold, tt.interval)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// GET /admin/bans lists active bans; DELETE /admin/bans/{ip} lifts one
+func (s *server) handleBans(w http.ResponseWriter, r *http.Request) {
+	bans := s.limiter.Bans()
+	ip := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/bans"), "/")
+	switch {
+	case ip == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"bans": bans.List(time.Now())})
+	case ip != "" && r.Method == http.MethodDelete:
+		if !bans.Lift(ip) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "No ban for " + ip})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"ip": ip, "status": "lifted"})
+	case ip == "":
+		methodNotAllowed(w, "GET")
+	default:
+		methodNotAllowed(w, "DELETE")
+	}
+}
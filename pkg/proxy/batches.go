@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Most orders BloFin takes in one batch call
+const MAX_BATCH_ORDERS = 20
+
+const (
+	DEFAULT_BATCH_PACING = 200 * time.Millisecond
+	RATE_LIMIT_RETRIES   = 3 // of a call BloFin answered with 429
+)
+
+// Batch routes whose bodies the proxy splits into BloFin-sized chunks
+var batchRoutes = map[string]bool{
+	"/api/v1/trade/batch-orders":        true,
+	"/api/v1/trade/cancel-batch-orders": true,
+}
+
+// Send a tenant's batch of more than MAX_BATCH_ORDERS orders in chunks,
+// BatchPacing apart, and answer with one result per order, in order.
+// False when body isn't such a batch and should be forwarded as it is.
+// A chunk that fails outright stops the rest, whose orders are reported
+// as not sent.
+func (s *server) sendChunked(w http.ResponseWriter, r *http.Request, t *tenant, body []byte) bool {
+	var orders []json.RawMessage
+	if err := json.Unmarshal(body, &orders); err != nil || len(orders) <= MAX_BATCH_ORDERS {
+		return false
+	}
+	log.Printf("📦 Splitting %d orders for %s into chunks of %d: %s", len(orders), t.Name, MAX_BATCH_ORDERS, r.URL.Path)
+
+	results := make([]blofinOrderResult, 0, len(orders))
+	var failure error
+	for start := 0; start < len(orders); start += MAX_BATCH_ORDERS {
+		chunk := orders[start:min(start+MAX_BATCH_ORDERS, len(orders))]
+		if failure != nil {
+			for range chunk {
+				results = append(results, blofinOrderResult{Code: "-1", Msg: "Not sent: an earlier chunk failed"})
+			}
+			continue
+		}
+		delay := time.Duration(0)
+		if start > 0 {
+			delay = s.cfg.BatchPacing
+		}
+		placed, err := s.sendChunk(r.Context(), r.URL.Path, chunk, t, delay)
+		if err != nil {
+			failure = err
+			log.Printf("❌ Chunk of %d orders for %s failed: %v", len(chunk), t.Name, err)
+			for range chunk {
+				results = append(results, blofinOrderResult{Code: "-1", Msg: "Chunk failed: " + err.Error()})
+			}
+			continue
+		}
+		results = append(results, placed...)
+	}
+	if clientGone(r) {
+		return true
+	}
+
+	code, msg := "0", "success"
+	for _, result := range results {
+		if result.Code != "" && result.Code != "0" {
+			code, msg = "1", "Some orders failed, see data"
+			break
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"code": code, "msg": msg, "data": results})
+	return true
+}
+
+// Send one chunk after delay
+func (s *server) sendChunk(ctx context.Context, path string, chunk []json.RawMessage, t *tenant, delay time.Duration) ([]blofinOrderResult, error) {
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	var placed []blofinOrderResult
+	if err := s.doPaced(ctx, http.MethodPost, path, nil, chunk, t, &placed); err != nil {
+		return nil, err
+	}
+	if len(placed) != len(chunk) {
+		return nil, fmt.Errorf("BloFin answered %d results for %d orders", len(placed), len(chunk))
+	}
+	return placed, nil
+}
+
+// blofin.do, backing off and retrying while BloFin answers 429
+func (s *server) doPaced(ctx context.Context, method, path string, query url.Values, payload interface{}, t *tenant, out interface{}) error {
+	for attempt := 0; ; attempt++ {
+		err := s.blofin.do(ctx, method, path, query, payload, t, out)
+		if !isRateLimited(err) || attempt == RATE_LIMIT_RETRIES {
+			return err
+		}
+		select {
+		case <-time.After(time.Second << attempt):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// A proxy in front of upstream instead of BloFin, serving tenants (a JSON
+// array, or empty for none), with configure's changes to the config
+func newUpstreamProxy(t *testing.T, upstream http.Handler, tenants string, configure ...func(*Config)) *Proxy {
+	t.Helper()
+	server := httptest.NewServer(upstream)
+	t.Cleanup(server.Close)
+	dir, err := os.MkdirTemp("", "blofin-proxy-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	cfg := DefaultConfig()
+	cfg.Mode = MODE_PROXY
+	cfg.DataDir = dir
+	cfg.UpstreamHosts = []string{server.URL}
+	if tenants != "" {
+		cfg.TenantsFile = filepath.Join(dir, "tenants.json")
+		if err := os.WriteFile(cfg.TenantsFile, []byte(tenants), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, change := range configure {
+		change(&cfg)
+	}
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestSendChunked(t *testing.T) {
+	var mu sync.Mutex
+	var chunks []int
+	failChunk := 0 // 1-based chunk BloFin fails, 0 for none
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/trade/batch-orders" {
+			io.WriteString(w, `{"code":"0","msg":"","data":[]}`)
+			return
+		}
+		var orders []map[string]string
+		json.NewDecoder(r.Body).Decode(&orders)
+		mu.Lock()
+		chunks = append(chunks, len(orders))
+		fail := len(chunks) == failChunk
+		mu.Unlock()
+		if fail {
+			http.Error(w, `{"code":"500","msg":"internal error"}`, http.StatusInternalServerError)
+			return
+		}
+		data := []map[string]string{}
+		for _, order := range orders {
+			data = append(data, map[string]string{"orderId": "o-" + order["clientOrderId"], "clientOrderId": order["clientOrderId"], "code": "0", "msg": ""})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": "0", "msg": "", "data": data})
+	})
+	p := newUpstreamProxy(t, upstream, `[{"name":"alice","token":"tok-a","apiKey":"k","secret":"s","passphrase":"p"}]`)
+	p.srv.cfg.BatchPacing = 0
+
+	send := func(n int, run string) (int, map[string]interface{}) {
+		t.Helper()
+		orders := make([]string, n)
+		for i := range orders {
+			orders[i] = fmt.Sprintf(`{"instId":"BTC-USDT","marginMode":"cross","side":"buy","orderType":"market","size":"1","clientOrderId":"%s%d"}`, run, i)
+		}
+		mu.Lock()
+		chunks = nil
+		mu.Unlock()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/trade/batch-orders", strings.NewReader("["+strings.Join(orders, ",")+"]"))
+		req.Header.Set(TENANT_HEADER, "tok-a")
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+		var resp map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+	sent := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return fmt.Sprint(chunks)
+	}
+
+	// Up to MAX_BATCH_ORDERS go out as they are, more are split
+	for n, want := range map[int]string{MAX_BATCH_ORDERS: "[20]", MAX_BATCH_ORDERS + 1: "[20 1]", 2 * MAX_BATCH_ORDERS: "[20 20]", 45: "[20 20 5]"} {
+		code, resp := send(n, fmt.Sprintf("n%d-", n))
+		if got := sent(); code != http.StatusOK || got != want || len(resp["data"].([]interface{})) != n {
+			t.Errorf("%d orders: %d, sent in chunks %s, want %s", n, code, got, want)
+		}
+		if data := resp["data"].([]interface{}); data[n-1].(map[string]interface{})["clientOrderId"] != fmt.Sprintf("n%d-%d", n, n-1) {
+			t.Errorf("%d orders: results out of order: %v", n, data[n-1])
+		}
+	}
+
+	// The second chunk fails: its orders are reported failed and the third
+	// chunk isn't sent
+	failChunk = 2
+	code, resp := send(45, "f")
+	if got := sent(); code != http.StatusOK || got != "[20 20]" || resp["code"] != "1" {
+		t.Fatalf("failed chunk: %d %v, sent %s", code, resp["code"], got)
+	}
+	data := resp["data"].([]interface{})
+	for i, want := range map[int]string{0: "", 19: "", 20: "Chunk failed: ", 39: "Chunk failed: ", 40: "Not sent: ", 44: "Not sent: "} {
+		result := data[i].(map[string]interface{})
+		msg, _ := result["msg"].(string)
+		if want == "" && (result["code"] != "0" || result["orderId"] != fmt.Sprintf("o-f%d", i)) || want != "" && (result["code"] != "-1" || !strings.HasPrefix(msg, want)) {
+			t.Errorf("result %d = %v, want %q", i, result, want)
+		}
+	}
+}
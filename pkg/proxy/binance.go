@@ -0,0 +1,758 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Quote currencies recognised when splitting Binance symbols like BTCUSDT
+var binanceQuotes = []string{"USDT", "USDC", "USD"}
+
+// Binance-style error: {"code":-1121,"msg":"Invalid symbol."}
+type binanceError struct {
+	Status int
+	Code   int
+	Msg    string
+}
+
+func (e *binanceError) Error() string { return e.Msg }
+
+func binanceBadRequest(code int, format string, args ...interface{}) error {
+	return &binanceError{Status: http.StatusBadRequest, Code: code, Msg: fmt.Sprintf(format, args...)}
+}
+
+// BTCUSDT -> BTC-USDT
+func binanceInstID(symbol string) (string, error) {
+	symbol = strings.ToUpper(symbol)
+	for _, quote := range binanceQuotes {
+		if base, ok := strings.CutSuffix(symbol, quote); ok && base != "" {
+			return base + "-" + quote, nil
+		}
+	}
+	return "", binanceBadRequest(-1121, "Invalid symbol.")
+}
+
+// BTC-USDT -> BTCUSDT
+func binanceSymbol(instID string) string {
+	return strings.ReplaceAll(instID, "-", "")
+}
+
+// Binance quantities are in base currency, BloFin sizes in contracts
+func binanceContracts(qty string, inst instrument) (string, error) {
+	amount, ok := parseDecimal(qty)
+	if !ok || amount.Sign() <= 0 {
+		return "", binanceBadRequest(-1102, "Mandatory parameter 'quantity' was not sent, was empty/null, or malformed.")
+	}
+	contractValue, ok := parseDecimal(inst.ContractValue)
+	if !ok || contractValue.Sign() == 0 {
+		return qty, nil
+	}
+	contracts := new(big.Rat).Quo(amount, contractValue)
+	if lot, ok := parseDecimal(inst.LotSize); ok && !isMultiple(contracts, lot) {
+		return "", binanceBadRequest(-1111, "Quantity %s is not a multiple of %s %s (lot size %s x contract value %s).",
+			qty, decimalString(new(big.Rat).Mul(lot, contractValue)), inst.BaseCurrency, inst.LotSize, inst.ContractValue)
+	}
+	return decimalString(contracts), nil
+}
+
+func binanceQty(contracts string, inst instrument) string {
+	size, ok := parseDecimal(contracts)
+	if !ok {
+		return "0"
+	}
+	if contractValue, ok := parseDecimal(inst.ContractValue); ok {
+		size.Mul(size, contractValue)
+	}
+	return decimalString(size)
+}
+
+// Shortest plain decimal representation of r
+func decimalString(r *big.Rat) string {
+	s := r.FloatString(12)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// Binance answers with bare integers for ids and times
+func binanceInt(value string) int64 {
+	n, _ := strconv.ParseInt(value, 10, 64)
+	return n
+}
+
+type binanceEndpoint struct {
+	private bool
+	call    func(s *server, ctx context.Context, params url.Values, t *tenant) (interface{}, error)
+	summary string // for the OpenAPI document
+}
+
+var binanceEndpoints = map[string]binanceEndpoint{
+	"GET /fapi/v1/ping":              {false, (*server).binancePing, "Connectivity check"},
+	"GET /fapi/v1/time":              {false, (*server).binanceTime, "Server time"},
+	"GET /fapi/v1/exchangeInfo":      {false, (*server).binanceExchangeInfo, "Symbols and their filters, from BloFin's instruments"},
+	"GET /fapi/v1/ticker/price":      {false, (*server).binanceTickerPrice, "Last price for symbol, or all symbols"},
+	"GET /fapi/v1/ticker/24hr":       {false, (*server).binanceTicker24h, "24 hour statistics for symbol, or all symbols"},
+	"GET /fapi/v1/ticker/bookTicker": {false, (*server).binanceBookTicker, "Best bid and ask for symbol, or all symbols"},
+	"GET /fapi/v1/depth":             {false, (*server).binanceDepth, "Order book for symbol"},
+	"GET /fapi/v1/klines":            {false, (*server).binanceKlines, "Candles for symbol and interval"},
+	"GET /fapi/v1/premiumIndex":      {false, (*server).binancePremiumIndex, "Mark price and funding rate for symbol, or all symbols"},
+	"GET /fapi/v2/balance":           {true, (*server).binanceBalance, "The tenant's balances (tenant token as X-MBX-APIKEY)"},
+	"GET /fapi/v2/positionRisk":      {true, (*server).binancePositionRisk, "The tenant's positions (tenant token as X-MBX-APIKEY)"},
+	"GET /fapi/v1/openOrders":        {true, (*server).binanceOpenOrders, "The tenant's open orders (tenant token as X-MBX-APIKEY)"},
+	"POST /fapi/v1/order":            {true, (*server).binanceNewOrder, "Place an order (tenant token as X-MBX-APIKEY)"},
+	"DELETE /fapi/v1/order":          {true, (*server).binanceCancelOrder, "Cancel an order by orderId or origClientOrderId (tenant token as X-MBX-APIKEY)"},
+	"POST /fapi/v1/leverage":         {true, (*server).binanceLeverage, "Set a symbol's leverage (tenant token as X-MBX-APIKEY)"},
+}
+
+// /binance/fapi/... accepts a subset of the Binance USDⓈ-M Futures API and
+// translates it to BloFin. Bots authenticate by sending a tenant token as
+// their API key (X-MBX-APIKEY); Binance signatures are ignored because the
+// proxy signs for the tenant itself.
+func (s *server) handleBinance(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/binance")
+	endpoint, ok := binanceEndpoints[r.Method+" "+path]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"code": -5000, "msg": "Path " + r.Method + " " + path + " is not supported by the BloFin proxy."})
+		return
+	}
+
+	token := r.Header.Get(TENANT_HEADER)
+	if token == "" {
+		token = r.Header.Get("X-MBX-APIKEY")
+	}
+	t, err := s.tenants.lookup(token)
+	if endpoint.private && (err != nil || (t == nil && s.mock == nil)) {
+		writeJSON(w, http.StatusUnauthorized, map[string]interface{}{"code": -2015, "msg": "Invalid API-key: send a proxy tenant token as X-MBX-APIKEY."})
+		return
+	}
+	if err := t.allows(r.Method, r.URL.Path); err != nil {
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{"code": -2015, "msg": "Invalid API-key, IP, or permissions for action: " + err.Error()})
+		return
+	}
+	if !s.quotas.spend(t) {
+		quotaExceeded(w, t)
+		return
+	}
+	r = r.WithContext(withQuotaSpent(r.Context()))
+
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"code": -1100, "msg": "Malformed parameters: " + err.Error()})
+		return
+	}
+	result, err := endpoint.call(s, r.Context(), r.Form, t)
+	if err != nil {
+		if clientGone(r) {
+			log.Printf("🔌 Client went away before BloFin answered: binance %s", path)
+			return
+		}
+		switch e := err.(type) {
+		case *binanceError:
+			writeJSON(w, e.Status, map[string]interface{}{"code": e.Code, "msg": e.Msg})
+		case *orderRefusal:
+			code := -2010
+			if e.status == http.StatusTooManyRequests {
+				code = -1015
+			}
+			e.setRetryAfter(w)
+			writeJSON(w, e.status, map[string]interface{}{"code": code, "msg": e.msg})
+		case *blofinError:
+			status, code := http.StatusBadGateway, -1000
+			switch {
+			case isRateLimited(e):
+				status, code = http.StatusTooManyRequests, -1003
+			case e.Code != "":
+				status = http.StatusBadRequest
+			}
+			writeJSON(w, status, map[string]interface{}{"code": code, "msg": e.Error()})
+		default:
+			log.Printf("❌ Binance %s failed: %v", path, err)
+			writeJSON(w, http.StatusBadGateway, map[string]interface{}{"code": -1000, "msg": "BloFin request failed"})
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *server) binancePing(context.Context, url.Values, *tenant) (interface{}, error) {
+	return map[string]interface{}{}, nil
+}
+
+func (s *server) binanceTime(context.Context, url.Values, *tenant) (interface{}, error) {
+	return map[string]int64{"serverTime": time.Now().UnixMilli()}, nil
+}
+
+// Instrument named by the symbol parameter
+func (s *server) binanceInstrument(params url.Values) (instrument, error) {
+	if params.Get("symbol") == "" {
+		return instrument{}, binanceBadRequest(-1102, "Mandatory parameter 'symbol' was not sent, was empty/null, or malformed.")
+	}
+	instID, err := binanceInstID(params.Get("symbol"))
+	if err != nil {
+		return instrument{}, err
+	}
+	inst, ok, err := s.instruments.get(instID)
+	if err != nil {
+		return instrument{}, err
+	}
+	if !ok {
+		return instrument{}, binanceBadRequest(-1121, "Invalid symbol.")
+	}
+	return inst, nil
+}
+
+func (s *server) binanceExchangeInfo(ctx context.Context, _ url.Values, _ *tenant) (interface{}, error) {
+	var list []instrument
+	if err := s.blofin.get(ctx, "/api/v1/market/instruments", nil, &list); err != nil {
+		return nil, err
+	}
+	symbols := []map[string]interface{}{}
+	for _, inst := range list {
+		status := "TRADING"
+		if inst.State != "live" {
+			status = "BREAK"
+		}
+		step := binanceQty(inst.LotSize, inst)
+		symbols = append(symbols, map[string]interface{}{
+			"symbol":       binanceSymbol(inst.InstID),
+			"pair":         binanceSymbol(inst.InstID),
+			"contractType": "PERPETUAL",
+			"status":       status,
+			"baseAsset":    inst.BaseCurrency,
+			"quoteAsset":   inst.QuoteCurrency,
+			"marginAsset":  inst.QuoteCurrency,
+			"onboardDate":  binanceInt(inst.ListTime),
+			"filters": []map[string]interface{}{
+				{"filterType": "PRICE_FILTER", "tickSize": inst.TickSize, "minPrice": inst.TickSize, "maxPrice": "0"},
+				{"filterType": "LOT_SIZE", "stepSize": step, "minQty": binanceQty(inst.MinSize, inst), "maxQty": binanceQty(inst.MaxLimitSize, inst)},
+				{"filterType": "MARKET_LOT_SIZE", "stepSize": step, "minQty": binanceQty(inst.MinSize, inst), "maxQty": binanceQty(inst.MaxMarketSize, inst)},
+			},
+			"orderTypes":  []string{"LIMIT", "MARKET"},
+			"timeInForce": []string{"GTC", "IOC", "FOK", "GTX"},
+		})
+	}
+	return map[string]interface{}{
+		"timezone":   "UTC",
+		"serverTime": time.Now().UnixMilli(),
+		"symbols":    symbols,
+	}, nil
+}
+
+// Tickers for the symbol parameter, or all of them; the bool reports
+// whether a single object (rather than a list) should be returned
+func (s *server) binanceTickers(ctx context.Context, params url.Values) ([]ticker, bool, error) {
+	query := url.Values{}
+	if symbol := params.Get("symbol"); symbol != "" {
+		instID, err := binanceInstID(symbol)
+		if err != nil {
+			return nil, false, err
+		}
+		query.Set("instId", instID)
+	}
+	var list []ticker
+	if err := s.blofin.get(ctx, "/api/v1/market/tickers", query, &list); err != nil {
+		return nil, false, err
+	}
+	if len(query) > 0 && len(list) == 0 {
+		return nil, false, binanceBadRequest(-1121, "Invalid symbol.")
+	}
+	return list, len(query) > 0, nil
+}
+
+func binanceOne(list []map[string]interface{}, single bool) interface{} {
+	if single {
+		return list[0]
+	}
+	return list
+}
+
+func (s *server) binanceTickerPrice(ctx context.Context, params url.Values, _ *tenant) (interface{}, error) {
+	list, single, err := s.binanceTickers(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	out := []map[string]interface{}{}
+	for _, t := range list {
+		out = append(out, map[string]interface{}{"symbol": binanceSymbol(t.InstID), "price": t.Last, "time": binanceInt(t.Ts)})
+	}
+	return binanceOne(out, single), nil
+}
+
+func (s *server) binanceTicker24h(ctx context.Context, params url.Values, _ *tenant) (interface{}, error) {
+	list, single, err := s.binanceTickers(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	out := []map[string]interface{}{}
+	for _, t := range list {
+		change, percent := "0", "0"
+		last, okLast := parseDecimal(t.Last)
+		open, okOpen := parseDecimal(t.Open24h)
+		if okLast && okOpen && open.Sign() != 0 {
+			diff := new(big.Rat).Sub(last, open)
+			change = decimalString(diff)
+			percent = new(big.Rat).Mul(new(big.Rat).Quo(diff, open), big.NewRat(100, 1)).FloatString(3)
+		}
+		lastQty := t.LastSize
+		if inst, ok, _ := s.instruments.get(t.InstID); ok {
+			lastQty = binanceQty(lastQty, inst)
+		}
+		closeTime := binanceInt(t.Ts)
+		out = append(out, map[string]interface{}{
+			"symbol":             binanceSymbol(t.InstID),
+			"priceChange":        change,
+			"priceChangePercent": percent,
+			"lastPrice":          t.Last,
+			"lastQty":            lastQty,
+			"openPrice":          t.Open24h,
+			"highPrice":          t.High24h,
+			"lowPrice":           t.Low24h,
+			"volume":             t.VolCurrency24h,
+			"openTime":           closeTime - 24*60*60*1000,
+			"closeTime":          closeTime,
+		})
+	}
+	return binanceOne(out, single), nil
+}
+
+func (s *server) binanceBookTicker(ctx context.Context, params url.Values, _ *tenant) (interface{}, error) {
+	list, single, err := s.binanceTickers(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	out := []map[string]interface{}{}
+	for _, t := range list {
+		bidQty, askQty := t.BidSize, t.AskSize
+		if inst, ok, _ := s.instruments.get(t.InstID); ok {
+			bidQty, askQty = binanceQty(bidQty, inst), binanceQty(askQty, inst)
+		}
+		out = append(out, map[string]interface{}{
+			"symbol":   binanceSymbol(t.InstID),
+			"bidPrice": t.BidPrice,
+			"bidQty":   bidQty,
+			"askPrice": t.AskPrice,
+			"askQty":   askQty,
+			"time":     binanceInt(t.Ts),
+		})
+	}
+	return binanceOne(out, single), nil
+}
+
+func (s *server) binanceDepth(ctx context.Context, params url.Values, _ *tenant) (interface{}, error) {
+	inst, err := s.binanceInstrument(params)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"instId": {inst.InstID}}
+	if limit := params.Get("limit"); limit != "" {
+		query.Set("size", limit)
+	}
+	var books []struct {
+		Asks [][]string `json:"asks"`
+		Bids [][]string `json:"bids"`
+		Ts   string     `json:"ts"`
+	}
+	if err := s.blofin.get(ctx, "/api/v1/market/books", query, &books); err != nil {
+		return nil, err
+	}
+	if len(books) == 0 {
+		return nil, binanceBadRequest(-1121, "Invalid symbol.")
+	}
+	levels := func(rows [][]string) [][]string {
+		out := [][]string{}
+		for _, row := range rows {
+			if len(row) >= 2 {
+				out = append(out, []string{row[0], binanceQty(row[1], inst)})
+			}
+		}
+		return out
+	}
+	ts := binanceInt(books[0].Ts)
+	return map[string]interface{}{
+		"lastUpdateId": ts,
+		"E":            ts,
+		"T":            ts,
+		"bids":         levels(books[0].Bids),
+		"asks":         levels(books[0].Asks),
+	}, nil
+}
+
+// At most CANDLE_PAGE_LIMIT klines per call, BloFin's page size
+func (s *server) binanceKlines(ctx context.Context, params url.Values, _ *tenant) (interface{}, error) {
+	inst, err := s.binanceInstrument(params)
+	if err != nil {
+		return nil, err
+	}
+	bar, ok := unifiedTimeframes[params.Get("interval")]
+	if !ok {
+		return nil, binanceBadRequest(-1120, "Invalid interval.")
+	}
+	barMs := barDurations[bar].Milliseconds()
+	limit := 500
+	if value := params.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			return nil, binanceBadRequest(-1100, "Illegal characters found in parameter 'limit'.")
+		}
+	}
+	limit = min(limit, CANDLE_PAGE_LIMIT)
+	start, end := binanceInt(params.Get("startTime")), binanceInt(params.Get("endTime"))
+
+	query := url.Values{"instId": {inst.InstID}, "bar": {bar}, "limit": {strconv.Itoa(limit)}}
+	switch {
+	case end > 0:
+		query.Set("after", strconv.FormatInt(end+1, 10))
+	case start > 0:
+		query.Set("after", strconv.FormatInt(start+int64(limit)*barMs, 10))
+	}
+	var rows []candle
+	if err := s.blofin.get(ctx, "/api/v1/market/candles", query, &rows); err != nil {
+		return nil, err
+	}
+	klines := [][]interface{}{}
+	for i := len(rows) - 1; i >= 0; i-- {
+		row := rows[i]
+		if len(row) < 8 || row.ts() < start {
+			continue
+		}
+		klines = append(klines, []interface{}{
+			row.ts(), row[1], row[2], row[3], row[4], row[6], row.ts() + barMs - 1, row[7], 0, "0", "0", "0",
+		})
+	}
+	return klines, nil
+}
+
+func (s *server) binancePremiumIndex(ctx context.Context, params url.Values, _ *tenant) (interface{}, error) {
+	query := url.Values{}
+	if symbol := params.Get("symbol"); symbol != "" {
+		instID, err := binanceInstID(symbol)
+		if err != nil {
+			return nil, err
+		}
+		query.Set("instId", instID)
+	}
+	var marks []struct {
+		InstID     string `json:"instId"`
+		IndexPrice string `json:"indexPrice"`
+		MarkPrice  string `json:"markPrice"`
+		Ts         string `json:"ts"`
+	}
+	if err := s.blofin.get(ctx, "/api/v1/market/mark-price", query, &marks); err != nil {
+		return nil, err
+	}
+	var rates []struct {
+		InstID      string `json:"instId"`
+		FundingRate string `json:"fundingRate"`
+		FundingTime string `json:"fundingTime"`
+	}
+	if err := s.blofin.get(ctx, "/api/v1/market/funding-rate", query, &rates); err != nil {
+		return nil, err
+	}
+	funding := map[string]int{}
+	for i, rate := range rates {
+		funding[rate.InstID] = i
+	}
+	out := []map[string]interface{}{}
+	for _, mark := range marks {
+		entry := map[string]interface{}{
+			"symbol":          binanceSymbol(mark.InstID),
+			"markPrice":       mark.MarkPrice,
+			"indexPrice":      mark.IndexPrice,
+			"lastFundingRate": "0",
+			"nextFundingTime": 0,
+			"time":            binanceInt(mark.Ts),
+		}
+		if i, ok := funding[mark.InstID]; ok {
+			entry["lastFundingRate"] = rates[i].FundingRate
+			entry["nextFundingTime"] = binanceInt(rates[i].FundingTime)
+		}
+		out = append(out, entry)
+	}
+	if len(query) > 0 {
+		if len(out) == 0 {
+			return nil, binanceBadRequest(-1121, "Invalid symbol.")
+		}
+		return out[0], nil
+	}
+	return out, nil
+}
+
+func (s *server) binanceBalance(ctx context.Context, _ url.Values, t *tenant) (interface{}, error) {
+	var account struct {
+		Ts      string `json:"ts"`
+		Details []struct {
+			Currency              string `json:"currency"`
+			Equity                string `json:"equity"`
+			Balance               string `json:"balance"`
+			Available             string `json:"available"`
+			IsolatedUnrealizedPnl string `json:"isolatedUnrealizedPnl"`
+		} `json:"details"`
+	}
+	if err := s.blofin.do(ctx, http.MethodGet, "/api/v1/account/balance", nil, nil, t, &account); err != nil {
+		return nil, err
+	}
+	out := []map[string]interface{}{}
+	for _, d := range account.Details {
+		unrealized := "0"
+		equity, okEquity := parseDecimal(d.Equity)
+		balance, okBalance := parseDecimal(d.Balance)
+		if okEquity && okBalance {
+			unrealized = decimalString(new(big.Rat).Sub(equity, balance))
+		}
+		out = append(out, map[string]interface{}{
+			"accountAlias":       "",
+			"asset":              d.Currency,
+			"balance":            d.Balance,
+			"crossWalletBalance": d.Balance,
+			"crossUnPnl":         unrealized,
+			"availableBalance":   d.Available,
+			"maxWithdrawAmount":  d.Available,
+			"marginAvailable":    true,
+			"updateTime":         binanceInt(account.Ts),
+		})
+	}
+	return out, nil
+}
+
+var binancePositionSides = map[string]string{"net": "BOTH", "long": "LONG", "short": "SHORT"}
+
+func (s *server) binancePositionRisk(ctx context.Context, params url.Values, t *tenant) (interface{}, error) {
+	query := url.Values{}
+	if symbol := params.Get("symbol"); symbol != "" {
+		instID, err := binanceInstID(symbol)
+		if err != nil {
+			return nil, err
+		}
+		query.Set("instId", instID)
+	}
+	var list []map[string]string
+	if err := s.blofin.do(ctx, http.MethodGet, "/api/v1/account/positions", query, nil, t, &list); err != nil {
+		return nil, err
+	}
+	out := []map[string]interface{}{}
+	for _, p := range list {
+		amount := p["positions"]
+		if inst, ok, _ := s.instruments.get(p["instId"]); ok {
+			amount = binanceQty(amount, inst)
+		}
+		if p["positionSide"] == "short" && !strings.HasPrefix(amount, "-") && amount != "0" {
+			amount = "-" + amount
+		}
+		out = append(out, map[string]interface{}{
+			"symbol":           binanceSymbol(p["instId"]),
+			"positionAmt":      amount,
+			"entryPrice":       p["averagePrice"],
+			"markPrice":        p["markPrice"],
+			"unRealizedProfit": p["unrealizedPnl"],
+			"liquidationPrice": p["liquidationPrice"],
+			"leverage":         p["leverage"],
+			"marginType":       p["marginMode"],
+			"isolatedMargin":   p["margin"],
+			"positionSide":     binancePositionSides[p["positionSide"]],
+			"updateTime":       binanceInt(p["updateTime"]),
+		})
+	}
+	return out, nil
+}
+
+// BloFin order types and the Binance type/timeInForce pair each maps to
+var binanceOrderTypes = map[string][2]string{
+	"market":    {"MARKET", "GTC"},
+	"limit":     {"LIMIT", "GTC"},
+	"post_only": {"LIMIT", "GTX"},
+	"ioc":       {"LIMIT", "IOC"},
+	"fok":       {"LIMIT", "FOK"},
+}
+
+var binanceOrderStatus = map[string]string{
+	"live":             "NEW",
+	"partially_filled": "PARTIALLY_FILLED",
+	"filled":           "FILLED",
+	"canceled":         "CANCELED",
+}
+
+func (s *server) binanceOpenOrders(ctx context.Context, params url.Values, t *tenant) (interface{}, error) {
+	query := url.Values{}
+	if symbol := params.Get("symbol"); symbol != "" {
+		instID, err := binanceInstID(symbol)
+		if err != nil {
+			return nil, err
+		}
+		query.Set("instId", instID)
+	}
+	var list []map[string]interface{}
+	if err := s.blofin.do(ctx, http.MethodGet, "/api/v1/trade/orders-pending", query, nil, t, &list); err != nil {
+		return nil, err
+	}
+	out := []map[string]interface{}{}
+	for _, o := range list {
+		field := func(name string) string { v, _ := o[name].(string); return v }
+		origQty, executedQty := field("size"), field("filledSize")
+		if inst, ok, _ := s.instruments.get(field("instId")); ok {
+			origQty, executedQty = binanceQty(origQty, inst), binanceQty(executedQty, inst)
+		}
+		kind := binanceOrderTypes[field("orderType")]
+		out = append(out, map[string]interface{}{
+			"orderId":       binanceInt(field("orderId")),
+			"clientOrderId": field("clientOrderId"),
+			"symbol":        binanceSymbol(field("instId")),
+			"status":        binanceOrderStatus[field("state")],
+			"price":         field("price"),
+			"avgPrice":      field("averagePrice"),
+			"origQty":       origQty,
+			"executedQty":   executedQty,
+			"type":          kind[0],
+			"timeInForce":   kind[1],
+			"side":          strings.ToUpper(field("side")),
+			"positionSide":  binancePositionSides[field("positionSide")],
+			"reduceOnly":    field("reduceOnly") == "true",
+			"time":          binanceInt(field("createTime")),
+			"updateTime":    binanceInt(field("updateTime")),
+		})
+	}
+	return out, nil
+}
+
+func (s *server) binanceNewOrder(ctx context.Context, params url.Values, t *tenant) (interface{}, error) {
+	inst, err := s.binanceInstrument(params)
+	if err != nil {
+		return nil, err
+	}
+	side := strings.ToLower(params.Get("side"))
+	if side != "buy" && side != "sell" {
+		return nil, binanceBadRequest(-1117, "Invalid side.")
+	}
+	var orderType string
+	switch strings.ToUpper(params.Get("type")) {
+	case "MARKET":
+		orderType = "market"
+	case "LIMIT":
+		orderType = map[string]string{"": "limit", "GTC": "limit", "IOC": "ioc", "FOK": "fok", "GTX": "post_only"}[strings.ToUpper(params.Get("timeInForce"))]
+		if orderType == "" {
+			return nil, binanceBadRequest(-1115, "Invalid timeInForce.")
+		}
+		if params.Get("price") == "" {
+			return nil, binanceBadRequest(-1102, "Mandatory parameter 'price' was not sent, was empty/null, or malformed.")
+		}
+	default:
+		return nil, binanceBadRequest(-1116, "Invalid orderType. Only LIMIT and MARKET are supported.")
+	}
+	size, err := binanceContracts(params.Get("quantity"), inst)
+	if err != nil {
+		return nil, err
+	}
+	positionSide := "net"
+	switch strings.ToUpper(params.Get("positionSide")) {
+	case "LONG":
+		positionSide = "long"
+	case "SHORT":
+		positionSide = "short"
+	}
+
+	order := map[string]string{
+		"instId":       inst.InstID,
+		"marginMode":   "cross",
+		"positionSide": positionSide,
+		"side":         side,
+		"orderType":    orderType,
+		"size":         size,
+	}
+	if orderType != "market" {
+		order["price"] = params.Get("price")
+	}
+	if strings.EqualFold(params.Get("reduceOnly"), "true") {
+		order["reduceOnly"] = "true"
+	}
+	if id := params.Get("newClientOrderId"); id != "" {
+		order["clientOrderId"] = id
+	} else if s.cfg.AutoClientOrderID {
+		order["clientOrderId"] = s.cfg.ClientOrderPrefix + newULID(time.Now())
+	}
+
+	var results []blofinOrderResult
+	if err := s.placeOrder(ctx, t, order, &results); err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("empty order response")
+	}
+	if results[0].Code != "" && results[0].Code != "0" {
+		return nil, binanceBadRequest(-2010, "BloFin rejected the order: %s (code %s)", results[0].Msg, results[0].Code)
+	}
+	kind := binanceOrderTypes[orderType]
+	now := time.Now().UnixMilli()
+	return map[string]interface{}{
+		"orderId":       binanceInt(results[0].OrderID),
+		"clientOrderId": results[0].ClientOrderID,
+		"symbol":        binanceSymbol(inst.InstID),
+		"status":        "NEW",
+		"price":         order["price"],
+		"origQty":       params.Get("quantity"),
+		"executedQty":   "0",
+		"type":          kind[0],
+		"timeInForce":   kind[1],
+		"side":          strings.ToUpper(side),
+		"positionSide":  binancePositionSides[positionSide],
+		"reduceOnly":    order["reduceOnly"] == "true",
+		"updateTime":    now,
+	}, nil
+}
+
+func (s *server) binanceCancelOrder(ctx context.Context, params url.Values, t *tenant) (interface{}, error) {
+	inst, err := s.binanceInstrument(params)
+	if err != nil {
+		return nil, err
+	}
+	body := map[string]string{"instId": inst.InstID}
+	switch {
+	case params.Get("orderId") != "":
+		body["orderId"] = params.Get("orderId")
+	case params.Get("origClientOrderId") != "":
+		body["clientOrderId"] = params.Get("origClientOrderId")
+	default:
+		return nil, binanceBadRequest(-1102, "Param 'origClientOrderId' or 'orderId' must be sent, but both were empty/null!")
+	}
+	var results []blofinOrderResult
+	if err := s.blofin.do(ctx, http.MethodPost, "/api/v1/trade/cancel-order", nil, body, t, &results); err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("empty cancel response")
+	}
+	if results[0].Code != "" && results[0].Code != "0" {
+		return nil, binanceBadRequest(-2011, "Unknown order sent.")
+	}
+	return map[string]interface{}{
+		"orderId":       binanceInt(results[0].OrderID),
+		"clientOrderId": results[0].ClientOrderID,
+		"symbol":        binanceSymbol(inst.InstID),
+		"status":        "CANCELED",
+		"updateTime":    time.Now().UnixMilli(),
+	}, nil
+}
+
+func (s *server) binanceLeverage(ctx context.Context, params url.Values, t *tenant) (interface{}, error) {
+	inst, err := s.binanceInstrument(params)
+	if err != nil {
+		return nil, err
+	}
+	leverage, err := strconv.Atoi(params.Get("leverage"))
+	if err != nil || leverage <= 0 {
+		return nil, binanceBadRequest(-1102, "Mandatory parameter 'leverage' was not sent, was empty/null, or malformed.")
+	}
+	body := map[string]string{"instId": inst.InstID, "leverage": strconv.Itoa(leverage), "marginMode": "cross"}
+	var result map[string]interface{}
+	if err := s.blofin.do(ctx, http.MethodPost, "/api/v1/account/set-leverage", nil, body, t, &result); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"symbol": binanceSymbol(inst.InstID), "leverage": leverage, "maxNotionalValue": "0"}, nil
+}
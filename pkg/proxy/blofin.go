@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Envelope wrapped around every BloFin REST response
+type blofinResponse struct {
+	Code string          `json:"code"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Error from a BloFin call the proxy made on its own behalf
+type blofinError struct {
+	Status int
+	Code   string
+	Msg    string
+}
+
+func (e *blofinError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("BloFin error %s: %s (HTTP %d)", e.Code, e.Msg, e.Status)
+	}
+	return fmt.Sprintf("BloFin returned HTTP %d", e.Status)
+}
+
+func isRateLimited(err error) bool {
+	e, ok := err.(*blofinError)
+	return ok && e.Status == http.StatusTooManyRequests
+}
+
+// Client for the BloFin endpoints the proxy calls itself (instrument
+// specs, candles, ...), public or signed for a tenant. In mock mode it
+// answers from the mock exchange so those features work offline too.
+type blofinClient struct {
+	hosts   *hostSelector
+	http    *http.Client
+	mock    *mockExchange
+	headers staticHeaders
+	broker  *brokerTagger
+	clock   *signingClock
+	paper   *paperDesk // answers paper tenants' private calls
+}
+
+func newBlofinClient(mock *mockExchange, hosts *hostSelector, transport http.RoundTripper, headers []StaticHeader, broker *brokerTagger, clock *signingClock) *blofinClient {
+	return &blofinClient{
+		hosts:   hosts,
+		http:    &http.Client{Transport: transport, Timeout: 15 * time.Second},
+		mock:    mock,
+		headers: headers,
+		broker:  broker,
+		clock:   clock,
+	}
+}
+
+// GET a public endpoint and decode its data field into out
+func (c *blofinClient) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	return c.do(ctx, http.MethodGet, path, query, nil, nil, out)
+}
+
+// Call an endpoint, signed for t when t is set, sending payload as the JSON
+// body if it isn't nil, and decode the data field into out
+func (c *blofinClient) do(ctx context.Context, method, path string, query url.Values, payload interface{}, t *tenant, out interface{}) error {
+	var body []byte
+	if payload != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return err
+		}
+		if method == http.MethodPost && orderRoutes[path] {
+			body = c.broker.tag(body)
+		}
+	}
+	target := path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	if c.paper.serves(t, method, path) {
+		data, err := c.paper.call(t, method, path, query, body)
+		if err != nil {
+			return err
+		}
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(encoded, out)
+	}
+	if c.mock != nil {
+		req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		data, ok := c.mock.dispatch(req, body)
+		if !ok {
+			return &blofinError{Status: http.StatusNotFound}
+		}
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(encoded, out)
+	}
+
+	buf := getBodyBuffer()
+	defer putBodyBuffer(buf)
+	var resp *http.Response
+	for retried := false; ; retried = true {
+		req, err := http.NewRequestWithContext(ctx, method, c.hosts.best()+target, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		c.headers.apply(req.Header, path)
+		applyTrace(ctx, req.Header)
+		if t != nil {
+			t.sign(req, body, c.clock.now())
+		}
+		if resp, err = c.http.Do(req); err != nil {
+			return err
+		}
+		buf.Reset()
+		_, err = buf.ReadFrom(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if t == nil || !c.clock.resign(resp.Header, buf.Bytes(), retried) {
+			break
+		}
+	}
+	return decodeResponse(path, resp.StatusCode, buf.Bytes(), out)
+}
+
+// Decode the data field of a BloFin response into out, or turn the
+// response into a *blofinError
+func decodeResponse(path string, status int, body []byte, out interface{}) error {
+	var envelope blofinResponse
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		if status != http.StatusOK {
+			return &blofinError{Status: status}
+		}
+		return fmt.Errorf("invalid response from %s: %v", path, err)
+	}
+	if status != http.StatusOK || envelope.Code != "0" {
+		return &blofinError{Status: status, Code: envelope.Code, Msg: envelope.Msg}
+	}
+	return json.Unmarshal(envelope.Data, out)
+}
@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Order fields BROKER_TAGGING can fill in
+const (
+	TAG_BROKER_ID       = "brokerId"
+	TAG_CLIENT_ORDER_ID = "clientOrderId"
+)
+
+// BloFin rejects longer client order IDs
+const MAX_CLIENT_ORDER_ID = 32
+
+// Rewrites order placement bodies so every order carries the broker code:
+// brokerId is set outright, clientOrderId gets the code as a prefix (or a
+// generated ID when the client sent none) unless that would make it too
+// long. Only applied to bodies the
+// proxy signs itself, since changing a client-signed body breaks its
+// signature.
+type brokerTagger struct {
+	code   string
+	fields map[string]bool
+}
+
+func newBrokerTagger(code string, fields []string) (*brokerTagger, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	if code == "" {
+		return nil, fmt.Errorf("broker tagging needs a broker ID")
+	}
+	t := &brokerTagger{code: code, fields: map[string]bool{}}
+	for _, field := range fields {
+		if field != TAG_BROKER_ID && field != TAG_CLIENT_ORDER_ID {
+			return nil, fmt.Errorf("unknown field %q (expected %s or %s)", field, TAG_BROKER_ID, TAG_CLIENT_ORDER_ID)
+		}
+		t.fields[field] = true
+	}
+	if t.fields[TAG_CLIENT_ORDER_ID] && len(code) >= MAX_CLIENT_ORDER_ID {
+		return nil, fmt.Errorf("broker ID %q is too long to prefix client order IDs", code)
+	}
+	return t, nil
+}
+
+// Tag the order (or batch of orders) in body. Bodies that aren't valid
+// JSON are passed through for BloFin to reject.
+func (t *brokerTagger) tag(body []byte) []byte {
+	if t == nil {
+		return body
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var parsed interface{}
+	if err := dec.Decode(&parsed); err != nil {
+		return body
+	}
+	switch v := parsed.(type) {
+	case map[string]interface{}:
+		t.tagOrder(v)
+	case []interface{}:
+		for _, item := range v {
+			if order, ok := item.(map[string]interface{}); ok {
+				t.tagOrder(order)
+			}
+		}
+	default:
+		return body
+	}
+	tagged, err := json.Marshal(parsed)
+	if err != nil {
+		log.Printf("❌ Failed to encode tagged order: %v", err)
+		return body
+	}
+	return tagged
+}
+
+func (t *brokerTagger) tagOrder(order map[string]interface{}) {
+	if t.fields[TAG_BROKER_ID] {
+		order["brokerId"] = t.code
+	}
+	if t.fields[TAG_CLIENT_ORDER_ID] {
+		id, _ := order["clientOrderId"].(string)
+		switch {
+		case id == "":
+			// A generated ID is only random characters, so it may be cut
+			id = t.code + strings.ReplaceAll(newNonce(), "-", "")
+			id = id[:min(len(id), MAX_CLIENT_ORDER_ID)]
+		case strings.HasPrefix(id, t.code):
+		case len(t.code)+len(id) <= MAX_CLIENT_ORDER_ID:
+			id = t.code + id
+		default:
+			// Cutting the client's own ID would stop it finding the order
+			// again, so it goes untagged
+			log.Printf("🏷️ clientOrderId %q too long to prefix with %s, sent as is", id, t.code)
+		}
+		order["clientOrderId"] = id
+	}
+}
@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestBrokerTagClientOrderID(t *testing.T) {
+	tagger, err := newBrokerTagger("brk", []string{TAG_BROKER_ID, TAG_CLIENT_ORDER_ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	long := strings.Repeat("x", 30)
+	tests := []struct {
+		name string
+		id   string
+		want string
+	}{
+		{"prefixed", "abc123", "brkabc123"},
+		{"already prefixed", "brkabc123", "brkabc123"},
+		{"fits exactly", strings.Repeat("x", 29), "brk" + strings.Repeat("x", 29)},
+		{"too long to prefix", long, long},
+		{"too long for BloFin", long + "yyy", long + "yyy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var order map[string]string
+			if err := json.Unmarshal(tagger.tag([]byte(`{"instId":"BTC-USDT","clientOrderId":"`+tt.id+`"}`)), &order); err != nil {
+				t.Fatal(err)
+			}
+			if order["clientOrderId"] != tt.want || order["brokerId"] != "brk" {
+				t.Errorf("tagged order = %v, want clientOrderId %q", order, tt.want)
+			}
+		})
+	}
+}
+
+func TestBrokerTagGeneratedID(t *testing.T) {
+	tagger, err := newBrokerTagger("brk", []string{TAG_CLIENT_ORDER_ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var orders []map[string]string
+	if err := json.Unmarshal(tagger.tag([]byte(`[{"instId":"BTC-USDT"},{"instId":"ETH-USDT"}]`)), &orders); err != nil {
+		t.Fatal(err)
+	}
+	for _, order := range orders {
+		id := order["clientOrderId"]
+		if !strings.HasPrefix(id, "brk") || len(id) > MAX_CLIENT_ORDER_ID || order["brokerId"] != "" {
+			t.Errorf("tagged order = %v", order)
+		}
+	}
+	if orders[0]["clientOrderId"] == orders[1]["clientOrderId"] {
+		t.Error("generated IDs repeat")
+	}
+	if body := tagger.tag([]byte("not json")); string(body) != "not json" {
+		t.Errorf("invalid body rewritten to %s", body)
+	}
+}
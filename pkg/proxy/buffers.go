@@ -0,0 +1,38 @@
+package proxy
+
+import (
+	"bytes"
+	"sync"
+)
+
+// Size of the buffers used to stream bodies between BloFin and clients
+const COPY_BUFFER_SIZE = 32 << 10
+
+var copyBuffers = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, COPY_BUFFER_SIZE)
+		return &b
+	},
+}
+
+// Scratch buffers for bodies that are read whole and then decoded. Only
+// use them for data that doesn't outlive the call.
+var bodyBuffers = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// Buffers that grew past this are dropped rather than pooled, so one huge
+// response doesn't pin its memory for good
+const MAX_POOLED_BODY = 1 << 20
+
+func getBodyBuffer() *bytes.Buffer {
+	buf := bodyBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBodyBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= MAX_POOLED_BODY {
+		bodyBuffers.Put(buf)
+	}
+}
@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+)
+
+// A body the size of a large ticker response
+var benchBody = bytes.Repeat([]byte(`{"instId":"BTC-USDT","last":"64453.3"},`), 4096)
+
+// Hide WriterTo and ReaderFrom so io.Copy has to use a buffer, as it does
+// between a response body and a ResponseWriter
+type plainReader struct{ r io.Reader }
+
+func (p plainReader) Read(b []byte) (int, error) { return p.r.Read(b) }
+
+type plainWriter struct{ w io.Writer }
+
+func (p plainWriter) Write(b []byte) (int, error) { return p.w.Write(b) }
+
+func BenchmarkCopyBody(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		io.Copy(plainWriter{io.Discard}, plainReader{bytes.NewReader(benchBody)})
+	}
+}
+
+func BenchmarkCopyBodyPooled(b *testing.B) {
+	b.ReportAllocs()
+	var pool proxyBufferPool
+	for i := 0; i < b.N; i++ {
+		buf := pool.Get()
+		io.CopyBuffer(plainWriter{io.Discard}, plainReader{bytes.NewReader(benchBody)}, buf)
+		pool.Put(buf)
+	}
+}
+
+func BenchmarkDecodeBody(b *testing.B) {
+	b.ReportAllocs()
+	payload := []byte(`{"code":"0","data":[` + string(bytes.TrimSuffix(benchBody, []byte(","))) + `]}`)
+	for i := 0; i < b.N; i++ {
+		body, _ := io.ReadAll(plainReader{bytes.NewReader(payload)})
+		var envelope blofinResponse
+		json.Unmarshal(body, &envelope)
+	}
+}
+
+func BenchmarkDecodeBodyPooled(b *testing.B) {
+	b.ReportAllocs()
+	payload := []byte(`{"code":"0","data":[` + string(bytes.TrimSuffix(benchBody, []byte(","))) + `]}`)
+	for i := 0; i < b.N; i++ {
+		buf := getBodyBuffer()
+		buf.ReadFrom(plainReader{bytes.NewReader(payload)})
+		var envelope blofinResponse
+		json.Unmarshal(buf.Bytes(), &envelope)
+		putBodyBuffer(buf)
+	}
+}
+
+func BenchmarkHopByHopHeader(b *testing.B) {
+	b.ReportAllocs()
+	names := []string{"Content-Type", "Connection", "Access-Sign", "Transfer-Encoding", "X-Request-Id"}
+	for i := 0; i < b.N; i++ {
+		isHopByHopHeader(names[i%len(names)])
+	}
+}
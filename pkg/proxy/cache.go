@@ -0,0 +1,282 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_CACHE_STALE = time.Minute
+	MAX_CACHE_ENTRIES   = 10000
+	CACHE_HEADER        = "X-Proxy-Cache"
+)
+
+// Account endpoints a tenant's requests may be cached for with
+// ACCOUNT_CACHE_TTL
+var DEFAULT_ACCOUNT_CACHE_ROUTES = []string{
+	"/api/v1/account/balance",
+	"/api/v1/account/positions",
+	"/api/v1/asset/balances",
+	"/api/v1/copytrading/account/balance",
+	"/api/v1/copytrading/account/positions",
+}
+
+// Public GET requests under Prefix are answered from a shared cache for TTL
+type CacheRule struct {
+	Prefix string
+	TTL    time.Duration
+}
+
+// Parse "/path/prefix ttl" entries
+func parseCacheRules(entries []string) ([]CacheRule, error) {
+	var rules []CacheRule
+	for _, entry := range entries {
+		fields := strings.Fields(entry)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%q: expected a path prefix and a TTL", entry)
+		}
+		ttl, err := time.ParseDuration(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%q: invalid TTL %q", entry, fields[1])
+		}
+		rules = append(rules, CacheRule{Prefix: fields[0], TTL: ttl})
+	}
+	return rules, nil
+}
+
+type cachedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+type cacheEntry struct {
+	resp      *cachedResponse // last 200, what gets served
+	fetchedAt time.Time
+	last      *cachedResponse // result of the last fetch, for callers waiting on it
+	err       error
+	inflight  chan struct{} // closed when the running fetch finishes
+}
+
+// Cache of BloFin responses. Entries past their TTL are still served, for
+// up to stale longer, while one background fetch revalidates them, so
+// clients never wait on a slow upstream for data that was good a moment
+// ago. Concurrent misses share one fetch.
+type responseCache struct {
+	name    string      // metric label
+	rules   []CacheRule // longest prefix first
+	stale   time.Duration
+	metrics *metricsRegistry
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+// nil when no rules are configured
+func newResponseCache(name string, rules []CacheRule, stale time.Duration, metrics *metricsRegistry) *responseCache {
+	if len(rules) == 0 {
+		return nil
+	}
+	rules = append([]CacheRule(nil), rules...)
+	sort.SliceStable(rules, func(i, j int) bool { return len(rules[i].Prefix) > len(rules[j].Prefix) })
+	metrics.register("blofin_proxy_cache_requests_total", METRIC_COUNTER, "Cacheable requests by cache and result: hit, stale or miss")
+	return &responseCache{name: name, rules: rules, stale: stale, metrics: metrics, entries: map[string]*cacheEntry{}}
+}
+
+// TTL for a GET request, false when it isn't cacheable
+func (c *responseCache) ttlFor(r *http.Request) (time.Duration, bool) {
+	if c == nil || r.Method != http.MethodGet {
+		return 0, false
+	}
+	for _, rule := range c.rules {
+		if strings.HasPrefix(r.URL.Path, rule.Prefix) {
+			return rule.TTL, rule.TTL > 0
+		}
+	}
+	return 0, false
+}
+
+// Same request with the query in canonical order
+func cacheKey(r *http.Request) string {
+	query, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		return r.URL.RequestURI()
+	}
+	if len(query) == 0 {
+		return r.URL.Path
+	}
+	return r.URL.Path + "?" + query.Encode()
+}
+
+// Answer from the entry for key, calling fetch when it must be (re)loaded
+func (c *responseCache) serve(w http.ResponseWriter, r *http.Request, key string, ttl time.Duration, fetch func() (*cachedResponse, error)) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if !ok {
+		if len(c.entries) >= MAX_CACHE_ENTRIES {
+			c.evictLocked()
+		}
+		e = &cacheEntry{}
+		c.entries[key] = e
+	}
+	if e.resp != nil {
+		age := time.Since(e.fetchedAt)
+		if age <= ttl {
+			resp := e.resp
+			c.mu.Unlock()
+			c.write(w, resp, "HIT", age)
+			return
+		}
+		if age <= ttl+c.stale {
+			resp := e.resp
+			if e.inflight == nil {
+				go c.refresh(key, e, c.startLocked(e), fetch)
+			}
+			c.mu.Unlock()
+			c.write(w, resp, "STALE", age)
+			return
+		}
+	}
+	done := e.inflight
+	if done == nil {
+		done = c.startLocked(e)
+		go c.refresh(key, e, done, fetch)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-r.Context().Done():
+		if errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+			deadlineExceeded(w)
+		}
+		return
+	}
+	c.mu.Lock()
+	resp, err := e.last, e.err
+	c.mu.Unlock()
+	if err != nil {
+		log.Printf("❌ Cache fetch %s failed: %v", key, err)
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "Proxy request failed"})
+		return
+	}
+	c.write(w, resp, "MISS", 0)
+}
+
+func (c *responseCache) startLocked(e *cacheEntry) chan struct{} {
+	e.inflight = make(chan struct{})
+	return e.inflight
+}
+
+func (c *responseCache) refresh(key string, e *cacheEntry, done chan struct{}, fetch func() (*cachedResponse, error)) {
+	resp, err := fetch()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e.last, e.err = resp, err
+	if err == nil && resp.status == http.StatusOK {
+		e.resp, e.fetchedAt = resp, time.Now()
+	} else if e.resp != nil {
+		log.Printf("⚠️ Revalidating %s failed, keeping the cached copy", key)
+	}
+	e.inflight = nil
+	close(done)
+}
+
+// Drop entries too old to be served; if that frees nothing, drop them all
+func (c *responseCache) evictLocked() {
+	longest := time.Duration(0)
+	for _, rule := range c.rules {
+		if rule.TTL > longest {
+			longest = rule.TTL
+		}
+	}
+	for key, e := range c.entries {
+		if e.inflight == nil && time.Since(e.fetchedAt) > longest+c.stale {
+			delete(c.entries, key)
+		}
+	}
+	if len(c.entries) >= MAX_CACHE_ENTRIES {
+		c.entries = map[string]*cacheEntry{}
+	}
+}
+
+func (c *responseCache) write(w http.ResponseWriter, resp *cachedResponse, result string, age time.Duration) {
+	c.metrics.add("blofin_proxy_cache_requests_total", labels("cache", c.name, "result", strings.ToLower(result)), 1)
+	for name, values := range resp.header {
+		if isHopByHopHeader(name) || name == "Content-Length" {
+			continue
+		}
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	w.Header().Set(CACHE_HEADER, result)
+	w.Header().Set("Age", strconv.Itoa(int(age.Seconds())))
+	w.WriteHeader(resp.status)
+	w.Write(resp.body)
+}
+
+// Answer a cacheable request from the public cache, or for a tenant from
+// the account cache; false when the request must be forwarded. Public
+// entries are only used for anonymous requests, and account entries only
+// for the proxy's own tenants, keyed by their API key: a client-signed
+// request can't be checked without the secret, so it is never cached.
+func (s *server) serveCached(w http.ResponseWriter, r *http.Request, t *tenant) bool {
+	uri := cacheKey(r)
+	if t == nil && r.Header.Get("ACCESS-KEY") == "" {
+		if ttl, ok := s.cache.ttlFor(r); ok {
+			s.cache.serve(w, r, uri, ttl, func() (*cachedResponse, error) { return s.fetchCached(uri, nil) })
+			return true
+		}
+	}
+	if t != nil {
+		if ttl, ok := s.accounts.ttlFor(r); ok {
+			sum := sha256.Sum256([]byte(t.APIKey))
+			key := hex.EncodeToString(sum[:8]) + " " + uri
+			s.accounts.serve(w, r, key, ttl, func() (*cachedResponse, error) { return s.fetchCached(uri, t) })
+			return true
+		}
+	}
+	return false
+}
+
+// Fetch an endpoint for a cache, signed for t if set, outside any client
+// request
+func (s *server) fetchCached(uri string, t *tenant) (*cachedResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.UpstreamTimeout)
+	defer cancel()
+	for retried := false; ; retried = true {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.hosts.best()+uri, nil)
+		if err != nil {
+			return nil, err
+		}
+		s.blofin.headers.apply(req.Header, req.URL.Path)
+		if t != nil {
+			t.sign(req, nil, s.blofin.clock.now())
+		}
+		resp, err := s.blofin.http.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, MAX_INSPECT_BODY*8))
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if t == nil || !s.blofin.clock.resign(resp.Header, body, retried) {
+			return &cachedResponse{status: resp.StatusCode, header: resp.Header, body: body}, nil
+		}
+	}
+}
@@ -0,0 +1,400 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Candle intervals BloFin offers with a fixed length
+var barDurations = map[string]time.Duration{
+	"1m": time.Minute, "3m": 3 * time.Minute, "5m": 5 * time.Minute, "15m": 15 * time.Minute,
+	"30m": 30 * time.Minute, "1H": time.Hour, "2H": 2 * time.Hour, "4H": 4 * time.Hour,
+	"6H": 6 * time.Hour, "8H": 8 * time.Hour, "12H": 12 * time.Hour, "1D": 24 * time.Hour,
+	"3D": 72 * time.Hour, "1W": 7 * 24 * time.Hour,
+}
+
+const (
+	CANDLE_PAGE_LIMIT         = 300
+	DEFAULT_BACKFILL_PACING   = 250 * time.Millisecond
+	DEFAULT_BACKFILL_LOOKBACK = 30 * 24 * time.Hour
+	MAX_LOCAL_CANDLES         = 1440
+)
+
+// A candle row exactly as BloFin returns it:
+// [ts, open, high, low, close, vol, volCurrency, volCurrencyQuote, confirm]
+type candle []string
+
+func (c candle) ts() int64 {
+	if len(c) == 0 {
+		return 0
+	}
+	ts, _ := strconv.ParseInt(c[0], 10, 64)
+	return ts
+}
+
+// Candle history on disk, one JSON file per instrument and bar, rows in
+// ascending time order
+type candleStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func newCandleStore(dataDir string) (*candleStore, error) {
+	dir := filepath.Join(dataDir, "candles")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &candleStore{dir: dir}, nil
+}
+
+func (s *candleStore) path(instID, bar string) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s_%s.json", instID, bar))
+}
+
+func (s *candleStore) load(instID, bar string) ([]candle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadLocked(instID, bar)
+}
+
+func (s *candleStore) loadLocked(instID, bar string) ([]candle, error) {
+	data, err := os.ReadFile(s.path(instID, bar))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rows []candle
+	err = json.Unmarshal(data, &rows)
+	return rows, err
+}
+
+// Merge rows into the stored history; newer copies of a timestamp win so
+// the still-forming candle gets replaced once it is confirmed
+func (s *candleStore) merge(instID, bar string, rows []candle) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, err := s.loadLocked(instID, bar)
+	if err != nil {
+		return 0, err
+	}
+	byTS := make(map[int64]candle, len(existing)+len(rows))
+	for _, row := range existing {
+		byTS[row.ts()] = row
+	}
+	added := 0
+	for _, row := range rows {
+		if _, ok := byTS[row.ts()]; !ok {
+			added++
+		}
+		byTS[row.ts()] = row
+	}
+	merged := make([]candle, 0, len(byTS))
+	for _, row := range byTS {
+		merged = append(merged, row)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].ts() < merged[j].ts() })
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return 0, err
+	}
+	// Write then rename so readers never see a half-written file
+	tmp := s.path(instID, bar) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return 0, err
+	}
+	return added, os.Rename(tmp, s.path(instID, bar))
+}
+
+// An instrument/bar pair to keep backfilled
+type BackfillTarget struct {
+	InstID string `json:"instId"`
+	Bar    string `json:"bar"`
+}
+
+func (t BackfillTarget) String() string {
+	return t.InstID + ":" + t.Bar
+}
+
+// Parse "BTC-USDT:1H,ETH-USDT:1m"
+func parseBackfillTargets(items []string) ([]BackfillTarget, error) {
+	var targets []BackfillTarget
+	for _, item := range items {
+		instID, bar, ok := strings.Cut(item, ":")
+		if !ok || instID == "" {
+			return nil, fmt.Errorf("target %q must look like INST-ID:BAR", item)
+		}
+		if _, ok := barDurations[bar]; !ok {
+			return nil, fmt.Errorf("target %q has unsupported bar %q", item, bar)
+		}
+		targets = append(targets, BackfillTarget{InstID: instID, Bar: bar})
+	}
+	return targets, nil
+}
+
+// Progress of the most recent backfill run
+type backfillStatus struct {
+	Running    bool           `json:"running"`
+	StartedAt  string         `json:"startedAt,omitempty"`
+	FinishedAt string         `json:"finishedAt,omitempty"`
+	Lookback   string         `json:"lookback,omitempty"`
+	Added      map[string]int `json:"added"`
+	Errors     []string       `json:"errors,omitempty"`
+}
+
+// Downloads candle history page by page into the candle store, pacing
+// requests to stay well inside BloFin's public rate limits
+type candleBackfiller struct {
+	client   *blofinClient
+	store    *candleStore
+	targets  []BackfillTarget
+	lookback time.Duration
+	pacing   time.Duration
+
+	mu     sync.Mutex
+	status backfillStatus
+}
+
+// Start a run in the background; returns false if one is already running
+func (b *candleBackfiller) start(targets []BackfillTarget, lookback time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.status.Running {
+		return false
+	}
+	b.status = backfillStatus{
+		Running:   true,
+		StartedAt: time.Now().UTC().Format(time.RFC3339),
+		Lookback:  lookback.String(),
+		Added:     map[string]int{},
+	}
+	go b.run(targets, lookback)
+	return true
+}
+
+func (b *candleBackfiller) run(targets []BackfillTarget, lookback time.Duration) {
+	log.Printf("🕯️ Backfill started for %d targets (lookback %s)", len(targets), lookback)
+	since := time.Now().Add(-lookback).UnixMilli()
+	for _, target := range targets {
+		added, err := b.backfill(target, since)
+		b.mu.Lock()
+		b.status.Added[target.String()] = added
+		if err != nil {
+			b.status.Errors = append(b.status.Errors, fmt.Sprintf("%s: %v", target, err))
+		}
+		b.mu.Unlock()
+		if err != nil {
+			log.Printf("❌ Backfill %s failed after %d candles: %v", target, added, err)
+		} else {
+			log.Printf("🕯️ Backfill %s added %d candles", target, added)
+		}
+	}
+	b.mu.Lock()
+	b.status.Running = false
+	b.status.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+	b.mu.Unlock()
+}
+
+// Fill in anything newer than the stored history, then extend it back to
+// the lookback horizon
+func (b *candleBackfiller) backfill(target BackfillTarget, since int64) (int, error) {
+	stored, err := b.store.load(target.InstID, target.Bar)
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+
+	// Newest pages first, stopping once they overlap what we already have
+	var newest int64
+	if len(stored) > 0 {
+		newest = stored[len(stored)-1].ts()
+	}
+	added, oldestFetched, err := b.page(target, 0, func(oldest int64) bool {
+		return oldest <= newest || oldest <= since
+	})
+	total += added
+	if err != nil {
+		return total, err
+	}
+
+	// Then extend backwards from the oldest candle on record
+	oldest := oldestFetched
+	if len(stored) > 0 && (oldest == 0 || stored[0].ts() < oldest) {
+		oldest = stored[0].ts()
+	}
+	if oldest > since {
+		added, _, err = b.page(target, oldest, func(o int64) bool { return o <= since })
+		total += added
+	}
+	return total, err
+}
+
+// Page backwards from `after` (0 = now) until done reports true or the
+// history runs out. Returns candles added and the oldest timestamp seen.
+func (b *candleBackfiller) page(target BackfillTarget, after int64, done func(oldest int64) bool) (int, int64, error) {
+	added, oldest := 0, int64(0)
+	for {
+		query := url.Values{"instId": {target.InstID}, "bar": {target.Bar}, "limit": {strconv.Itoa(CANDLE_PAGE_LIMIT)}}
+		if after > 0 {
+			query.Set("after", strconv.FormatInt(after, 10))
+		}
+
+		var rows []candle
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := b.client.get(ctx, "/api/v1/market/candles", query, &rows)
+		cancel()
+		if isRateLimited(err) {
+			log.Printf("⏳ Backfill %s rate limited, backing off", target)
+			time.Sleep(10 * b.pacing)
+			continue
+		}
+		if err != nil {
+			return added, oldest, err
+		}
+		if len(rows) == 0 {
+			return added, oldest, nil
+		}
+
+		n, err := b.store.merge(target.InstID, target.Bar, rows)
+		added += n
+		if err != nil {
+			return added, oldest, err
+		}
+		pageOldest := rows[0].ts()
+		for _, row := range rows {
+			if ts := row.ts(); ts < pageOldest {
+				pageOldest = ts
+			}
+		}
+		if oldest == 0 || pageOldest < oldest {
+			oldest = pageOldest
+		}
+		if done(pageOldest) || pageOldest >= after && after > 0 {
+			return added, oldest, nil
+		}
+		after = pageOldest
+		time.Sleep(b.pacing)
+	}
+}
+
+func (b *candleBackfiller) currentStatus() backfillStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := b.status
+	status.Added = make(map[string]int, len(b.status.Added))
+	for k, v := range b.status.Added {
+		status.Added[k] = v
+	}
+	status.Errors = append([]string(nil), b.status.Errors...)
+	return status
+}
+
+// GET reports the last run; POST starts one, optionally overriding the
+// configured targets and lookback with {"targets":[...],"lookback":"720h"}
+func (b *candleBackfiller) handleAdmin(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, b.currentStatus())
+	case http.MethodPost:
+		var req struct {
+			Targets  []BackfillTarget `json:"targets"`
+			Lookback string           `json:"lookback"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON: " + err.Error()})
+				return
+			}
+		}
+		targets, lookback := b.targets, b.lookback
+		if len(req.Targets) > 0 {
+			targets = req.Targets
+			for _, t := range targets {
+				if _, ok := barDurations[t.Bar]; !ok || t.InstID == "" {
+					writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid target %s", t)})
+					return
+				}
+			}
+		}
+		if req.Lookback != "" {
+			d, err := time.ParseDuration(req.Lookback)
+			if err != nil || d <= 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid lookback, expected a duration such as 720h"})
+				return
+			}
+			lookback = d
+		}
+		if len(targets) == 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "No targets configured, set BACKFILL_TARGETS or pass targets"})
+			return
+		}
+		if !b.start(targets, lookback) {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "A backfill is already running"})
+			return
+		}
+		writeJSON(w, http.StatusAccepted, b.currentStatus())
+	default:
+		methodNotAllowed(w, "GET", "POST")
+	}
+}
+
+// Serve stored candles in BloFin's response shape (newest first) so charting
+// code can switch between /api/v1/market/candles and /local/candles
+func (s *candleStore) handleLocal(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	instID, bar := query.Get("instId"), query.Get("bar")
+	if bar == "" {
+		bar = "1m"
+	}
+	if instID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "instId is required"})
+		return
+	}
+	if _, ok := barDurations[bar]; !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Unsupported bar %q", bar)})
+		return
+	}
+	limit := MAX_LOCAL_CANDLES
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = min(n, MAX_LOCAL_CANDLES)
+	}
+	// Same semantics as BloFin: after = older than, before = newer than
+	after, _ := strconv.ParseInt(query.Get("after"), 10, 64)
+	before, _ := strconv.ParseInt(query.Get("before"), 10, 64)
+
+	rows, err := s.load(instID, bar)
+	if err != nil {
+		log.Printf("❌ Failed to read candles for %s %s: %v", instID, bar, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to read local candles"})
+		return
+	}
+	data := []candle{}
+	for i := len(rows) - 1; i >= 0 && len(data) < limit; i-- {
+		ts := rows[i].ts()
+		if (after > 0 && ts >= after) || (before > 0 && ts <= before) {
+			continue
+		}
+		data = append(data, rows[i])
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"code": "0", "msg": "", "data": data})
+}
@@ -0,0 +1,147 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Fault kinds that can be injected
+const (
+	FAULT_LATENCY = "latency"
+	FAULT_ERROR   = "error"
+	FAULT_RESET   = "reset"
+)
+
+// Fault injection settings, adjustable at runtime through /admin/chaos
+type ChaosSettings struct {
+	Percent       float64  `json:"percent"`       // share of /api requests affected, 0-100
+	Faults        []string `json:"faults"`        // latency, error and/or reset
+	MaxLatencyMs  int      `json:"maxLatencyMs"`  // upper bound for injected latency
+	ErrorStatuses []int    `json:"errorStatuses"` // statuses returned by the error fault
+}
+
+type chaosMonkey struct {
+	mu       sync.RWMutex
+	settings ChaosSettings
+}
+
+func newChaosMonkey(settings ChaosSettings) *chaosMonkey {
+	return &chaosMonkey{settings: settings}
+}
+
+func (c *chaosMonkey) current() ChaosSettings {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.settings
+}
+
+func (s ChaosSettings) validate() error {
+	if s.Percent < 0 || s.Percent > 100 {
+		return fmt.Errorf("percent must be between 0 and 100")
+	}
+	for _, fault := range s.Faults {
+		if fault != FAULT_LATENCY && fault != FAULT_ERROR && fault != FAULT_RESET {
+			return fmt.Errorf("unknown fault %q", fault)
+		}
+	}
+	if s.MaxLatencyMs < 0 {
+		return fmt.Errorf("maxLatencyMs must not be negative")
+	}
+	for _, status := range s.ErrorStatuses {
+		if status < 400 || status > 599 {
+			return fmt.Errorf("error status %d is not a 4xx/5xx code", status)
+		}
+	}
+	return nil
+}
+
+// Possibly inject a fault. Returns true when the request has been answered
+// (or its connection dropped) and must not be forwarded.
+func (c *chaosMonkey) intercept(w http.ResponseWriter, r *http.Request) bool {
+	settings := c.current()
+	if settings.Percent <= 0 || len(settings.Faults) == 0 || rand.Float64()*100 >= settings.Percent {
+		return false
+	}
+
+	switch fault := settings.Faults[rand.Intn(len(settings.Faults))]; fault {
+	case FAULT_LATENCY:
+		if settings.MaxLatencyMs <= 0 {
+			return false
+		}
+		delay := time.Duration(rand.Intn(settings.MaxLatencyMs)+1) * time.Millisecond
+		log.Printf("🐒 Chaos: delaying %s %s by %s", r.Method, r.URL.Path, delay)
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+		}
+		return false
+
+	case FAULT_ERROR:
+		status := http.StatusBadGateway
+		if len(settings.ErrorStatuses) > 0 {
+			status = settings.ErrorStatuses[rand.Intn(len(settings.ErrorStatuses))]
+		}
+		log.Printf("🐒 Chaos: failing %s %s with %d", r.Method, r.URL.Path, status)
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "1")
+		}
+		writeJSON(w, status, map[string]string{
+			"code": strconv.Itoa(status),
+			"msg":  "Injected fault: " + http.StatusText(status),
+		})
+		return true
+
+	case FAULT_RESET:
+		log.Printf("🐒 Chaos: resetting connection for %s %s", r.Method, r.URL.Path)
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			return false
+		}
+		conn, _, err := hijacker.Hijack()
+		if err != nil {
+			return false
+		}
+		// Linger 0 makes Close send RST instead of FIN
+		if tcp, ok := conn.(*net.TCPConn); ok {
+			tcp.SetLinger(0)
+		}
+		conn.Close()
+		return true
+	}
+	return false
+}
+
+// GET returns the current settings; PUT/POST merges the JSON body into them
+func (c *chaosMonkey) handleAdmin(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, c.current())
+	case http.MethodPut, http.MethodPost:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		// Decoding reuses slice storage, so give it copies readers don't share
+		updated := c.settings
+		updated.Faults = append([]string(nil), updated.Faults...)
+		updated.ErrorStatuses = append([]int(nil), updated.ErrorStatuses...)
+		if err := json.NewDecoder(r.Body).Decode(&updated); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON: " + err.Error()})
+			return
+		}
+		if err := updated.validate(); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		c.settings = updated
+		log.Printf("🐒 Chaos settings updated: %g%% of requests, faults %v", updated.Percent, updated.Faults)
+		writeJSON(w, http.StatusOK, updated)
+	default:
+		methodNotAllowed(w, "GET", "PUT", "POST")
+	}
+}
@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Response header listing the clientOrderId of each order sent, in order
+const CLIENT_ORDER_ID_HEADER = "X-Client-Order-Id"
+
+// Generated IDs are a prefix plus a 26 character ULID, within BloFin's 32
+const MAX_CLIENT_ORDER_PREFIX = MAX_CLIENT_ORDER_ID - 26
+
+const crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// Millisecond timestamp followed by 80 random bits, so IDs sort by
+// creation time
+func newULID(now time.Time) string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(now.UnixMilli())<<16)
+	rand.Read(b[6:])
+	n := new(big.Int).SetBytes(b[:])
+	mask := big.NewInt(31)
+	out := make([]byte, 26)
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = crockfordBase32[new(big.Int).And(n, mask).Int64()]
+		n.Rsh(n, 5)
+	}
+	return string(out)
+}
+
+// Give every order in body without a clientOrderId a generated one.
+// Bodies that aren't valid JSON are passed through for BloFin to reject.
+func fillClientOrderIDs(prefix string, body []byte) []byte {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var parsed interface{}
+	if err := dec.Decode(&parsed); err != nil {
+		return body
+	}
+	orders := []interface{}{parsed}
+	if batch, ok := parsed.([]interface{}); ok {
+		orders = batch
+	}
+	now := time.Now()
+	filled := false
+	for _, item := range orders {
+		order, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if id, _ := order["clientOrderId"].(string); id == "" {
+			order["clientOrderId"] = prefix + newULID(now)
+			filled = true
+		}
+	}
+	if !filled {
+		return body
+	}
+	out, err := json.Marshal(parsed)
+	if err != nil {
+		log.Printf("❌ Failed to encode order with client order ID: %v", err)
+		return body
+	}
+	return out
+}
+
+// Report the client order IDs of the orders about to be sent
+func setClientOrderIDHeader(w http.ResponseWriter, path string, body []byte) {
+	orders, err := parseOrders(path, body)
+	if err != nil {
+		return
+	}
+	ids := make([]string, 0, len(orders))
+	for _, order := range orders {
+		id, _ := order["clientOrderId"].(string)
+		ids = append(ids, id)
+	}
+	w.Header().Set(CLIENT_ORDER_ID_HEADER, strings.Join(ids, ", "))
+}
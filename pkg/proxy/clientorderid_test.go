@@ -0,0 +1,41 @@
+package proxy
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestTranslatedOrdersGetClientOrderIDs(t *testing.T) {
+	p := newTestProxy(t, "")
+	s := p.srv
+	s.cfg.AutoClientOrderID = true
+	s.cfg.ClientOrderPrefix = "px"
+	alice := s.tenants.list[0]
+	ctx := context.Background()
+
+	idOf := func(result interface{}, err error) string {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, _ := result.(map[string]interface{})["clientOrderId"].(string)
+		return id
+	}
+
+	if id := idOf(s.unifiedCreateOrder(ctx, unifiedArgs{"symbol": "BTC/USDT:USDT", "type": "market", "side": "buy", "amount": "1"}, alice)); !strings.HasPrefix(id, "px") || len(id) != 28 {
+		t.Errorf("unified createOrder clientOrderId = %q, want px and a ULID", id)
+	}
+	if id := idOf(s.unifiedCreateOrder(ctx, unifiedArgs{"symbol": "BTC/USDT:USDT", "type": "market", "side": "buy", "amount": "1", "params": map[string]interface{}{"clientOrderId": "mine"}}, alice)); id != "mine" {
+		t.Errorf("unified createOrder replaced the client's ID with %q", id)
+	}
+	params := url.Values{"symbol": {"BTCUSDT"}, "side": {"BUY"}, "type": {"MARKET"}, "quantity": {"0.001"}}
+	if id := idOf(s.binanceNewOrder(ctx, params, alice)); !strings.HasPrefix(id, "px") || len(id) != 28 {
+		t.Errorf("Binance order clientOrderId = %q, want px and a ULID", id)
+	}
+	params.Set("newClientOrderId", "theirs")
+	if id := idOf(s.binanceNewOrder(ctx, params, alice)); id != "theirs" {
+		t.Errorf("Binance order replaced the client's ID with %q", id)
+	}
+}
@@ -0,0 +1,611 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joshthetrader/blofin-proxy/pkg/ratelimit"
+)
+
+// Operating modes selected with MODE
+const (
+	MODE_PROXY  = "proxy"
+	MODE_MOCK   = "mock"
+	MODE_RECORD = "record"
+	MODE_REPLAY = "replay"
+)
+
+const (
+	DEFAULT_CASSETTE_DIR = "cassettes"
+	DEFAULT_DATA_DIR     = "data"
+	DEFAULT_ENV_FILE     = ".env" // read at startup when present, see ENV_FILE
+)
+
+// Runtime configuration. LoadConfig reads it from environment variables;
+// embedders can start from DefaultConfig and fill it in directly.
+type Config struct {
+	Port                 string
+	Mode                 string // proxy, mock, record or replay
+	CassetteDir          string
+	ValidateRequests     bool
+	StrictSymbols        bool   // forward instIds exactly as sent
+	OrderPrecision       string // off, reject or round
+	MinNotional          string // in quote currency; empty for no limit
+	MaxNotional          string
+	DailyOrderLimit      int    // orders per sender per UTC day, 0 for no limit
+	DailyNotional        string // order value per sender per UTC day
+	DailyLossLimit       string // trading loss per tenant per UTC day that halts trading
+	AdminToken           string
+	AdminPort            string // serve /admin/, /metrics, /local/, /export/ and /docs here instead
+	AdminLocalOnly       bool
+	Chaos                ChaosSettings
+	Maintenance          MaintenanceSettings
+	ShadowUpstream       string
+	ShadowPercent        float64
+	ShadowMethods        []string
+	ShadowIgnoreFields   []string
+	InstrumentsTTL       time.Duration
+	DataDir              string
+	BackfillTargets      []BackfillTarget
+	BackfillLookback     time.Duration
+	BackfillPacing       time.Duration
+	Schedule             []string // name=spec entries
+	TickerPollInterval   time.Duration
+	FundingInterval      time.Duration
+	MarkPriceInterval    time.Duration
+	TelegramBotToken     string
+	TenantsFile          string
+	OrderTemplatesFile   string // named orders webhook alerts can refer to
+	UnifiedAPI           bool
+	BinanceAPI           bool
+	GraphQLAPI           bool
+	UpstreamTimeout      time.Duration
+	MaxClientTimeout     time.Duration // cap on X-Proxy-Timeout-Ms
+	UpstreamHosts        []string      // BloFin base URLs to pick from by latency
+	ProbeInterval        time.Duration
+	Transport            TransportSettings
+	TLS                  TLSSettings
+	Server               ServerSettings
+	Breaker              BreakerSettings
+	SlowThreshold        time.Duration
+	Middleware           []string // enabled middleware, see standardChain
+	Hooks                []Hook   // set by embedders, not read from the environment
+	StaticHeaders        []StaticHeader
+	KeyStyles            []ResponseRule // camel or snake per path prefix
+	NumberStyles         []ResponseRule // whether to parse numeric strings, per path prefix
+	StringFields         []string       // fields kept as strings when parsing numbers
+	TimeStyles           []ResponseRule // rfc3339 or epoch per path prefix
+	TimestampFields      []string
+	BrokerID             string
+	BrokerTagging        []string // order fields to fill with BrokerID
+	AutoClientOrderID    bool     // generate missing clientOrderIds on tenant orders
+	ClientOrderPrefix    string
+	RateLimit            RateLimitSettings
+	LimitSaveInterval    time.Duration // 0 keeps rate limit and quota state in memory only
+	Cache                []CacheRule
+	CacheStale           time.Duration // how long past its TTL an entry may be served while revalidating
+	AccountCacheTTL      time.Duration // 0 disables caching tenants' account data
+	AccountCacheRoutes   []string
+	AffiliateCacheTTL    time.Duration // 0 disables caching tenants' affiliate data
+	BalanceWebhook       string        // enables the balance watcher
+	BalanceInterval      time.Duration
+	BalanceThreshold     string // smallest balance change reported, in the currency's units
+	OrderWatchInterval   time.Duration
+	OrderCallbackPrivate bool          // let order callbacks reach private and loopback addresses
+	DuplicateWindow      time.Duration // 0 disables duplicate order checks
+	WebhookMaxAge        time.Duration // how old a signed webhook may be
+	BatchPacing          time.Duration // between chunks of an oversized batch
+	PushRoutes           []PushRule    // endpoints that can be subscribed to at /sse/poll
+
+	loadErrs []error // environment values LoadConfig couldn't parse, reported by Validate
+}
+
+// Settings used when the matching environment variable is unset
+func DefaultConfig() Config {
+	return Config{
+		Port:        DEFAULT_PORT,
+		Mode:        MODE_PROXY,
+		CassetteDir: DEFAULT_CASSETTE_DIR,
+		Chaos: ChaosSettings{
+			Faults:        []string{FAULT_LATENCY, FAULT_ERROR, FAULT_RESET},
+			MaxLatencyMs:  2000,
+			ErrorStatuses: []int{429, 502},
+		},
+		Maintenance: MaintenanceSettings{
+			Message:           DEFAULT_MAINTENANCE_MESSAGE,
+			RetryAfterSeconds: DEFAULT_MAINTENANCE_RETRY_AFTER,
+		},
+		ShadowPercent: 100,
+		// Only idempotent reads by default; mirroring orders would place them twice
+		ShadowMethods:      []string{"GET"},
+		ShadowIgnoreFields: strings.Split(DEFAULT_SHADOW_IGNORE, ","),
+		InstrumentsTTL:     DEFAULT_INSTRUMENTS_TTL,
+		DataDir:            DEFAULT_DATA_DIR,
+		OrderPrecision:     PRECISION_OFF,
+		StringFields:       strings.Split(DEFAULT_STRING_FIELDS, ","),
+		TimestampFields:    strings.Split(DEFAULT_TIMESTAMP_FIELDS, ","),
+		BackfillLookback:   DEFAULT_BACKFILL_LOOKBACK,
+		BackfillPacing:     DEFAULT_BACKFILL_PACING,
+		TickerPollInterval: DEFAULT_TICKER_POLL_INTERVAL,
+		FundingInterval:    DEFAULT_FUNDING_POLL_INTERVAL,
+		MarkPriceInterval:  DEFAULT_MARK_PRICE_POLL_INTERVAL,
+		UpstreamTimeout:    DEFAULT_UPSTREAM_TIMEOUT,
+		MaxClientTimeout:   DEFAULT_MAX_CLIENT_TIMEOUT,
+		ProbeInterval:      DEFAULT_PROBE_INTERVAL,
+		Transport: TransportSettings{
+			MaxIdleConns:        DEFAULT_MAX_IDLE_CONNS,
+			MaxIdleConnsPerHost: DEFAULT_MAX_IDLE_CONNS_PER_HOST,
+			IdleConnTimeout:     DEFAULT_IDLE_CONN_TIMEOUT,
+			TLSHandshakeTimeout: DEFAULT_TLS_HANDSHAKE_TIMEOUT,
+			HTTP2:               true,
+			DNSCacheTTL:         DEFAULT_DNS_CACHE_TTL,
+		},
+		TLS: TLSSettings{
+			ReloadInterval: DEFAULT_TLS_RELOAD_INTERVAL,
+			MinVersion:     "1.2",
+		},
+		Server: ServerSettings{
+			ReadHeaderTimeout: DEFAULT_READ_HEADER_TIMEOUT,
+			ReadTimeout:       DEFAULT_READ_TIMEOUT,
+			WriteTimeout:      DEFAULT_WRITE_TIMEOUT,
+			IdleTimeout:       DEFAULT_IDLE_TIMEOUT,
+			ShutdownTimeout:   DEFAULT_SHUTDOWN_TIMEOUT,
+			MaxHeaderBytes:    DEFAULT_MAX_HEADER_BYTES,
+		},
+		Breaker: BreakerSettings{
+			Failures: DEFAULT_BREAKER_FAILURES,
+			Cooldown: DEFAULT_BREAKER_COOLDOWN,
+		},
+		SlowThreshold: DEFAULT_SLOW_REQUEST,
+		Middleware:    []string{MIDDLEWARE_RECOVER, MIDDLEWARE_CORS},
+		RateLimit: RateLimitSettings{
+			BanWindow:   ratelimit.DEFAULT_BAN_WINDOW,
+			BanDuration: ratelimit.DEFAULT_BAN_DURATION,
+		},
+		LimitSaveInterval:  DEFAULT_LIMIT_SAVE_INTERVAL,
+		CacheStale:         DEFAULT_CACHE_STALE,
+		AccountCacheRoutes: DEFAULT_ACCOUNT_CACHE_ROUTES,
+		AffiliateCacheTTL:  DEFAULT_AFFILIATE_CACHE_TTL,
+		BalanceInterval:    DEFAULT_BALANCE_POLL_INTERVAL,
+		BalanceThreshold:   DEFAULT_BALANCE_THRESHOLD,
+		OrderWatchInterval: DEFAULT_ORDER_WATCH_INTERVAL,
+		DuplicateWindow:    DEFAULT_DUPLICATE_WINDOW,
+		WebhookMaxAge:      DEFAULT_WEBHOOK_MAX_AGE,
+		BatchPacing:        DEFAULT_BATCH_PACING,
+	}
+}
+
+// LoadConfig reads the environment. Values that don't parse keep their
+// default and are reported by Validate along with every other problem.
+func LoadConfig() Config {
+	def := DefaultConfig()
+	env := &envReader{}
+	cfg := Config{
+		Port:             env.string("PORT", def.Port),
+		Mode:             strings.ToLower(env.string("MODE", def.Mode)),
+		CassetteDir:      env.string("CASSETTE_DIR", def.CassetteDir),
+		ValidateRequests: env.bool("VALIDATE_REQUESTS", def.ValidateRequests),
+		StrictSymbols:    env.bool("STRICT_SYMBOLS", def.StrictSymbols),
+		OrderPrecision:   env.string("ORDER_PRECISION", def.OrderPrecision),
+		MinNotional:      os.Getenv("MIN_ORDER_NOTIONAL"),
+		MaxNotional:      os.Getenv("MAX_ORDER_NOTIONAL"),
+		DailyOrderLimit:  env.int("DAILY_ORDER_LIMIT", def.DailyOrderLimit),
+		DailyNotional:    os.Getenv("DAILY_NOTIONAL_LIMIT"),
+		DailyLossLimit:   os.Getenv("DAILY_LOSS_LIMIT"),
+		AdminToken:       os.Getenv("ADMIN_TOKEN"),
+		AdminPort:        os.Getenv("ADMIN_PORT"),
+		AdminLocalOnly:   env.bool("ADMIN_LOCAL_ONLY", def.AdminLocalOnly),
+		Chaos: ChaosSettings{
+			Percent:       env.float("CHAOS_PERCENT", def.Chaos.Percent),
+			Faults:        env.list("CHAOS_FAULTS", def.Chaos.Faults),
+			MaxLatencyMs:  env.int("CHAOS_MAX_LATENCY_MS", def.Chaos.MaxLatencyMs),
+			ErrorStatuses: env.ints("CHAOS_ERROR_STATUSES", def.Chaos.ErrorStatuses),
+		},
+		Maintenance: MaintenanceSettings{
+			Enabled:           env.bool("MAINTENANCE", def.Maintenance.Enabled),
+			Message:           env.string("MAINTENANCE_MESSAGE", def.Maintenance.Message),
+			RetryAfterSeconds: env.int("MAINTENANCE_RETRY_AFTER", def.Maintenance.RetryAfterSeconds),
+		},
+		ShadowUpstream:     os.Getenv("SHADOW_UPSTREAM"),
+		ShadowPercent:      env.float("SHADOW_PERCENT", def.ShadowPercent),
+		ShadowMethods:      env.list("SHADOW_METHODS", def.ShadowMethods),
+		ShadowIgnoreFields: env.list("SHADOW_IGNORE_FIELDS", def.ShadowIgnoreFields),
+		InstrumentsTTL:     env.duration("INSTRUMENTS_TTL", def.InstrumentsTTL),
+		DataDir:            env.string("DATA_DIR", def.DataDir),
+		BackfillLookback:   env.duration("BACKFILL_LOOKBACK", def.BackfillLookback),
+		BackfillPacing:     env.duration("BACKFILL_PACING", def.BackfillPacing),
+		TickerPollInterval: env.duration("TICKER_POLL_INTERVAL", def.TickerPollInterval),
+		FundingInterval:    env.duration("FUNDING_POLL_INTERVAL", def.FundingInterval),
+		MarkPriceInterval:  env.duration("MARK_PRICE_POLL_INTERVAL", def.MarkPriceInterval),
+		TelegramBotToken:   os.Getenv("TELEGRAM_BOT_TOKEN"),
+		TenantsFile:        os.Getenv("TENANTS_FILE"),
+		OrderTemplatesFile: os.Getenv("ORDER_TEMPLATES_FILE"),
+		UnifiedAPI:         env.bool("UNIFIED_API", def.UnifiedAPI),
+		BinanceAPI:         env.bool("BINANCE_API", def.BinanceAPI),
+		GraphQLAPI:         env.bool("GRAPHQL_API", def.GraphQLAPI),
+		UpstreamTimeout:    env.duration("UPSTREAM_TIMEOUT", def.UpstreamTimeout),
+		MaxClientTimeout:   env.duration("MAX_CLIENT_TIMEOUT", def.MaxClientTimeout),
+		UpstreamHosts:      env.list("BLOFIN_UPSTREAMS", def.UpstreamHosts),
+		ProbeInterval:      env.duration("UPSTREAM_PROBE_INTERVAL", def.ProbeInterval),
+		Transport: TransportSettings{
+			MaxIdleConns:        env.int("UPSTREAM_MAX_IDLE_CONNS", def.Transport.MaxIdleConns),
+			MaxIdleConnsPerHost: env.int("UPSTREAM_MAX_IDLE_CONNS_PER_HOST", def.Transport.MaxIdleConnsPerHost),
+			MaxConnsPerHost:     env.int("UPSTREAM_MAX_CONNS_PER_HOST", def.Transport.MaxConnsPerHost),
+			IdleConnTimeout:     env.duration("UPSTREAM_IDLE_CONN_TIMEOUT", def.Transport.IdleConnTimeout),
+			TLSHandshakeTimeout: env.duration("UPSTREAM_TLS_HANDSHAKE_TIMEOUT", def.Transport.TLSHandshakeTimeout),
+			HTTP2:               env.bool("UPSTREAM_HTTP2", def.Transport.HTTP2),
+			Proxy:               os.Getenv("UPSTREAM_PROXY"),
+			DNSCacheTTL:         env.duration("DNS_CACHE_TTL", def.Transport.DNSCacheTTL),
+		},
+		TLS: TLSSettings{
+			CertFile:       os.Getenv("TLS_CERT_FILE"),
+			KeyFile:        os.Getenv("TLS_KEY_FILE"),
+			ClientCAFile:   os.Getenv("TLS_CLIENT_CA_FILE"),
+			ReloadInterval: env.duration("TLS_RELOAD_INTERVAL", def.TLS.ReloadInterval),
+			MinVersion:     env.string("TLS_MIN_VERSION", def.TLS.MinVersion),
+			CipherSuites:   env.list("TLS_CIPHER_SUITES", def.TLS.CipherSuites),
+			Curves:         env.list("TLS_CURVES", def.TLS.Curves),
+		},
+		Server: ServerSettings{
+			ReadHeaderTimeout: env.duration("SERVER_READ_HEADER_TIMEOUT", def.Server.ReadHeaderTimeout),
+			ReadTimeout:       env.duration("SERVER_READ_TIMEOUT", def.Server.ReadTimeout),
+			WriteTimeout:      env.duration("SERVER_WRITE_TIMEOUT", def.Server.WriteTimeout),
+			IdleTimeout:       env.duration("SERVER_IDLE_TIMEOUT", def.Server.IdleTimeout),
+			ShutdownTimeout:   env.duration("SERVER_SHUTDOWN_TIMEOUT", def.Server.ShutdownTimeout),
+			MaxHeaderBytes:    env.int("SERVER_MAX_HEADER_BYTES", def.Server.MaxHeaderBytes),
+		},
+		Breaker: BreakerSettings{
+			Failures: env.int("BREAKER_FAILURES", def.Breaker.Failures),
+			Cooldown: env.duration("BREAKER_COOLDOWN", def.Breaker.Cooldown),
+		},
+		SlowThreshold: env.duration("SLOW_REQUEST_THRESHOLD", def.SlowThreshold),
+		Middleware:    env.list("MIDDLEWARE", def.Middleware),
+		BrokerID:      os.Getenv("BROKER_ID"),
+		BrokerTagging: env.list("BROKER_TAGGING", def.BrokerTagging),
+		RateLimit: RateLimitSettings{
+			PerIP:          env.float("RATE_LIMIT_IP", def.RateLimit.PerIP),
+			IPBurst:        env.int("RATE_LIMIT_IP_BURST", def.RateLimit.IPBurst),
+			PerOrigin:      env.float("RATE_LIMIT_ORIGIN", def.RateLimit.PerOrigin),
+			OriginBurst:    env.int("RATE_LIMIT_ORIGIN_BURST", def.RateLimit.OriginBurst),
+			TrustForwarded: env.bool("TRUST_FORWARDED_FOR", def.RateLimit.TrustForwarded),
+			BanAfter:       env.int("BAN_AFTER", def.RateLimit.BanAfter),
+			BanWindow:      env.duration("BAN_WINDOW", def.RateLimit.BanWindow),
+			BanDuration:    env.duration("BAN_DURATION", def.RateLimit.BanDuration),
+			MaxConcurrent:  env.int("MAX_CONCURRENT_PER_CLIENT", def.RateLimit.MaxConcurrent),
+		},
+		LimitSaveInterval:    env.duration("RATE_LIMIT_SAVE_INTERVAL", def.LimitSaveInterval),
+		CacheStale:           env.duration("CACHE_STALE", def.CacheStale),
+		AccountCacheTTL:      env.duration("ACCOUNT_CACHE_TTL", def.AccountCacheTTL),
+		AccountCacheRoutes:   env.list("ACCOUNT_CACHE_ROUTES", def.AccountCacheRoutes),
+		StringFields:         env.list("NUMBER_STRING_FIELDS", def.StringFields),
+		TimestampFields:      env.list("TIMESTAMP_FIELDS", def.TimestampFields),
+		AffiliateCacheTTL:    env.duration("AFFILIATE_CACHE_TTL", def.AffiliateCacheTTL),
+		BalanceWebhook:       os.Getenv("BALANCE_WEBHOOK"),
+		BalanceInterval:      env.duration("BALANCE_POLL_INTERVAL", def.BalanceInterval),
+		BalanceThreshold:     env.string("BALANCE_CHANGE_THRESHOLD", def.BalanceThreshold),
+		OrderWatchInterval:   env.duration("ORDER_WATCH_INTERVAL", def.OrderWatchInterval),
+		OrderCallbackPrivate: env.bool("ORDER_CALLBACK_ALLOW_PRIVATE", def.OrderCallbackPrivate),
+		AutoClientOrderID:    env.bool("AUTO_CLIENT_ORDER_ID", def.AutoClientOrderID),
+		ClientOrderPrefix:    env.string("CLIENT_ORDER_ID_PREFIX", def.ClientOrderPrefix),
+		DuplicateWindow:      env.duration("DUPLICATE_ORDER_WINDOW", def.DuplicateWindow),
+		WebhookMaxAge:        env.duration("WEBHOOK_MAX_AGE", def.WebhookMaxAge),
+		BatchPacing:          env.duration("BATCH_CHUNK_PACING", def.BatchPacing),
+	}
+	targets, err := parseBackfillTargets(env.list("BACKFILL_TARGETS", nil))
+	if err != nil {
+		env.fail("invalid BACKFILL_TARGETS: %v", err)
+	}
+	cfg.BackfillTargets = targets
+	for _, kind := range []struct {
+		env      string
+		override bool
+	}{{"DEFAULT_HEADERS", false}, {"OVERRIDE_HEADERS", true}} {
+		headers, err := parseStaticHeaders(env.entries(kind.env), kind.override)
+		if err != nil {
+			env.fail("invalid %s: %v", kind.env, err)
+		}
+		cfg.StaticHeaders = append(cfg.StaticHeaders, headers...)
+	}
+	keyStyles, err := parseResponseRules(env.entries("RESPONSE_KEY_STYLE"))
+	if err != nil {
+		env.fail("invalid RESPONSE_KEY_STYLE: %v", err)
+	}
+	cfg.KeyStyles = keyStyles
+	numberStyles, err := parseResponseRules(env.entries("RESPONSE_NUMBERS"))
+	if err != nil {
+		env.fail("invalid RESPONSE_NUMBERS: %v", err)
+	}
+	cfg.NumberStyles = numberStyles
+	timeStyles, err := parseResponseRules(env.entries("RESPONSE_TIMESTAMPS"))
+	if err != nil {
+		env.fail("invalid RESPONSE_TIMESTAMPS: %v", err)
+	}
+	cfg.TimeStyles = timeStyles
+	groups, err := ratelimit.ParseGroups(env.entries("RATE_LIMIT_GROUPS"))
+	if err != nil {
+		env.fail("invalid RATE_LIMIT_GROUPS: %v", err)
+	}
+	cfg.RateLimit.Groups = groups
+	rules, err := parseCacheRules(env.entries("CACHE_ROUTES"))
+	if err != nil {
+		env.fail("invalid CACHE_ROUTES: %v", err)
+	}
+	cfg.Cache = rules
+	push, err := parsePushRules(env.entries("PUSH_ROUTES"))
+	if err != nil {
+		env.fail("invalid PUSH_ROUTES: %v", err)
+	}
+	cfg.PushRoutes = push
+	cfg.Schedule = env.entries("SCHEDULE")
+	cfg.loadErrs = env.errs
+	return cfg
+}
+
+// Collects the environment values LoadConfig couldn't parse
+type envReader struct {
+	errs []error
+}
+
+func (env *envReader) fail(format string, args ...interface{}) {
+	env.errs = append(env.errs, fmt.Errorf(format, args...))
+}
+
+func (env *envReader) string(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func (env *envReader) bool(name string, fallback bool) bool {
+	switch strings.ToLower(os.Getenv(name)) {
+	case "1", "true", "yes", "on":
+		return true
+	case "0", "false", "no", "off":
+		return false
+	}
+	return fallback
+}
+
+func (env *envReader) int(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		env.fail("invalid %s %q: expected an integer", name, value)
+		return fallback
+	}
+	return n
+}
+
+func (env *envReader) float(name string, fallback float64) float64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		env.fail("invalid %s %q: expected a number", name, value)
+		return fallback
+	}
+	return f
+}
+
+// Comma-separated list, with surrounding whitespace and empty items dropped
+func (env *envReader) list(name string, fallback []string) []string {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Semicolon-separated entries, for values that contain spaces and commas
+// themselves (cron specs, header values)
+func (env *envReader) entries(name string) []string {
+	var entries []string
+	for _, entry := range strings.Split(os.Getenv(name), ";") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func (env *envReader) ints(name string, fallback []int) []int {
+	items := env.list(name, nil)
+	if items == nil {
+		return fallback
+	}
+	numbers := make([]int, 0, len(items))
+	for _, item := range items {
+		n, err := strconv.Atoi(item)
+		if err != nil {
+			env.fail("invalid %s %q: expected comma-separated integers", name, os.Getenv(name))
+			return fallback
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers
+}
+
+// Go duration syntax, e.g. 500ms, 30s, 10m
+func (env *envReader) duration(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		env.fail("invalid %s %q: expected a duration such as 30s or 10m", name, value)
+		return fallback
+	}
+	return d
+}
+
+// Validate checks cfg without starting anything or touching DATA_DIR, and
+// reports every problem found rather than just the first. New runs it too;
+// `blofin-proxy -validate` runs only this.
+func (cfg Config) Validate() error {
+	errs := append([]error(nil), cfg.loadErrs...)
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	switch cfg.Mode {
+	case MODE_PROXY, MODE_MOCK, MODE_RECORD, MODE_REPLAY:
+	default:
+		fail("unknown mode %q (expected %s, %s, %s or %s)", cfg.Mode, MODE_PROXY, MODE_MOCK, MODE_RECORD, MODE_REPLAY)
+	}
+	switch cfg.OrderPrecision {
+	case PRECISION_OFF, PRECISION_REJECT, PRECISION_ROUND:
+	default:
+		fail("unknown order precision policy %q (expected %s, %s or %s)", cfg.OrderPrecision, PRECISION_OFF, PRECISION_REJECT, PRECISION_ROUND)
+	}
+	for _, limit := range [][2]string{{"min", cfg.MinNotional}, {"max", cfg.MaxNotional}, {"daily", cfg.DailyNotional}} {
+		if value, ok := parseDecimal(limit[1]); limit[1] != "" && (!ok || value.Sign() <= 0) {
+			fail("invalid %s order notional %q: must be a positive decimal", limit[0], limit[1])
+		}
+	}
+	if value, ok := parseDecimal(cfg.DailyLossLimit); cfg.DailyLossLimit != "" && (!ok || value.Sign() <= 0) {
+		fail("invalid daily loss limit %q: must be a positive decimal", cfg.DailyLossLimit)
+	}
+	if cfg.DailyOrderLimit < 0 {
+		fail("invalid daily order limit: must not be negative")
+	}
+	if err := cfg.Chaos.validate(); err != nil {
+		fail("invalid chaos settings: %v", err)
+	}
+	if cfg.AdminPort != "" && cfg.AdminPort == cfg.Port {
+		fail("ADMIN_PORT must differ from PORT")
+	}
+	if cfg.AdminLocalOnly && cfg.AdminPort == "" {
+		fail("ADMIN_LOCAL_ONLY needs ADMIN_PORT")
+	}
+	if err := cfg.Maintenance.validate(); err != nil {
+		fail("invalid maintenance settings: %v", err)
+	}
+	if err := cfg.Transport.validate(); err != nil {
+		fail("invalid transport settings: %v", err)
+	}
+	if err := cfg.TLS.validate(); err != nil {
+		fail("invalid TLS settings: %v", err)
+	}
+	if err := cfg.Server.validate(); err != nil {
+		fail("invalid server settings: %v", err)
+	}
+	if cfg.Breaker.Failures < 0 || cfg.Breaker.Cooldown < 0 {
+		fail("invalid breaker settings: values must not be negative")
+	}
+	if err := cfg.RateLimit.Validate(); err != nil {
+		fail("invalid rate limit settings: %v", err)
+	}
+	if cfg.CacheStale < 0 || cfg.AccountCacheTTL < 0 || cfg.AffiliateCacheTTL < 0 {
+		fail("invalid cache settings: durations must not be negative")
+	}
+	if cfg.MaxClientTimeout <= 0 {
+		fail("invalid max client timeout: must be positive")
+	}
+	if cfg.LimitSaveInterval < 0 {
+		fail("invalid rate limit save interval: must not be negative")
+	}
+	if cfg.TickerPollInterval <= 0 {
+		fail("invalid ticker poll interval: must be positive")
+	}
+	if cfg.FundingInterval <= 0 {
+		fail("invalid funding poll interval: must be positive")
+	}
+	if cfg.MarkPriceInterval <= 0 {
+		fail("invalid mark price poll interval: must be positive")
+	}
+	if len(cfg.ClientOrderPrefix) > MAX_CLIENT_ORDER_PREFIX {
+		fail("invalid client order ID prefix %q: at most %d characters", cfg.ClientOrderPrefix, MAX_CLIENT_ORDER_PREFIX)
+	}
+	for _, rule := range cfg.KeyStyles {
+		if rule.Value != KEYS_CAMEL && rule.Value != KEYS_SNAKE {
+			fail("invalid response key style %q for %s (expected %s or %s)", rule.Value, rule.Prefix, KEYS_CAMEL, KEYS_SNAKE)
+		}
+	}
+	for _, rule := range cfg.NumberStyles {
+		if _, err := strconv.ParseBool(rule.Value); err != nil {
+			fail("invalid response number setting %q for %s (expected true or false)", rule.Value, rule.Prefix)
+		}
+	}
+	for _, rule := range cfg.TimeStyles {
+		if rule.Value != TIMES_RFC3339 && rule.Value != TIMES_EPOCH {
+			fail("invalid response timestamp format %q for %s (expected %s or %s)", rule.Value, rule.Prefix, TIMES_RFC3339, TIMES_EPOCH)
+		}
+	}
+	if cfg.DuplicateWindow < 0 {
+		fail("invalid duplicate order window: must not be negative")
+	}
+	if cfg.WebhookMaxAge <= 0 {
+		fail("invalid webhook max age: must be positive")
+	}
+	if cfg.BatchPacing < 0 {
+		fail("invalid batch chunk pacing: must not be negative")
+	}
+	available := map[string]bool{}
+	for _, name := range standardChain().Names() {
+		available[name] = true
+	}
+	for _, name := range cfg.Middleware {
+		if !available[name] {
+			fail("unknown middleware %q (expected any of %v)", name, standardChain().Names())
+		}
+	}
+	if _, err := newHostSelector(cfg.UpstreamHosts, cfg.ProbeInterval, nil); err != nil {
+		fail("invalid BloFin hosts: %v", err)
+	}
+	if _, err := newBrokerTagger(cfg.BrokerID, cfg.BrokerTagging); err != nil {
+		fail("invalid broker tagging: %v", err)
+	}
+	if err := (&pushBridge{rules: cfg.PushRoutes}).validate(); err != nil {
+		fail("invalid push routes: %v", err)
+	}
+	if _, err := newScheduler(cfg.Schedule, (&server{}).scheduledJobs()); err != nil {
+		fail("invalid schedule: %v", err)
+	}
+	if cfg.ShadowUpstream != "" {
+		u, err := url.Parse(cfg.ShadowUpstream)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("invalid shadow upstream %q: must be an http(s) URL", cfg.ShadowUpstream)
+		}
+	}
+	tenants, err := loadTenants(cfg.TenantsFile)
+	if err != nil {
+		fail("failed to load tenants file: %v", err)
+	} else {
+		if cfg.BalanceWebhook != "" && len(tenants.list) == 0 {
+			fail("BALANCE_WEBHOOK needs tenants to watch, see TENANTS_FILE")
+		}
+		if len(tenants.list) > 0 && cfg.OrderWatchInterval <= 0 {
+			fail("invalid order watch interval: must be positive")
+		}
+		lossLimits := len(tenants.list) > 0 && cfg.DailyLossLimit != ""
+		for _, t := range tenants.list {
+			if t.DailyLossLimit != "" {
+				lossLimits = true
+			}
+		}
+		if (lossLimits || cfg.BalanceWebhook != "") && cfg.BalanceInterval <= 0 {
+			fail("invalid balance poll interval: must be positive when a daily loss limit or BALANCE_WEBHOOK is set")
+		}
+	}
+	if _, err := loadOrderTemplates(cfg.OrderTemplatesFile); err != nil {
+		fail("failed to load order templates file: %v", err)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateBalanceInterval(t *testing.T) {
+	tenants := filepath.Join(t.TempDir(), "tenants.json")
+	if err := os.WriteFile(tenants, []byte(`[{"name":"alice","token":"tok-a","apiKey":"k","secret":"s","passphrase":"p","dailyLossLimit":"50"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.TenantsFile = tenants
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("valid config refused: %v", err)
+	}
+	cfg.BalanceInterval = 0
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "balance poll interval") {
+		t.Fatalf("Validate with a loss limit and no poll interval = %v", err)
+	}
+	cfg.TenantsFile = ""
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("poll interval required without a loss limit: %v", err)
+	}
+}
+
+func TestValidatePollIntervals(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.TickerPollInterval = 0
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "ticker poll interval") {
+		t.Errorf("Validate with no ticker poll interval = %v", err)
+	}
+	cfg = DefaultConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.FundingInterval = -time.Minute
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "funding poll interval") {
+		t.Errorf("Validate with a negative funding poll interval = %v", err)
+	}
+	cfg = DefaultConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.MarkPriceInterval = 0
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "mark price poll interval") {
+		t.Errorf("Validate with no mark price poll interval = %v", err)
+	}
+}
+
+func TestLoadConfigReportsEveryBadValue(t *testing.T) {
+	t.Setenv("DAILY_ORDER_LIMIT", "lots")
+	t.Setenv("ORDER_WATCH_INTERVAL", "5")
+	t.Setenv("RATE_LIMIT_GROUPS", "/api/")
+	t.Setenv("MODE", "nope")
+	cfg := LoadConfig()
+	if cfg.DailyOrderLimit != DefaultConfig().DailyOrderLimit {
+		t.Errorf("DailyOrderLimit = %d, want the default", cfg.DailyOrderLimit)
+	}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate accepted unparseable values")
+	}
+	for _, want := range []string{"DAILY_ORDER_LIMIT", "ORDER_WATCH_INTERVAL", "RATE_LIMIT_GROUPS", "unknown mode"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() = %v, missing %s", err, want)
+		}
+	}
+}
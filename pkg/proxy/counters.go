@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"math/rand"
+	"sync/atomic"
+)
+
+// Shards per counter; concurrent increments mostly land on different
+// cache lines instead of fighting over one
+const COUNTER_SHARDS = 16
+
+// Route label for /api paths that aren't in the BloFin route table, so
+// arbitrary client paths can't blow up the metric's cardinality
+const OTHER_ROUTE = "other"
+
+type shardedCounter struct {
+	shards [COUNTER_SHARDS]struct {
+		n atomic.Uint64
+		_ [56]byte // pad to a cache line
+	}
+}
+
+func (c *shardedCounter) inc() {
+	// The top-level rand functions don't take a lock since Go 1.20
+	c.shards[rand.Intn(COUNTER_SHARDS)].n.Add(1)
+}
+
+func (c *shardedCounter) load() uint64 {
+	var total uint64
+	for i := range c.shards {
+		total += c.shards[i].n.Load()
+	}
+	return total
+}
+
+// Request counts per route. The route set is fixed before serving starts,
+// so lookups need no lock either.
+type requestCounters struct {
+	routes map[string]*shardedCounter
+}
+
+func newRequestCounters(metrics *metricsRegistry) *requestCounters {
+	c := &requestCounters{routes: map[string]*shardedCounter{OTHER_ROUTE: {}}}
+	for _, route := range blofinRoutes {
+		c.routes[route.Path] = &shardedCounter{}
+	}
+	metrics.registerFunc("blofin_proxy_requests_total", METRIC_COUNTER, "Requests received, by route", c.snapshot)
+	return c
+}
+
+// Counter for a local route; only call while setting up routes
+func (c *requestCounters) route(path string) *shardedCounter {
+	counter, ok := c.routes[path]
+	if !ok {
+		counter = &shardedCounter{}
+		c.routes[path] = counter
+	}
+	return counter
+}
+
+func (c *requestCounters) inc(path string) {
+	counter, ok := c.routes[path]
+	if !ok {
+		counter = c.routes[OTHER_ROUTE]
+	}
+	counter.inc()
+}
+
+func (c *requestCounters) snapshot() map[string]float64 {
+	values := map[string]float64{}
+	for path, counter := range c.routes {
+		if n := counter.load(); n > 0 {
+			values[labels("route", path)] = float64(n)
+		}
+	}
+	return values
+}
@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	TIMEOUT_HEADER             = "X-Proxy-Timeout-Ms"
+	DEFAULT_MAX_CLIENT_TIMEOUT = 2 * time.Minute
+)
+
+// How long the client gave the proxy to get BloFin's answer: its
+// X-Proxy-Timeout-Ms clamped to MAX_CLIENT_TIMEOUT, or 0 when it set none
+func (s *server) clientTimeout(r *http.Request) (time.Duration, bool) {
+	value := r.Header.Get(TIMEOUT_HEADER)
+	if value == "" {
+		return 0, true
+	}
+	ms, err := strconv.Atoi(value)
+	if err != nil || ms <= 0 {
+		return 0, false
+	}
+	return min(time.Duration(ms)*time.Millisecond, s.cfg.MaxClientTimeout), true
+}
+
+// Middleware putting the client's deadline on the request context, so
+// latency-sensitive calls fail fast while bulk downloads can wait longer
+// than UPSTREAM_TIMEOUT
+func (s *server) clientDeadline(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		timeout, ok := s.clientTimeout(r)
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": TIMEOUT_HEADER + " must be a positive number of milliseconds"})
+			return
+		}
+		if timeout == 0 {
+			next(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// Answer a request whose deadline passed before BloFin did
+func deadlineExceeded(w http.ResponseWriter) {
+	writeJSON(w, http.StatusGatewayTimeout, map[string]string{"error": "BloFin did not answer in time"})
+}
@@ -0,0 +1,43 @@
+package proxy
+
+import (
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Per-request body capture: an admin sending "X-Proxy-Debug: true" gets
+// the outbound request and BloFin's response logged in full, with secrets
+// redacted, to reproduce "BloFin rejects my order body" reports
+const (
+	DEBUG_HEADER   = "X-Proxy-Debug"
+	MAX_DEBUG_BODY = 64 << 10 // logged bytes per body
+)
+
+// Whether the request asks for, and is allowed, a debug capture
+func (s *server) debugRequested(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get(DEBUG_HEADER), "true") && s.isAdmin(r)
+}
+
+// Log the request as sent upstream and the response as received
+func logDebugCapture(r *http.Request, state *forwardState) {
+	headers := state.sent
+	if headers == nil {
+		headers = r.Header
+	}
+	log.Printf("🐞 Debug %s %s\n  request headers: %v\n  request body: %s",
+		r.Method, r.URL.RequestURI(), redactHeaders(headers), truncateDebug(redactBody(state.body)))
+	if state.resp == nil {
+		log.Printf("🐞 Debug %s %s: no response from BloFin", r.Method, r.URL.Path)
+		return
+	}
+	log.Printf("🐞 Debug %s %s -> %d\n  response headers: %v\n  response body: %s",
+		r.Method, r.URL.Path, state.resp.StatusCode, redactHeaders(state.resp.Header), truncateDebug(redactBody(state.recorded.Bytes())))
+}
+
+func truncateDebug(body string) string {
+	if len(body) > MAX_DEBUG_BODY {
+		return body[:MAX_DEBUG_BODY] + "... (truncated)"
+	}
+	return body
+}
@@ -0,0 +1,98 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Log output written while fn runs
+func captureLog(t *testing.T, fn func()) string {
+	t.Helper()
+	var mu sync.Mutex
+	var buf bytes.Buffer
+	previous := log.Writer()
+	defer log.SetOutput(previous)
+	log.SetOutput(writerFunc(func(p []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return buf.Write(p)
+	}))
+	fn()
+	mu.Lock()
+	defer mu.Unlock()
+	return buf.String()
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+func TestDebugCapture(t *testing.T) {
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Upstream", "seen")
+		io.WriteString(w, `{"code":"0","msg":"","data":`+string(body)+`}`)
+	})
+	p := newUpstreamProxy(t, upstream, `[{"name":"alice","token":"tok-a","apiKey":"key-a","secret":"s","passphrase":"phrase-a"}]`, func(cfg *Config) {
+		cfg.AdminToken = "adm"
+	})
+	send := func(admin, debug string) string {
+		t.Helper()
+		return captureLog(t, func() {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/account/set-leverage", strings.NewReader(`{"instId":"BTC-USDT","leverage":"5","marginMode":"cross","secret":"hunter2"}`))
+			req.Header.Set(TENANT_HEADER, "tok-a")
+			req.Header.Set("Authorization", "Bearer "+admin)
+			req.Header.Set(DEBUG_HEADER, debug)
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("request = %d %s", rec.Code, rec.Body)
+			}
+		})
+	}
+
+	out := send("adm", "TRUE")
+	for _, want := range []string{
+		"🐞 Debug POST /api/v1/account/set-leverage",
+		`"leverage":"5"`,
+		"Access-Sign:[" + REDACTED + "]",
+		"Access-Passphrase:[" + REDACTED + "]",
+		"-> 200",
+		"X-Upstream:[seen]",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("capture is missing %q:\n%s", want, out)
+		}
+	}
+	for _, secret := range []string{"hunter2", "phrase-a", "key-a"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("capture leaks %q:\n%s", secret, out)
+		}
+	}
+
+	// Only admins get captures
+	for _, admin := range []string{"", "wrong"} {
+		if out := send(admin, "true"); strings.Contains(out, "🐞") {
+			t.Errorf("capture for Authorization %q:\n%s", admin, out)
+		}
+	}
+	if out := send("adm", "false"); strings.Contains(out, "🐞") {
+		t.Errorf("capture without asking:\n%s", out)
+	}
+}
+
+func TestTruncateDebug(t *testing.T) {
+	if got := truncateDebug("short"); got != "short" {
+		t.Errorf("truncateDebug(short) = %q", got)
+	}
+	long := strings.Repeat("x", MAX_DEBUG_BODY+1)
+	if got := truncateDebug(long); len(got) != MAX_DEBUG_BODY+len("... (truncated)") || !strings.HasSuffix(got, "... (truncated)") {
+		t.Errorf("truncateDebug(long) has %d bytes", len(got))
+	}
+}
@@ -0,0 +1,178 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limits on what a single comparison reports and what the stats retain
+const (
+	MAX_DIFF_PATHS        = 10
+	MAX_TRACKED_PATHS     = 50
+	DEFAULT_SHADOW_IGNORE = "ts,timestamp,time,createTime,updateTime,fundingTime"
+)
+
+// Mismatch statistics for one route
+type routeDiffStats struct {
+	Compared         int64            `json:"compared"`
+	Mismatched       int64            `json:"mismatched"`
+	StatusMismatches int64            `json:"statusMismatches"`
+	MismatchRate     float64          `json:"mismatchRate"`
+	LastMismatchAt   string           `json:"lastMismatchAt,omitempty"`
+	Fields           map[string]int64 `json:"fields,omitempty"` // differing JSON paths and how often they differed
+}
+
+// Compares primary and shadow responses and keeps per-route mismatch rates
+type responseDiffer struct {
+	ignore map[string]bool
+
+	mu     sync.Mutex
+	routes map[string]*routeDiffStats
+}
+
+func newResponseDiffer(ignoreFields []string) *responseDiffer {
+	ignore := map[string]bool{}
+	for _, field := range ignoreFields {
+		ignore[field] = true
+	}
+	return &responseDiffer{ignore: ignore, routes: map[string]*routeDiffStats{}}
+}
+
+func (d *responseDiffer) compare(route string, primaryStatus int, primaryBody []byte, shadowStatus int, shadowBody []byte) {
+	var paths []string
+	statusMismatch := primaryStatus != shadowStatus
+	if !statusMismatch {
+		paths = d.diffBodies(primaryBody, shadowBody)
+	}
+	mismatch := statusMismatch || len(paths) > 0
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stats, ok := d.routes[route]
+	if !ok {
+		stats = &routeDiffStats{Fields: map[string]int64{}}
+		d.routes[route] = stats
+	}
+	stats.Compared++
+	if mismatch {
+		stats.Mismatched++
+		stats.LastMismatchAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	if statusMismatch {
+		stats.StatusMismatches++
+	}
+	for _, path := range paths {
+		if _, tracked := stats.Fields[path]; tracked || len(stats.Fields) < MAX_TRACKED_PATHS {
+			stats.Fields[path]++
+		}
+	}
+	stats.MismatchRate = float64(stats.Mismatched) / float64(stats.Compared)
+
+	if statusMismatch {
+		log.Printf("👥 Shadow: status mismatch for %s (primary %d, shadow %d)", route, primaryStatus, shadowStatus)
+	} else if len(paths) > 0 {
+		log.Printf("👥 Shadow: body mismatch for %s at %s", route, strings.Join(paths, ", "))
+	}
+}
+
+// JSON paths that differ between the two bodies, ignoring volatile fields.
+// Non-JSON bodies are compared byte for byte.
+func (d *responseDiffer) diffBodies(primary, shadow []byte) []string {
+	a, errA := decodeJSON(primary)
+	b, errB := decodeJSON(shadow)
+	if errA != nil || errB != nil {
+		if bytes.Equal(primary, shadow) {
+			return nil
+		}
+		return []string{"(body)"}
+	}
+	var paths []string
+	d.diffValues("", a, b, &paths)
+	return paths
+}
+
+func (d *responseDiffer) diffValues(path string, a, b interface{}, paths *[]string) {
+	if len(*paths) >= MAX_DIFF_PATHS {
+		return
+	}
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			*paths = append(*paths, displayPath(path))
+			return
+		}
+		keys := map[string]bool{}
+		for key := range av {
+			keys[key] = true
+		}
+		for key := range bv {
+			keys[key] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			if !d.ignore[key] {
+				sorted = append(sorted, key)
+			}
+		}
+		sort.Strings(sorted)
+		for _, key := range sorted {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			d.diffValues(child, av[key], bv[key], paths)
+		}
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			*paths = append(*paths, displayPath(path))
+			return
+		}
+		for i := range av {
+			d.diffValues(fmt.Sprintf("%s[%d]", path, i), av[i], bv[i], paths)
+		}
+	default:
+		if !reflect.DeepEqual(a, b) {
+			*paths = append(*paths, displayPath(path))
+		}
+	}
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
+
+func decodeJSON(body []byte) (interface{}, error) {
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	err := decoder.Decode(&doc)
+	return doc, err
+}
+
+// GET reports per-route mismatch rates; DELETE resets them
+func (d *responseDiffer) handleAdmin(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"routes": d.routes})
+	case http.MethodDelete:
+		d.routes = map[string]*routeDiffStats{}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"routes": d.routes})
+	default:
+		methodNotAllowed(w, "GET", "DELETE")
+	}
+}
@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDiffBodies(t *testing.T) {
+	d := newResponseDiffer(strings.Split(DEFAULT_SHADOW_IGNORE, ","))
+	tests := []struct {
+		name            string
+		primary, shadow string
+		want            []string
+	}{
+		{"equal", `{"code":"0","data":[{"last":"1"}]}`, `{"data":[{"last":"1"}],"code":"0"}`, nil},
+		{"ignored fields", `{"data":[{"last":"1","ts":"1"}]}`, `{"data":[{"last":"1","ts":"2"}]}`, nil},
+		{"changed values", `{"code":"0","data":[{"last":"1","bid":"1"}]}`, `{"code":"1","data":[{"last":"2","bid":"1"}]}`, []string{"code", "data[0].last"}},
+		{"missing field", `{"a":"1","b":"2"}`, `{"a":"1"}`, []string{"b"}},
+		{"array length", `{"data":[1,2]}`, `{"data":[1]}`, []string{"data"}},
+		{"different shapes", `{"data":{"a":1}}`, `{"data":[1]}`, []string{"data"}},
+		{"numbers keep their text", `{"n":1.0}`, `{"n":1}`, []string{"n"}},
+		{"root", `[1]`, `{"a":1}`, []string{"(root)"}},
+		{"plain text", `ok`, `not ok`, []string{"(body)"}},
+		{"same text", `ok`, `ok`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := d.diffBodies([]byte(tt.primary), []byte(tt.shadow))
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("diffBodies = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Reports stop at MAX_DIFF_PATHS
+	var a, b []string
+	for i := 0; i < MAX_DIFF_PATHS+5; i++ {
+		a = append(a, fmt.Sprintf(`"f%02d":1`, i))
+		b = append(b, fmt.Sprintf(`"f%02d":2`, i))
+	}
+	if got := d.diffBodies([]byte("{"+strings.Join(a, ",")+"}"), []byte("{"+strings.Join(b, ",")+"}")); len(got) != MAX_DIFF_PATHS {
+		t.Errorf("%d paths reported, want %d", len(got), MAX_DIFF_PATHS)
+	}
+}
+
+func TestDiffStats(t *testing.T) {
+	d := newResponseDiffer(nil)
+	d.compare("GET /a", 200, []byte(`{"x":1}`), 200, []byte(`{"x":1}`))
+	d.compare("GET /a", 200, []byte(`{"x":1}`), 200, []byte(`{"x":2}`))
+	d.compare("GET /a", 200, []byte(`{"x":1}`), 500, []byte(`{"x":2}`))
+	d.compare("GET /a", 200, []byte(`{"x":1}`), 200, []byte(`{"x":3}`))
+
+	get := func() map[string]routeDiffStats {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		d.handleAdmin(rec, httptest.NewRequest(http.MethodGet, "/admin/shadow", nil))
+		var resp struct {
+			Routes map[string]routeDiffStats `json:"routes"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.Routes
+	}
+	// A status mismatch doesn't compare the bodies
+	stats := get()["GET /a"]
+	if stats.Compared != 4 || stats.Mismatched != 3 || stats.StatusMismatches != 1 || stats.MismatchRate != 0.75 || stats.Fields["x"] != 2 || stats.LastMismatchAt == "" {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	rec := httptest.NewRecorder()
+	d.handleAdmin(rec, httptest.NewRequest(http.MethodDelete, "/admin/shadow", nil))
+	if routes := get(); len(routes) != 0 {
+		t.Errorf("routes after DELETE = %+v", routes)
+	}
+	rec = httptest.NewRecorder()
+	d.handleAdmin(rec, httptest.NewRequest(http.MethodPost, "/admin/shadow", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST = %d, want 405", rec.Code)
+	}
+}
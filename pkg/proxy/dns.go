@@ -0,0 +1,141 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DEFAULT_DNS_CACHE_TTL = 30 * time.Second
+
+// Caches upstream A/AAAA records so new connections don't wait on DNS.
+// Hosts passed to start are resolved up front and then refreshed in the
+// background, so lookups normally never block; other hosts are resolved
+// on first use and kept for the TTL.
+type dnsCache struct {
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+	ttl        time.Duration
+	metrics    *metricsRegistry
+
+	mu    sync.RWMutex
+	hosts map[string]*dnsEntry
+}
+
+type dnsEntry struct {
+	addrs      []string
+	resolvedAt time.Time
+}
+
+func newDNSCache(ttl time.Duration, metrics *metricsRegistry) *dnsCache {
+	metrics.register("blofin_dns_lookups_total", METRIC_COUNTER, "Upstream DNS lookups by result")
+	metrics.register("blofin_dns_changes_total", METRIC_COUNTER, "Times an upstream host resolved to a different address set")
+	metrics.register("blofin_dns_addresses", METRIC_GAUGE, "Addresses an upstream host currently resolves to")
+	metrics.register("blofin_dns_resolved_timestamp_seconds", METRIC_GAUGE, "Unix time of the last successful lookup")
+	return &dnsCache{
+		lookupHost: net.DefaultResolver.LookupHost,
+		ttl:        ttl,
+		metrics:    metrics,
+		hosts:      map[string]*dnsEntry{},
+	}
+}
+
+// Resolve hosts now and keep them fresh until the process exits
+func (c *dnsCache) start(hosts ...string) {
+	for _, host := range hosts {
+		if _, err := c.resolve(host); err != nil {
+			log.Printf("⚠️ Failed to pre-resolve %s: %v", host, err)
+		}
+	}
+	go func() {
+		// Refresh at half the TTL so entries never go stale between rounds
+		ticker := time.NewTicker(c.ttl / 2)
+		defer ticker.Stop()
+		for range ticker.C {
+			for _, host := range hosts {
+				if _, err := c.resolve(host); err != nil {
+					log.Printf("⚠️ Failed to re-resolve %s: %v", host, err)
+				}
+			}
+		}
+	}()
+}
+
+func (c *dnsCache) lookup(host string) ([]string, error) {
+	c.mu.RLock()
+	entry := c.hosts[host]
+	c.mu.RUnlock()
+	if entry != nil && time.Since(entry.resolvedAt) < c.ttl {
+		return entry.addrs, nil
+	}
+	addrs, err := c.resolve(host)
+	if err != nil && entry != nil {
+		// A stale answer beats failing the request outright
+		return entry.addrs, nil
+	}
+	return addrs, err
+}
+
+func (c *dnsCache) resolve(host string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addrs, err := c.lookupHost(ctx, host)
+	if err != nil {
+		c.metrics.add("blofin_dns_lookups_total", labels("host", host, "result", "error"), 1)
+		return nil, err
+	}
+	c.metrics.add("blofin_dns_lookups_total", labels("host", host, "result", "ok"), 1)
+	sorted := append([]string(nil), addrs...)
+	sort.Strings(sorted)
+
+	c.mu.Lock()
+	previous := c.hosts[host]
+	c.hosts[host] = &dnsEntry{addrs: addrs, resolvedAt: time.Now()}
+	c.mu.Unlock()
+
+	c.metrics.set("blofin_dns_resolved_timestamp_seconds", labels("host", host), float64(time.Now().Unix()))
+	if previous != nil {
+		old := append([]string(nil), previous.addrs...)
+		sort.Strings(old)
+		if strings.Join(old, ",") == strings.Join(sorted, ",") {
+			return addrs, nil
+		}
+		c.metrics.add("blofin_dns_changes_total", labels("host", host), 1)
+		log.Printf("🌐 %s now resolves to %s (was %s)", host, strings.Join(sorted, ", "), strings.Join(old, ", "))
+	}
+	c.metrics.clear("blofin_dns_addresses", labels("host", host)+",")
+	for _, addr := range sorted {
+		c.metrics.set("blofin_dns_addresses", labels("host", host, "addr", addr), 1)
+	}
+	return addrs, nil
+}
+
+// DialContext that connects to cached addresses in turn, falling back to
+// the dialer's own resolution if the cache has nothing
+func (c *dnsCache) dialContext(dialer *net.Dialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil || net.ParseIP(host) != nil {
+			return dialer.DialContext(ctx, network, address)
+		}
+		addrs, err := c.lookup(host)
+		if err != nil || len(addrs) == 0 {
+			return dialer.DialContext(ctx, network, address)
+		}
+		var lastErr error
+		for _, addr := range addrs {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addr, port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, lastErr
+	}
+}
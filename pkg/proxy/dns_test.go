@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A resolver answering with whatever addrs is set to
+type fakeResolver struct {
+	mu    sync.Mutex
+	addrs []string
+	err   error
+	calls int
+}
+
+func (f *fakeResolver) set(err error, addrs ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.addrs, f.err = addrs, err
+}
+
+func (f *fakeResolver) lookupHost(_ context.Context, host string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	return append([]string(nil), f.addrs...), f.err
+}
+
+func (f *fakeResolver) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func metricSnapshot(m *metricsRegistry, name string) map[string]float64 {
+	f, ok := m.families.Load(name)
+	if !ok {
+		return nil
+	}
+	return f.(*metricFamily).snapshot()
+}
+
+func TestDNSCacheChanges(t *testing.T) {
+	m := newMetricsRegistry()
+	c := newDNSCache(time.Hour, m)
+	resolver := &fakeResolver{}
+	c.lookupHost = resolver.lookupHost
+	host := labels("host", "api.example")
+
+	resolver.set(nil, "10.0.0.2", "10.0.0.1")
+	if addrs, err := c.lookup("api.example"); err != nil || len(addrs) != 2 {
+		t.Fatalf("lookup = %v, %v", addrs, err)
+	}
+	if got := metricSnapshot(m, "blofin_dns_addresses"); len(got) != 2 || got[labels("host", "api.example", "addr", "10.0.0.1")] != 1 {
+		t.Errorf("addresses = %v", got)
+	}
+	if got := metricSnapshot(m, "blofin_dns_resolved_timestamp_seconds")[host]; got == 0 {
+		t.Error("resolved timestamp not set")
+	}
+
+	// Cached within the TTL
+	c.lookup("api.example")
+	if n := resolver.count(); n != 1 {
+		t.Errorf("%d lookups within the TTL, want 1", n)
+	}
+
+	// The same addresses in another order aren't a change
+	resolver.set(nil, "10.0.0.1", "10.0.0.2")
+	c.resolve("api.example")
+	if got := metricSnapshot(m, "blofin_dns_changes_total")[host]; got != 0 {
+		t.Errorf("changes after a reordered answer = %v, want 0", got)
+	}
+
+	resolver.set(nil, "10.0.0.3")
+	c.resolve("api.example")
+	if got := metricSnapshot(m, "blofin_dns_changes_total")[host]; got != 1 {
+		t.Errorf("changes = %v, want 1", got)
+	}
+	if got := metricSnapshot(m, "blofin_dns_addresses"); len(got) != 1 || got[labels("host", "api.example", "addr", "10.0.0.3")] != 1 {
+		t.Errorf("addresses after the change = %v", got)
+	}
+
+	// A failed refresh keeps serving the stale answer
+	resolver.set(errors.New("no such host"))
+	c.mu.Lock()
+	c.hosts["api.example"].resolvedAt = time.Now().Add(-2 * time.Hour)
+	c.mu.Unlock()
+	if addrs, err := c.lookup("api.example"); err != nil || len(addrs) != 1 || addrs[0] != "10.0.0.3" {
+		t.Errorf("stale lookup = %v, %v", addrs, err)
+	}
+	if _, err := c.lookup("other.example"); err == nil {
+		t.Error("a host never resolved should fail")
+	}
+	lookups := metricSnapshot(m, "blofin_dns_lookups_total")
+	if lookups[labels("host", "api.example", "result", "ok")] != 3 || lookups[labels("host", "api.example", "result", "error")] != 1 {
+		t.Errorf("lookups = %v", lookups)
+	}
+}
+
+func TestDNSCacheRefresh(t *testing.T) {
+	m := newMetricsRegistry()
+	c := newDNSCache(20*time.Millisecond, m)
+	resolver := &fakeResolver{}
+	c.lookupHost = resolver.lookupHost
+	resolver.set(nil, "10.0.0.1")
+
+	c.start("api.example")
+	if n := resolver.count(); n != 1 {
+		t.Fatalf("%d lookups on start, want 1", n)
+	}
+	resolver.set(nil, "10.0.0.2")
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+		c.mu.RLock()
+		addrs := c.hosts["api.example"].addrs
+		c.mu.RUnlock()
+		if len(addrs) == 1 && addrs[0] == "10.0.0.2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("not refreshed: %v", addrs)
+		}
+	}
+	if got := metricSnapshot(m, "blofin_dns_changes_total")[labels("host", "api.example")]; got != 1 {
+		t.Errorf("changes = %v, want 1", got)
+	}
+}
@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_DUPLICATE_WINDOW = 2 * time.Second
+	DUPLICATE_ORDER_MESSAGE  = "Duplicate order: the same order is in flight or was just sent"
+)
+
+// Catches the same order sent twice at once, from a double click or a
+// client retrying before its first attempt was answered. Orders are keyed
+// per sender by clientOrderId, or by the whole body when an order has
+// none; a key is held while its request is in flight and for window
+// after it resolves.
+type duplicateGuard struct {
+	window time.Duration
+
+	mu   sync.Mutex
+	held map[string]time.Time // zero while in flight, else when it frees up
+}
+
+func newDuplicateGuard(window time.Duration) *duplicateGuard {
+	if window <= 0 {
+		return nil
+	}
+	return &duplicateGuard{window: window, held: map[string]time.Time{}}
+}
+
+// Hold every key, or none and return the one already held
+func (g *duplicateGuard) claim(keys []string) (string, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	for key, until := range g.held {
+		if !until.IsZero() && now.After(until) {
+			delete(g.held, key)
+		}
+	}
+	for _, key := range keys {
+		if _, ok := g.held[key]; ok {
+			return key, false
+		}
+	}
+	for _, key := range keys {
+		g.held[key] = time.Time{}
+	}
+	return "", true
+}
+
+func (g *duplicateGuard) release(keys []string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	until := time.Now().Add(g.window)
+	for _, key := range keys {
+		g.held[key] = until
+	}
+}
+
+// Keys for the orders in a placement body; none when it can't be parsed,
+// leaving BloFin to reject it
+func duplicateKeys(sender, path string, body []byte) []string {
+	orders, err := parseOrders(path, body)
+	if err != nil {
+		return nil
+	}
+	var keys []string
+	for _, order := range orders {
+		id, _ := order["clientOrderId"].(string)
+		if id == "" {
+			sum := sha256.Sum256(append([]byte(path+" "), body...))
+			return []string{sender + " body " + hex.EncodeToString(sum[:])}
+		}
+		keys = append(keys, sender+" id "+id)
+	}
+	return keys
+}
+
+// Answer 409 when the order in r duplicates one in flight or just sent;
+// otherwise release must be called once it is answered. Orders are only
+// checked for a known sender: a tenant, or a client signing with its key.
+func (s *server) holdOrder(w http.ResponseWriter, r *http.Request, t *tenant) (release func(), ok bool) {
+	sender := r.Header.Get("ACCESS-KEY")
+	if t != nil {
+		sender = "tenant:" + t.Name
+	}
+	if s.duplicates == nil || sender == "" {
+		return func() {}, true
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MAX_INSPECT_BODY))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Failed to read request body"})
+		return nil, false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	release, ok = s.claimOrder(sender, r.URL.Path, body)
+	if !ok {
+		log.Printf("♻️ Duplicate order held back: %s %s", r.Method, r.URL.Path)
+		writeJSON(w, http.StatusConflict, map[string]string{"error": DUPLICATE_ORDER_MESSAGE})
+	}
+	return release, ok
+}
+
+// Claim the orders in a placement body from sender; false when one
+// duplicates an order in flight or just sent
+func (s *server) claimOrder(sender, path string, body []byte) (release func(), ok bool) {
+	if s.duplicates == nil || sender == "" {
+		return func() {}, true
+	}
+	keys := duplicateKeys(sender, path, body)
+	if len(keys) == 0 {
+		return func() {}, true
+	}
+	if _, ok := s.duplicates.claim(keys); !ok {
+		return nil, false
+	}
+	return func() { s.duplicates.release(keys) }, true
+}
@@ -0,0 +1,176 @@
+package proxy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// One KEY=value pair read from a settings file
+type setting struct {
+	key, value string
+}
+
+// LoadEnvFile sets the settings in path as environment variables for
+// LoadConfig to read. Variables already set in the environment win over
+// the file. A path ending in .yaml or .yml is read as a flat YAML mapping
+// (see parseYAMLSettings), anything else as KEY=value lines.
+func LoadEnvFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	parse := parseEnvSettings
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		parse = parseYAMLSettings
+	}
+	settings, err := parse(path, string(data))
+	if err != nil {
+		return err
+	}
+	for _, s := range settings {
+		if _, set := os.LookupEnv(s.key); !set {
+			os.Setenv(s.key, s.value)
+		}
+	}
+	return nil
+}
+
+// KEY=value lines. Blank lines, # comments and an "export " prefix are
+// allowed and values may be quoted.
+func parseEnvSettings(path, data string) ([]setting, error) {
+	var settings []setting
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("%s:%d: expected KEY=value", path, i+1)
+		}
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			if value[0] == '"' {
+				if unquoted, err := strconv.Unquote(value); err == nil {
+					value = unquoted
+				} else {
+					return nil, fmt.Errorf("%s:%d: %v", path, i+1, err)
+				}
+			} else {
+				value = value[1 : len(value)-1]
+			}
+		}
+		settings = append(settings, setting{key, value})
+	}
+	return settings, nil
+}
+
+// Top level KEY: value pairs of a YAML document, with plain, quoted or
+// null scalars and # comments. Nesting, lists, anchors and block scalars
+// are refused. A value tagged !file is read from that file, relative to
+// the config file, so secrets mounted by Docker or Kubernetes can be
+// referenced instead of written out:
+//
+//	ADMIN_TOKEN: !file /run/secrets/admin_token
+func parseYAMLSettings(path, data string) ([]setting, error) {
+	var settings []setting
+	for i, line := range strings.Split(data, "\n") {
+		fail := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%s:%d: %s", path, i+1, fmt.Sprintf(format, args...))
+		}
+		line = strings.TrimRight(line, " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			return nil, fail("nested values are not supported, settings must be top level KEY: value pairs")
+		}
+		key, value, ok := strings.Cut(line, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t\"'-[{") {
+			return nil, fail("expected KEY: value")
+		}
+		if value != "" && value[0] != ' ' && value[0] != '\t' {
+			return nil, fail("expected a space after %s:", key)
+		}
+		value = strings.TrimSpace(value)
+		secret := false
+		if rest, ok := strings.CutPrefix(value, "!file"); ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t') {
+			secret, value = true, strings.TrimSpace(rest)
+		}
+		value, err := yamlScalar(value)
+		if err != nil {
+			return nil, fail("%s: %v", key, err)
+		}
+		if secret {
+			if value == "" {
+				return nil, fail("%s: !file needs a path", key)
+			}
+			if !filepath.IsAbs(value) {
+				value = filepath.Join(filepath.Dir(path), value)
+			}
+			content, err := os.ReadFile(value)
+			if err != nil {
+				return nil, fail("%s: %v", key, err)
+			}
+			value = strings.TrimRight(string(content), "\r\n")
+		}
+		settings = append(settings, setting{key, value})
+	}
+	return settings, nil
+}
+
+// Value of a single line YAML scalar, with any trailing comment removed
+func yamlScalar(s string) (string, error) {
+	// Only whitespace or a comment may follow a closing quote
+	trailing := func(rest string) error {
+		if rest = strings.TrimSpace(rest); rest != "" && rest[0] != '#' {
+			return fmt.Errorf("unexpected %q after the closing quote", rest)
+		}
+		return nil
+	}
+	switch {
+	case s == "":
+		return "", nil
+	case s[0] == '"':
+		quoted, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid double quoted value %s", s)
+		}
+		if err := trailing(s[len(quoted):]); err != nil {
+			return "", err
+		}
+		return strconv.Unquote(quoted)
+	case s[0] == '\'':
+		// '' is the only escape in single quoted scalars
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			if s[i] != '\'' {
+				b.WriteByte(s[i])
+			} else if i+1 < len(s) && s[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+			} else {
+				return b.String(), trailing(s[i+1:])
+			}
+		}
+		return "", fmt.Errorf("unterminated single quoted value %s", s)
+	case strings.ContainsRune("[{|>&*!%@`", rune(s[0])):
+		return "", fmt.Errorf("%q values are not supported, quote the value if it is meant literally", s[:1])
+	}
+	for i := 1; i < len(s); i++ {
+		if s[i] == '#' && (s[i-1] == ' ' || s[i-1] == '\t') {
+			s = strings.TrimSpace(s[:i])
+			break
+		}
+	}
+	if s == "~" || s == "null" {
+		return "", nil
+	}
+	return s, nil
+}
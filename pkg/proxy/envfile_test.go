@@ -0,0 +1,163 @@
+package proxy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeEnvFile(t *testing.T, content string) string {
+	t.Helper()
+	return writeConfigFile(t, ".env", content)
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Unset keys for the test, restoring them afterwards
+func unsetEnv(t *testing.T, keys ...string) {
+	for _, key := range keys {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	unsetEnv(t, "EF_PLAIN", "EF_EXPORTED", "EF_DOUBLE", "EF_SINGLE", "EF_ESCAPED", "EF_EMPTY", "EF_SPACED", "EF_HASH", "EF_MISMATCHED")
+	t.Setenv("EF_PRESET", "from the environment")
+
+	path := writeEnvFile(t, strings.Join([]string{
+		"# a comment",
+		"",
+		"EF_PLAIN=plain",
+		"export EF_EXPORTED=exported",
+		`EF_DOUBLE="two words"`,
+		`EF_SINGLE='$NOT_EXPANDED \n'`,
+		`EF_ESCAPED="line\nbreak \"quoted\""`,
+		"EF_EMPTY=",
+		"  EF_SPACED  =  spaced  ",
+		"EF_HASH=a#b",
+		`EF_MISMATCHED="open'`,
+		"EF_PRESET=from the file",
+		"   # an indented comment",
+	}, "\r\n"))
+	if err := LoadEnvFile(path); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"EF_PLAIN":      "plain",
+		"EF_EXPORTED":   "exported",
+		"EF_DOUBLE":     "two words",
+		"EF_SINGLE":     `$NOT_EXPANDED \n`,
+		"EF_ESCAPED":    "line\nbreak \"quoted\"",
+		"EF_SPACED":     "spaced",
+		"EF_HASH":       "a#b",
+		"EF_MISMATCHED": `"open'`,
+		"EF_PRESET":     "from the environment",
+	} {
+		if got := os.Getenv(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if value, set := os.LookupEnv("EF_EMPTY"); !set || value != "" {
+		t.Errorf("EF_EMPTY = %q, %v; want set and empty", value, set)
+	}
+}
+
+func TestLoadEnvFileErrors(t *testing.T) {
+	unsetEnv(t, "EF_OK")
+	tests := map[string]string{
+		"no equals":    "EF_OK=1\nJUST_A_WORD\n",
+		"space in key": "EF OK=1\n",
+		"empty key":    "=value\n",
+		"bad escape":   `EF_OK="\q"` + "\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := LoadEnvFile(writeEnvFile(t, content))
+			if err == nil || !strings.Contains(err.Error(), ".env:") {
+				t.Errorf("err = %v, want one naming the file and line", err)
+			}
+		})
+	}
+	if err := LoadEnvFile(filepath.Join(t.TempDir(), "missing")); !os.IsNotExist(err) {
+		t.Errorf("missing file: err = %v", err)
+	}
+}
+
+func TestLoadYAMLFile(t *testing.T) {
+	unsetEnv(t, "YF_PLAIN", "YF_NUMBER", "YF_DOUBLE", "YF_SINGLE", "YF_COMMENTED", "YF_HASH", "YF_NULL", "YF_EMPTY", "YF_SECRET")
+	t.Setenv("YF_PRESET", "from the environment")
+
+	path := writeConfigFile(t, "config.yaml", strings.Join([]string{
+		"---",
+		"# a comment",
+		"YF_PLAIN: plain value",
+		"YF_NUMBER: 8080",
+		`YF_DOUBLE: "line\nbreak # not a comment"`,
+		`YF_SINGLE: 'it''s' # a comment`,
+		"YF_COMMENTED: 10s # a comment",
+		"YF_HASH: a#b",
+		"YF_NULL: ~",
+		"YF_EMPTY:",
+		"YF_SECRET: !file secret.txt",
+		"YF_PRESET: from the file",
+	}, "\r\n"))
+	if err := os.WriteFile(filepath.Join(filepath.Dir(path), "secret.txt"), []byte("s3cr3t\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadEnvFile(path); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"YF_PLAIN":     "plain value",
+		"YF_NUMBER":    "8080",
+		"YF_DOUBLE":    "line\nbreak # not a comment",
+		"YF_SINGLE":    "it's",
+		"YF_COMMENTED": "10s",
+		"YF_HASH":      "a#b",
+		"YF_NULL":      "",
+		"YF_EMPTY":     "",
+		"YF_SECRET":    "s3cr3t",
+		"YF_PRESET":    "from the environment",
+	} {
+		if got := os.Getenv(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestLoadYAMLFileErrors(t *testing.T) {
+	unsetEnv(t, "YF_OK")
+	tests := map[string]string{
+		"nested":            "YF_OK: 1\nTENANTS:\n  alice: tok\n",
+		"list":              "- YF_OK\n",
+		"no colon":          "YF_OK\n",
+		"no space":          "YF_OK:1\n",
+		"flow mapping":      "YF_OK: {a: 1}\n",
+		"block scalar":      "YF_OK: |\n",
+		"unknown tag":       "YF_OK: !env HOME\n",
+		"after quote":       `YF_OK: "a" b` + "\n",
+		"unterminated":      "YF_OK: 'open\n",
+		"missing secret":    "YF_OK: !file does-not-exist\n",
+		"secret needs path": "YF_OK: !file\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := LoadEnvFile(writeConfigFile(t, "config.yml", content))
+			if err == nil || !strings.Contains(err.Error(), "config.yml:") {
+				t.Errorf("err = %v, want one naming the file and line", err)
+			}
+		})
+	}
+	if _, set := os.LookupEnv("YF_OK"); set {
+		t.Error("a file with errors still set variables")
+	}
+}
@@ -0,0 +1,361 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Larger exports must go through the jobs API
+	EXPORT_SYNC_LIMIT = 50000
+	EXPORT_JOB_TTL    = time.Hour
+	MAX_EXPORT_JOBS   = 2 // running at once
+)
+
+var candleColumns = []string{"ts", "time", "open", "high", "low", "close", "vol", "volCurrency", "volCurrencyQuote", "confirm"}
+
+// What to export: dataset, instrument, bar, time range and output format
+type exportRequest struct {
+	Dataset string `json:"dataset"`
+	InstID  string `json:"instId"`
+	Bar     string `json:"bar"`
+	From    int64  `json:"from"` // ms, inclusive; 0 = beginning
+	To      int64  `json:"to"`   // ms, inclusive; 0 = now
+	Format  string `json:"format"`
+}
+
+func parseExportRequest(dataset string, query map[string][]string) (exportRequest, error) {
+	get := func(name string) string {
+		if values := query[name]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	req := exportRequest{Dataset: dataset, InstID: get("instId"), Bar: get("bar"), Format: get("format")}
+	var err error
+	if req.From, err = parseExportTime(get("from")); err != nil {
+		return req, fmt.Errorf("invalid from: %v", err)
+	}
+	if req.To, err = parseExportTime(get("to")); err != nil {
+		return req, fmt.Errorf("invalid to: %v", err)
+	}
+	return req, req.validate()
+}
+
+// Millisecond timestamp or RFC3339
+func parseExportTime(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return ms, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, fmt.Errorf("expected milliseconds or RFC3339, got %q", value)
+	}
+	return t.UnixMilli(), nil
+}
+
+func (req *exportRequest) validate() error {
+	if req.Dataset != "candles" {
+		return fmt.Errorf("unknown dataset %q, only candles are stored locally", req.Dataset)
+	}
+	if req.InstID == "" {
+		return fmt.Errorf("instId is required")
+	}
+	if req.Bar == "" {
+		req.Bar = "1m"
+	}
+	if _, ok := barDurations[req.Bar]; !ok {
+		return fmt.Errorf("unsupported bar %q", req.Bar)
+	}
+	switch req.Format {
+	case "":
+		req.Format = "csv"
+	case "csv", "ndjson":
+	case "parquet":
+		return fmt.Errorf("parquet output is not available, use csv or ndjson")
+	default:
+		return fmt.Errorf("unknown format %q, use csv or ndjson", req.Format)
+	}
+	return nil
+}
+
+func (req exportRequest) filename() string {
+	return fmt.Sprintf("%s_%s_%s.%s", req.Dataset, req.InstID, req.Bar, req.Format)
+}
+
+func (req exportRequest) contentType() string {
+	if req.Format == "ndjson" {
+		return "application/x-ndjson"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Stored candles inside the requested range, oldest first
+func (req exportRequest) rows(store *candleStore) ([]candle, error) {
+	all, err := store.load(req.InstID, req.Bar)
+	if err != nil {
+		return nil, err
+	}
+	rows := all[:0:0]
+	for _, row := range all {
+		ts := row.ts()
+		if (req.From > 0 && ts < req.From) || (req.To > 0 && ts > req.To) {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func writeCandles(w io.Writer, format string, rows []candle) error {
+	if format == "ndjson" {
+		encoder := json.NewEncoder(w)
+		for _, row := range rows {
+			record := map[string]string{}
+			for i, column := range candleColumns {
+				record[column] = candleColumn(row, i)
+			}
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(candleColumns); err != nil {
+		return err
+	}
+	record := make([]string, len(candleColumns))
+	for _, row := range rows {
+		for i := range candleColumns {
+			record[i] = candleColumn(row, i)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Column i of candleColumns for a BloFin candle row, which has no "time"
+func candleColumn(row candle, i int) string {
+	switch {
+	case i == 0:
+		return row[0]
+	case i == 1:
+		return time.UnixMilli(row.ts()).UTC().Format(time.RFC3339)
+	case i-1 < len(row):
+		return row[i-1]
+	}
+	return ""
+}
+
+// State of an asynchronous export
+type exportJob struct {
+	ID          string        `json:"id"`
+	Request     exportRequest `json:"request"`
+	Status      string        `json:"status"` // running, done or failed
+	Rows        int           `json:"rows"`
+	Error       string        `json:"error,omitempty"`
+	CreatedAt   time.Time     `json:"createdAt"`
+	DownloadURL string        `json:"downloadUrl,omitempty"`
+	file        string
+}
+
+// Streams locally stored data as CSV or NDJSON, synchronously for small
+// ranges and through background jobs for large ones
+type exporter struct {
+	store *candleStore
+	dir   string
+
+	mu   sync.Mutex
+	jobs map[string]*exportJob
+}
+
+func newExporter(store *candleStore, dataDir string) (*exporter, error) {
+	dir := filepath.Join(dataDir, "exports")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &exporter{store: store, dir: dir, jobs: map[string]*exportJob{}}, nil
+}
+
+// GET /export/candles?instId=...&bar=...&from=...&to=...&format=csv|ndjson
+func (e *exporter) handleCandles(w http.ResponseWriter, r *http.Request) {
+	req, err := parseExportRequest("candles", r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	rows, err := req.rows(e.store)
+	if err != nil {
+		log.Printf("❌ Export failed to read candles: %v", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to read local candles"})
+		return
+	}
+	if len(rows) > EXPORT_SYNC_LIMIT {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{
+			"error": fmt.Sprintf("%d rows exceed the synchronous limit of %d, POST the same parameters to /export/jobs", len(rows), EXPORT_SYNC_LIMIT),
+		})
+		return
+	}
+	w.Header().Set("Content-Type", req.contentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", req.filename()))
+	if err := writeCandles(w, req.Format, rows); err != nil {
+		log.Printf("❌ Export stream failed: %v", err)
+	}
+}
+
+// POST /export/jobs starts a job; GET /export/jobs/{id} reports it and
+// GET /export/jobs/{id}/download returns the file once done
+func (e *exporter) handleJobs(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/export/jobs"), "/")
+	id, action, _ := strings.Cut(rest, "/")
+
+	switch {
+	case id == "" && r.Method == http.MethodPost:
+		e.createJob(w, r)
+	case id != "" && action == "" && r.Method == http.MethodGet:
+		job, ok := e.job(id)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "Unknown export job"})
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	case id != "" && action == "download" && r.Method == http.MethodGet:
+		job, ok := e.job(id)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "Unknown export job"})
+			return
+		}
+		if job.Status != "done" {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "Export job is " + job.Status})
+			return
+		}
+		w.Header().Set("Content-Type", job.Request.contentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", job.Request.filename()))
+		http.ServeFile(w, r, job.file)
+	case id == "":
+		methodNotAllowed(w, "POST")
+	case action == "" || action == "download":
+		methodNotAllowed(w, "GET")
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Unknown export job action " + action})
+	}
+}
+
+func (e *exporter) createJob(w http.ResponseWriter, r *http.Request) {
+	var req exportRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MAX_INSPECT_BODY)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON: " + err.Error()})
+		return
+	}
+	if req.Dataset == "" {
+		req.Dataset = "candles"
+	}
+	if err := req.validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	e.mu.Lock()
+	e.pruneLocked()
+	running := 0
+	for _, job := range e.jobs {
+		if job.Status == "running" {
+			running++
+		}
+	}
+	if running >= MAX_EXPORT_JOBS {
+		e.mu.Unlock()
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "Too many export jobs running, try again shortly"})
+		return
+	}
+	id := newJobID()
+	job := &exportJob{
+		ID:          id,
+		Request:     req,
+		Status:      "running",
+		CreatedAt:   time.Now().UTC(),
+		DownloadURL: "/export/jobs/" + id + "/download",
+		file:        filepath.Join(e.dir, id+"."+req.Format),
+	}
+	e.jobs[id] = job
+	snapshot := *job
+	e.mu.Unlock()
+
+	go e.runJob(job)
+	writeJSON(w, http.StatusAccepted, snapshot)
+}
+
+func (e *exporter) runJob(job *exportJob) {
+	rows, err := job.Request.rows(e.store)
+	if err == nil {
+		var f *os.File
+		if f, err = os.Create(job.file); err == nil {
+			err = writeCandles(f, job.Request.Format, rows)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err != nil {
+		job.Status, job.Error = "failed", err.Error()
+		log.Printf("❌ Export job %s failed: %v", job.ID, err)
+		return
+	}
+	job.Status, job.Rows = "done", len(rows)
+	log.Printf("📦 Export job %s wrote %d rows", job.ID, len(rows))
+}
+
+func (e *exporter) job(id string) (exportJob, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	job, ok := e.jobs[id]
+	if !ok {
+		return exportJob{}, false
+	}
+	return *job, true
+}
+
+func (e *exporter) prune() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.pruneLocked()
+}
+
+// Forget finished jobs older than EXPORT_JOB_TTL and delete their files
+func (e *exporter) pruneLocked() {
+	for id, job := range e.jobs {
+		if job.Status != "running" && time.Since(job.CreatedAt) > EXPORT_JOB_TTL {
+			os.Remove(job.file)
+			delete(e.jobs, id)
+		}
+	}
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,128 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestExporter(t *testing.T) *exporter {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := newCandleStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []candle{
+		{"60000", "1", "2", "0.5", "1.5", "10", "0.01", "15", "1"},
+		{"120000", "1.5", "3", "1", "2.5", "20", "0.02", "50", "1"},
+		{"180000", "2.5", "4", "2", "3", "30", "0.03", "90", "0"},
+	}
+	if _, err := store.merge("BTC-USDT", "1m", rows); err != nil {
+		t.Fatal(err)
+	}
+	e, err := newExporter(store, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestExportCandles(t *testing.T) {
+	e := newTestExporter(t)
+	get := func(query string) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		e.handleCandles(rec, httptest.NewRequest(http.MethodGet, "/export/candles?"+query, nil))
+		return rec
+	}
+
+	rec := get("instId=BTC-USDT&from=120000&to=1970-01-01T00:03:00Z")
+	want := "ts,time,open,high,low,close,vol,volCurrency,volCurrencyQuote,confirm\n" +
+		"120000,1970-01-01T00:02:00Z,1.5,3,1,2.5,20,0.02,50,1\n" +
+		"180000,1970-01-01T00:03:00Z,2.5,4,2,3,30,0.03,90,0\n"
+	if rec.Code != http.StatusOK || rec.Body.String() != want {
+		t.Errorf("csv = %d\n%s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="candles_BTC-USDT_1m.csv"` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+
+	rec = get("instId=BTC-USDT&format=ndjson&to=60000")
+	var record map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &record); err != nil || rec.Header().Get("Content-Type") != "application/x-ndjson" || record["close"] != "1.5" || record["time"] != "1970-01-01T00:01:00Z" || strings.Count(rec.Body.String(), "\n") != 1 {
+		t.Errorf("ndjson = %s (%v)", rec.Body, err)
+	}
+
+	for query, want := range map[string]string{
+		"bar=1m":                            "instId is required",
+		"instId=BTC-USDT&bar=7m":            "unsupported bar",
+		"instId=BTC-USDT&format=parquet":    "parquet output is not available",
+		"instId=BTC-USDT&format=xml":        "unknown format",
+		"instId=BTC-USDT&from=yesterday":    "invalid from",
+		"instId=BTC-USDT&to=2024-13-01T00Z": "invalid to",
+	} {
+		if rec := get(query); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("%s = %d %s, want %q", query, rec.Code, rec.Body, want)
+		}
+	}
+}
+
+func TestExportJobs(t *testing.T) {
+	e := newTestExporter(t)
+	call := func(method, path, body string) (*httptest.ResponseRecorder, exportJob) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		e.handleJobs(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		var job exportJob
+		json.Unmarshal(rec.Body.Bytes(), &job)
+		return rec, job
+	}
+
+	rec, job := call(http.MethodPost, "/export/jobs", `{"instId":"BTC-USDT","format":"ndjson","from":120000}`)
+	if rec.Code != http.StatusAccepted || job.Status != "running" || job.DownloadURL != "/export/jobs/"+job.ID+"/download" || job.Request.Bar != "1m" {
+		t.Fatalf("create = %d %s", rec.Code, rec.Body)
+	}
+	for deadline := time.Now().Add(5 * time.Second); job.Status == "running"; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("export job never finished")
+		}
+		_, job = call(http.MethodGet, "/export/jobs/"+job.ID, "")
+	}
+	if job.Status != "done" || job.Rows != 2 {
+		t.Fatalf("job = %+v", job)
+	}
+	rec, _ = call(http.MethodGet, job.DownloadURL, "")
+	if rec.Code != http.StatusOK || strings.Count(rec.Body.String(), "\n") != 2 || !strings.Contains(rec.Body.String(), `"ts":"180000"`) {
+		t.Errorf("download = %d %s", rec.Code, rec.Body)
+	}
+
+	for _, tt := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{http.MethodPost, "/export/jobs", `{"instId":"BTC-USDT","format":"parquet"}`, http.StatusBadRequest},
+		{http.MethodPost, "/export/jobs", `{"instId":`, http.StatusBadRequest},
+		{http.MethodGet, "/export/jobs/nope", "", http.StatusNotFound},
+		{http.MethodGet, "/export/jobs/nope/download", "", http.StatusNotFound},
+		{http.MethodGet, "/export/jobs/" + job.ID + "/cancel", "", http.StatusNotFound},
+		{http.MethodGet, "/export/jobs", "", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/export/jobs/" + job.ID, "", http.StatusMethodNotAllowed},
+	} {
+		if rec, _ := call(tt.method, tt.path, tt.body); rec.Code != tt.status {
+			t.Errorf("%s %s = %d %s, want %d", tt.method, tt.path, rec.Code, rec.Body, tt.status)
+		}
+	}
+
+	// Finished jobs expire with their files
+	e.mu.Lock()
+	e.jobs[job.ID].CreatedAt = time.Now().Add(-2 * EXPORT_JOB_TTL)
+	e.mu.Unlock()
+	e.prune()
+	if rec, _ := call(http.MethodGet, "/export/jobs/"+job.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expired job = %d", rec.Code)
+	}
+}
@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	MAX_FANOUT_SYMBOLS = 20
+	FANOUT_PARALLEL    = 4 // calls to BloFin in flight per fanned-out request
+)
+
+// Single-instrument routes whose items carry their instId, so results for
+// several instruments can be merged into one list
+var fanOutRoutes = map[string]bool{
+	"/api/v1/market/tickers":                            true,
+	"/api/v1/market/trades":                             true,
+	"/api/v1/market/mark-price":                         true,
+	"/api/v1/market/funding-rate":                       true,
+	"/api/v1/account/positions":                         true,
+	"/api/v1/trade/orders-pending":                      true,
+	"/api/v1/trade/orders-tpsl-pending":                 true,
+	"/api/v1/trade/orders-history":                      true,
+	"/api/v1/trade/orders-tpsl-history":                 true,
+	"/api/v1/trade/fills-history":                       true,
+	"/api/v1/copytrading/account/positions-by-contract": true,
+	"/api/v1/copytrading/trade/orders-pending":          true,
+	"/api/v1/copytrading/trade/orders-history":          true,
+}
+
+// Answer a GET whose instId lists several instruments with one call per
+// instrument, FANOUT_PARALLEL at a time, merged in the order listed. Other
+// parameters (limit, ...) apply to each call. False when the request isn't
+// one to fan out. Private routes are only fanned out for tenants, since a
+// client's signature covers the query as sent.
+func (s *server) fanOut(w http.ResponseWriter, r *http.Request, t *tenant) bool {
+	if r.Method != http.MethodGet || !fanOutRoutes[r.URL.Path] || !strings.Contains(r.URL.Query().Get("instId"), ",") {
+		return false
+	}
+	if route := findRoute(r.Method, r.URL.Path); route == nil || (route.Private && t == nil) {
+		return false
+	}
+	var instIDs []string
+	seen := map[string]bool{}
+	for _, instID := range strings.Split(r.URL.Query().Get("instId"), ",") {
+		if instID = strings.TrimSpace(instID); instID != "" && !seen[instID] {
+			seen[instID] = true
+			instIDs = append(instIDs, instID)
+		}
+	}
+	if len(instIDs) > MAX_FANOUT_SYMBOLS {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("At most %d instruments per request", MAX_FANOUT_SYMBOLS)})
+		return true
+	}
+
+	results := make([][]json.RawMessage, len(instIDs))
+	errs := make([]error, len(instIDs))
+	slots := make(chan struct{}, FANOUT_PARALLEL)
+	var wg sync.WaitGroup
+	for i, instID := range instIDs {
+		wg.Add(1)
+		go func(i int, instID string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			query := r.URL.Query()
+			query.Set("instId", instID)
+			errs[i] = s.doPaced(r.Context(), http.MethodGet, r.URL.Path, query, nil, t, &results[i])
+		}(i, instID)
+	}
+	wg.Wait()
+	if clientGone(r) {
+		return true
+	}
+
+	merged := []json.RawMessage{}
+	for i, err := range errs {
+		if err == nil {
+			merged = append(merged, results[i]...)
+			continue
+		}
+		log.Printf("❌ Fan-out %s for %s failed: %v", r.URL.Path, instIDs[i], err)
+		status := http.StatusBadGateway
+		if e, ok := err.(*blofinError); ok {
+			switch {
+			case isRateLimited(e):
+				status = http.StatusTooManyRequests
+			case e.Code != "":
+				status = http.StatusBadRequest
+			}
+		}
+		writeJSON(w, status, map[string]string{"error": instIDs[i] + ": " + err.Error()})
+		return true
+	}
+	log.Printf("🔀 Fanned out %s over %d instruments", r.URL.Path, len(instIDs))
+	writeJSON(w, http.StatusOK, map[string]interface{}{"code": "0", "msg": "success", "data": merged})
+	return true
+}
@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFanOut(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		instID := r.URL.Query().Get("instId")
+		if r.URL.Path == "/api/v1/market/tickers" || r.URL.Path == "/api/v1/account/positions" {
+			mu.Lock()
+			calls = append(calls, r.Header.Get("ACCESS-KEY")+" "+instID)
+			mu.Unlock()
+		}
+		if instID == "BAD-USDT" {
+			w.Write([]byte(`{"code":"152001","msg":"Instrument does not exist","data":null}`))
+			return
+		}
+		data := []map[string]string{}
+		if instID != "" {
+			data = append(data, map[string]string{"instId": instID, "key": r.Header.Get("ACCESS-KEY")})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": "0", "msg": "", "data": data})
+	})
+	p := newUpstreamProxy(t, upstream, `[
+		{"name":"alice","token":"tok-a","apiKey":"key-a","secret":"s","passphrase":"p"},
+		{"name":"bob","token":"tok-b","apiKey":"key-b","secret":"s","passphrase":"p"}
+	]`)
+	get := func(uri, token string) (int, string, []string) {
+		t.Helper()
+		mu.Lock()
+		calls = nil
+		mu.Unlock()
+		req := httptest.NewRequest(http.MethodGet, uri, nil)
+		if token != "" {
+			req.Header.Set(TENANT_HEADER, token)
+		}
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+		mu.Lock()
+		defer mu.Unlock()
+		return rec.Code, rec.Body.String(), append([]string(nil), calls...)
+	}
+	items := func(body string) []string {
+		t.Helper()
+		var resp struct {
+			Data []map[string]string `json:"data"`
+		}
+		json.Unmarshal([]byte(body), &resp)
+		var got []string
+		for _, item := range resp.Data {
+			got = append(got, item["key"]+" "+item["instId"])
+		}
+		return got
+	}
+
+	// Merged in the order listed, duplicates dropped
+	code, body, calls := get("/api/v1/market/tickers?instId=ETH-USDT,BTC-USDT,SOL-USDT,ETH-USDT", "")
+	if got := strings.Join(items(body), ","); code != http.StatusOK || got != " ETH-USDT, BTC-USDT, SOL-USDT" || len(calls) != 3 {
+		t.Errorf("tickers = %d %s", code, body)
+	}
+
+	// Each tenant's calls are signed with its own key
+	for token, key := range map[string]string{"tok-a": "key-a", "tok-b": "key-b"} {
+		code, body, calls := get("/api/v1/account/positions?instId=BTC-USDT,ETH-USDT", token)
+		if got := strings.Join(items(body), ","); code != http.StatusOK || got != key+" BTC-USDT,"+key+" ETH-USDT" || len(calls) != 2 {
+			t.Errorf("%s positions = %d %s, calls %v", token, code, body, calls)
+		}
+	}
+
+	// One instrument failing fails the whole request, for one tenant only
+	code, body, calls = get("/api/v1/account/positions?instId=BTC-USDT,BAD-USDT,ETH-USDT", "tok-a")
+	if code != http.StatusBadRequest || !strings.Contains(body, "BAD-USDT: ") || len(calls) != 3 {
+		t.Errorf("partial failure = %d %s, calls %v", code, body, calls)
+	}
+	if code, body, _ := get("/api/v1/account/positions?instId=BTC-USDT,ETH-USDT", "tok-b"); code != http.StatusOK || len(items(body)) != 2 {
+		t.Errorf("bob after alice's failure = %d %s", code, body)
+	}
+
+	// A client's own signature covers the query as sent, so its private
+	// requests go to BloFin unchanged
+	if _, _, calls := get("/api/v1/account/positions?instId=BTC-USDT,ETH-USDT", ""); len(calls) != 1 || calls[0] != " BTC-USDT,ETH-USDT" {
+		t.Errorf("unsigned client's request sent as %v", calls)
+	}
+}
@@ -0,0 +1,243 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	FILL_PAGE_SIZE   = 100
+	MAX_FILL_PAGES   = 100 // per tenant and sync; the next sync picks up the rest
+	MAX_LOCAL_FILLS  = 1000
+	LOCAL_FILLS_PAGE = 100
+)
+
+// The fields of a BloFin fill the proxy reads. Stored fills keep every
+// field BloFin sent.
+type fill struct {
+	InstID    string `json:"instId"`
+	TradeID   string `json:"tradeId"`
+	OrderID   string `json:"orderId"`
+	FillPrice string `json:"fillPrice"`
+	FillSize  string `json:"fillSize"`
+	FillPnl   string `json:"fillPnl"`
+	Side      string `json:"side"`
+	Fee       string `json:"fee"`
+	Ts        string `json:"ts"`
+}
+
+func (f fill) ts() int64 {
+	ts, _ := strconv.ParseInt(f.Ts, 10, 64)
+	return ts
+}
+
+// A stored fill: the parsed fields and the row exactly as BloFin returned it
+type storedFill struct {
+	fill
+	raw json.RawMessage
+}
+
+// Each tenant's fills, paged from /api/v1/trade/fills-history by the
+// sync-fills job and kept in DATA_DIR/fills/<tenant>.json in ascending
+// time order. BloFin only keeps a few months of fills and pages them 100
+// at a time, so the local copy answers longer and larger queries.
+type fillStore struct {
+	client  *blofinClient
+	tenants *tenantRegistry
+	dir     string
+	mu      sync.Mutex
+}
+
+func newFillStore(client *blofinClient, tenants *tenantRegistry, dataDir string) *fillStore {
+	return &fillStore{client: client, tenants: tenants, dir: filepath.Join(dataDir, "fills")}
+}
+
+func (s *fillStore) path(t *tenant) string {
+	return filepath.Join(s.dir, t.fileName()+".json")
+}
+
+// t's stored fills, oldest first; nil if none were synced yet
+func (s *fillStore) load(t *tenant) ([]storedFill, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadLocked(t)
+}
+
+func (s *fillStore) loadLocked(t *tenant) ([]storedFill, error) {
+	data, err := os.ReadFile(s.path(t))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rows []json.RawMessage
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, err
+	}
+	fills := make([]storedFill, 0, len(rows))
+	for _, raw := range rows {
+		f := storedFill{raw: raw}
+		if err := json.Unmarshal(raw, &f.fill); err != nil {
+			return nil, err
+		}
+		fills = append(fills, f)
+	}
+	return fills, nil
+}
+
+// Sync every tenant's fills; used by the sync-fills job
+func (s *fillStore) sync(ctx context.Context) error {
+	if len(s.tenants.list) == 0 {
+		return fmt.Errorf("no tenants configured, see TENANTS_FILE")
+	}
+	var failed []string
+	for _, t := range s.tenants.list {
+		n, err := s.syncTenant(ctx, t)
+		if err != nil {
+			log.Printf("❌ Fill sync for %s failed: %v", t.Name, err)
+			failed = append(failed, t.Name)
+			continue
+		}
+		if n > 0 {
+			log.Printf("🧾 Stored %d new fills for %s", n, t.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("fill sync failed for %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// Page back from t's newest fill until one that is already stored
+func (s *fillStore) syncTenant(ctx context.Context, t *tenant) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fills, err := s.loadLocked(t)
+	if err != nil {
+		return 0, err
+	}
+	known := make(map[string]bool, len(fills))
+	for _, f := range fills {
+		known[f.TradeID] = true
+	}
+	var fresh []storedFill // newest first, as BloFin pages them
+	after := ""
+	for page := 0; page < MAX_FILL_PAGES; page++ {
+		query := url.Values{"limit": {strconv.Itoa(FILL_PAGE_SIZE)}}
+		if after != "" {
+			query.Set("after", after)
+		}
+		var batch []json.RawMessage
+		if err := s.client.do(ctx, http.MethodGet, "/api/v1/trade/fills-history", query, nil, t, &batch); err != nil {
+			return 0, err
+		}
+		caughtUp := false
+		for _, raw := range batch {
+			f := storedFill{raw: raw}
+			if json.Unmarshal(raw, &f.fill) != nil || f.TradeID == "" {
+				continue
+			}
+			after = f.TradeID
+			if known[f.TradeID] {
+				caughtUp = true
+				continue
+			}
+			known[f.TradeID] = true
+			fresh = append(fresh, f)
+		}
+		if caughtUp || len(batch) < FILL_PAGE_SIZE {
+			break
+		}
+	}
+	// The file is written even without fills, so queries can tell a
+	// synced tenant that hasn't traded from one never synced
+	if len(fresh) == 0 && fills != nil {
+		return 0, nil
+	}
+	for i := len(fresh) - 1; i >= 0; i-- {
+		fills = append(fills, fresh[i])
+	}
+	sort.SliceStable(fills, func(i, j int) bool { return fills[i].ts() < fills[j].ts() })
+	rows := make([]json.RawMessage, len(fills))
+	for i, f := range fills {
+		rows[i] = f.raw
+	}
+	data, err := json.Marshal(rows)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return 0, err
+	}
+	tmp := s.path(t) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return 0, err
+	}
+	return len(fresh), os.Rename(tmp, s.path(t))
+}
+
+// GET /local/fills returns the tenant's stored fills, newest first, in
+// BloFin's response shape. instId filters by instrument, begin and end
+// (ms, inclusive) by time, and limit caps the count.
+func (s *fillStore) handleLocal(w http.ResponseWriter, r *http.Request) {
+	t, err := s.tenants.fromRequest(r)
+	if err != nil || t == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Fill history needs the " + TENANT_HEADER + " of a configured tenant"})
+		return
+	}
+	query := r.URL.Query()
+	limit := LOCAL_FILLS_PAGE
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = min(n, MAX_LOCAL_FILLS)
+	}
+	var bounds [2]int64
+	for i, name := range []string{"begin", "end"} {
+		if value := query.Get(name); value != "" {
+			if bounds[i], err = strconv.ParseInt(value, 10, 64); err != nil || bounds[i] < 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": name + " must be a timestamp in milliseconds"})
+				return
+			}
+		}
+	}
+	instID := query.Get("instId")
+	if instID != "" {
+		instID = normalizeInstID(instID)
+	}
+
+	fills, err := s.load(t)
+	if err != nil {
+		log.Printf("❌ Failed to read fills for %s: %v", t.Name, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to read the fill history"})
+		return
+	}
+	if fills == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "No fills stored yet, schedule the sync-fills job"})
+		return
+	}
+	data := []json.RawMessage{}
+	for i := len(fills) - 1; i >= 0 && len(data) < limit; i-- {
+		f := fills[i]
+		if (instID != "" && f.InstID != instID) || (bounds[0] > 0 && f.ts() < bounds[0]) || (bounds[1] > 0 && f.ts() > bounds[1]) {
+			continue
+		}
+		data = append(data, f.raw)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"code": "0", "msg": "", "data": data})
+}
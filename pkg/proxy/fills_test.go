@@ -0,0 +1,114 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestFillStore(t *testing.T) (*fillStore, *mockExchange, *tenant) {
+	t.Helper()
+	reg, err := loadTestTenants(t, `{"name":"alice","token":"tok-a","apiKey":"k","secret":"s","passphrase":"p"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock := newMockExchange()
+	client := newBlofinClient(mock, nil, nil, nil, nil, nil)
+	return newFillStore(client, reg, t.TempDir()), mock, reg.list[0]
+}
+
+func mockFills(m *mockExchange, instID string, n int) {
+	for i := 0; i < n; i++ {
+		m.acceptOrder(map[string]interface{}{"instId": instID, "side": "buy", "orderType": "market", "size": "1"})
+	}
+}
+
+func TestFillSync(t *testing.T) {
+	store, mock, alice := newTestFillStore(t)
+	ctx := context.Background()
+
+	if n, err := store.syncTenant(ctx, alice); err != nil || n != 0 {
+		t.Fatalf("first sync without fills = %d, %v", n, err)
+	}
+	if fills, err := store.load(alice); err != nil || fills == nil {
+		t.Fatalf("a synced tenant without fills should have an empty history, got %v, %v", fills, err)
+	}
+
+	// More than one page
+	mockFills(mock, "BTC-USDT", FILL_PAGE_SIZE+50)
+	if n, err := store.syncTenant(ctx, alice); err != nil || n != FILL_PAGE_SIZE+50 {
+		t.Fatalf("sync = %d, %v; want %d", n, err, FILL_PAGE_SIZE+50)
+	}
+	mockFills(mock, "ETH-USDT", 3)
+	if n, err := store.syncTenant(ctx, alice); err != nil || n != 3 {
+		t.Fatalf("second sync = %d, %v; want only the 3 new fills", n, err)
+	}
+	if n, err := store.syncTenant(ctx, alice); err != nil || n != 0 {
+		t.Fatalf("sync with nothing new = %d, %v", n, err)
+	}
+
+	fills, err := store.load(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fills) != FILL_PAGE_SIZE+53 {
+		t.Fatalf("stored %d fills, want %d", len(fills), FILL_PAGE_SIZE+53)
+	}
+	for i := 1; i < len(fills); i++ {
+		if fills[i].TradeID <= fills[i-1].TradeID {
+			t.Fatalf("fills out of order at %d: %s after %s", i, fills[i].TradeID, fills[i-1].TradeID)
+		}
+	}
+}
+
+func TestLocalFills(t *testing.T) {
+	store, mock, alice := newTestFillStore(t)
+	get := func(query, token string) (int, []fill) {
+		req := httptest.NewRequest(http.MethodGet, "/local/fills"+query, nil)
+		if token != "" {
+			req.Header.Set(TENANT_HEADER, token)
+		}
+		rec := httptest.NewRecorder()
+		store.handleLocal(rec, req)
+		var resp struct {
+			Data []fill `json:"data"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp.Data
+	}
+
+	if code, _ := get("", ""); code != http.StatusUnauthorized {
+		t.Errorf("without a tenant token = %d, want 401", code)
+	}
+	if code, _ := get("", "tok-a"); code != http.StatusNotFound {
+		t.Errorf("before the first sync = %d, want 404", code)
+	}
+	mockFills(mock, "BTC-USDT", 5)
+	mockFills(mock, "ETH-USDT", 2)
+	if _, err := store.syncTenant(context.Background(), alice); err != nil {
+		t.Fatal(err)
+	}
+
+	code, fills := get("", "tok-a")
+	if code != http.StatusOK || len(fills) != 7 {
+		t.Fatalf("all fills = %d with %d fills, want 200 with 7", code, len(fills))
+	}
+	if fills[0].InstID != "ETH-USDT" {
+		t.Errorf("first fill is %s, want the newest (ETH-USDT)", fills[0].InstID)
+	}
+	if _, fills := get("?instId=btcusdt&limit=2", "tok-a"); len(fills) != 2 || fills[0].InstID != "BTC-USDT" {
+		t.Errorf("instId and limit filter = %+v", fills)
+	}
+	ts := fills[0].ts()
+	if _, fills := get("?begin="+fills[0].Ts+"&end="+fills[0].Ts, "tok-a"); len(fills) == 0 || fills[0].ts() != ts {
+		t.Errorf("begin and end filter = %+v", fills)
+	}
+	if code, _ := get("?limit=0", "tok-a"); code != http.StatusBadRequest {
+		t.Errorf("limit=0 = %d, want 400", code)
+	}
+	if code, _ := get("?begin=yesterday", "tok-a"); code != http.StatusBadRequest {
+		t.Errorf("begin=yesterday = %d, want 400", code)
+	}
+}
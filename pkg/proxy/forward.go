@@ -0,0 +1,174 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+)
+
+// Per-request state shared by the reverse proxy's callbacks
+type forwardState struct {
+	record   bool
+	mirror   bool
+	tenant   *tenant
+	debug    bool
+	body     []byte      // buffered request body when recording, mirroring, signing or debugging
+	sent     http.Header // outbound headers, kept for debug captures
+	resp     *http.Response
+	recorded bytes.Buffer
+	complete bool // the whole response body reached the client
+}
+
+type forwardKey struct{}
+
+func forwardStateFrom(ctx context.Context) *forwardState {
+	state, _ := ctx.Value(forwardKey{}).(*forwardState)
+	if state == nil {
+		return &forwardState{}
+	}
+	return state
+}
+
+// Failure raised by a request or response hook
+type hookError struct {
+	err      error
+	rejected bool // by OnRequest
+}
+
+func (e *hookError) Error() string { return e.err.Error() }
+
+// Reverse proxy that forwards /api requests to BloFin. httputil takes care
+// of hop-by-hop and Connection-listed headers, trailers, 1xx responses and
+// cancelling the upstream call when the client goes away.
+func (s *server) newForwarder(transport http.RoundTripper) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			state := forwardStateFrom(pr.In.Context())
+			target, _ := url.Parse(s.hosts.best())
+			pr.SetURL(target)
+			pr.Out.Header.Del(TENANT_HEADER)
+			pr.Out.Header.Del(DEBUG_HEADER)
+			pr.Out.Header.Del(TIMEOUT_HEADER)
+			if state.debug {
+				// The admin token is for the proxy, not BloFin
+				pr.Out.Header.Del("Authorization")
+			}
+			s.blofin.headers.apply(pr.Out.Header, pr.In.URL.Path)
+			if state.record || state.mirror || state.debug || state.tenant != nil {
+				// Let the transport handle compression so the body can be
+				// inspected, for timestamp rejections on signed requests too
+				pr.Out.Header.Del("Accept-Encoding")
+			}
+		},
+		Transport:      &forwardTransport{hooks: s.hooks, base: transport, clock: s.blofin.clock},
+		ModifyResponse: s.modifyResponse,
+		ErrorHandler:   s.forwardError,
+		BufferPool:     proxyBufferPool{},
+		ErrorLog:       log.Default(),
+	}
+}
+
+// Runs request hooks and tenant signing on the final outbound request,
+// signing again once if BloFin rejects the timestamp
+type forwardTransport struct {
+	hooks hookChain
+	base  http.RoundTripper
+	clock *signingClock
+}
+
+func (t *forwardTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.hooks.request(req); err != nil {
+		return nil, &hookError{err: err, rejected: true}
+	}
+	state := forwardStateFrom(req.Context())
+	for retried := false; ; retried = true {
+		if state.tenant != nil {
+			if retried {
+				req = req.Clone(req.Context())
+				req.Body = http.NoBody
+				if len(state.body) > 0 {
+					req.Body = io.NopCloser(bytes.NewReader(state.body))
+				}
+			}
+			state.tenant.sign(req, state.body, t.clock.now())
+		}
+		if state.debug {
+			state.sent = req.Header.Clone()
+		}
+		resp, err := t.base.RoundTrip(req)
+		if err != nil || state.tenant == nil || !t.clock.resign(resp.Header, peekBody(resp), retried) {
+			return resp, err
+		}
+		resp.Body.Close()
+	}
+}
+
+func (s *server) modifyResponse(resp *http.Response) error {
+	if err := s.hooks.response(resp.Request, resp); err != nil {
+		return &hookError{err: err}
+	}
+	state := forwardStateFrom(resp.Request.Context())
+	state.resp = resp
+	if state.record || state.mirror || state.debug {
+		resp.Body = &teeBody{ReadCloser: resp.Body, state: state}
+	}
+	return nil
+}
+
+func (s *server) forwardError(w http.ResponseWriter, r *http.Request, err error) {
+	var hookErr *hookError
+	switch {
+	case errors.As(err, &hookErr) && hookErr.rejected:
+		hookRejected(w, r, hookErr.err)
+	case errors.As(err, &hookErr):
+		log.Printf("🪝 Hook failed %s %s: %v", r.Method, r.URL.Path, hookErr.err)
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "Proxy request failed"})
+	case errors.Is(err, errCircuitOpen):
+		w.Header().Set("Retry-After", strconv.Itoa(int(s.cfg.Breaker.Cooldown.Seconds())))
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "BloFin is failing, requests are paused briefly"})
+	case errors.Is(err, context.DeadlineExceeded):
+		log.Printf("⏱️ BloFin did not answer %s %s in time", r.Method, r.URL.Path)
+		s.hooks.error(r, err)
+		deadlineExceeded(w)
+	case clientGone(r):
+		log.Printf("🔌 Client went away before BloFin answered: %s %s", r.Method, r.URL.Path)
+		s.hooks.error(r, err)
+	default:
+		log.Printf("❌ Proxy request failed: %v", err)
+		s.hooks.error(r, err)
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "Proxy request failed"})
+	}
+}
+
+// Keeps a copy of the response body for the cassette recorder, shadow
+// diffing and debug captures, noting whether it was read to the end
+type teeBody struct {
+	io.ReadCloser
+	state *forwardState
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.state.recorded.Write(p[:n])
+	if err == io.EOF {
+		b.state.complete = true
+	}
+	return n, err
+}
+
+// Lends the pooled copy buffers to httputil
+type proxyBufferPool struct{}
+
+func (proxyBufferPool) Get() []byte {
+	return *copyBuffers.Get().(*[]byte)
+}
+
+func (proxyBufferPool) Put(b []byte) {
+	copyBuffers.Put(&b)
+}
@@ -0,0 +1,265 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_FUNDING_POLL_INTERVAL = time.Minute
+	MAX_FUNDING_HISTORY           = 500 // per instrument, about five months of 8-hourly rates
+	DEFAULT_FUNDING_LIMIT         = 100
+)
+
+// Funding rate as returned by /api/v1/market/funding-rate and
+// /api/v1/market/funding-rate-history
+type fundingRate struct {
+	InstID      string `json:"instId"`
+	FundingRate string `json:"fundingRate"`
+	FundingTime string `json:"fundingTime"`
+}
+
+// Polls current funding rates for every instrument and keeps a history of
+// settled ones in DATA_DIR/funding.json. An instrument's history is seeded
+// from BloFin the first time it's asked for and then extended locally each
+// time a funding time passes, so dashboards need no per-instrument calls.
+// Polling only starts once something needs funding rates.
+type fundingFeed struct {
+	client   *blofinClient
+	interval time.Duration
+	file     string
+
+	once      sync.Once
+	pollMu    sync.Mutex // one poll at a time
+	mu        sync.Mutex
+	current   map[string]fundingRate
+	updatedAt time.Time
+	history   map[string][]fundingRate // newest first
+	seeded    map[string]bool          // history fetched from BloFin this run
+}
+
+func newFundingFeed(client *blofinClient, interval time.Duration, dataDir string) (*fundingFeed, error) {
+	f := &fundingFeed{
+		client:   client,
+		interval: interval,
+		file:     filepath.Join(dataDir, "funding.json"),
+		history:  map[string][]fundingRate{},
+		seeded:   map[string]bool{},
+	}
+	data, err := os.ReadFile(f.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &f.history); err != nil {
+		return nil, fmt.Errorf("%s: %v", f.file, err)
+	}
+	return f, nil
+}
+
+func (f *fundingFeed) start() {
+	f.once.Do(func() {
+		log.Printf("💸 Polling funding rates every %s", f.interval)
+		go f.run()
+	})
+}
+
+func (f *fundingFeed) run() {
+	for {
+		if err := f.poll(); err != nil {
+			log.Printf("❌ Funding rate poll failed: %v", err)
+		}
+		time.Sleep(f.interval)
+	}
+}
+
+func (f *fundingFeed) poll() error {
+	f.pollMu.Lock()
+	defer f.pollMu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var list []fundingRate
+	if err := f.client.get(ctx, "/api/v1/market/funding-rate", nil, &list); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	settled := false
+	current := make(map[string]fundingRate, len(list))
+	for _, rate := range list {
+		current[rate.InstID] = rate
+		// Once the funding time moves on, the previous rate was the one
+		// that got settled
+		if prev, ok := f.current[rate.InstID]; ok && prev.FundingTime != rate.FundingTime {
+			settled = f.addLocked(rate.InstID, []fundingRate{prev}) || settled
+		}
+	}
+	f.current = current
+	f.updatedAt = time.Now()
+	if settled {
+		f.saveLocked()
+	}
+	return nil
+}
+
+// Merge rates into an instrument's history, newest first and capped;
+// reports whether anything was new
+func (f *fundingFeed) addLocked(instID string, rates []fundingRate) bool {
+	seen := map[string]bool{}
+	for _, rate := range f.history[instID] {
+		seen[rate.FundingTime] = true
+	}
+	merged := f.history[instID]
+	for _, rate := range rates {
+		if !seen[rate.FundingTime] {
+			seen[rate.FundingTime] = true
+			merged = append(merged, rate)
+		}
+	}
+	if len(merged) == len(f.history[instID]) {
+		return false
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		a, _ := strconv.ParseInt(merged[i].FundingTime, 10, 64)
+		b, _ := strconv.ParseInt(merged[j].FundingTime, 10, 64)
+		return a > b
+	})
+	if len(merged) > MAX_FUNDING_HISTORY {
+		merged = merged[:MAX_FUNDING_HISTORY]
+	}
+	f.history[instID] = merged
+	return true
+}
+
+func (f *fundingFeed) saveLocked() {
+	data, err := json.Marshal(f.history)
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(f.file), 0o755); err == nil {
+			tmp := f.file + ".tmp"
+			if err = os.WriteFile(tmp, data, 0o644); err == nil {
+				err = os.Rename(tmp, f.file)
+			}
+		}
+	}
+	if err != nil {
+		log.Printf("❌ Failed to save funding history: %v", err)
+	}
+}
+
+// Latest rates and when they were polled, fetching synchronously if the
+// snapshot is missing or stale
+func (f *fundingFeed) rates() (map[string]fundingRate, time.Time, error) {
+	f.start()
+	fresh := func() (map[string]fundingRate, time.Time, bool) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.current, f.updatedAt, time.Since(f.updatedAt) <= 2*f.interval
+	}
+	if current, at, ok := fresh(); ok {
+		return current, at, nil
+	}
+	// Wait for any poll in flight rather than starting a second one
+	f.pollMu.Lock()
+	f.pollMu.Unlock()
+	if current, at, ok := fresh(); ok {
+		return current, at, nil
+	}
+	if err := f.poll(); err != nil {
+		return nil, time.Time{}, err
+	}
+	current, at, _ := fresh()
+	return current, at, nil
+}
+
+// Settled rates for instID, newest first, seeding from BloFin once per run
+func (f *fundingFeed) historyFor(ctx context.Context, instID string, limit int) ([]fundingRate, error) {
+	f.mu.Lock()
+	seeded := f.seeded[instID]
+	f.mu.Unlock()
+	if !seeded {
+		var rates []fundingRate
+		query := url.Values{"instId": {instID}, "limit": {strconv.Itoa(DEFAULT_FUNDING_LIMIT)}}
+		if err := f.client.get(ctx, "/api/v1/market/funding-rate-history", query, &rates); err != nil {
+			return nil, err
+		}
+		f.mu.Lock()
+		f.seeded[instID] = true
+		if f.addLocked(instID, rates) {
+			f.saveLocked()
+		}
+		f.mu.Unlock()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	history := f.history[instID]
+	if len(history) > limit {
+		history = history[:limit]
+	}
+	return append([]fundingRate{}, history...), nil
+}
+
+// GET /local/funding returns every instrument's current funding rate;
+// with ?instId=BTC-USDT&limit=50 it returns that instrument's settled
+// history, newest first, with the current rate alongside
+func (f *fundingFeed) handleLocal(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	instID := query.Get("instId")
+	limit := DEFAULT_FUNDING_LIMIT
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = min(n, MAX_FUNDING_HISTORY)
+	}
+
+	current, updatedAt, err := f.rates()
+	if err != nil {
+		log.Printf("❌ Failed to load funding rates: %v", err)
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "Funding rates are unavailable"})
+		return
+	}
+	if instID == "" {
+		data := make([]fundingRate, 0, len(current))
+		for _, rate := range current {
+			data = append(data, rate)
+		}
+		sort.Slice(data, func(i, j int) bool { return data[i].InstID < data[j].InstID })
+		writeJSON(w, http.StatusOK, map[string]interface{}{"code": "0", "msg": "", "data": data, "updatedAt": updatedAt.UnixMilli()})
+		return
+	}
+
+	rate, ok := current[instID]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Unknown instrument " + instID})
+		return
+	}
+	history, err := f.historyFor(r.Context(), instID, limit)
+	if err != nil {
+		log.Printf("❌ Failed to load funding history for %s: %v", instID, err)
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "Funding history is unavailable"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"code":      "0",
+		"msg":       "",
+		"data":      history,
+		"current":   rate,
+		"updatedAt": updatedAt.UnixMilli(),
+	})
+}
@@ -0,0 +1,608 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// How deeply GraphQL selections may nest
+const MAX_GRAPHQL_DEPTH = 8
+
+// A field of a GraphQL selection set
+type gqlField struct {
+	alias     string
+	name      string
+	args      map[string]interface{}
+	selection []*gqlField // nil for scalars
+}
+
+// The key the field's value is answered under
+func (f *gqlField) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+// Recursive-descent parser for the part of GraphQL /graphql understands:
+// one query, anonymous or named, with variables, aliases, arguments and
+// nested selections. Fragments, directives, mutations and subscriptions
+// are refused. The first error sticks and ends parsing.
+type gqlParser struct {
+	src  string
+	pos  int
+	vars map[string]interface{}
+	err  error
+}
+
+func parseGraphQL(query string, vars map[string]interface{}) ([]*gqlField, error) {
+	if vars == nil {
+		vars = map[string]interface{}{}
+	}
+	p := &gqlParser{src: query, vars: vars}
+	p.skip()
+	if p.peek() != '{' {
+		switch keyword := p.name(); keyword {
+		case "query":
+		case "mutation", "subscription":
+			return nil, fmt.Errorf("%s operations are not supported, only queries", keyword)
+		case "fragment":
+			return nil, fmt.Errorf("fragments are not supported")
+		default:
+			p.fail("expected a query")
+		}
+		if p.skip(); p.peek() != '{' && p.peek() != '(' {
+			p.name() // operation name
+		}
+		if p.skip(); p.peek() == '(' {
+			p.variableDefinitions()
+		}
+	}
+	fields := p.selectionSet(1)
+	if p.skip(); p.err == nil && p.pos < len(p.src) {
+		p.fail("only one operation per request is supported")
+	}
+	return fields, p.err
+}
+
+func (p *gqlParser) fail(format string, args ...interface{}) {
+	if p.err == nil {
+		p.err = fmt.Errorf("syntax error at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+	}
+}
+
+// Skip whitespace, commas and comments
+func (p *gqlParser) skip() {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// The next character, or 0 at the end or after an error, which ends
+// every loop
+func (p *gqlParser) peek() byte {
+	if p.err != nil || p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *gqlParser) expect(c byte) {
+	if p.skip(); p.peek() != c {
+		p.fail("expected %q", c)
+		return
+	}
+	p.pos++
+}
+
+func (p *gqlParser) name() string {
+	p.skip()
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (p.pos > start && c >= '0' && c <= '9') {
+			p.pos++
+			continue
+		}
+		break
+	}
+	if start == p.pos {
+		p.fail("expected a name")
+	}
+	return p.src[start:p.pos]
+}
+
+// ($name: Type = default, ...); types aren't checked, defaults fill in
+// variables the request left out
+func (p *gqlParser) variableDefinitions() {
+	p.expect('(')
+	for p.skip(); p.peek() != ')' && p.peek() != 0; p.skip() {
+		p.expect('$')
+		name := p.name()
+		p.expect(':')
+		p.typeRef()
+		if p.skip(); p.peek() == '=' {
+			p.pos++
+			value := p.value()
+			if _, ok := p.vars[name]; !ok {
+				p.vars[name] = value
+			}
+		}
+	}
+	p.expect(')')
+}
+
+func (p *gqlParser) typeRef() {
+	if p.skip(); p.peek() == '[' {
+		p.pos++
+		p.typeRef()
+		p.expect(']')
+	} else {
+		p.name()
+	}
+	if p.skip(); p.peek() == '!' {
+		p.pos++
+	}
+}
+
+func (p *gqlParser) selectionSet(depth int) []*gqlField {
+	if depth > MAX_GRAPHQL_DEPTH {
+		p.fail("selections nest deeper than %d levels", MAX_GRAPHQL_DEPTH)
+		return nil
+	}
+	p.expect('{')
+	var fields []*gqlField
+	for p.skip(); p.peek() != '}' && p.peek() != 0; p.skip() {
+		if strings.HasPrefix(p.src[p.pos:], "...") {
+			p.fail("fragments are not supported")
+			break
+		}
+		field := &gqlField{name: p.name()}
+		if p.skip(); p.peek() == ':' {
+			p.pos++
+			field.alias, field.name = field.name, p.name()
+		}
+		if p.skip(); p.peek() == '(' {
+			field.args = p.arguments()
+		}
+		if p.skip(); p.peek() == '@' {
+			p.fail("directives are not supported")
+			break
+		}
+		if p.skip(); p.peek() == '{' {
+			field.selection = p.selectionSet(depth + 1)
+		}
+		fields = append(fields, field)
+	}
+	p.expect('}')
+	if p.err == nil && len(fields) == 0 {
+		p.fail("empty selection set")
+	}
+	return fields
+}
+
+func (p *gqlParser) arguments() map[string]interface{} {
+	p.expect('(')
+	args := map[string]interface{}{}
+	for p.skip(); p.peek() != ')' && p.peek() != 0; p.skip() {
+		name := p.name()
+		p.expect(':')
+		args[name] = p.value()
+	}
+	p.expect(')')
+	return args
+}
+
+// A value: variables, strings, numbers (as json.Number), booleans, null,
+// enums (as strings) and lists
+func (p *gqlParser) value() interface{} {
+	p.skip()
+	switch c := p.peek(); {
+	case c == '$':
+		p.pos++
+		return p.vars[p.name()]
+	case c == '"':
+		if strings.HasPrefix(p.src[p.pos:], `"""`) {
+			p.fail("block strings are not supported")
+			return nil
+		}
+		start := p.pos
+		for p.pos++; p.pos < len(p.src) && p.src[p.pos] != '"'; p.pos++ {
+			if p.src[p.pos] == '\\' {
+				p.pos++
+			}
+		}
+		p.pos++
+		var s string
+		if p.pos > len(p.src) || json.Unmarshal([]byte(p.src[start:p.pos]), &s) != nil {
+			p.fail("invalid string")
+		}
+		return s
+	case c == '[':
+		p.pos++
+		list := []interface{}{}
+		for p.skip(); p.peek() != ']' && p.peek() != 0; p.skip() {
+			list = append(list, p.value())
+		}
+		p.expect(']')
+		return list
+	case c == '{':
+		p.fail("object values are not supported")
+		return nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		for p.pos < len(p.src) && strings.IndexByte("-+.eE0123456789", p.src[p.pos]) >= 0 {
+			p.pos++
+		}
+		return json.Number(p.src[start:p.pos])
+	}
+	switch name := p.name(); name {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	default:
+		return name
+	}
+}
+
+// A string argument, or a list of them; a single string is a list of one
+func gqlStrings(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		var list []string
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a list of strings")
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("expected a list of strings")
+}
+
+// An object answered with its fields in selection order
+type gqlObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *gqlObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		value, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Answer field from value, decoded JSON: objects keep the selected
+// fields, lists are answered item by item, and fields BloFin didn't send
+// are null
+func gqlProject(field *gqlField, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			projected, err := gqlProject(field, item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = projected
+		}
+		return out, nil
+	case map[string]interface{}:
+		if field.selection == nil {
+			return nil, fmt.Errorf("field %q must have a selection of subfields", field.name)
+		}
+		out := &gqlObject{values: map[string]interface{}{}}
+		for _, sub := range field.selection {
+			if len(sub.args) > 0 {
+				return nil, fmt.Errorf("field %q takes no arguments", sub.name)
+			}
+			if _, ok := out.values[sub.key()]; ok {
+				return nil, fmt.Errorf("field %q is selected twice; give one an alias", sub.key())
+			}
+			projected, err := gqlProject(sub, v[sub.name])
+			if err != nil {
+				return nil, err
+			}
+			out.keys = append(out.keys, sub.key())
+			out.values[sub.key()] = projected
+		}
+		return out, nil
+	}
+	if field.selection != nil && value != nil {
+		return nil, fmt.Errorf("field %q has no subfields", field.name)
+	}
+	return value, nil
+}
+
+// Round-trip v through JSON so it can be projected like a BloFin response
+func gqlGeneric(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	err = json.Unmarshal(data, &generic)
+	return generic, err
+}
+
+type gqlRoot struct {
+	path    string // BloFin route read, for tenant permissions; empty for cached public data
+	args    []string
+	resolve func(s *server, ctx context.Context, args map[string]interface{}, t *tenant) (interface{}, error)
+}
+
+// Query's fields. Public data comes from the proxy's caches, private data
+// is read from BloFin signed for the tenant.
+var gqlRoots = map[string]gqlRoot{
+	"tickers":     {"", []string{"instIds"}, (*server).gqlTickers},
+	"instruments": {"", []string{"instIds"}, (*server).gqlInstruments},
+	"positions":   {"/api/v1/account/positions", []string{"instId"}, (*server).gqlPositions},
+	"balances":    {"/api/v1/account/balance", nil, (*server).gqlBalances},
+	"openOrders":  {"/api/v1/trade/orders-pending", []string{"instId"}, (*server).gqlOpenOrders},
+}
+
+type gqlError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+// GET or POST /graphql answers a GraphQL query over tickers, instruments,
+// positions, balances and open orders. Each top-level field is resolved
+// concurrently, and a field that fails is null with its error listed,
+// leaving the others intact.
+func (s *server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+	if r.Method == http.MethodGet {
+		req.Query = r.URL.Query().Get("query")
+		if vars := r.URL.Query().Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]interface{}{"errors": []gqlError{{Message: "variables must be a JSON object"}}})
+				return
+			}
+		}
+	} else if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MAX_INSPECT_BODY)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"errors": []gqlError{{Message: "Invalid JSON: " + err.Error()}}})
+		return
+	}
+	fields, err := parseGraphQL(req.Query, req.Variables)
+	if err == nil {
+		err = checkGraphQLRoots(fields)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"errors": []gqlError{{Message: err.Error()}}})
+		return
+	}
+
+	t, err := s.tenants.fromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Invalid " + TENANT_HEADER})
+		return
+	}
+	if !s.quotas.spend(t) {
+		quotaExceeded(w, t)
+		return
+	}
+
+	data := &gqlObject{values: map[string]interface{}{}}
+	errs := make([]*gqlError, len(fields))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for i, field := range fields {
+		data.keys = append(data.keys, field.key())
+		wg.Add(1)
+		go func(i int, field *gqlField) {
+			defer wg.Done()
+			value, err := s.resolveGraphQL(r.Context(), field, t)
+			mu.Lock()
+			defer mu.Unlock()
+			data.values[field.key()] = value
+			if err != nil {
+				errs[i] = &gqlError{Message: err.Error(), Path: []string{field.key()}}
+			}
+		}(i, field)
+	}
+	wg.Wait()
+	if clientGone(r) {
+		log.Printf("🔌 Client went away before BloFin answered: graphql")
+		return
+	}
+
+	resp := map[string]interface{}{"data": data}
+	var listed []*gqlError
+	for _, e := range errs {
+		if e != nil {
+			listed = append(listed, e)
+		}
+	}
+	if len(listed) > 0 {
+		resp["errors"] = listed
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Refuse unknown top-level fields and arguments before anything is fetched
+func checkGraphQLRoots(fields []*gqlField) error {
+	seen := map[string]bool{}
+	for _, field := range fields {
+		root, ok := gqlRoots[field.name]
+		if !ok {
+			names := make([]string, 0, len(gqlRoots))
+			for name := range gqlRoots {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("cannot query field %q on type Query (available: %s)", field.name, strings.Join(names, ", "))
+		}
+		if seen[field.key()] {
+			return fmt.Errorf("field %q is selected twice; give one an alias", field.key())
+		}
+		seen[field.key()] = true
+		for arg := range field.args {
+			found := false
+			for _, known := range root.args {
+				found = found || arg == known
+			}
+			if !found {
+				return fmt.Errorf("unknown argument %q on field %q", arg, field.name)
+			}
+		}
+		if field.selection == nil {
+			return fmt.Errorf("field %q must have a selection of subfields", field.name)
+		}
+	}
+	return nil
+}
+
+func (s *server) resolveGraphQL(ctx context.Context, field *gqlField, t *tenant) (interface{}, error) {
+	root := gqlRoots[field.name]
+	if root.path != "" {
+		// Mock mode never contacts BloFin, so it needs no credentials
+		if t == nil && s.mock == nil {
+			return nil, fmt.Errorf("%s needs the %s of a configured tenant", field.name, TENANT_HEADER)
+		}
+		if err := t.allows(http.MethodGet, root.path); err != nil {
+			return nil, err
+		}
+	}
+	value, err := root.resolve(s, ctx, field.args, t)
+	if err != nil {
+		var refused *blofinError
+		if !errors.As(err, &refused) {
+			log.Printf("❌ GraphQL %s failed: %v", field.name, err)
+		}
+		return nil, err
+	}
+	return gqlProject(field, value)
+}
+
+// The items keyed by instIDs, in that order, skipping unknown ones; all
+// of them sorted by instId when instIDs is empty
+func gqlByInstID[T any](items map[string]T, instIDs []string) []T {
+	out := []T{}
+	if len(instIDs) == 0 {
+		keys := make([]string, 0, len(items))
+		for key := range items {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			out = append(out, items[key])
+		}
+		return out
+	}
+	for _, id := range instIDs {
+		if item, ok := items[normalizeInstID(id)]; ok {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func (s *server) gqlTickers(_ context.Context, args map[string]interface{}, _ *tenant) (interface{}, error) {
+	instIDs, err := gqlStrings(args["instIds"])
+	if err != nil {
+		return nil, fmt.Errorf("instIds: %v", err)
+	}
+	latest, _, err := s.tickers.current()
+	if err != nil {
+		return nil, err
+	}
+	return gqlGeneric(gqlByInstID(latest, instIDs))
+}
+
+func (s *server) gqlInstruments(_ context.Context, args map[string]interface{}, _ *tenant) (interface{}, error) {
+	instIDs, err := gqlStrings(args["instIds"])
+	if err != nil {
+		return nil, fmt.Errorf("instIds: %v", err)
+	}
+	list, _, err := s.instruments.list()
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]instrument, len(list))
+	for _, inst := range list {
+		byID[inst.InstID] = inst
+	}
+	return gqlGeneric(gqlByInstID(byID, instIDs))
+}
+
+// A private BloFin list, optionally for one instrument
+func (s *server) gqlPrivateList(ctx context.Context, path string, args map[string]interface{}, t *tenant) (interface{}, error) {
+	query := url.Values{}
+	if instID, ok := args["instId"].(string); ok && instID != "" {
+		query.Set("instId", normalizeInstID(instID))
+	} else if args["instId"] != nil {
+		return nil, fmt.Errorf("instId must be a string")
+	}
+	var list interface{}
+	if err := s.blofin.do(ctx, http.MethodGet, path, query, nil, t, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s *server) gqlPositions(ctx context.Context, args map[string]interface{}, t *tenant) (interface{}, error) {
+	return s.gqlPrivateList(ctx, "/api/v1/account/positions", args, t)
+}
+
+func (s *server) gqlOpenOrders(ctx context.Context, args map[string]interface{}, t *tenant) (interface{}, error) {
+	return s.gqlPrivateList(ctx, "/api/v1/trade/orders-pending", args, t)
+}
+
+// The futures account's balance per currency
+func (s *server) gqlBalances(ctx context.Context, _ map[string]interface{}, t *tenant) (interface{}, error) {
+	var balance struct {
+		Details interface{} `json:"details"`
+	}
+	if err := s.blofin.do(ctx, http.MethodGet, "/api/v1/account/balance", nil, nil, t, &balance); err != nil {
+		return nil, err
+	}
+	if balance.Details == nil {
+		return []interface{}{}, nil
+	}
+	return balance.Details, nil
+}
@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseGraphQL(t *testing.T) {
+	fields, err := parseGraphQL(`query Dash($ids: [String!] = ["ETH-USDT"], $inst: String) {
+		# comments and commas are ignored
+		btc: tickers(instIds: ["BTC-USDT", "ETH-USDT"]) { instId, last }
+		others: tickers(instIds: $ids) { instId }
+		positions(instId: $inst) { instId positions }
+	}`, map[string]interface{}{"inst": "BTC-USDT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 3 || fields[0].key() != "btc" || fields[0].name != "tickers" || len(fields[0].selection) != 2 {
+		t.Fatalf("fields = %+v", fields)
+	}
+	if ids, _ := gqlStrings(fields[0].args["instIds"]); strings.Join(ids, ",") != "BTC-USDT,ETH-USDT" {
+		t.Errorf("list argument = %v", fields[0].args)
+	}
+	if ids, _ := gqlStrings(fields[1].args["instIds"]); strings.Join(ids, ",") != "ETH-USDT" {
+		t.Errorf("variable default = %v", fields[1].args)
+	}
+	if fields[2].args["instId"] != "BTC-USDT" {
+		t.Errorf("variable = %v", fields[2].args)
+	}
+
+	for query, want := range map[string]string{
+		`mutation { placeOrder }`:                               "only queries",
+		`{ tickers { ...TickerFields } }`:                       "fragments are not supported",
+		`fragment F on Ticker { last }`:                         "fragments are not supported",
+		`{ tickers @skip(if: true) { last } }`:                  "directives are not supported",
+		`{ tickers(instIds: {a: 1}) { last } }`:                 "object values are not supported",
+		`{ tickers { last }`:                                    "expected '}'",
+		`{ }`:                                                   "empty selection set",
+		`{ tickers { last } } { instruments { instId } }`:       "only one operation",
+		`{ a { b { c { d { e { f { g { h { i } } } } } } } } }`: "nest deeper",
+		`{ tickers(instIds: "BTC) { last } }`:                   "invalid string",
+	} {
+		if _, err := parseGraphQL(query, nil); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parseGraphQL(%s) = %v, want %q", query, err, want)
+		}
+	}
+}
+
+func TestGraphQL(t *testing.T) {
+	p := newTestProxy(t, "")
+	query := func(token, q string) (int, map[string]interface{}, string) {
+		t.Helper()
+		body, _ := json.Marshal(map[string]interface{}{"query": q})
+		req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+		if token != "" {
+			req.Header.Set(TENANT_HEADER, token)
+		}
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+		var resp map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp, rec.Body.String()
+	}
+
+	code, resp, raw := query("tok-a", `{
+		tickers(instIds: ["ETH-USDT", "BTC-USDT", "NOPE-USDT"]) { last instId }
+		instruments(instIds: "BTC-USDT") { instId tickSize notAField }
+		positions { instId }
+		balances { currency }
+		openOrders(instId: "BTC-USDT") { orderId }
+	}`)
+	if code != http.StatusOK || resp["errors"] != nil {
+		t.Fatalf("query = %d %s", code, raw)
+	}
+	// Fields come back in the order they were asked for
+	if !strings.Contains(raw, `"tickers":[{"last":`) || !strings.Contains(raw, `"instId":"ETH-USDT"},{"last":`) {
+		t.Errorf("tickers = %s", raw)
+	}
+	if !strings.Contains(raw, `"instruments":[{"instId":"BTC-USDT","tickSize":"0.1","notAField":null}]`) {
+		t.Errorf("instruments = %s", raw)
+	}
+	data := resp["data"].(map[string]interface{})
+	for _, field := range []string{"positions", "balances", "openOrders"} {
+		if _, ok := data[field].([]interface{}); !ok {
+			t.Errorf("%s = %v", field, data[field])
+		}
+	}
+
+	// A failing field is null, the rest still answer
+	code, resp, raw = query("", `{ tickers(instIds: "BTC-USDT") { instId } last: tickers(instIds: 5) { instId } }`)
+	errs, _ := resp["errors"].([]interface{})
+	if code != http.StatusOK || len(errs) != 1 || !strings.Contains(raw, `"last":null`) || !strings.Contains(raw, `"tickers":[{"instId":"BTC-USDT"}]`) {
+		t.Errorf("partial failure = %d %s", code, raw)
+	}
+
+	for q, want := range map[string]string{
+		`{ orders { orderId } }`:                  "cannot query field",
+		`{ tickers(symbol: "BTC") { last } }`:     "unknown argument",
+		`{ tickers }`:                             "must have a selection",
+		`{ tickers { last } tickers { instId } }`: "selected twice",
+	} {
+		if code, _, raw := query("", q); code != http.StatusBadRequest || !strings.Contains(raw, want) {
+			t.Errorf("%s = %d %s, want %q", q, code, raw, want)
+		}
+	}
+	// Selections are checked against the data once it is in
+	if code, _, raw := query("", `{ tickers(instIds: "BTC-USDT") { last { x } } }`); code != http.StatusOK || !strings.Contains(raw, `"tickers":null`) || !strings.Contains(raw, "has no subfields") {
+		t.Errorf("subfields of a scalar = %d %s", code, raw)
+	}
+
+	// GET takes the query and variables from the query string
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/graphql?"+url.Values{
+		"query":     {`query($ids: [String!]) { tickers(instIds: $ids) { instId } }`},
+		"variables": {`{"ids":["BTC-USDT"]}`},
+	}.Encode(), nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"data":{"tickers":[{"instId":"BTC-USDT"}]}}`+"\n" {
+		t.Errorf("GET = %d %s", rec.Code, rec.Body)
+	}
+}
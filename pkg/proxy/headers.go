@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// A header added to upstream requests whose path starts with Prefix.
+// Defaults are only set when the client didn't send the header; overrides
+// always replace it.
+type StaticHeader struct {
+	Prefix   string
+	Name     string
+	Value    string
+	Override bool
+}
+
+type staticHeaders []StaticHeader
+
+func (hs staticHeaders) apply(h http.Header, path string) {
+	for _, sh := range hs {
+		if !strings.HasPrefix(path, sh.Prefix) {
+			continue
+		}
+		if sh.Override || h.Get(sh.Name) == "" {
+			h.Set(sh.Name, sh.Value)
+		}
+	}
+}
+
+// Parse "[/path/prefix ]Name: value" entries
+func parseStaticHeaders(entries []string, override bool) ([]StaticHeader, error) {
+	var headers []StaticHeader
+	for _, entry := range entries {
+		prefix := "/"
+		if strings.HasPrefix(entry, "/") {
+			fields := strings.SplitN(entry, " ", 2)
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("%q: expected a header after the path prefix", entry)
+			}
+			prefix, entry = fields[0], strings.TrimSpace(fields[1])
+		}
+		name, value, ok := strings.Cut(entry, ":")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("%q: expected Name: value", entry)
+		}
+		headers = append(headers, StaticHeader{Prefix: prefix, Name: name, Value: value, Override: override})
+	}
+	return headers, nil
+}
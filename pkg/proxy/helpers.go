@@ -0,0 +1,517 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Entry order with take-profit and stop-loss prices, for
+// POST /helpers/order-with-tpsl. Order prices of -1 (the default) close
+// at market once triggered.
+type tpslOrderRequest struct {
+	InstID         string `json:"instId"`
+	MarginMode     string `json:"marginMode"`
+	PositionSide   string `json:"positionSide"`
+	Side           string `json:"side"`
+	OrderType      string `json:"orderType"`
+	Price          string `json:"price"`
+	Size           string `json:"size"`
+	ClientOrderID  string `json:"clientOrderId"`
+	TPTriggerPrice string `json:"tpTriggerPrice"`
+	TPOrderPrice   string `json:"tpOrderPrice"`
+	SLTriggerPrice string `json:"slTriggerPrice"`
+	SLOrderPrice   string `json:"slOrderPrice"`
+}
+
+type tpslOrderResult struct {
+	TPSLID        string `json:"tpslId"`
+	ClientOrderID string `json:"clientOrderId"`
+	Code          string `json:"code"`
+	Msg           string `json:"msg"`
+}
+
+// The error of a single order call, including one BloFin reported inside
+// an otherwise successful response
+func orderOutcome(results []blofinOrderResult, err error) error {
+	switch {
+	case err != nil:
+		return err
+	case len(results) == 0:
+		return fmt.Errorf("empty order response")
+	case results[0].Code != "" && results[0].Code != "0":
+		return &blofinError{Status: http.StatusOK, Code: results[0].Code, Msg: results[0].Msg}
+	}
+	return nil
+}
+
+// The tenant a helper acts for; nil once the request has been refused
+func (s *server) helperTenant(w http.ResponseWriter, r *http.Request) *tenant {
+	t, err := s.tenants.fromRequest(r)
+	if err != nil || t == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Order helpers need the " + TENANT_HEADER + " of a configured tenant"})
+		return nil
+	}
+	if err := t.allows(r.Method, r.URL.Path); err != nil {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+		return nil
+	}
+	if !s.checkInstruments(w, r, t) {
+		return nil
+	}
+	return t
+}
+
+// Answer a failed helper step: 400 when BloFin refused the request, 429 or
+// 403 when the proxy's order limits refused it, 502 when BloFin couldn't
+// be reached
+func helperFailed(w http.ResponseWriter, r *http.Request, step string, err error) {
+	if clientGone(r) {
+		return
+	}
+	status := http.StatusBadGateway
+	switch e := err.(type) {
+	case *blofinError:
+		if e.Code != "" {
+			status = http.StatusBadRequest
+		}
+	case *orderRefusal:
+		e.setRetryAfter(w)
+		status = e.status
+	}
+	writeJSON(w, status, map[string]string{"error": step + ": " + err.Error()})
+}
+
+func (req *tpslOrderRequest) validate() error {
+	switch {
+	case req.InstID == "":
+		return fmt.Errorf("instId is required")
+	case req.Side != "buy" && req.Side != "sell":
+		return fmt.Errorf("side must be buy or sell")
+	case !validOrderTypes[req.OrderType]:
+		return fmt.Errorf("orderType must be one of market, limit, post_only, fok, ioc")
+	case req.OrderType != "market" && req.Price == "":
+		return fmt.Errorf("price is required for %s orders", req.OrderType)
+	case req.Size == "":
+		return fmt.Errorf("size is required")
+	case req.TPTriggerPrice == "" && req.SLTriggerPrice == "":
+		return fmt.Errorf("tpTriggerPrice or slTriggerPrice is required")
+	}
+	return nil
+}
+
+// The entry order, without the TP/SL fields
+func (req *tpslOrderRequest) entry() map[string]string {
+	order := map[string]string{
+		"instId":        req.InstID,
+		"marginMode":    req.MarginMode,
+		"positionSide":  req.PositionSide,
+		"side":          req.Side,
+		"orderType":     req.OrderType,
+		"price":         req.Price,
+		"size":          req.Size,
+		"clientOrderId": req.ClientOrderID,
+	}
+	for key, value := range order {
+		if value == "" {
+			delete(order, key)
+		}
+	}
+	return order
+}
+
+// The reduce-only TP/SL order closing the entry: same position, opposite
+// side and size
+func (req *tpslOrderRequest) closing() map[string]string {
+	order := map[string]string{
+		"instId":       req.InstID,
+		"marginMode":   req.MarginMode,
+		"positionSide": req.PositionSide,
+		"side":         "sell",
+		"size":         req.Size,
+		"reduceOnly":   "true",
+	}
+	if req.Side == "sell" {
+		order["side"] = "buy"
+	}
+	if req.TPTriggerPrice != "" {
+		order["tpTriggerPrice"] = req.TPTriggerPrice
+		order["tpOrderPrice"] = req.TPOrderPrice
+		if req.TPOrderPrice == "" {
+			order["tpOrderPrice"] = "-1"
+		}
+	}
+	if req.SLTriggerPrice != "" {
+		order["slTriggerPrice"] = req.SLTriggerPrice
+		order["slOrderPrice"] = req.SLOrderPrice
+		if req.SLOrderPrice == "" {
+			order["slOrderPrice"] = "-1"
+		}
+	}
+	for key, value := range order {
+		if value == "" {
+			delete(order, key)
+		}
+	}
+	return order
+}
+
+// POST /helpers/order-with-tpsl places a tenant's entry order, then the
+// TP/SL order protecting it. When the TP/SL order fails the entry is
+// cancelled again, so the tenant isn't left with an unprotected order; a
+// market entry that already filled can't be, and the response says so.
+func (s *server) handleOrderWithTPSL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	t := s.helperTenant(w, r)
+	if t == nil {
+		return
+	}
+	var req tpslOrderRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MAX_INSPECT_BODY)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON: " + err.Error()})
+		return
+	}
+	req.InstID = normalizeInstID(req.InstID)
+	if err := req.validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if req.ClientOrderID == "" && s.cfg.AutoClientOrderID {
+		req.ClientOrderID = s.cfg.ClientOrderPrefix + newULID(time.Now())
+	}
+
+	var placed []blofinOrderResult
+	err := s.placeOrder(r.Context(), t, req.entry(), &placed)
+	if err = orderOutcome(placed, err); err != nil {
+		helperFailed(w, r, "Entry order failed", err)
+		return
+	}
+	entry := placed[0]
+
+	// Past this point the entry exists, so the steps run to the end even
+	// when the client goes away
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), s.cfg.UpstreamTimeout)
+	defer cancel()
+	var tpsl tpslOrderResult
+	err = s.sendOrder(ctx, t, "/api/v1/trade/order-tpsl", req.closing(), &tpsl)
+	if err == nil && tpsl.Code != "" && tpsl.Code != "0" {
+		err = &blofinError{Status: http.StatusOK, Code: tpsl.Code, Msg: tpsl.Msg}
+	}
+	if err == nil {
+		log.Printf("🎯 %s placed %s %s with TP/SL %s", t.Name, req.InstID, entry.OrderID, tpsl.TPSLID)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"entry": entry, "tpsl": tpsl})
+		return
+	}
+
+	log.Printf("❌ TP/SL for %s order %s failed, cancelling the entry: %v", t.Name, entry.OrderID, err)
+	response := map[string]interface{}{
+		"error":      "TP/SL order failed: " + err.Error(),
+		"entry":      entry,
+		"rolledBack": true,
+	}
+	var cancelled []blofinOrderResult
+	err = s.blofin.do(ctx, http.MethodPost, "/api/v1/trade/cancel-order", nil, map[string]string{"instId": req.InstID, "orderId": entry.OrderID}, t, &cancelled)
+	if err = orderOutcome(cancelled, err); err != nil {
+		log.Printf("⚠️ %s order %s is live without TP/SL: %v", t.Name, entry.OrderID, err)
+		response["rolledBack"] = false
+		response["rollbackError"] = err.Error()
+	}
+	writeJSON(w, http.StatusBadGateway, response)
+}
+
+// Position to close for POST /helpers/close-position: Percent of it (all
+// when unset), at market or at Price with a limit order
+type closePositionRequest struct {
+	InstID        string  `json:"instId"`
+	PositionSide  string  `json:"positionSide"`
+	MarginMode    string  `json:"marginMode"`
+	Percent       float64 `json:"percent"`
+	OrderType     string  `json:"orderType"`
+	Price         string  `json:"price"`
+	ClientOrderID string  `json:"clientOrderId"`
+}
+
+func (req *closePositionRequest) validate() error {
+	if req.OrderType == "" {
+		req.OrderType = "market"
+	}
+	if req.Percent == 0 {
+		req.Percent = 100
+	}
+	switch {
+	case req.InstID == "":
+		return fmt.Errorf("instId is required")
+	case req.Percent < 0 || req.Percent > 100:
+		return fmt.Errorf("percent must be between 0 and 100")
+	case !validOrderTypes[req.OrderType]:
+		return fmt.Errorf("orderType must be one of market, limit, post_only, fok, ioc")
+	case req.OrderType != "market" && req.Price == "":
+		return fmt.Errorf("price is required for %s orders", req.OrderType)
+	}
+	return nil
+}
+
+// Size to close: the whole position, or the percentage of it rounded down
+// to the instrument's lot size
+func closeSize(contracts string, percent float64, inst instrument) (string, error) {
+	contracts = strings.TrimPrefix(contracts, "-")
+	if percent == 100 {
+		return contracts, nil
+	}
+	held, ok := parseDecimal(contracts)
+	if !ok {
+		return "", fmt.Errorf("unreadable position size %q", contracts)
+	}
+	size := new(big.Rat).Mul(held, new(big.Rat).SetFloat64(percent/100))
+	if lot, ok := parseDecimal(inst.LotSize); ok && lot.Sign() > 0 {
+		size = floorToStep(size, lot)
+	}
+	if min, ok := parseDecimal(inst.MinSize); size.Sign() <= 0 || (ok && size.Cmp(min) < 0) {
+		return "", fmt.Errorf("%g%% of %s contracts is below the minimum size %s", percent, contracts, inst.MinSize)
+	}
+	return size.FloatString(stepDecimals(inst.LotSize)), nil
+}
+
+// POST /helpers/close-position looks up a tenant's position and sends the
+// reduce-only order closing it, on the side that reduces it
+func (s *server) handleClosePosition(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	t := s.helperTenant(w, r)
+	if t == nil {
+		return
+	}
+	var req closePositionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MAX_INSPECT_BODY)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON: " + err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	var list []map[string]string
+	if err := s.blofin.do(r.Context(), http.MethodGet, "/api/v1/account/positions", url.Values{"instId": {req.InstID}}, nil, t, &list); err != nil {
+		helperFailed(w, r, "Position lookup failed", err)
+		return
+	}
+	var matches []map[string]string
+	for _, p := range list {
+		if p["instId"] != req.InstID || (req.PositionSide != "" && p["positionSide"] != req.PositionSide) || (req.MarginMode != "" && p["marginMode"] != req.MarginMode) {
+			continue
+		}
+		if held, ok := parseDecimal(p["positions"]); ok && held.Sign() != 0 {
+			matches = append(matches, p)
+		}
+	}
+	switch {
+	case len(matches) == 0:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "No open position in " + req.InstID})
+		return
+	case len(matches) > 1:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Several positions in " + req.InstID + ", pick one with positionSide or marginMode"})
+		return
+	}
+	position := matches[0]
+
+	inst, ok, err := s.instruments.get(req.InstID)
+	if err != nil || !ok {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "Instrument specifications are unavailable for " + req.InstID})
+		return
+	}
+	size, err := closeSize(position["positions"], req.Percent, inst)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	// Long positions close by selling; net ones by the opposite of their sign
+	side := "sell"
+	if position["positionSide"] == "short" || strings.HasPrefix(position["positions"], "-") {
+		side = "buy"
+	}
+	if req.ClientOrderID == "" && s.cfg.AutoClientOrderID {
+		req.ClientOrderID = s.cfg.ClientOrderPrefix + newULID(time.Now())
+	}
+	order := map[string]string{
+		"instId":       req.InstID,
+		"marginMode":   position["marginMode"],
+		"positionSide": position["positionSide"],
+		"side":         side,
+		"orderType":    req.OrderType,
+		"size":         size,
+		"reduceOnly":   "true",
+	}
+	if req.OrderType != "market" {
+		order["price"] = req.Price
+	}
+	if req.ClientOrderID != "" {
+		order["clientOrderId"] = req.ClientOrderID
+	}
+
+	var placed []blofinOrderResult
+	err = s.placeOrder(r.Context(), t, order, &placed)
+	if err = orderOutcome(placed, err); err != nil {
+		helperFailed(w, r, "Close order failed", err)
+		return
+	}
+	log.Printf("🎯 %s closing %s of %s %s position with order %s", t.Name, size, req.InstID, position["positionSide"], placed[0].OrderID)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"order": placed[0], "side": side, "size": size, "position": position})
+}
+
+// Leverage change for POST /helpers/leverage
+type leverageRequest struct {
+	InstID       string `json:"instId"`
+	Leverage     string `json:"leverage"`
+	MarginMode   string `json:"marginMode"`
+	PositionSide string `json:"positionSide"`
+}
+
+// POST /helpers/leverage sets a tenant's leverage on an instrument after
+// checking that BloFin could accept it: at least 1, at most the
+// instrument's maxLeverage, and when lowering it on an open position, no
+// more extra margin than the account has available. BloFin's answer is
+// returned as is.
+func (s *server) handleLeverage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	t := s.helperTenant(w, r)
+	if t == nil {
+		return
+	}
+	var req leverageRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MAX_INSPECT_BODY)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON: " + err.Error()})
+		return
+	}
+	req.InstID = normalizeInstID(req.InstID)
+	leverage, ok := parseDecimal(req.Leverage)
+	switch {
+	case req.InstID == "":
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "instId is required"})
+		return
+	case req.MarginMode != "cross" && req.MarginMode != "isolated":
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "marginMode must be cross or isolated"})
+		return
+	case !ok || leverage.Cmp(big.NewRat(1, 1)) < 0:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "leverage must be a number of at least 1"})
+		return
+	}
+	inst, ok, err := s.instruments.get(req.InstID)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "Instrument specifications are unavailable for " + req.InstID})
+		return
+	}
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Unknown instrument " + req.InstID})
+		return
+	}
+	if max, ok := parseDecimal(inst.MaxLeverage); ok && leverage.Cmp(max) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("%s allows at most %sx leverage", req.InstID, inst.MaxLeverage)})
+		return
+	}
+
+	if err := s.checkLeverageMargin(r.Context(), t, req, leverage, inst); err != nil {
+		if e, ok := err.(*helperRefusal); ok {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"error": e.msg, "values": e.values})
+			return
+		}
+		helperFailed(w, r, "Position lookup failed", err)
+		return
+	}
+
+	body := map[string]string{"instId": req.InstID, "leverage": req.Leverage, "marginMode": req.MarginMode}
+	if req.PositionSide != "" {
+		body["positionSide"] = req.PositionSide
+	}
+	var result interface{}
+	if err := s.blofin.do(r.Context(), http.MethodPost, "/api/v1/account/set-leverage", nil, body, t, &result); err != nil {
+		helperFailed(w, r, "Setting leverage failed", err)
+		return
+	}
+	log.Printf("🎚️ %s set %s leverage to %sx (%s)", t.Name, req.InstID, req.Leverage, req.MarginMode)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"code": "0", "msg": "success", "data": result})
+}
+
+// A request a helper won't send because BloFin would refuse it, with the
+// numbers showing why
+type helperRefusal struct {
+	msg    string
+	values map[string]string
+}
+
+func (e *helperRefusal) Error() string { return e.msg }
+
+// Refuse a leverage cut the account can't fund: the open position's
+// margin at the new leverage, less what it holds now, has to fit in the
+// available balance of the instrument's quote currency
+func (s *server) checkLeverageMargin(ctx context.Context, t *tenant, req leverageRequest, leverage *big.Rat, inst instrument) error {
+	var positions []map[string]string
+	if err := s.blofin.do(ctx, http.MethodGet, "/api/v1/account/positions", url.Values{"instId": {req.InstID}}, nil, t, &positions); err != nil {
+		return err
+	}
+	needed := new(big.Rat)
+	held := new(big.Rat)
+	for _, p := range positions {
+		if p["instId"] != req.InstID || p["marginMode"] != req.MarginMode || (req.PositionSide != "" && p["positionSide"] != req.PositionSide) {
+			continue
+		}
+		size, sizeOK := parseDecimal(strings.TrimPrefix(p["positions"], "-"))
+		mark, markOK := parseDecimal(p["markPrice"])
+		contractValue, cvOK := parseDecimal(inst.ContractValue)
+		if !sizeOK || !markOK || !cvOK {
+			continue
+		}
+		notional := new(big.Rat).Mul(new(big.Rat).Mul(size, contractValue), mark)
+		needed.Add(needed, new(big.Rat).Quo(notional, leverage))
+		if margin, ok := parseDecimal(p["margin"]); ok {
+			held.Add(held, margin)
+		}
+	}
+	extra := new(big.Rat).Sub(needed, held)
+	if extra.Sign() <= 0 {
+		return nil
+	}
+
+	var balance struct {
+		Details []struct {
+			Currency  string `json:"currency"`
+			Available string `json:"available"`
+		} `json:"details"`
+	}
+	if err := s.blofin.do(ctx, http.MethodGet, "/api/v1/account/balance", nil, nil, t, &balance); err != nil {
+		return err
+	}
+	available := new(big.Rat)
+	for _, detail := range balance.Details {
+		if detail.Currency == inst.QuoteCurrency {
+			if value, ok := parseDecimal(detail.Available); ok {
+				available = value
+			}
+		}
+	}
+	if extra.Cmp(available) <= 0 {
+		return nil
+	}
+	return &helperRefusal{
+		msg: fmt.Sprintf("%sx on %s needs %s %s more margin than the %s available", req.Leverage, req.InstID, extra.FloatString(2), inst.QuoteCurrency, available.FloatString(2)),
+		values: map[string]string{
+			"requiredMargin":  needed.FloatString(8),
+			"positionMargin":  held.FloatString(8),
+			"availableMargin": available.FloatString(8),
+		},
+	}
+}
@@ -0,0 +1,36 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOrderWithTPSL(t *testing.T) {
+	p := newTestProxy(t, "")
+	s := p.srv
+	send := func(body string) (int, map[string]interface{}) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/helpers/order-with-tpsl", strings.NewReader(body))
+		req.Header.Set(TENANT_HEADER, "tok-a")
+		rec := httptest.NewRecorder()
+		s.handleOrderWithTPSL(rec, req)
+		var resp map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+
+	if code, resp := send(`{"instId":"btcusdt","side":"buy","orderType":"limit","price":"50000","size":"1","marginMode":"cross","positionSide":"net","slTriggerPrice":"45000"}`); code != http.StatusOK {
+		t.Fatalf("unnormalized instId = %d %v", code, resp)
+	}
+
+	// The TP/SL leg counts against the daily order cap like any order, and
+	// the entry is rolled back when it is refused
+	s.tenants.list[0].DailyOrders = 1
+	code, resp := send(`{"instId":"BTC-USDT","side":"buy","orderType":"limit","price":"49000","size":"1","marginMode":"cross","positionSide":"net","slTriggerPrice":"44000"}`)
+	if code != http.StatusBadGateway || resp["rolledBack"] != true || !strings.Contains(resp["error"].(string), "Daily cap of 1 ") {
+		t.Fatalf("TP/SL past the order cap = %d %v", code, resp)
+	}
+}
@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"log"
+	"net/http"
+)
+
+// Hook lets embedders and forks observe or change /api traffic without
+// touching the forwarding code. Hooks run in registration order on
+// requests that are forwarded upstream (not on mock, replay, dry-run or
+// chaos responses).
+type Hook interface {
+	// OnRequest sees the outbound request after headers are copied and may
+	// change its headers, URL or body. An error rejects the request with 403.
+	OnRequest(req *http.Request) error
+	// OnResponse sees BloFin's response before it is copied to the client
+	// and may change its status, headers or body. An error turns it into a 502.
+	OnResponse(req *http.Request, resp *http.Response) error
+	// OnError is told about upstream failures and hook rejections
+	OnError(req *http.Request, err error)
+}
+
+// HookFuncs adapts plain functions to Hook; nil fields are skipped
+type HookFuncs struct {
+	Request  func(req *http.Request) error
+	Response func(req *http.Request, resp *http.Response) error
+	Error    func(req *http.Request, err error)
+}
+
+func (h HookFuncs) OnRequest(req *http.Request) error {
+	if h.Request == nil {
+		return nil
+	}
+	return h.Request(req)
+}
+
+func (h HookFuncs) OnResponse(req *http.Request, resp *http.Response) error {
+	if h.Response == nil {
+		return nil
+	}
+	return h.Response(req, resp)
+}
+
+func (h HookFuncs) OnError(req *http.Request, err error) {
+	if h.Error != nil {
+		h.Error(req, err)
+	}
+}
+
+type hookChain []Hook
+
+func (c hookChain) request(req *http.Request) error {
+	for _, h := range c {
+		if err := h.OnRequest(req); err != nil {
+			c.error(req, err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (c hookChain) response(req *http.Request, resp *http.Response) error {
+	for _, h := range c {
+		if err := h.OnResponse(req, resp); err != nil {
+			c.error(req, err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (c hookChain) error(req *http.Request, err error) {
+	for _, h := range c {
+		h.OnError(req, err)
+	}
+}
+
+// Reject a request refused by a hook
+func hookRejected(w http.ResponseWriter, req *http.Request, err error) {
+	log.Printf("🪝 Hook rejected %s %s: %v", req.Method, req.URL.Path, err)
+	writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Records the hook calls it sees, and fails the steps it's told to
+type recordingHook struct {
+	name              string
+	log               *[]string
+	mu                *sync.Mutex
+	rejects, breaks   string // paths whose request or response fails
+	requestHeader     string // set on every outbound request
+	rewrittenResponse string // replaces every response body
+}
+
+func (h *recordingHook) note(event string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	*h.log = append(*h.log, h.name+" "+event)
+}
+
+func (h *recordingHook) OnRequest(req *http.Request) error {
+	h.note("request")
+	if req.URL.Path == h.rejects {
+		return errors.New(h.name + " says no")
+	}
+	if h.requestHeader != "" {
+		req.Header.Set("X-Hooked", req.Header.Get("X-Hooked")+h.requestHeader)
+	}
+	return nil
+}
+
+func (h *recordingHook) OnResponse(req *http.Request, resp *http.Response) error {
+	h.note("response")
+	if req.URL.Path == h.breaks {
+		return errors.New(h.name + " broke")
+	}
+	if h.rewrittenResponse != "" {
+		resp.Body.Close()
+		resp.Body = io.NopCloser(strings.NewReader(h.rewrittenResponse))
+		resp.ContentLength = int64(len(h.rewrittenResponse))
+		resp.Header.Del("Content-Length")
+		resp.StatusCode = http.StatusAccepted
+	}
+	return nil
+}
+
+func (h *recordingHook) OnError(req *http.Request, err error) {
+	h.note("error: " + err.Error())
+}
+
+func TestHooks(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	upstreamHits := 0
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/market/broken" {
+			panic(http.ErrAbortHandler)
+		}
+		mu.Lock()
+		upstreamHits++
+		mu.Unlock()
+		io.WriteString(w, `{"code":"0","msg":"","data":"`+r.Header.Get("X-Hooked")+`"}`)
+	})
+	first := &recordingHook{name: "first", log: &calls, mu: &mu, rejects: "/api/v1/market/rejected", requestHeader: "1"}
+	second := &recordingHook{name: "second", log: &calls, mu: &mu, breaks: "/api/v1/market/breaks", requestHeader: "2"}
+	p := newUpstreamProxy(t, upstream, "", func(cfg *Config) {
+		cfg.Hooks = []Hook{first, second, HookFuncs{}}
+	})
+	get := func(path string) (int, string, []string, int) {
+		t.Helper()
+		mu.Lock()
+		calls, upstreamHits = nil, 0
+		mu.Unlock()
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		mu.Lock()
+		defer mu.Unlock()
+		return rec.Code, rec.Body.String(), append([]string(nil), calls...), upstreamHits
+	}
+
+	// Request hooks run in order and change what is sent
+	code, body, log, _ := get("/api/v1/market/tickers")
+	if code != http.StatusOK || !strings.Contains(body, `"data":"12"`) || strings.Join(log, ",") != "first request,second request,first response,second response" {
+		t.Errorf("hooked request = %d %s, calls %v", code, body, log)
+	}
+
+	// A rejected request never reaches BloFin, and every hook hears of it
+	code, body, log, hits := get("/api/v1/market/rejected")
+	if code != http.StatusForbidden || !strings.Contains(body, "first says no") || hits != 0 || strings.Join(log, ",") != "first request,first error: first says no,second error: first says no" {
+		t.Errorf("rejected request = %d %s, %d upstream calls, calls %v", code, body, hits, log)
+	}
+
+	// A failing response hook turns the response into a 502
+	code, _, log, _ = get("/api/v1/market/breaks")
+	if code != http.StatusBadGateway || log[len(log)-1] != "second error: second broke" {
+		t.Errorf("broken response = %d, calls %v", code, log)
+	}
+
+	// Upstream failures are reported to the hooks
+	code, _, log, _ = get("/api/v1/market/broken")
+	if code != http.StatusBadGateway || !strings.HasPrefix(log[len(log)-1], "second error: ") {
+		t.Errorf("upstream failure = %d, calls %v", code, log)
+	}
+}
+
+func TestHookRewritesResponse(t *testing.T) {
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"code":"0","msg":"","data":[]}`)
+	})
+	var mu sync.Mutex
+	var calls []string
+	hook := &recordingHook{name: "rewrite", log: &calls, mu: &mu, rewrittenResponse: `{"rewritten":true}`}
+	p := newUpstreamProxy(t, upstream, "", func(cfg *Config) { cfg.Hooks = []Hook{hook} })
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/market/tickers", nil))
+	if rec.Code != http.StatusAccepted || rec.Body.String() != `{"rewritten":true}` {
+		t.Errorf("rewritten response = %d %s", rec.Code, rec.Body)
+	}
+}
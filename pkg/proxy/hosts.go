@@ -0,0 +1,189 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_PROBE_INTERVAL = 30 * time.Second
+	PROBE_PATH             = "/api/v1/market/tickers?instId=BTC-USDT"
+	PROBE_TIMEOUT          = 5 * time.Second
+	PROBE_SMOOTHING        = 0.3 // weight of the newest probe in the moving average
+)
+
+// One BloFin API host and what probing has found out about it
+type probedHost struct {
+	base string
+
+	mu        sync.Mutex
+	latency   time.Duration // moving average of successful probes
+	healthy   bool
+	probed    bool
+	lastError string
+	lastProbe time.Time
+}
+
+type hostStatus struct {
+	Base      string  `json:"base"`
+	Selected  bool    `json:"selected"`
+	Healthy   bool    `json:"healthy"`
+	LatencyMs float64 `json:"latencyMs,omitempty"`
+	LastProbe string  `json:"lastProbe,omitempty"`
+	LastError string  `json:"lastError,omitempty"`
+}
+
+// Picks the BloFin host requests go to. With several configured, each is
+// probed every interval and requests go to the healthy one with the lowest
+// average latency; until the first probes are in, and whenever none is
+// healthy, the first host is used.
+type hostSelector struct {
+	hosts    []*probedHost
+	interval time.Duration
+	client   *http.Client
+}
+
+func newHostSelector(bases []string, interval time.Duration, transport http.RoundTripper) (*hostSelector, error) {
+	if len(bases) == 0 {
+		bases = []string{BLOFIN_API_BASE}
+	}
+	s := &hostSelector{interval: interval, client: &http.Client{Transport: transport, Timeout: PROBE_TIMEOUT}}
+	for _, base := range bases {
+		base = strings.TrimRight(base, "/")
+		u, err := url.Parse(base)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return nil, fmt.Errorf("%q must be an http(s) URL without a path", base)
+		}
+		s.hosts = append(s.hosts, &probedHost{base: base})
+	}
+	if len(s.hosts) > 1 && interval <= 0 {
+		return nil, fmt.Errorf("probe interval must be positive")
+	}
+	return s, nil
+}
+
+func (s *hostSelector) bases() []string {
+	var bases []string
+	for _, h := range s.hosts {
+		bases = append(bases, h.base)
+	}
+	return bases
+}
+
+// Host names, for the DNS cache
+func (s *hostSelector) hostnames() []string {
+	var names []string
+	for _, h := range s.hosts {
+		u, _ := url.Parse(h.base)
+		names = append(names, u.Hostname())
+	}
+	return names
+}
+
+// Probe the hosts in the background; does nothing with only one
+func (s *hostSelector) start() {
+	if len(s.hosts) < 2 {
+		return
+	}
+	log.Printf("📍 Probing %d BloFin hosts every %s", len(s.hosts), s.interval)
+	go func() {
+		for {
+			var wg sync.WaitGroup
+			for _, h := range s.hosts {
+				wg.Add(1)
+				go func(h *probedHost) {
+					defer wg.Done()
+					s.probe(h)
+				}(h)
+			}
+			wg.Wait()
+			time.Sleep(s.interval)
+		}
+	}()
+}
+
+func (s *hostSelector) probe(h *probedHost) {
+	ctx, cancel := context.WithTimeout(context.Background(), PROBE_TIMEOUT)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.base+PROBE_PATH, nil)
+	if err != nil {
+		return
+	}
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	if err == nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			err = fmt.Errorf("HTTP %d", resp.StatusCode)
+		}
+	}
+	elapsed := time.Since(start)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastProbe = time.Now()
+	if err != nil {
+		if h.healthy || !h.probed {
+			log.Printf("📍 BloFin host %s is unhealthy: %v", h.base, err)
+		}
+		h.healthy, h.probed, h.lastError = false, true, err.Error()
+		return
+	}
+	if h.latency == 0 || !h.healthy {
+		h.latency = elapsed
+	} else {
+		h.latency = time.Duration(PROBE_SMOOTHING*float64(elapsed) + (1-PROBE_SMOOTHING)*float64(h.latency))
+	}
+	h.healthy, h.probed, h.lastError = true, true, ""
+}
+
+// Base URL to send the next request to
+func (s *hostSelector) best() string {
+	best := s.hosts[0]
+	var bestLatency time.Duration
+	for _, h := range s.hosts {
+		h.mu.Lock()
+		healthy, latency := h.healthy, h.latency
+		h.mu.Unlock()
+		if healthy && (bestLatency == 0 || latency < bestLatency) {
+			best, bestLatency = h, latency
+		}
+	}
+	return best.base
+}
+
+func (s *hostSelector) status() []hostStatus {
+	selected := s.best()
+	statuses := make([]hostStatus, 0, len(s.hosts))
+	for _, h := range s.hosts {
+		h.mu.Lock()
+		st := hostStatus{Base: h.base, Selected: h.base == selected, Healthy: h.healthy || !h.probed, LastError: h.lastError}
+		if h.healthy {
+			st.LatencyMs = float64(h.latency.Microseconds()) / 1000
+		}
+		if h.probed {
+			st.LastProbe = h.lastProbe.UTC().Format(time.RFC3339)
+		}
+		h.mu.Unlock()
+		statuses = append(statuses, st)
+	}
+	return statuses
+}
+
+// GET /stats/upstreams reports each BloFin host's measured latency and
+// which one requests currently go to
+func (s *hostSelector) handleStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"hosts":         s.status(),
+		"probeInterval": s.interval.String(),
+		"probing":       len(s.hosts) > 1,
+	})
+}
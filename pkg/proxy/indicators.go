@@ -0,0 +1,185 @@
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+const DEFAULT_INDICATOR_LIMIT = 100
+
+// Default period per indicator; 0 means the indicator has no window
+var indicatorPeriods = map[string]int{
+	"ema":  20,
+	"rsi":  14,
+	"vwap": 0,
+}
+
+// One computed value, aligned with the candle it was computed at
+type indicatorPoint struct {
+	ts    string
+	value float64
+}
+
+// Column i of a candle as a float. Indicators are display values, so exact
+// decimal arithmetic isn't needed here.
+func candleFloat(c candle, i int) float64 {
+	if i >= len(c) {
+		return 0
+	}
+	f, _ := strconv.ParseFloat(c[i], 64)
+	return f
+}
+
+// Exponential moving average, seeded with the simple average of the first
+// period closes
+func computeEMA(rows []candle, period int) []indicatorPoint {
+	if len(rows) < period {
+		return nil
+	}
+	sum := 0.0
+	for _, row := range rows[:period] {
+		sum += candleFloat(row, 4)
+	}
+	ema := sum / float64(period)
+	points := []indicatorPoint{{rows[period-1][0], ema}}
+	alpha := 2 / float64(period+1)
+	for _, row := range rows[period:] {
+		ema += alpha * (candleFloat(row, 4) - ema)
+		points = append(points, indicatorPoint{row[0], ema})
+	}
+	return points
+}
+
+// Relative strength index with Wilder's smoothing
+func computeRSI(rows []candle, period int) []indicatorPoint {
+	if len(rows) <= period {
+		return nil
+	}
+	rsi := func(gain, loss float64) float64 {
+		if loss == 0 {
+			return 100
+		}
+		return 100 - 100/(1+gain/loss)
+	}
+	var gain, loss float64
+	for i := 1; i <= period; i++ {
+		change := candleFloat(rows[i], 4) - candleFloat(rows[i-1], 4)
+		if change > 0 {
+			gain += change
+		} else {
+			loss -= change
+		}
+	}
+	gain /= float64(period)
+	loss /= float64(period)
+	points := []indicatorPoint{{rows[period][0], rsi(gain, loss)}}
+	for i := period + 1; i < len(rows); i++ {
+		change := candleFloat(rows[i], 4) - candleFloat(rows[i-1], 4)
+		up, down := max(change, 0), max(-change, 0)
+		gain = (gain*float64(period-1) + up) / float64(period)
+		loss = (loss*float64(period-1) + down) / float64(period)
+		points = append(points, indicatorPoint{rows[i][0], rsi(gain, loss)})
+	}
+	return points
+}
+
+// Volume-weighted average of the typical price (high+low+close)/3, over a
+// rolling window of period candles, or anchored at each UTC day when
+// period is 0. Volume is in base currency.
+func computeVWAP(rows []candle, period int) []indicatorPoint {
+	const day = 24 * 60 * 60 * 1000
+	points := []indicatorPoint{}
+	var pv, vol float64
+	for i, row := range rows {
+		if period == 0 && i > 0 && row.ts()/day != rows[i-1].ts()/day {
+			pv, vol = 0, 0
+		}
+		typical := (candleFloat(row, 2) + candleFloat(row, 3) + candleFloat(row, 4)) / 3
+		pv += typical * candleFloat(row, 6)
+		vol += candleFloat(row, 6)
+		if period > 0 && i >= period {
+			old := rows[i-period]
+			pv -= (candleFloat(old, 2) + candleFloat(old, 3) + candleFloat(old, 4)) / 3 * candleFloat(old, 6)
+			vol -= candleFloat(old, 6)
+		}
+		if period > 0 && i < period-1 {
+			continue
+		}
+		if vol > 0 {
+			points = append(points, indicatorPoint{row[0], pv / vol})
+		}
+	}
+	return points
+}
+
+// GET /analytics/indicators?instId=...&bar=...&type=vwap|ema|rsi&period=...
+// computes an indicator over the locally stored candles and returns the
+// newest limit values, newest first
+func (s *candleStore) handleIndicators(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	instID, bar, kind := query.Get("instId"), query.Get("bar"), query.Get("type")
+	if bar == "" {
+		bar = "1m"
+	}
+	if instID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "instId is required"})
+		return
+	}
+	if _, ok := barDurations[bar]; !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Unsupported bar %q", bar)})
+		return
+	}
+	period, ok := indicatorPeriods[kind]
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "type must be one of ema, rsi, vwap"})
+		return
+	}
+	if value := query.Get("period"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > MAX_LOCAL_CANDLES {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("period must be an integer between 1 and %d", MAX_LOCAL_CANDLES)})
+			return
+		}
+		period = n
+	}
+	limit := DEFAULT_INDICATOR_LIMIT
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = min(n, MAX_LOCAL_CANDLES)
+	}
+
+	rows, err := s.load(instID, bar)
+	if err != nil {
+		log.Printf("❌ Failed to read candles for %s %s: %v", instID, bar, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to read local candles"})
+		return
+	}
+	var points []indicatorPoint
+	switch kind {
+	case "ema":
+		points = computeEMA(rows, period)
+	case "rsi":
+		points = computeRSI(rows, period)
+	case "vwap":
+		points = computeVWAP(rows, period)
+	}
+
+	data := [][]string{}
+	for i := len(points) - 1; i >= 0 && len(data) < limit; i-- {
+		data = append(data, []string{points[i].ts, strconv.FormatFloat(points[i].value, 'f', -1, 64)})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"instId":  instID,
+		"bar":     bar,
+		"type":    kind,
+		"period":  period,
+		"candles": len(rows),
+		"data":    data,
+	})
+}
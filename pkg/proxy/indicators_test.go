@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+// A candle at ts with the given high, low, close and base volume
+func testCandle(ts int64, high, low, close, volume string) candle {
+	return candle{strconv.FormatInt(ts, 10), close, high, low, close, "0", volume}
+}
+
+func closes(values ...string) []candle {
+	rows := make([]candle, len(values))
+	for i, v := range values {
+		rows[i] = testCandle(int64(i+1)*60000, v, v, v, "1")
+	}
+	return rows
+}
+
+func checkPoints(t *testing.T, name string, got []indicatorPoint, want ...float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s = %+v, want %v", name, got, want)
+	}
+	for i := range want {
+		if math.Abs(got[i].value-want[i]) > 1e-9 {
+			t.Errorf("%s[%d] = %v, want %v", name, i, got[i].value, want[i])
+		}
+	}
+}
+
+func TestComputeEMA(t *testing.T) {
+	// Seeded with (1+2+3)/3, then halfway to each close with period 3
+	rows := closes("1", "2", "3", "4", "6")
+	points := computeEMA(rows, 3)
+	checkPoints(t, "EMA", points, 2, 3, 4.5)
+	if points[0].ts != rows[2][0] {
+		t.Errorf("first EMA at %s, want the third candle", points[0].ts)
+	}
+	if computeEMA(rows[:2], 3) != nil {
+		t.Error("EMA with fewer candles than its period")
+	}
+}
+
+func TestComputeRSI(t *testing.T) {
+	// Only gains, then average gain 0.5 against average loss 0.25
+	checkPoints(t, "RSI", computeRSI(closes("10", "11", "12", "11.5"), 2), 100, 100-100/(1+0.5/0.25))
+	checkPoints(t, "RSI", computeRSI(closes("3", "2", "1"), 2), 0)
+	if computeRSI(closes("1", "2"), 2) != nil {
+		t.Error("RSI needs period changes")
+	}
+}
+
+func TestComputeVWAP(t *testing.T) {
+	const day = 24 * 60 * 60 * 1000
+	rows := []candle{
+		testCandle(day+60000, "3", "1", "2", "1"),  // typical price 2
+		testCandle(day+120000, "6", "2", "4", "3"), // 4
+		testCandle(2*day, "11", "9", "10", "1"),    // 10, on the next day
+	}
+	// Anchored at each UTC day
+	checkPoints(t, "daily VWAP", computeVWAP(rows, 0), 2, (2+4*3)/4.0, 10)
+	// Rolling over two candles, across the day boundary
+	checkPoints(t, "rolling VWAP", computeVWAP(rows, 2), (2+4*3)/4.0, (4*3+10)/4.0)
+}
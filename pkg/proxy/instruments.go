@@ -0,0 +1,149 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DEFAULT_INSTRUMENTS_TTL = 10 * time.Minute
+
+// Contract specification as returned by /api/v1/market/instruments
+type instrument struct {
+	InstID        string `json:"instId"`
+	BaseCurrency  string `json:"baseCurrency"`
+	QuoteCurrency string `json:"quoteCurrency"`
+	ContractValue string `json:"contractValue"`
+	ListTime      string `json:"listTime"`
+	ExpireTime    string `json:"expireTime"`
+	MaxLeverage   string `json:"maxLeverage"`
+	MinSize       string `json:"minSize"`
+	LotSize       string `json:"lotSize"`
+	TickSize      string `json:"tickSize"`
+	InstType      string `json:"instType"`
+	ContractType  string `json:"contractType"`
+	MaxLimitSize  string `json:"maxLimitSize"`
+	MaxMarketSize string `json:"maxMarketSize"`
+	State         string `json:"state"`
+}
+
+// Lazily loaded, periodically refreshed copy of the instrument list. A
+// failed refresh keeps serving the previous list.
+type instrumentCache struct {
+	load func() ([]instrument, error)
+	ttl  time.Duration
+
+	mu       sync.Mutex
+	byID     map[string]instrument
+	loadedAt time.Time
+}
+
+func newInstrumentCache(load func() ([]instrument, error), ttl time.Duration) *instrumentCache {
+	return &instrumentCache{load: load, ttl: ttl}
+}
+
+func (c *instrumentCache) get(instID string) (instrument, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.currentLocked(); err != nil {
+		return instrument{}, false, err
+	}
+	inst, ok := c.byID[instID]
+	return inst, ok, nil
+}
+
+// Every instrument sorted by ID, and when the list was loaded
+func (c *instrumentCache) list() ([]instrument, time.Time, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.currentLocked(); err != nil {
+		return nil, time.Time{}, err
+	}
+	list := make([]instrument, 0, len(c.byID))
+	for _, inst := range c.byID {
+		list = append(list, inst)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].InstID < list[j].InstID })
+	return list, c.loadedAt, nil
+}
+
+// Load or refresh the list when needed; errors only if there is none
+func (c *instrumentCache) currentLocked() error {
+	if c.byID == nil || time.Since(c.loadedAt) > c.ttl {
+		if err := c.refreshLocked(); err != nil && c.byID == nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reload now regardless of the TTL
+func (c *instrumentCache) refresh() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.refreshLocked()
+}
+
+func (c *instrumentCache) refreshLocked() error {
+	list, err := c.load()
+	if err != nil {
+		log.Printf("❌ Failed to load instruments: %v", err)
+		return err
+	}
+	byID := make(map[string]instrument, len(list))
+	for _, inst := range list {
+		byID[inst.InstID] = inst
+	}
+	c.byID = byID
+	c.loadedAt = time.Now()
+	log.Printf("📋 Loaded %d instruments", len(list))
+	return nil
+}
+
+func (s *server) loadInstruments() ([]instrument, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	var list []instrument
+	err := s.blofin.get(ctx, "/api/v1/market/instruments", nil, &list)
+	return list, err
+}
+
+// GET /local/instruments?search=btc&state=live&ctType=linear filters the
+// cached instrument list, so clients looking up one contract's tick size
+// don't download all of it
+func (c *instrumentCache) handleLocal(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	search := strings.ToLower(query.Get("search"))
+	instID, instType := query.Get("instId"), query.Get("instType")
+	state, ctType := query.Get("state"), query.Get("ctType")
+
+	list, loadedAt, err := c.list()
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "Instruments are unavailable"})
+		return
+	}
+	data := []instrument{}
+	for _, inst := range list {
+		if (instID != "" && inst.InstID != instID) ||
+			(instType != "" && !strings.EqualFold(inst.InstType, instType)) ||
+			(state != "" && !strings.EqualFold(inst.State, state)) ||
+			(ctType != "" && !strings.EqualFold(inst.ContractType, ctType)) {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(inst.InstID), search) &&
+			!strings.EqualFold(inst.BaseCurrency, search) && !strings.EqualFold(inst.QuoteCurrency, search) {
+			continue
+		}
+		data = append(data, inst)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"code":      "0",
+		"msg":       "",
+		"data":      data,
+		"updatedAt": loadedAt.UnixMilli(),
+	})
+}
@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// Harmless authenticated call used to check a key
+const KEY_CHECK_PATH = "/api/v1/user/query-apikey"
+
+// What BloFin says about the key making the call
+type apiKeyInfo struct {
+	APIName    string   `json:"apiName"`
+	APIKey     string   `json:"apiKey"`
+	ReadOnly   int      `json:"readOnly"`
+	IPs        []string `json:"ips"`
+	ExpireTime string   `json:"expireTime"`
+}
+
+// GET /utils/key-check: whether a key works from this proxy, what it may
+// do and which IPs it is bound to. Checks the tenant's key given
+// X-Proxy-Token, otherwise the client's own ACCESS-* headers, which must
+// be signed for GET KEY_CHECK_PATH.
+func (s *server) handleKeyCheck(w http.ResponseWriter, r *http.Request) {
+	t, err := s.tenants.fromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unknown " + TENANT_HEADER})
+		return
+	}
+	report := map[string]interface{}{}
+	var info apiKeyInfo
+	switch {
+	case t != nil:
+		report["tenant"] = t.Name
+		if t.Permission != "" {
+			report["declaredPermission"] = t.Permission
+		}
+		err = s.blofin.do(r.Context(), http.MethodGet, KEY_CHECK_PATH, nil, nil, t, &info)
+	case r.Header.Get("ACCESS-KEY") != "":
+		err = s.checkClientKey(r.Context(), r.Header, &info)
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Send " + TENANT_HEADER + ", or ACCESS-* headers signed for GET " + KEY_CHECK_PATH})
+		return
+	}
+
+	if e, ok := err.(*blofinError); ok && e.Code != "" {
+		report["valid"] = false
+		report["code"] = e.Code
+		report["msg"] = e.Msg
+		if hint := keyCheckHint(e.Msg); hint != "" {
+			report["hint"] = hint
+		}
+		writeJSON(w, http.StatusOK, report)
+		return
+	}
+	if err != nil {
+		helperFailed(w, r, "Key check", err)
+		return
+	}
+	permission := "trade"
+	if info.ReadOnly == 1 {
+		permission = "read"
+	}
+	report["valid"] = true
+	report["apiName"] = info.APIName
+	report["permission"] = permission
+	report["ipBound"] = len(info.IPs) > 0
+	report["ips"] = info.IPs
+	report["expireTime"] = info.ExpireTime
+	writeJSON(w, http.StatusOK, report)
+}
+
+// Call KEY_CHECK_PATH with the client's own signature
+func (s *server) checkClientKey(ctx context.Context, header http.Header, info *apiKeyInfo) error {
+	if s.mock != nil {
+		return s.blofin.do(ctx, http.MethodGet, KEY_CHECK_PATH, nil, nil, nil, info)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.hosts.best()+KEY_CHECK_PATH, nil)
+	if err != nil {
+		return err
+	}
+	s.blofin.headers.apply(req.Header, KEY_CHECK_PATH)
+	for _, name := range []string{"ACCESS-KEY", "ACCESS-SIGN", "ACCESS-TIMESTAMP", "ACCESS-NONCE", "ACCESS-PASSPHRASE"} {
+		req.Header.Set(name, header.Get(name))
+	}
+	resp, err := s.blofin.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MAX_INSPECT_BODY))
+	if err != nil {
+		return err
+	}
+	return decodeResponse(KEY_CHECK_PATH, resp.StatusCode, body, info)
+}
+
+// Likely cause of a failed key check, from BloFin's message
+func keyCheckHint(msg string) string {
+	msg = strings.ToLower(msg)
+	words := map[string]bool{}
+	for _, word := range strings.FieldsFunc(msg, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		words[word] = true
+	}
+	switch {
+	case words["ip"] || words["ips"] || strings.Contains(msg, "whitelist"):
+		return "The key is bound to IPs that don't include this proxy's address"
+	case strings.Contains(msg, "timestamp"):
+		return "The signature's timestamp is too far from BloFin's clock"
+	case strings.Contains(msg, "passphrase"):
+		return "The passphrase doesn't match the one set when the key was created"
+	case strings.Contains(msg, "sign"):
+		return "The signature doesn't match: check the secret, and sign GET " + KEY_CHECK_PATH + " exactly"
+	case strings.Contains(msg, "key"):
+		return "BloFin doesn't know this API key, or it was deleted or has expired"
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestKeyCheckHint(t *testing.T) {
+	tests := map[string]string{
+		"Your IP is not in the whitelist":  "bound to IPs",
+		"Request IP not allowed":           "bound to IPs",
+		"Invalid ACCESS-TIMESTAMP":         "timestamp",
+		"Invalid ACCESS-PASSPHRASE":        "passphrase",
+		"Signature verification failed":    "signature doesn't match",
+		"Invalid ACCESS-KEY":               "doesn't know this API key",
+		"Something else entirely happened": "",
+		"Description of ship":              "", // "ip" only as a word
+	}
+	for msg, want := range tests {
+		got := keyCheckHint(msg)
+		if want == "" && got != "" || want != "" && !strings.Contains(got, want) {
+			t.Errorf("keyCheckHint(%q) = %q, want %q", msg, got, want)
+		}
+	}
+}
+
+func TestKeyCheck(t *testing.T) {
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != KEY_CHECK_PATH {
+			w.Write([]byte(`{"code":"0","msg":"","data":[]}`))
+			return
+		}
+		switch r.Header.Get("ACCESS-KEY") {
+		case "key-a":
+			w.Write([]byte(`{"code":"0","msg":"","data":{"apiName":"bot","apiKey":"key-a","readOnly":1,"ips":["203.0.113.7"],"expireTime":"0"}}`))
+		case "client":
+			if r.Header.Get("ACCESS-SIGN") != "sig" || r.Header.Get("ACCESS-PASSPHRASE") != "pp" {
+				w.Write([]byte(`{"code":"152409","msg":"Signature verification failed"}`))
+				return
+			}
+			w.Write([]byte(`{"code":"0","msg":"","data":{"apiName":"mine","readOnly":0,"ips":[]}}`))
+		default:
+			w.Write([]byte(`{"code":"152401","msg":"Your IP is not in the whitelist"}`))
+		}
+	})
+	p := newUpstreamProxy(t, upstream, `[
+		{"name":"alice","token":"tok-a","apiKey":"key-a","secret":"s","passphrase":"p","permission":"read"},
+		{"name":"bob","token":"tok-b","apiKey":"key-b","secret":"s","passphrase":"p"}
+	]`)
+	check := func(header http.Header) (int, map[string]interface{}) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/utils/key-check", nil)
+		req.Header = header
+		rec := httptest.NewRecorder()
+		p.srv.handleKeyCheck(rec, req)
+		var report map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &report)
+		return rec.Code, report
+	}
+
+	code, report := check(http.Header{TENANT_HEADER: {"tok-a"}})
+	if code != http.StatusOK || report["valid"] != true || report["tenant"] != "alice" || report["permission"] != "read" || report["declaredPermission"] != "read" || report["ipBound"] != true {
+		t.Errorf("alice = %d %v", code, report)
+	}
+	code, report = check(http.Header{TENANT_HEADER: {"tok-b"}})
+	if code != http.StatusOK || report["valid"] != false || report["code"] != "152401" || !strings.Contains(fmt.Sprint(report["hint"]), "bound to IPs") {
+		t.Errorf("bob = %d %v", code, report)
+	}
+
+	client := http.Header{"Access-Key": {"client"}, "Access-Sign": {"sig"}, "Access-Timestamp": {"1"}, "Access-Nonce": {"n"}, "Access-Passphrase": {"pp"}}
+	if code, report := check(client); code != http.StatusOK || report["valid"] != true || report["permission"] != "trade" || report["ipBound"] != false {
+		t.Errorf("client key = %d %v", code, report)
+	}
+	client.Set("Access-Sign", "wrong")
+	if code, report := check(client); code != http.StatusOK || report["valid"] != false || !strings.Contains(fmt.Sprint(report["hint"]), "signature") {
+		t.Errorf("badly signed client key = %d %v", code, report)
+	}
+
+	if code, _ := check(http.Header{}); code != http.StatusBadRequest {
+		t.Errorf("no key = %d, want 400", code)
+	}
+	if code, _ := check(http.Header{TENANT_HEADER: {"nobody"}}); code != http.StatusUnauthorized {
+		t.Errorf("unknown token = %d, want 401", code)
+	}
+}
@@ -0,0 +1,136 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/joshthetrader/blofin-proxy/pkg/ratelimit"
+)
+
+const DEFAULT_LIMIT_SAVE_INTERVAL = 10 * time.Second
+
+// Rate-limit buckets, bans, tenant quota counts and daily order caps as saved in
+// DATA_DIR/limits.json, so a restart doesn't hand every client a fresh
+// budget. Buckets refill from their saved time, so downtime still counts.
+type limitState struct {
+	SavedAt time.Time                                   `json:"savedAt"`
+	Buckets map[string]map[string]ratelimit.SavedBucket `json:"buckets,omitempty"` // scope -> key -> bucket
+	Day     string                                      `json:"day"`
+	Used    map[string]int                              `json:"used,omitempty"`   // tenant -> requests
+	Orders  map[string]int                              `json:"orders,omitempty"` // sender -> orders placed
+	Volume  map[string]string                           `json:"volume,omitempty"` // sender -> notional placed
+	Bans    map[string]time.Time                        `json:"bans,omitempty"`   // client IP -> banned until
+}
+
+// Persists the limiter, quotas and order caps; nil when there's nothing
+// to keep
+type limitStore struct {
+	file     string
+	limiter  *ratelimit.Limiter
+	quotas   *tenantQuotas
+	caps     *orderCaps
+	interval time.Duration
+	mu       sync.Mutex // the ticker and Close share the temp file
+}
+
+func newLimitStore(dataDir string, interval time.Duration, limiter *ratelimit.Limiter, quotas *tenantQuotas, caps *orderCaps) (*limitStore, error) {
+	if interval == 0 {
+		return nil, nil
+	}
+	st := &limitStore{file: filepath.Join(dataDir, "limits.json"), limiter: limiter, quotas: quotas, caps: caps, interval: interval}
+	data, err := os.ReadFile(st.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state limitState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("%s: %v", st.file, err)
+	}
+	// Scopes that are no longer configured are dropped
+	for scope, buckets := range limiter.Scopes() {
+		buckets.Restore(state.Buckets[scope])
+	}
+	if bans := limiter.Bans(); bans != nil {
+		bans.Restore(state.Bans)
+	}
+	if state.Day == quotas.day && state.Used != nil {
+		quotas.used = state.Used
+	}
+	if state.Day == caps.day {
+		for sender, n := range state.Orders {
+			caps.orders[sender] = n
+		}
+		for sender, volume := range state.Volume {
+			if value, ok := parseDecimal(volume); ok {
+				caps.notional[sender] = value
+			}
+		}
+	}
+	return st, nil
+}
+
+// Save every interval until the process exits
+func (st *limitStore) start() {
+	go func() {
+		ticker := time.NewTicker(st.interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := st.save(); err != nil {
+				log.Printf("⚠️ Failed to save rate limit state: %v", err)
+			}
+		}
+	}()
+}
+
+func (st *limitStore) save() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	state := limitState{SavedAt: time.Now().UTC(), Buckets: map[string]map[string]ratelimit.SavedBucket{}}
+	for scope, buckets := range st.limiter.Scopes() {
+		state.Buckets[scope] = buckets.Snapshot()
+	}
+	if bans := st.limiter.Bans(); bans != nil {
+		state.Bans = bans.Snapshot()
+	}
+	st.quotas.mu.Lock()
+	state.Day = st.quotas.day
+	state.Used = make(map[string]int, len(st.quotas.used))
+	for name, n := range st.quotas.used {
+		state.Used[name] = n
+	}
+	st.quotas.mu.Unlock()
+	st.caps.mu.Lock()
+	if st.caps.day == state.Day {
+		state.Orders = make(map[string]int, len(st.caps.orders))
+		for sender, n := range st.caps.orders {
+			state.Orders[sender] = n
+		}
+		state.Volume = make(map[string]string, len(st.caps.notional))
+		for sender, value := range st.caps.notional {
+			state.Volume[sender] = value.FloatString(8)
+		}
+	}
+	st.caps.mu.Unlock()
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(st.file), 0o755); err != nil {
+		return err
+	}
+	tmp := st.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, st.file)
+}
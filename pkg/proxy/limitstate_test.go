@@ -0,0 +1,38 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCloseSavesLimits(t *testing.T) {
+	p := newTestProxy(t, "")
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/market/tickers", nil)
+	req.RemoteAddr = "203.0.113.9:1234"
+	p.ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(p.srv.cfg.DataDir, "limits.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state limitState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := state.Buckets["ip"]["203.0.113.9"]; !ok {
+		t.Errorf("buckets = %v, want one for 203.0.113.9", state.Buckets)
+	}
+}
+
+func TestCloseWithoutLimits(t *testing.T) {
+	if err := (&Proxy{}).Close(); err != nil {
+		t.Errorf("Close() = %v, want nil", err)
+	}
+}
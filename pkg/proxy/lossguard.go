@@ -0,0 +1,253 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A tenant's trading day as the loss guard sees it. The loss is the
+// day's trading PnL turned around: PnL realized by fills since 00:00 UTC,
+// less their fees, plus the change in unrealized PnL since the first poll
+// of the day. Transfers in and out of the account don't move it.
+type lossDay struct {
+	Day            string `json:"day"`
+	OpenUnrealized string `json:"openUnrealizedPnl"`
+	Realized       string `json:"realizedPnl"`
+	Fees           string `json:"fees"`
+	Unrealized     string `json:"unrealizedPnl"`
+	Loss           string `json:"loss"`
+	Limit          string `json:"limit"`
+	HaltedAt       int64  `json:"haltedAt,omitempty"` // ms; zero while trading
+	Resumed        bool   `json:"resumed,omitempty"`  // an admin lifted today's halt
+}
+
+// Polls the fills and positions of tenants with a daily loss limit and
+// halts their risk-increasing orders for the rest of the UTC day once the
+// loss reaches it; reduce-only orders and closes still go through. Fills
+// are synced into the same store as the sync-fills job. Days are kept in
+// DATA_DIR/losses.json so a restart keeps the day's opening unrealized
+// PnL and any halt.
+type lossGuard struct {
+	client   *blofinClient
+	fills    *fillStore
+	tenants  []*tenant
+	limits   map[string]*big.Rat
+	interval time.Duration
+	file     string
+
+	mu   sync.Mutex
+	days map[string]*lossDay // by tenant name
+}
+
+// Nil when no tenant has a loss limit, from DAILY_LOSS_LIMIT or its own
+func newLossGuard(client *blofinClient, fills *fillStore, tenants []*tenant, defaultLimit string, interval time.Duration, dataDir string) (*lossGuard, error) {
+	g := &lossGuard{
+		client:   client,
+		fills:    fills,
+		limits:   map[string]*big.Rat{},
+		interval: interval,
+		file:     filepath.Join(dataDir, "losses.json"),
+		days:     map[string]*lossDay{},
+	}
+	for _, t := range tenants {
+		limit := defaultLimit
+		if t.DailyLossLimit != "" {
+			limit = t.DailyLossLimit
+		}
+		if value, ok := parseDecimal(limit); ok {
+			g.tenants = append(g.tenants, t)
+			g.limits[t.Name] = value
+		}
+	}
+	if len(g.tenants) == 0 {
+		return nil, nil
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("poll interval must be positive")
+	}
+	data, err := os.ReadFile(g.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return g, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &g.days); err != nil {
+		return nil, fmt.Errorf("%s: %v", g.file, err)
+	}
+	return g, nil
+}
+
+func (g *lossGuard) start() {
+	log.Printf("🧯 Watching daily losses of %d tenant(s) every %s", len(g.tenants), g.interval)
+	go func() {
+		for {
+			for _, t := range g.tenants {
+				if err := g.poll(t); err != nil {
+					log.Printf("❌ Loss poll for %s failed: %v", t.Name, err)
+				}
+			}
+			time.Sleep(g.interval)
+		}
+	}()
+}
+
+// The tenant's futures account equity in USD, as BloFin reports it and parsed
+func (c *blofinClient) totalEquity(ctx context.Context, t *tenant) (string, *big.Rat, error) {
+	var balance struct {
+		TotalEquity string `json:"totalEquity"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/account/balance", nil, nil, t, &balance); err != nil {
+		return "", nil, err
+	}
+	equity, ok := parseDecimal(balance.TotalEquity)
+	if !ok {
+		return "", nil, fmt.Errorf("invalid totalEquity %q", balance.TotalEquity)
+	}
+	return balance.TotalEquity, equity, nil
+}
+
+func (g *lossGuard) poll(t *tenant) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if _, err := g.fills.syncTenant(ctx, t); err != nil {
+		return err
+	}
+	fills, err := g.fills.load(t)
+	if err != nil {
+		return err
+	}
+	var positions []map[string]string
+	if err := g.client.do(ctx, http.MethodGet, "/api/v1/account/positions", nil, nil, t, &positions); err != nil {
+		return err
+	}
+	now := time.Now()
+	today := &pnlSum{}
+	for _, inst := range sumPnL(fills, positions, now.UTC().Truncate(24*time.Hour)) {
+		today.add(inst)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	limit := g.limits[t.Name]
+	d := g.days[t.Name]
+	if d == nil || d.Day != quotaDay(now) {
+		d = &lossDay{Day: quotaDay(now), OpenUnrealized: decimalString(&today.unrealized)}
+		g.days[t.Name] = d
+	}
+	open, ok := parseDecimal(d.OpenUnrealized)
+	if !ok {
+		open = new(big.Rat).Set(&today.unrealized)
+		d.OpenUnrealized = decimalString(open)
+	}
+	// Loss = fees - realized - (unrealized now - unrealized at the open)
+	loss := new(big.Rat).Sub(&today.fees, &today.realized)
+	loss.Sub(loss, &today.unrealized)
+	loss.Add(loss, open)
+	d.Realized, d.Fees, d.Unrealized = decimalString(&today.realized), decimalString(&today.fees), decimalString(&today.unrealized)
+	d.Loss, d.Limit = loss.FloatString(2), limit.FloatString(2)
+	if loss.Cmp(limit) >= 0 && d.HaltedAt == 0 && !d.Resumed {
+		d.HaltedAt = now.UnixMilli()
+		log.Printf("🧯 %s lost %s trading today (limit %s): new positions halted until 00:00 UTC", t.Name, d.Loss, d.Limit)
+	}
+	g.saveLocked()
+	return nil
+}
+
+// Whether t's risk-increasing orders are halted right now
+func (g *lossGuard) halted(t *tenant) bool {
+	if g == nil || t == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	d := g.days[t.Name]
+	return d != nil && d.Day == quotaDay(time.Now()) && d.HaltedAt != 0 && !d.Resumed
+}
+
+// Refusal for a placement body while t is halted, unless every order in
+// it is reduce-only
+func (g *lossGuard) check(t *tenant, path string, body []byte) *orderRefusal {
+	if !g.halted(t) {
+		return nil
+	}
+	if reduceOnlyBody(path, body) {
+		return nil
+	}
+	g.mu.Lock()
+	d := g.days[t.Name]
+	msg := fmt.Sprintf("Trading halted for today: %s lost %s trading against a daily loss limit of %s. Only reduce-only orders and closes are accepted until 00:00 UTC", t.Name, d.Loss, d.Limit)
+	g.mu.Unlock()
+	return &orderRefusal{status: http.StatusForbidden, msg: msg}
+}
+
+// GET /admin/losses lists today's figures per tenant; DELETE
+// /admin/losses/{tenant} lifts a halt for the rest of the day
+func (g *lossGuard) handleAdmin(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/losses"), "/")
+	switch {
+	case name == "" && r.Method == http.MethodGet:
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]interface{}{"tenants": g.days})
+	case name != "" && r.Method == http.MethodDelete:
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		d := g.days[name]
+		if d == nil || d.Day != quotaDay(time.Now()) || d.HaltedAt == 0 || d.Resumed {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "No trading halt for " + name})
+			return
+		}
+		d.Resumed = true
+		g.saveLocked()
+		log.Printf("🧯 Trading halt for %s lifted by an admin", name)
+		writeJSON(w, http.StatusOK, map[string]string{"tenant": name, "status": "resumed"})
+	case name == "":
+		methodNotAllowed(w, "GET")
+	default:
+		methodNotAllowed(w, "DELETE")
+	}
+}
+
+func (g *lossGuard) saveLocked() {
+	data, err := json.MarshalIndent(g.days, "", "  ")
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(g.file), 0o755); err == nil {
+			tmp := g.file + ".tmp"
+			if err = os.WriteFile(tmp, data, 0o600); err == nil {
+				err = os.Rename(tmp, g.file)
+			}
+		}
+	}
+	if err != nil {
+		log.Printf("❌ Failed to save daily losses: %v", err)
+	}
+}
+
+// Whether a placement body can only shrink positions: a close, or orders
+// that are all reduce-only
+func reduceOnlyBody(path string, body []byte) bool {
+	if closeRoutes[path] {
+		return true
+	}
+	orders, err := parseOrders(path, body)
+	if err != nil {
+		return false
+	}
+	for _, order := range orders {
+		if reduceOnly, _ := order["reduceOnly"].(string); reduceOnly != "true" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewLossGuard(t *testing.T) {
+	limited := testTenant(t, `"dailyLossLimit":"50"`)
+	unlimited := testTenant(t, `"name":"bob"`)
+	dir := t.TempDir()
+
+	if g, err := newLossGuard(nil, nil, []*tenant{unlimited}, "", time.Second, dir); g != nil || err != nil {
+		t.Fatalf("guard without limits = %v, %v", g, err)
+	}
+	if _, err := newLossGuard(nil, nil, []*tenant{limited}, "", 0, dir); err == nil {
+		t.Fatal("a zero poll interval was accepted")
+	}
+	g, err := newLossGuard(nil, nil, []*tenant{limited, unlimited}, "100", time.Second, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.tenants) != 2 || g.limits["alice"].FloatString(0) != "50" {
+		t.Fatalf("limits = %v", g.limits)
+	}
+}
+
+func TestLossGuardCheck(t *testing.T) {
+	alice := testTenant(t, `"dailyLossLimit":"50"`)
+	g, err := newLossGuard(nil, nil, []*tenant{alice}, "", time.Second, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const path = "/api/v1/trade/order"
+	if err := g.check(alice, path, []byte(`{"instId":"BTC-USDT"}`)); err != nil {
+		t.Fatalf("refused before any halt: %v", err)
+	}
+
+	g.days["alice"] = &lossDay{Day: quotaDay(time.Now()), Realized: "-55", Fees: "5", Loss: "60.00", Limit: "50.00", HaltedAt: time.Now().UnixMilli()}
+	refusal := g.check(alice, path, []byte(`{"instId":"BTC-USDT"}`))
+	if refusal == nil || refusal.status != http.StatusForbidden || !strings.Contains(refusal.msg, "alice lost 60.00 trading") {
+		t.Fatalf("check while halted = %v", refusal)
+	}
+	if err := g.check(alice, path, []byte(`{"instId":"BTC-USDT","reduceOnly":"true"}`)); err != nil {
+		t.Fatalf("reduce-only refused while halted: %v", err)
+	}
+	if err := g.check(nil, path, []byte(`{}`)); err != nil {
+		t.Fatalf("requests without a tenant refused: %v", err)
+	}
+
+	// A halt from an earlier day doesn't carry over
+	g.days["alice"].Day = "2000-01-01"
+	if g.halted(alice) {
+		t.Fatal("yesterday's halt still applies")
+	}
+	g.days["alice"].Day = quotaDay(time.Now())
+	g.days["alice"].Resumed = true
+	if g.halted(alice) {
+		t.Fatal("halt lifted by an admin still applies")
+	}
+}
+
+func TestLossGuardPoll(t *testing.T) {
+	var mu sync.Mutex
+	var fills []string
+	unrealized := "-20"
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/api/v1/trade/fills-history":
+			fmt.Fprintf(w, `{"code":"0","data":[%s]}`, strings.Join(fills, ","))
+		case "/api/v1/account/positions":
+			fmt.Fprintf(w, `{"code":"0","data":[{"instId":"BTC-USDT","unrealizedPnl":%q}]}`, unrealized)
+		default:
+			w.Write([]byte(`{"code":"0","data":[]}`))
+		}
+	})
+	trade := func(id string, ts time.Time, pnl, fee, open string) {
+		mu.Lock()
+		defer mu.Unlock()
+		// BloFin pages fills newest first
+		fills = append([]string{fmt.Sprintf(`{"tradeId":%q,"instId":"BTC-USDT","fillPnl":%q,"fee":%q,"ts":"%d"}`, id, pnl, fee, ts.UnixMilli())}, fills...)
+		unrealized = open
+	}
+	p := newUpstreamProxy(t, upstream, `[{"name":"alice","token":"tok-a","apiKey":"k","secret":"s","passphrase":"p","dailyLossLimit":"50"}]`)
+	g, alice := p.srv.losses, p.srv.tenants.list[0]
+	poll := func() *lossDay {
+		t.Helper()
+		if err := g.poll(alice); err != nil {
+			t.Fatal(err)
+		}
+		return g.days["alice"]
+	}
+
+	// Yesterday's fills and the unrealized PnL at the first poll don't count
+	trade("1", time.Now().Add(-48*time.Hour), "-500", "-1", "-20")
+	if d := poll(); d.Loss != "0.00" || d.OpenUnrealized != "-20" || g.halted(alice) {
+		t.Fatalf("opening day = %+v", d)
+	}
+	// Realized losses and fees count, whatever the fee's sign
+	trade("2", time.Now(), "-30", "-2", "-25")
+	if d := poll(); d.Loss != "37.00" || d.Realized != "-30" || d.Fees != "2" || g.halted(alice) {
+		t.Fatalf("after a losing fill = %+v", d)
+	}
+	// So do unrealized losses since the first poll
+	trade("3", time.Now(), "0", "1", "-36")
+	if d := poll(); d.Loss != "49.00" {
+		t.Fatalf("loss = %+v", d)
+	}
+	trade("4", time.Now(), "0", "0.5", "-36")
+	if d := poll(); d.Loss != "49.50" || g.halted(alice) {
+		t.Fatalf("loss below the limit = %+v", d)
+	}
+	trade("5", time.Now(), "5", "0.5", "-50")
+	if d := poll(); d.Loss != "59.00" || !g.halted(alice) {
+		t.Fatalf("loss past the limit = %+v", d)
+	}
+}
@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_MAINTENANCE_MESSAGE     = "BloFin is under maintenance, please try again later"
+	DEFAULT_MAINTENANCE_RETRY_AFTER = 300
+)
+
+// Maintenance mode settings, adjustable at runtime through /admin/maintenance
+type MaintenanceSettings struct {
+	Enabled           bool   `json:"enabled"`
+	Message           string `json:"message"`           // returned to clients as the error
+	RetryAfterSeconds int    `json:"retryAfterSeconds"` // sent as Retry-After
+	Since             string `json:"since,omitempty"`   // when it was last enabled
+}
+
+func (m MaintenanceSettings) validate() error {
+	if m.RetryAfterSeconds < 0 {
+		return fmt.Errorf("retryAfterSeconds must not be negative")
+	}
+	return nil
+}
+
+// Holds client traffic to BloFin during planned maintenance: /api/*,
+// /unified/ and /binance/ get a 503, while health checks, metrics, local
+// data and the admin API stay up
+type maintenanceMode struct {
+	mu       sync.RWMutex
+	settings MaintenanceSettings
+}
+
+func newMaintenanceMode(settings MaintenanceSettings) *maintenanceMode {
+	if settings.Enabled {
+		settings.Since = time.Now().UTC().Format(time.RFC3339)
+	}
+	return &maintenanceMode{settings: settings}
+}
+
+func (m *maintenanceMode) current() MaintenanceSettings {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.settings
+}
+
+// Prefixes of paths whose requests end up at BloFin
+var blofinBoundPrefixes = []string{"/api/", "/unified/", "/binance/", "/helpers/", "/webhooks/"}
+
+func blofinBound(path string) bool {
+	for _, prefix := range blofinBoundPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware answering held-back paths with a 503 while maintenance is on
+func (s *server) maintenanceGate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		settings := s.maintenance.current()
+		if !settings.Enabled || !blofinBound(r.URL.Path) {
+			next(w, r)
+			return
+		}
+		if settings.RetryAfterSeconds > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(settings.RetryAfterSeconds))
+		}
+		writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{
+			"error":       settings.Message,
+			"maintenance": true,
+			"since":       settings.Since,
+		})
+	}
+}
+
+// GET returns the current settings; PUT/POST merges the JSON body into
+// them; DELETE turns maintenance off
+func (m *maintenanceMode) handleAdmin(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, m.current())
+	case http.MethodPut, http.MethodPost:
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		updated := m.settings
+		if err := json.NewDecoder(r.Body).Decode(&updated); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON: " + err.Error()})
+			return
+		}
+		if err := updated.validate(); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if updated.Message == "" {
+			updated.Message = DEFAULT_MAINTENANCE_MESSAGE
+		}
+		updated.Since = m.settings.Since
+		if updated.Enabled && !m.settings.Enabled {
+			updated.Since = time.Now().UTC().Format(time.RFC3339)
+		}
+		m.settings = updated
+		m.logChange()
+		writeJSON(w, http.StatusOK, updated)
+	case http.MethodDelete:
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.settings.Enabled = false
+		m.logChange()
+		writeJSON(w, http.StatusOK, m.settings)
+	default:
+		methodNotAllowed(w, "GET", "PUT", "POST", "DELETE")
+	}
+}
+
+func (m *maintenanceMode) logChange() {
+	if m.settings.Enabled {
+		log.Printf("🚧 Maintenance mode on: %s", m.settings.Message)
+	} else {
+		log.Printf("🚧 Maintenance mode off")
+	}
+}
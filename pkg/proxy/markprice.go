@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DEFAULT_MARK_PRICE_POLL_INTERVAL = time.Second
+
+// Mark and index price as returned by /api/v1/market/mark-price
+type markPrice struct {
+	InstID     string `json:"instId"`
+	IndexPrice string `json:"indexPrice"`
+	MarkPrice  string `json:"markPrice"`
+	Ts         string `json:"ts"`
+}
+
+// Keeps mark and index prices for every instrument hot with one bulk
+// request per interval, so clients polling them per instrument stop
+// reaching BloFin at all. Polling only starts once something asks.
+type markFeed struct {
+	client   *blofinClient
+	interval time.Duration
+
+	once      sync.Once
+	pollMu    sync.Mutex // one poll at a time
+	mu        sync.Mutex
+	latest    map[string]markPrice
+	updatedAt time.Time
+}
+
+func newMarkFeed(client *blofinClient, interval time.Duration) *markFeed {
+	return &markFeed{client: client, interval: interval}
+}
+
+func (f *markFeed) start() {
+	f.once.Do(func() {
+		log.Printf("🎯 Polling mark prices every %s", f.interval)
+		go f.run()
+	})
+}
+
+func (f *markFeed) run() {
+	for {
+		if err := f.poll(); err != nil {
+			log.Printf("❌ Mark price poll failed: %v", err)
+		}
+		time.Sleep(f.interval)
+	}
+}
+
+func (f *markFeed) poll() error {
+	f.pollMu.Lock()
+	defer f.pollMu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var list []markPrice
+	if err := f.client.get(ctx, "/api/v1/market/mark-price", nil, &list); err != nil {
+		return err
+	}
+	snapshot := make(map[string]markPrice, len(list))
+	for _, mark := range list {
+		snapshot[mark.InstID] = mark
+	}
+	f.mu.Lock()
+	f.latest = snapshot
+	f.updatedAt = time.Now()
+	f.mu.Unlock()
+	return nil
+}
+
+// Latest snapshot and when it was taken, fetching synchronously if it's
+// missing or stale
+func (f *markFeed) current() (map[string]markPrice, time.Time, error) {
+	f.start()
+	fresh := func() (map[string]markPrice, time.Time, bool) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.latest, f.updatedAt, time.Since(f.updatedAt) <= 2*f.interval
+	}
+	if latest, at, ok := fresh(); ok {
+		return latest, at, nil
+	}
+	// Wait for any poll in flight rather than starting a second one
+	f.pollMu.Lock()
+	f.pollMu.Unlock()
+	if latest, at, ok := fresh(); ok {
+		return latest, at, nil
+	}
+	if err := f.poll(); err != nil {
+		return nil, time.Time{}, err
+	}
+	latest, at, _ := fresh()
+	return latest, at, nil
+}
+
+// GET /local/mark-price?instId=BTC-USDT (or instIds=BTC-USDT,ETH-USDT)
+// answers from the polled snapshot; without either it returns every
+// instrument
+func (f *markFeed) handleLocal(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var instIDs []string
+	for _, id := range strings.Split(query.Get("instId")+","+query.Get("instIds"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			instIDs = append(instIDs, id)
+		}
+	}
+	latest, updatedAt, err := f.current()
+	if err != nil {
+		log.Printf("❌ Failed to load mark prices: %v", err)
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "Mark prices are unavailable"})
+		return
+	}
+
+	data := []markPrice{}
+	missing := []string{}
+	if len(instIDs) == 0 {
+		for _, mark := range latest {
+			data = append(data, mark)
+		}
+		sort.Slice(data, func(i, j int) bool { return data[i].InstID < data[j].InstID })
+	}
+	for _, id := range instIDs {
+		if mark, ok := latest[id]; ok {
+			data = append(data, mark)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	response := map[string]interface{}{
+		"code":      "0",
+		"msg":       "",
+		"data":      data,
+		"updatedAt": updatedAt.UnixMilli(),
+	}
+	if len(missing) > 0 {
+		response["missing"] = missing
+	}
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,165 @@
+package proxy
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	METRIC_COUNTER = "counter"
+	METRIC_GAUGE   = "gauge"
+)
+
+// Metrics served in the Prometheus text format at /metrics. Updates are
+// on every request's path, so they take no lock: families and series sit
+// in sync.Maps, which read without locking once a key exists, and values
+// are updated atomically.
+type metricsRegistry struct {
+	families sync.Map // name -> *metricFamily
+}
+
+type metricFamily struct {
+	kind    string
+	help    string
+	series  *sync.Map // rendered labels -> *metricValue; nil for collected families
+	collect func() map[string]float64
+}
+
+// A float64 updated with compare-and-swap on its bits
+type metricValue struct {
+	bits atomic.Uint64
+}
+
+func (v *metricValue) add(delta float64) {
+	for {
+		old := v.bits.Load()
+		if v.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func (v *metricValue) load() float64 { return math.Float64frombits(v.bits.Load()) }
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{}
+}
+
+func (m *metricsRegistry) register(name, kind, help string) {
+	m.families.LoadOrStore(name, &metricFamily{kind: kind, help: help, series: &sync.Map{}})
+}
+
+// Register a family whose series are read from collect at scrape time,
+// for values kept outside the registry (e.g. atomic counters)
+func (m *metricsRegistry) registerFunc(name, kind, help string, collect func() map[string]float64) {
+	m.families.Store(name, &metricFamily{kind: kind, help: help, collect: collect})
+}
+
+// The series of a registered family, created on first use; nil for
+// unknown or collected families
+func (m *metricsRegistry) value(name, labels string) *metricValue {
+	f, ok := m.families.Load(name)
+	if !ok || f.(*metricFamily).series == nil {
+		return nil
+	}
+	series := f.(*metricFamily).series
+	if v, ok := series.Load(labels); ok {
+		return v.(*metricValue)
+	}
+	v, _ := series.LoadOrStore(labels, &metricValue{})
+	return v.(*metricValue)
+}
+
+// Add to a counter (or gauge) series, e.g. add("x_total", labels("route", "/a"), 1)
+func (m *metricsRegistry) add(name, labels string, delta float64) {
+	if v := m.value(name, labels); v != nil {
+		v.add(delta)
+	}
+}
+
+func (m *metricsRegistry) set(name, labels string, value float64) {
+	if v := m.value(name, labels); v != nil {
+		v.bits.Store(math.Float64bits(value))
+	}
+}
+
+// Drop the series whose labels start with prefix, for gauges whose label
+// values come and go
+func (m *metricsRegistry) clear(name, prefix string) {
+	f, ok := m.families.Load(name)
+	if !ok || f.(*metricFamily).series == nil {
+		return
+	}
+	series := f.(*metricFamily).series
+	series.Range(func(key, _ interface{}) bool {
+		if strings.HasPrefix(key.(string), prefix) {
+			series.Delete(key)
+		}
+		return true
+	})
+}
+
+// Current values of a family's series
+func (f *metricFamily) snapshot() map[string]float64 {
+	if f.collect != nil {
+		return f.collect()
+	}
+	values := map[string]float64{}
+	f.series.Range(func(key, v interface{}) bool {
+		values[key.(string)] = v.(*metricValue).load()
+		return true
+	})
+	return values
+}
+
+// Render name/value pairs as a label set: labels("host", "a", "addr", "b")
+func labels(pairs ...string) string {
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, pairs[i]+"="+strconv.Quote(pairs[i+1]))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (m *metricsRegistry) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	families := map[string]*metricFamily{}
+	var names []string
+	m.families.Range(func(name, f interface{}) bool {
+		families[name.(string)] = f.(*metricFamily)
+		names = append(names, name.(string))
+		return true
+	})
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	var b strings.Builder
+	for _, name := range names {
+		f := families[name]
+		series := f.snapshot()
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, f.help, name, f.kind)
+		keys := make([]string, 0, len(series))
+		for key := range series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := strconv.FormatFloat(series[key], 'g', -1, 64)
+			if key == "" {
+				fmt.Fprintf(&b, "%s %s\n", name, value)
+			} else {
+				fmt.Fprintf(&b, "%s{%s} %s\n", name, key, value)
+			}
+		}
+	}
+	w.Write([]byte(b.String()))
+}
@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMetricsConcurrentAdd(t *testing.T) {
+	m := newMetricsRegistry()
+	m.register("x_total", METRIC_COUNTER, "x")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				m.add("x_total", labels("route", "/a"), 1)
+				m.add("x_total", labels("route", "/b"), 0.5)
+			}
+		}()
+	}
+	wg.Wait()
+	m.add("unregistered_total", "", 1)
+
+	rec := httptest.NewRecorder()
+	m.handle(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE x_total counter",
+		`x_total{route="/a"} 4000`,
+		`x_total{route="/b"} 2000`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "unregistered_total") {
+		t.Errorf("unregistered family rendered:\n%s", body)
+	}
+}
+
+func TestMetricsSetAndClear(t *testing.T) {
+	m := newMetricsRegistry()
+	m.register("g", METRIC_GAUGE, "g")
+	m.set("g", labels("host", "a"), 3)
+	m.set("g", labels("host", "a"), 1)
+	m.set("g", labels("host", "b"), 2)
+	m.clear("g", `host="a"`)
+
+	f, _ := m.families.Load("g")
+	got := f.(*metricFamily).snapshot()
+	if len(got) != 1 || got[labels("host", "b")] != 2 {
+		t.Fatalf("snapshot = %v, want only host b at 2", got)
+	}
+}
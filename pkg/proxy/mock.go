@@ -0,0 +1,645 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Instruments the mock exchange lists, with a reference price that the
+// generated market data oscillates around
+type mockInstrument struct {
+	InstID        string
+	Base          string
+	Price         float64
+	TickSize      string
+	Decimals      int
+	ContractValue string
+	MaxLeverage   string
+}
+
+var mockInstruments = []mockInstrument{
+	{InstID: "BTC-USDT", Base: "BTC", Price: 64250, TickSize: "0.1", Decimals: 1, ContractValue: "0.001", MaxLeverage: "150"},
+	{InstID: "ETH-USDT", Base: "ETH", Price: 3150, TickSize: "0.01", Decimals: 2, ContractValue: "0.01", MaxLeverage: "100"},
+	{InstID: "SOL-USDT", Base: "SOL", Price: 145.5, TickSize: "0.001", Decimals: 3, ContractValue: "1", MaxLeverage: "75"},
+}
+
+// Offline stand-in for the BloFin REST API used when MODE=mock. Orders
+// placed against it are kept in memory so pending-order screens behave.
+type mockExchange struct {
+	mu          sync.Mutex
+	nextOrderID int64
+	orders      []map[string]interface{}
+	finished    map[string]map[string]interface{} // filled or canceled, by orderId
+}
+
+func newMockExchange() *mockExchange {
+	return &mockExchange{nextOrderID: 1000000001, finished: map[string]map[string]interface{}{}}
+}
+
+type mockHandler func(m *mockExchange, r *http.Request, body []byte) interface{}
+
+var mockHandlers = map[string]mockHandler{
+	"GET /api/v1/market/instruments":          (*mockExchange).instruments,
+	"GET /api/v1/market/tickers":              (*mockExchange).tickers,
+	"GET /api/v1/market/books":                (*mockExchange).books,
+	"GET /api/v1/market/trades":               (*mockExchange).trades,
+	"GET /api/v1/market/mark-price":           (*mockExchange).markPrice,
+	"GET /api/v1/market/funding-rate":         (*mockExchange).fundingRate,
+	"GET /api/v1/market/funding-rate-history": (*mockExchange).fundingRateHistory,
+	"GET /api/v1/market/candles":              (*mockExchange).candles,
+	"GET /api/v1/asset/balances":              (*mockExchange).assetBalances,
+	"GET /api/v1/account/balance":             (*mockExchange).accountBalance,
+	"GET /api/v1/account/positions":           (*mockExchange).positions,
+	"GET /api/v1/account/margin-mode": func(*mockExchange, *http.Request, []byte) interface{} {
+		return map[string]string{"marginMode": "cross"}
+	},
+	"GET /api/v1/account/position-mode": func(*mockExchange, *http.Request, []byte) interface{} {
+		return map[string]string{"positionMode": "net_mode"}
+	},
+	"GET /api/v1/account/batch-leverage-info": (*mockExchange).leverageInfo,
+	"POST /api/v1/account/set-leverage":       (*mockExchange).echoBody,
+	"POST /api/v1/account/set-margin-mode":    (*mockExchange).echoBody,
+	"POST /api/v1/account/set-position-mode":  (*mockExchange).echoBody,
+	"GET /api/v1/user/query-apikey":           (*mockExchange).apiKeyInfo,
+	"POST /api/v1/trade/order":                (*mockExchange).placeOrder,
+	"POST /api/v1/trade/batch-orders":         (*mockExchange).placeBatchOrders,
+	"POST /api/v1/trade/cancel-order":         (*mockExchange).cancelOrder,
+	"POST /api/v1/trade/order-tpsl":           (*mockExchange).placeTPSL,
+	"GET /api/v1/trade/orders-pending":        (*mockExchange).ordersPending,
+	"GET /api/v1/trade/order-detail":          (*mockExchange).orderDetail,
+	"GET /api/v1/trade/orders-history":        (*mockExchange).ordersHistory,
+	"GET /api/v1/trade/fills-history":         (*mockExchange).fillsHistory,
+
+	"GET /api/v1/copytrading/account/balance":               (*mockExchange).accountBalance,
+	"GET /api/v1/copytrading/account/positions-by-contract": (*mockExchange).positions,
+	"POST /api/v1/copytrading/trade/place-order":            (*mockExchange).placeOrder,
+	"POST /api/v1/copytrading/trade/cancel-order":           (*mockExchange).cancelOrder,
+	"GET /api/v1/copytrading/trade/orders-pending":          (*mockExchange).ordersPending,
+	"GET /api/v1/copytrading/trade/orders-history":          (*mockExchange).ordersHistory,
+
+	"GET /api/v1/affiliate/invitees": (*mockExchange).affiliateInvitees,
+}
+
+func (m *mockExchange) serve(w http.ResponseWriter, r *http.Request) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, MAX_INSPECT_BODY))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Failed to read request body"})
+			return
+		}
+	}
+
+	data, ok := m.dispatch(r, body)
+	if !ok && findRoute(r.Method, r.URL.Path) == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{
+			"error": fmt.Sprintf("No mock response for %s %s", r.Method, r.URL.Path),
+		})
+		return
+	}
+	if !ok {
+		// Known route without a dedicated generator
+		data = []interface{}{}
+	}
+
+	log.Printf("🎭 Mock %s %s", r.Method, r.URL.Path)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"code": "0", "msg": "success", "data": data})
+}
+
+// Response data for a request, if the mock knows how to answer it
+func (m *mockExchange) dispatch(r *http.Request, body []byte) (interface{}, bool) {
+	handler, ok := mockHandlers[r.Method+" "+r.URL.Path]
+	if !ok {
+		return nil, false
+	}
+	return handler(m, r, body), true
+}
+
+// Price that drifts slowly around the reference so charts look alive
+func (inst mockInstrument) priceAt(t time.Time) float64 {
+	minutes := float64(t.Unix()) / 60
+	return inst.Price * (1 + 0.004*math.Sin(minutes/7) + 0.001*math.Sin(minutes*1.3))
+}
+
+func (inst mockInstrument) format(price float64) string {
+	return strconv.FormatFloat(price, 'f', inst.Decimals, 64)
+}
+
+// Instruments matching the instId query, or all of them when it's absent
+func selectMockInstruments(r *http.Request) []mockInstrument {
+	instID := r.URL.Query().Get("instId")
+	if instID == "" {
+		return mockInstruments
+	}
+	for _, inst := range mockInstruments {
+		if inst.InstID == instID {
+			return []mockInstrument{inst}
+		}
+	}
+	return nil
+}
+
+func millis(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
+
+func (m *mockExchange) instruments(r *http.Request, _ []byte) interface{} {
+	data := []map[string]string{}
+	for _, inst := range selectMockInstruments(r) {
+		data = append(data, map[string]string{
+			"instId":        inst.InstID,
+			"baseCurrency":  inst.Base,
+			"quoteCurrency": "USDT",
+			"contractValue": inst.ContractValue,
+			"listTime":      "1672531200000",
+			"expireTime":    "4070880000000",
+			"maxLeverage":   inst.MaxLeverage,
+			"minSize":       "1",
+			"lotSize":       "1",
+			"tickSize":      inst.TickSize,
+			"instType":      "SWAP",
+			"contractType":  "linear",
+			"maxLimitSize":  "100000",
+			"maxMarketSize": "20000",
+			"state":         "live",
+		})
+	}
+	return data
+}
+
+func (m *mockExchange) tickers(r *http.Request, _ []byte) interface{} {
+	now := time.Now()
+	data := []map[string]string{}
+	for _, inst := range selectMockInstruments(r) {
+		last := inst.priceAt(now)
+		tick, _ := strconv.ParseFloat(inst.TickSize, 64)
+		data = append(data, map[string]string{
+			"instId":         inst.InstID,
+			"last":           inst.format(last),
+			"lastSize":       "3",
+			"askPrice":       inst.format(last + tick),
+			"askSize":        "120",
+			"bidPrice":       inst.format(last - tick),
+			"bidSize":        "95",
+			"high24h":        inst.format(inst.Price * 1.021),
+			"open24h":        inst.format(inst.Price * 0.994),
+			"low24h":         inst.format(inst.Price * 0.978),
+			"volCurrency24h": "18250.5",
+			"vol24h":         "1825050",
+			"ts":             millis(now),
+		})
+	}
+	return data
+}
+
+func (m *mockExchange) books(r *http.Request, _ []byte) interface{} {
+	insts := selectMockInstruments(r)
+	if len(insts) != 1 {
+		return []interface{}{}
+	}
+	inst := insts[0]
+	now := time.Now()
+	mid := inst.priceAt(now)
+	tick, _ := strconv.ParseFloat(inst.TickSize, 64)
+	depth, _ := strconv.Atoi(r.URL.Query().Get("size"))
+	if depth <= 0 || depth > 100 {
+		depth = 5
+	}
+	asks, bids := [][]string{}, [][]string{}
+	for i := 1; i <= depth; i++ {
+		size := strconv.Itoa(10 * i * i)
+		asks = append(asks, []string{inst.format(mid + float64(i)*tick), size})
+		bids = append(bids, []string{inst.format(mid - float64(i)*tick), size})
+	}
+	return []map[string]interface{}{{"asks": asks, "bids": bids, "ts": millis(now)}}
+}
+
+func (m *mockExchange) trades(r *http.Request, _ []byte) interface{} {
+	insts := selectMockInstruments(r)
+	if len(insts) != 1 {
+		return []interface{}{}
+	}
+	inst := insts[0]
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	now := time.Now()
+	data := []map[string]string{}
+	for i := 0; i < limit; i++ {
+		ts := now.Add(-time.Duration(i) * 2 * time.Second)
+		side := "buy"
+		if i%3 == 0 {
+			side = "sell"
+		}
+		data = append(data, map[string]string{
+			"tradeId": strconv.FormatInt(ts.UnixMilli()/2000, 10),
+			"instId":  inst.InstID,
+			"price":   inst.format(inst.priceAt(ts)),
+			"size":    strconv.Itoa(1 + i%7),
+			"side":    side,
+			"ts":      millis(ts),
+		})
+	}
+	return data
+}
+
+func (m *mockExchange) markPrice(r *http.Request, _ []byte) interface{} {
+	now := time.Now()
+	data := []map[string]string{}
+	for _, inst := range selectMockInstruments(r) {
+		price := inst.priceAt(now)
+		data = append(data, map[string]string{
+			"instId":     inst.InstID,
+			"indexPrice": inst.format(price * 0.9999),
+			"markPrice":  inst.format(price),
+			"ts":         millis(now),
+		})
+	}
+	return data
+}
+
+// Next 8-hourly funding timestamp
+func nextFundingTime(now time.Time) time.Time {
+	return now.UTC().Truncate(8 * time.Hour).Add(8 * time.Hour)
+}
+
+func (m *mockExchange) fundingRate(r *http.Request, _ []byte) interface{} {
+	data := []map[string]string{}
+	for _, inst := range selectMockInstruments(r) {
+		data = append(data, map[string]string{
+			"instId":      inst.InstID,
+			"fundingRate": "0.000100",
+			"fundingTime": millis(nextFundingTime(time.Now())),
+		})
+	}
+	return data
+}
+
+func (m *mockExchange) fundingRateHistory(r *http.Request, _ []byte) interface{} {
+	insts := selectMockInstruments(r)
+	if len(insts) != 1 {
+		return []interface{}{}
+	}
+	data := []map[string]string{}
+	funding := nextFundingTime(time.Now())
+	for i := 1; i <= 10; i++ {
+		at := funding.Add(-time.Duration(i) * 8 * time.Hour)
+		data = append(data, map[string]string{
+			"instId":      insts[0].InstID,
+			"fundingRate": strconv.FormatFloat(0.0001*math.Cos(float64(i)), 'f', 6, 64),
+			"fundingTime": millis(at),
+		})
+	}
+	return data
+}
+
+func (m *mockExchange) candles(r *http.Request, _ []byte) interface{} {
+	insts := selectMockInstruments(r)
+	if len(insts) != 1 {
+		return []interface{}{}
+	}
+	inst := insts[0]
+	bar, ok := barDurations[r.URL.Query().Get("bar")]
+	if !ok {
+		bar = time.Minute
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 1440 {
+		limit = 100
+	}
+	start := time.Now().Truncate(bar)
+	if after, err := strconv.ParseInt(r.URL.Query().Get("after"), 10, 64); err == nil && after > 0 {
+		start = time.UnixMilli(after).Truncate(bar).Add(-bar)
+	}
+	data := [][]string{}
+	for i := 0; i < limit; i++ {
+		open := start.Add(-time.Duration(i) * bar)
+		o, c := inst.priceAt(open), inst.priceAt(open.Add(bar))
+		h, l := math.Max(o, c)*1.0008, math.Min(o, c)*0.9992
+		confirm := "1"
+		if i == 0 {
+			confirm = "0"
+		}
+		data = append(data, []string{
+			millis(open), inst.format(o), inst.format(h), inst.format(l), inst.format(c),
+			"1520", "152", strconv.FormatFloat(152*c, 'f', 2, 64), confirm,
+		})
+	}
+	return data
+}
+
+func (m *mockExchange) assetBalances(*http.Request, []byte) interface{} {
+	return []map[string]string{
+		{"currency": "USDT", "balance": "10000.000000", "available": "9500.000000", "frozen": "500.000000", "bonus": "0"},
+	}
+}
+
+func (m *mockExchange) accountBalance(*http.Request, []byte) interface{} {
+	ts := millis(time.Now())
+	return map[string]interface{}{
+		"ts":             ts,
+		"totalEquity":    "10125.430000",
+		"isolatedEquity": "0",
+		"details": []map[string]string{{
+			"currency":              "USDT",
+			"equity":                "10125.430000",
+			"balance":               "10000.000000",
+			"ts":                    ts,
+			"isolatedEquity":        "0",
+			"available":             "9250.000000",
+			"availableEquity":       "9250.000000",
+			"frozen":                "750.000000",
+			"orderFrozen":           "0",
+			"equityUsd":             "10125.430000",
+			"isolatedUnrealizedPnl": "0",
+			"bonus":                 "0",
+		}},
+	}
+}
+
+func (m *mockExchange) positions(r *http.Request, _ []byte) interface{} {
+	btc := mockInstruments[0]
+	if instID := r.URL.Query().Get("instId"); instID != "" && instID != btc.InstID {
+		return []interface{}{}
+	}
+	now := time.Now()
+	mark := btc.priceAt(now)
+	entry := btc.Price * 0.995
+	pnl := (mark - entry) * 100 * 0.001
+	return []map[string]string{{
+		"positionId":         "7001",
+		"instId":             btc.InstID,
+		"instType":           "SWAP",
+		"marginMode":         "cross",
+		"positionSide":       "net",
+		"adl":                "1",
+		"positions":          "100",
+		"availablePositions": "100",
+		"averagePrice":       btc.format(entry),
+		"margin":             "643.93",
+		"markPrice":          btc.format(mark),
+		"marginRatio":        "0.0312",
+		"liquidationPrice":   btc.format(entry * 0.82),
+		"unrealizedPnl":      strconv.FormatFloat(pnl, 'f', 4, 64),
+		"unrealizedPnlRatio": strconv.FormatFloat(pnl/643.93, 'f', 4, 64),
+		"leverage":           "10",
+		"createTime":         millis(now.Add(-6 * time.Hour)),
+		"updateTime":         millis(now),
+	}}
+}
+
+// A fixed list of invitees, newest first, paged by id like BloFin's
+func (m *mockExchange) affiliateInvitees(r *http.Request, _ []byte) interface{} {
+	const total = 250
+	query := r.URL.Query()
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 100
+	}
+	next := total
+	if after, err := strconv.Atoi(query.Get("after")); err == nil {
+		next = after - 1
+	}
+	joined := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	data := []map[string]string{}
+	for id := next; id > 0 && len(data) < limit; id-- {
+		data = append(data, map[string]string{
+			"id":                 strconv.Itoa(id),
+			"uid":                strconv.Itoa(20000000 + id),
+			"registerTime":       millis(joined.Add(time.Duration(id) * time.Hour)),
+			"totalCommission":    strconv.FormatFloat(float64(id)*1.25, 'f', 2, 64),
+			"totalTradingVolume": strconv.Itoa(id * 1000),
+		})
+	}
+	return data
+}
+
+func (m *mockExchange) leverageInfo(r *http.Request, _ []byte) interface{} {
+	marginMode := r.URL.Query().Get("marginMode")
+	data := []map[string]string{}
+	for _, inst := range selectMockInstruments(r) {
+		data = append(data, map[string]string{"instId": inst.InstID, "leverage": "10", "marginMode": marginMode, "positionSide": "net"})
+	}
+	return data
+}
+
+func (m *mockExchange) apiKeyInfo(*http.Request, []byte) interface{} {
+	return map[string]interface{}{
+		"apiName":    "mock",
+		"apiKey":     "mock-api-key",
+		"readOnly":   0,
+		"ips":        []string{},
+		"type":       1,
+		"expireTime": "0",
+		"createTime": millis(time.Now().Add(-30 * 24 * time.Hour)),
+	}
+}
+
+func (m *mockExchange) echoBody(_ *http.Request, body []byte) interface{} {
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return map[string]interface{}{}
+	}
+	return data
+}
+
+// Limit orders rest in the pending list; everything else fills immediately
+func (m *mockExchange) acceptOrder(order map[string]interface{}) map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	orderID := strconv.FormatInt(m.nextOrderID, 10)
+	m.nextOrderID++
+	clientOrderID, _ := order["clientOrderId"].(string)
+
+	now := millis(time.Now())
+	placed := map[string]interface{}{
+		"orderId":      orderID,
+		"filledSize":   "0",
+		"averagePrice": "0",
+		"state":        "live",
+		"fee":          "0",
+		"pnl":          "0",
+		"leverage":     "10",
+		"createTime":   now,
+		"updateTime":   now,
+	}
+	for key, value := range order {
+		placed[key] = value
+	}
+	if order["orderType"] != "market" {
+		m.orders = append(m.orders, placed)
+	} else {
+		placed["state"] = "filled"
+		placed["filledSize"] = placed["size"]
+		for _, inst := range mockInstruments {
+			if inst.InstID == placed["instId"] {
+				placed["averagePrice"] = inst.format(inst.priceAt(time.Now()))
+			}
+		}
+		m.finished[orderID] = placed
+	}
+	return map[string]string{"orderId": orderID, "clientOrderId": clientOrderID, "msg": "", "code": "0"}
+}
+
+func (m *mockExchange) placeOrder(_ *http.Request, body []byte) interface{} {
+	var order map[string]interface{}
+	if err := json.Unmarshal(body, &order); err != nil {
+		order = map[string]interface{}{}
+	}
+	return []map[string]string{m.acceptOrder(order)}
+}
+
+func (m *mockExchange) placeBatchOrders(_ *http.Request, body []byte) interface{} {
+	var orders []map[string]interface{}
+	if err := json.Unmarshal(body, &orders); err != nil {
+		return []interface{}{}
+	}
+	data := []map[string]string{}
+	for _, order := range orders {
+		data = append(data, m.acceptOrder(order))
+	}
+	return data
+}
+
+func (m *mockExchange) placeTPSL(_ *http.Request, body []byte) interface{} {
+	var order map[string]interface{}
+	if err := json.Unmarshal(body, &order); err != nil {
+		order = map[string]interface{}{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tpslID := strconv.FormatInt(m.nextOrderID, 10)
+	m.nextOrderID++
+	clientOrderID, _ := order["clientOrderId"].(string)
+	return map[string]string{"tpslId": tpslID, "clientOrderId": clientOrderID, "msg": "", "code": "0"}
+}
+
+func (m *mockExchange) cancelOrder(_ *http.Request, body []byte) interface{} {
+	var req map[string]interface{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		req = map[string]interface{}{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, order := range m.orders {
+		if (req["orderId"] != nil && order["orderId"] == req["orderId"]) ||
+			(req["clientOrderId"] != nil && order["clientOrderId"] == req["clientOrderId"]) {
+			m.orders = append(m.orders[:i], m.orders[i+1:]...)
+			order["state"] = "canceled"
+			order["updateTime"] = millis(time.Now())
+			m.finished[order["orderId"].(string)] = order
+			clientOrderID, _ := order["clientOrderId"].(string)
+			return []map[string]string{{"orderId": order["orderId"].(string), "clientOrderId": clientOrderID, "msg": "", "code": "0"}}
+		}
+	}
+	return []map[string]string{{"orderId": fmt.Sprint(req["orderId"]), "msg": "Order does not exist", "code": "1"}}
+}
+
+func (m *mockExchange) orderDetail(r *http.Request, _ []byte) interface{} {
+	query := r.URL.Query()
+	orderID, clientOrderID := query.Get("orderId"), query.Get("clientOrderId")
+	matches := func(order map[string]interface{}) bool {
+		return (orderID != "" && order["orderId"] == orderID) || (clientOrderID != "" && order["clientOrderId"] == clientOrderID)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, order := range m.orders {
+		if matches(order) {
+			return order
+		}
+	}
+	for _, order := range m.finished {
+		if matches(order) {
+			return order
+		}
+	}
+	return map[string]interface{}{}
+}
+
+// Finished orders, most recently updated first
+func (m *mockExchange) ordersHistory(r *http.Request, _ []byte) interface{} {
+	instID := r.URL.Query().Get("instId")
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data := []map[string]interface{}{}
+	for _, order := range m.finished {
+		if instID == "" || order["instId"] == instID {
+			data = append(data, order)
+		}
+	}
+	sort.Slice(data, func(i, j int) bool {
+		a, _ := strconv.ParseInt(fmt.Sprint(data[i]["updateTime"]), 10, 64)
+		b, _ := strconv.ParseInt(fmt.Sprint(data[j]["updateTime"]), 10, 64)
+		return a > b
+	})
+	if len(data) > limit {
+		data = data[:limit]
+	}
+	return data
+}
+
+func (m *mockExchange) ordersPending(r *http.Request, _ []byte) interface{} {
+	instID := r.URL.Query().Get("instId")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data := []map[string]interface{}{}
+	for _, order := range m.orders {
+		if instID == "" || order["instId"] == instID {
+			data = append(data, order)
+		}
+	}
+	return data
+}
+
+// One fill per filled order, newest first, paged by tradeId like BloFin's
+func (m *mockExchange) fillsHistory(r *http.Request, _ []byte) interface{} {
+	query := r.URL.Query()
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 100
+	}
+	after, _ := strconv.ParseInt(query.Get("after"), 10, 64)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []int64
+	for orderID, order := range m.finished {
+		id, _ := strconv.ParseInt(orderID, 10, 64)
+		if order["state"] == "filled" && (after == 0 || id < after) && (query.Get("instId") == "" || order["instId"] == query.Get("instId")) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+	data := []map[string]interface{}{}
+	for _, id := range ids[:min(len(ids), limit)] {
+		order := m.finished[strconv.FormatInt(id, 10)]
+		data = append(data, map[string]interface{}{
+			"instId":        order["instId"],
+			"tradeId":       order["orderId"],
+			"orderId":       order["orderId"],
+			"clientOrderId": order["clientOrderId"],
+			"fillPrice":     order["averagePrice"],
+			"fillSize":      order["filledSize"],
+			"fillPnl":       "0",
+			"positionSide":  "net",
+			"side":          order["side"],
+			"fee":           "0",
+			"ts":            order["updateTime"],
+		})
+	}
+	return data
+}
@@ -0,0 +1,463 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Endpoints served by the proxy itself rather than forwarded to BloFin
+var localRoutes = []apiRoute{
+	{Method: "GET", Path: "/health", Tag: "Proxy", Summary: "Health check"},
+	{Method: "GET", Path: "/", Tag: "Proxy", Summary: "Proxy information"},
+	{Method: "GET", Path: "/metrics", Tag: "Proxy", Summary: "Prometheus metrics"},
+	{Method: "GET", Path: "/stats/upstreams", Tag: "Proxy", Summary: "Measured latency and health of each BloFin host, and which one is in use"},
+	{Method: "GET", Path: "/openapi.json", Tag: "Proxy", Summary: "OpenAPI document for this proxy"},
+	{Method: "GET", Path: "/docs", Tag: "Proxy", Summary: "API explorer"},
+	{Method: "GET", Path: "/local/candles", Tag: "Local data", Summary: "Backfilled candlesticks from local storage, newest first",
+		Query: []apiParam{instIdReq, {Name: "bar", Type: "string", Description: "Bar size, default 1m"}, afterParam, beforeParam, limitParam}},
+	{Method: "GET", Path: "/local/instruments", Tag: "Local data", Summary: "Instrument specifications from the proxy's cache, filtered server-side",
+		Query: []apiParam{
+			{Name: "search", Type: "string", Description: "Case-insensitive match on instId, or exact base/quote currency, e.g. btc"},
+			{Name: "instId", Type: "string", Description: "Exact instrument ID"},
+			{Name: "instType", Type: "string", Description: "Instrument type, e.g. SWAP"},
+			{Name: "state", Type: "string", Description: "Instrument state, e.g. live"},
+			{Name: "ctType", Type: "string", Description: "Contract type: linear or inverse"},
+		}},
+	{Method: "GET", Path: "/local/funding", Tag: "Local data", Summary: "Current funding rates for all instruments, or one instrument's settled history, newest first",
+		Query: []apiParam{instIdParam, limitParam}},
+	{Method: "GET", Path: "/local/mark-price", Tag: "Local data", Summary: "Mark and index prices from the proxy's polled cache, all instruments when none is given",
+		Query: []apiParam{instIdParam, {Name: "instIds", Type: "string", Description: "Comma-separated instrument IDs, e.g. BTC-USDT,ETH-USDT"}}},
+	{Method: "GET", Path: "/aggregate/tickers", Tag: "Local data", Summary: "Tickers for several instruments from the proxy's polled cache",
+		Query: []apiParam{{Name: "instIds", Type: "string", Required: true, Description: "Comma-separated instrument IDs, e.g. BTC-USDT,ETH-USDT"}}},
+	{Method: "GET", Path: "/analytics/indicators", Tag: "Local data", Summary: "EMA, RSI or VWAP computed from stored candles, newest first",
+		Query: []apiParam{
+			instIdReq,
+			{Name: "bar", Type: "string", Description: "Bar size, default 1m"},
+			{Name: "type", Type: "string", Required: true, Description: "ema, rsi or vwap"},
+			{Name: "period", Type: "string", Description: "Window length in candles; ema defaults to 20, rsi to 14, vwap to a daily anchor"},
+			limitParam,
+		}},
+	{Method: "GET", Path: "/export/candles", Tag: "Local data", Summary: "Download stored candles as CSV or NDJSON",
+		Query: exportQuery},
+	{Method: "POST", Path: "/export/jobs", Tag: "Local data", Summary: "Start an asynchronous export for large ranges",
+		Body: append([]apiParam{{Name: "dataset", Type: "string", Description: "Dataset to export, currently candles"}}, exportQuery...)},
+	{Method: "GET", Path: "/export/jobs/{id}", Tag: "Local data", Summary: "Export job status"},
+	{Method: "GET", Path: "/export/jobs/{id}/download", Tag: "Local data", Summary: "Download a finished export"},
+	{Method: "GET", Path: "/local/affiliate/invitees", Tag: "Local data", Summary: "The tenant's latest (or a dated) affiliate invitee snapshot (needs X-Proxy-Token)",
+		Query: []apiParam{{Name: "date", Type: "string", Description: "Snapshot date, e.g. 2024-06-01; the latest when omitted"}}},
+	{Method: "GET", Path: "/local/fills", Tag: "Local data", Summary: "The tenant's fills stored by the sync-fills job, newest first (needs X-Proxy-Token)",
+		Query: []apiParam{
+			{Name: "instId", Type: "string", Description: "Instrument ID, e.g. BTC-USDT"},
+			{Name: "begin", Type: "integer", Description: "Earliest fill time in milliseconds"},
+			{Name: "end", Type: "integer", Description: "Latest fill time in milliseconds"},
+			{Name: "limit", Type: "integer", Description: "Number of fills, default 100, at most 1000"},
+		}},
+	{Method: "GET", Path: "/analytics/pnl", Tag: "Local data", Summary: "The tenant's realized PnL and fees over a period from stored fills, plus unrealized PnL now, by instrument (needs X-Proxy-Token)",
+		Query: []apiParam{{Name: "period", Type: "string", Description: "How far back, e.g. 24h or 7d; 24h when omitted"}}},
+	{Method: "GET", Path: "/sse/poll", Tag: "Local data", Summary: "Server-Sent Events stream of a PUSH_ROUTES endpoint: a snapshot, then only changed items",
+		Query: []apiParam{{Name: "path", Type: "string", Required: true, Description: "BloFin endpoint, e.g. /api/v1/market/funding-rate; other parameters are passed on"}}},
+	{Method: "GET", Path: "/orders/watch", Tag: "Order watches", Summary: "The tenant's watched orders (needs X-Proxy-Token)"},
+	{Method: "POST", Path: "/orders/watch", Tag: "Order watches", Summary: "POST an order's final state to a callback once it is filled or canceled (needs X-Proxy-Token)",
+		Body: []apiParam{
+			{Name: "instId", Type: "string", Required: true, Description: "Instrument ID, e.g. BTC-USDT"},
+			{Name: "orderId", Type: "string", Description: "Order ID; give this or clientOrderId"},
+			{Name: "clientOrderId", Type: "string", Description: "Client order ID; give this or orderId"},
+			{Name: "callback", Type: "string", Required: true, Description: "http(s) URL that receives the final state"},
+		}},
+	{Method: "GET", Path: "/orders/watch/{id}", Tag: "Order watches", Summary: "One watched order"},
+	{Method: "DELETE", Path: "/orders/watch/{id}", Tag: "Order watches", Summary: "Stop watching an order"},
+	{Method: "POST", Path: "/helpers/order-with-tpsl", Tag: "Order helpers", Summary: "Place an entry order and its TP/SL order, cancelling the entry if the TP/SL fails (needs X-Proxy-Token)",
+		Body: []apiParam{
+			instIdReq,
+			marginMode,
+			positionSide,
+			{Name: "side", Type: "string", Required: true, Description: "buy or sell; the TP/SL order takes the opposite side"},
+			{Name: "orderType", Type: "string", Required: true, Description: "market, limit, post_only, fok or ioc"},
+			{Name: "price", Type: "string", Description: "Entry price, required unless orderType is market"},
+			{Name: "size", Type: "string", Required: true, Description: "Number of contracts"},
+			clientOrderId,
+			{Name: "tpTriggerPrice", Type: "string", Description: "Take-profit trigger price; give this and/or slTriggerPrice"},
+			{Name: "tpOrderPrice", Type: "string", Description: "Take-profit order price, -1 (default) for market"},
+			{Name: "slTriggerPrice", Type: "string", Description: "Stop-loss trigger price"},
+			{Name: "slOrderPrice", Type: "string", Description: "Stop-loss order price, -1 (default) for market"},
+		}},
+	{Method: "POST", Path: "/helpers/close-position", Tag: "Order helpers", Summary: "Close all or part of a position with a reduce-only order (needs X-Proxy-Token)",
+		Body: []apiParam{
+			instIdReq,
+			{Name: "positionSide", Type: "string", Description: "net, long or short; needed when the instrument has both a long and a short position"},
+			{Name: "marginMode", Type: "string", Description: "cross or isolated; needed when the instrument has positions in both"},
+			{Name: "percent", Type: "number", Description: "Share of the position to close, rounded down to the lot size (default 100)"},
+			{Name: "orderType", Type: "string", Description: "market (default), limit, post_only, fok or ioc"},
+			{Name: "price", Type: "string", Description: "Limit price, required unless orderType is market"},
+			clientOrderId,
+		}},
+	{Method: "POST", Path: "/helpers/leverage", Tag: "Order helpers", Summary: "Set leverage after checking it against the instrument's maximum and the margin an open position would need (needs X-Proxy-Token)",
+		Body: []apiParam{
+			instIdReq,
+			{Name: "leverage", Type: "string", Required: true, Description: "Leverage, at least 1 and at most the instrument's maxLeverage"},
+			{Name: "marginMode", Type: "string", Required: true, Description: "cross or isolated"},
+			positionSide,
+		}},
+	{Method: "POST", Path: "/graphql", Tag: "GraphQL", Summary: "Run a GraphQL query over tickers, instruments, positions, balances and openOrders; private fields need X-Proxy-Token",
+		Body: []apiParam{
+			{Name: "query", Type: "string", Required: true, Description: "The query, e.g. { tickers(instIds: [\"BTC-USDT\"]) { instId last } }"},
+			{Name: "variables", Type: "object", Description: "Values for the query's $variables"},
+		}},
+	{Method: "GET", Path: "/graphql", Tag: "GraphQL", Summary: "Run a GraphQL query given in the query string",
+		Query: []apiParam{
+			{Name: "query", Type: "string", Required: true, Description: "The query"},
+			{Name: "variables", Type: "string", Description: "Values for the query's $variables, as a JSON object"},
+		}},
+	{Method: "GET", Path: "/utils/key-check", Tag: "Utilities", Summary: "Check that a BloFin API key works from this proxy and report its permission and IP binding (X-Proxy-Token, or ACCESS-* headers signed for GET /api/v1/user/query-apikey)"},
+	{Method: "POST", Path: "/webhooks/tradingview", Tag: "Webhooks", Summary: "Place the order a TradingView alert asks for, as the tenant whose tradingView secret the JSON alert message carries, or whose signing key produced X-Webhook-Signature"},
+	{Method: "GET", Path: "/sse/orders", Tag: "Order watches", Summary: "Server-Sent Events stream of the tenant's order and fill events (needs X-Proxy-Token)"},
+	{Method: "GET", Path: "/admin/chaos", Tag: "Admin", Admin: true, Summary: "Fault injection settings"},
+	{Method: "PUT", Path: "/admin/chaos", Tag: "Admin", Admin: true, Summary: "Change fault injection settings",
+		Body: []apiParam{
+			{Name: "percent", Type: "number", Description: "Share of /api requests affected, 0-100"},
+			{Name: "faults", Type: "array", Description: "latency, error and/or reset"},
+			{Name: "maxLatencyMs", Type: "integer", Description: "Upper bound for injected latency"},
+			{Name: "errorStatuses", Type: "array", Description: "Statuses returned by the error fault"},
+		}},
+	{Method: "GET", Path: "/admin/maintenance", Tag: "Admin", Admin: true, Summary: "Maintenance mode settings"},
+	{Method: "PUT", Path: "/admin/maintenance", Tag: "Admin", Admin: true, Summary: "Hold BloFin-bound traffic with a 503",
+		Body: []apiParam{
+			{Name: "enabled", Type: "boolean", Required: true, Description: "Whether to hold traffic"},
+			{Name: "message", Type: "string", Description: "Returned to clients as the error"},
+			{Name: "retryAfterSeconds", Type: "integer", Description: "Sent as Retry-After"},
+		}},
+	{Method: "DELETE", Path: "/admin/maintenance", Tag: "Admin", Admin: true, Summary: "Leave maintenance mode"},
+	{Method: "GET", Path: "/admin/backfill", Tag: "Admin", Admin: true, Summary: "Candle backfill progress"},
+	{Method: "POST", Path: "/admin/backfill", Tag: "Admin", Admin: true, Summary: "Start a candle backfill, of BACKFILL_TARGETS unless the body names targets",
+		Body: []apiParam{
+			{Name: "targets", Type: "array", Description: "Objects with instId and bar"},
+			{Name: "lookback", Type: "string", Description: "How far back to fetch, e.g. 2160h"},
+		}},
+	{Method: "GET", Path: "/admin/alerts", Tag: "Admin", Admin: true, Summary: "Price alert rules and whether each is armed"},
+	{Method: "POST", Path: "/admin/alerts", Tag: "Admin", Admin: true, Summary: "Add a price alert rule",
+		Body: []apiParam{
+			instIdReq,
+			{Name: "condition", Type: "string", Required: true, Description: "above or below"},
+			{Name: "threshold", Type: "string", Required: true, Description: "Price that fires the rule"},
+			{Name: "field", Type: "string", Description: "last (default), bidPrice or askPrice"},
+			{Name: "rearm", Type: "string", Description: "Price to cross back over before firing again, the threshold by default"},
+			{Name: "cooldown", Type: "string", Description: "Shortest time between firings, e.g. 10m"},
+			{Name: "webhook", Type: "string", Description: "URL that receives a JSON POST"},
+			{Name: "telegramChatId", Type: "string", Description: "Telegram chat that receives a message"},
+			{Name: "note", Type: "string", Description: "Text sent along when the rule fires"},
+		}},
+	{Method: "GET", Path: "/admin/alerts/{id}", Tag: "Admin", Admin: true, Summary: "One price alert rule"},
+	{Method: "DELETE", Path: "/admin/alerts/{id}", Tag: "Admin", Admin: true, Summary: "Remove a price alert rule"},
+	{Method: "GET", Path: "/admin/jobs", Tag: "Admin", Admin: true, Summary: "Scheduled jobs with their last and next run"},
+	{Method: "POST", Path: "/admin/jobs/{name}", Tag: "Admin", Admin: true, Summary: "Run a scheduled job now"},
+	{Method: "GET", Path: "/admin/bans", Tag: "Admin", Admin: true, Summary: "Client IPs banned for repeated 429s"},
+	{Method: "DELETE", Path: "/admin/bans/{ip}", Tag: "Admin", Admin: true, Summary: "Lift a ban"},
+	{Method: "GET", Path: "/admin/losses", Tag: "Admin", Admin: true, Summary: "Each tenant's PnL, loss and halt for today"},
+	{Method: "DELETE", Path: "/admin/losses/{tenant}", Tag: "Admin", Admin: true, Summary: "Lift a tenant's loss halt for the rest of the day"},
+	{Method: "GET", Path: "/admin/shadow", Tag: "Admin", Admin: true, Summary: "Per-route mismatch rates between the primary and shadow upstreams"},
+	{Method: "DELETE", Path: "/admin/shadow", Tag: "Admin", Admin: true, Summary: "Reset shadow mismatch counts"},
+}
+
+var exportQuery = []apiParam{
+	instIdReq,
+	{Name: "bar", Type: "string", Description: "Bar size, default 1m"},
+	{Name: "from", Type: "string", Description: "Start time, milliseconds or RFC3339 (jobs take milliseconds)"},
+	{Name: "to", Type: "string", Description: "End time, milliseconds or RFC3339 (jobs take milliseconds)"},
+	{Name: "format", Type: "string", Description: "csv (default) or ndjson"},
+}
+
+// Routes documented for a listener serving patterns, as registered on its
+// ServeMux: local routes served by one of them, the unified and Binance
+// methods when those APIs are on, and BloFin's routes behind "/"
+func servedRoutes(patterns []string) []apiRoute {
+	served := map[string]bool{}
+	for _, pattern := range patterns {
+		served[pattern] = true
+	}
+	var routes []apiRoute
+	for _, route := range localRoutes {
+		if servedBy(patterns, route.Path) != "" {
+			routes = append(routes, route)
+		}
+	}
+	if served["/unified/"] {
+		for name, method := range unifiedMethods {
+			routes = append(routes, apiRoute{Method: method.httpMethod, Path: "/unified/" + name, Tag: "Unified API", Summary: method.summary})
+		}
+	}
+	if served["/binance/"] {
+		for key, endpoint := range binanceEndpoints {
+			method, path, _ := strings.Cut(key, " ")
+			routes = append(routes, apiRoute{Method: method, Path: "/binance" + path, Tag: "Binance API", Summary: endpoint.summary})
+		}
+	}
+	if served["/"] {
+		routes = append(routes, blofinRoutes...)
+	}
+	return routes
+}
+
+// The pattern other than "/" that serves path, empty if none does
+func servedBy(patterns []string, path string) string {
+	for _, pattern := range patterns {
+		if pattern == path || (pattern != "/" && strings.HasSuffix(pattern, "/") && strings.HasPrefix(path, pattern)) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// Build the OpenAPI 3 document from the patterns the listener serving it
+// registered, so it never drifts from what the proxy actually serves
+func buildOpenAPI(serverURL string, patterns []string) map[string]interface{} {
+	paths := map[string]interface{}{}
+	for _, route := range servedRoutes(patterns) {
+		item, ok := paths[route.Path].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[route.Path] = item
+		}
+		item[strings.ToLower(route.Method)] = openAPIOperation(route)
+	}
+
+	schemes := map[string]interface{}{
+		"adminToken": map[string]interface{}{"type": "http", "scheme": "bearer", "description": "ADMIN_TOKEN"},
+	}
+	for _, name := range blofinAuthHeaders {
+		schemes[name] = map[string]interface{}{
+			"type": "apiKey",
+			"in":   "header",
+			"name": name,
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Blofin CORS Proxy",
+			"version":     "1.0",
+			"description": fmt.Sprintf("CORS proxy in front of %s. Routes under /api/ are forwarded with all authentication headers intact.", BLOFIN_API_BASE),
+		},
+		"servers":    []interface{}{map[string]interface{}{"url": serverURL}},
+		"paths":      paths,
+		"components": map[string]interface{}{"securitySchemes": schemes},
+	}
+}
+
+func openAPIOperation(route apiRoute) map[string]interface{} {
+	params := []interface{}{}
+	for _, p := range route.Query {
+		params = append(params, map[string]interface{}{
+			"name":        p.Name,
+			"in":          "query",
+			"required":    p.Required,
+			"description": p.Description,
+			"schema":      map[string]interface{}{"type": p.Type},
+		})
+	}
+	for _, h := range proxyHeaders {
+		if !h.appliesTo(route.Path) {
+			continue
+		}
+		params = append(params, map[string]interface{}{
+			"name":        h.Name,
+			"in":          "header",
+			"required":    false,
+			"description": h.Description,
+			"schema":      map[string]interface{}{"type": "string"},
+		})
+	}
+
+	op := map[string]interface{}{
+		"summary":    route.Summary,
+		"tags":       []string{route.Tag},
+		"parameters": params,
+		"responses": map[string]interface{}{
+			"200": map[string]interface{}{"description": "Successful response"},
+		},
+	}
+
+	if route.Private {
+		requirement := map[string]interface{}{}
+		for _, name := range blofinAuthHeaders {
+			requirement[name] = []string{}
+		}
+		op["security"] = []interface{}{requirement}
+	}
+	if route.Admin {
+		op["security"] = []interface{}{map[string]interface{}{"adminToken": []string{}}}
+	}
+
+	if len(route.Body) > 0 {
+		properties := map[string]interface{}{}
+		required := []string{}
+		for _, p := range route.Body {
+			property := map[string]interface{}{"type": p.Type, "description": p.Description}
+			if p.Type == "array" {
+				property["items"] = map[string]interface{}{}
+			}
+			properties[p.Name] = property
+			if p.Required {
+				required = append(required, p.Name)
+			}
+		}
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		if route.BodyArray {
+			schema = map[string]interface{}{"type": "array", "items": schema}
+		}
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": schema},
+			},
+		}
+	}
+	return op
+}
+
+// Serve the document for the routes registered as patterns
+func openAPIHandler(patterns *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		doc := buildOpenAPI(scheme+"://"+r.Host, *patterns)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(doc); err != nil {
+			log.Printf("❌ Failed to write OpenAPI document: %v", err)
+		}
+	}
+}
+
+// API explorer for /openapi.json. This stands in for Swagger UI: loading
+// that from a CDN would run outside code on a page where tokens are typed,
+// and vendoring it would add a megabyte of third-party JavaScript to a
+// module with no dependencies. Swagger UI can still be pointed at the
+// document.
+const docsScript = `
+const el = (tag, props, ...children) => {
+  const node = Object.assign(document.createElement(tag), props || {});
+  node.append(...children);
+  return node;
+};
+const field = (label, input) => el("label", {}, el("span", {}, label), input);
+
+function operation(path, method, op) {
+  const params = (op.parameters || []).map(p => {
+    const input = el("input", {placeholder: p.schema && p.schema.type || "string", title: p.description || ""});
+    return {p, input, node: field(p.name + " (" + p.in + (p.required ? ", required" : "") + ")", input)};
+  });
+  const pathParams = [...path.matchAll(/{(\w+)}/g)].map(m => {
+    const input = el("input", {placeholder: "string"});
+    return {p: {name: m[1], in: "path"}, input, node: field(m[1] + " (path, required)", input)};
+  });
+  const auth = [...Object.keys((op.security || [{}])[0] || {})].map(name => {
+    const input = el("input", {placeholder: name === "adminToken" ? "Bearer token" : name});
+    return {name, input, node: field(name === "adminToken" ? "Authorization" : name, input)};
+  });
+  let body = null;
+  const schema = op.requestBody && op.requestBody.content["application/json"].schema;
+  if (schema) {
+    const item = schema.items || schema;
+    const example = {};
+    for (const name of Object.keys(item.properties || {})) example[name] = "";
+    body = el("textarea", {rows: 8, value: JSON.stringify(schema.items ? [example] : example, null, 2)});
+  }
+  const output = el("pre", {className: "output"});
+  const send = el("button", {textContent: "Send"});
+  send.onclick = async () => {
+    let url = path;
+    const query = new URLSearchParams();
+    const headers = {};
+    for (const {p, input} of pathParams.concat(params)) {
+      if (!input.value) continue;
+      if (p.in === "path") url = url.replace("{" + p.name + "}", encodeURIComponent(input.value));
+      else if (p.in === "query") query.set(p.name, input.value);
+      else headers[p.name] = input.value;
+    }
+    for (const {name, input} of auth) {
+      if (!input.value) continue;
+      if (name === "adminToken") headers["Authorization"] = "Bearer " + input.value;
+      else headers[name] = input.value;
+    }
+    if (body) headers["Content-Type"] = "application/json";
+    if ([...query].length) url += "?" + query;
+    output.textContent = "...";
+    try {
+      const resp = await fetch(url, {method: method.toUpperCase(), headers, body: body ? body.value : undefined});
+      let text = await resp.text();
+      try { text = JSON.stringify(JSON.parse(text), null, 2); } catch (e) {}
+      output.textContent = resp.status + " " + resp.statusText + "\n\n" + text;
+    } catch (e) {
+      output.textContent = String(e);
+    }
+  };
+  return el("details", {className: "op"},
+    el("summary", {}, el("b", {className: method, textContent: method.toUpperCase()}), " ", el("code", {textContent: path}), " ", op.summary || ""),
+    el("div", {className: "form"}, ...pathParams.map(x => x.node), ...params.map(x => x.node), ...auth.map(x => x.node),
+      ...(body ? [field("body", body)] : []), send, output));
+}
+
+fetch("/openapi.json").then(resp => resp.json()).then(doc => {
+  document.getElementById("title").textContent = doc.info.title;
+  document.getElementById("description").textContent = doc.info.description;
+  const tags = {};
+  for (const path of Object.keys(doc.paths).sort()) {
+    for (const [method, op] of Object.entries(doc.paths[path])) {
+      const tag = (op.tags || ["Other"])[0];
+      (tags[tag] = tags[tag] || []).push(operation(path, method, op));
+    }
+  }
+  const root = document.getElementById("operations");
+  for (const tag of Object.keys(tags).sort()) root.append(el("h2", {textContent: tag}), ...tags[tag]);
+});
+`
+
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Blofin CORS Proxy - API Explorer</title>
+  <style>
+    body { font: 14px system-ui, sans-serif; margin: 2em auto; max-width: 70em; padding: 0 1em; }
+    .op { border: 1px solid #ddd; border-radius: 4px; margin: 0.3em 0; padding: 0.4em 0.8em; }
+    .op summary { cursor: pointer; }
+    .op b { display: inline-block; width: 4.5em; }
+    .get { color: #2a7ab0; } .post { color: #3c9a4b; } .put { color: #b07a2a; } .delete { color: #b03a2a; }
+    .form { display: grid; gap: 0.4em; margin: 0.8em 0; }
+    .form label { display: grid; grid-template-columns: 18em 1fr; align-items: start; }
+    .form input, .form textarea { font: 13px monospace; }
+    .form button { justify-self: start; }
+    .output { background: #f6f6f6; max-height: 30em; overflow: auto; padding: 0.5em; white-space: pre-wrap; }
+  </style>
+</head>
+<body>
+  <h1 id="title">API Explorer</h1>
+  <p id="description"></p>
+  <div id="operations"></div>
+  <script>` + docsScript + `</script>
+</body>
+</html>
+`
+
+// Only the page's own script and styles may run, and it may only call
+// the proxy
+var docsPolicy = func() string {
+	sum := sha256.Sum256([]byte(docsScript))
+	return "default-src 'none'; style-src 'unsafe-inline'; connect-src 'self'; script-src 'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+}()
+
+func docsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", docsPolicy)
+	fmt.Fprint(w, docsPage)
+}
@@ -0,0 +1,142 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A proxy in mock mode with every optional route turned on
+func newTestProxy(t *testing.T, adminPort string) *Proxy {
+	t.Helper()
+	// Background pollers keep writing here, so it is removed on a best
+	// effort basis rather than with t.TempDir
+	dir, err := os.MkdirTemp("", "blofin-proxy-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	tenants := filepath.Join(dir, "tenants.json")
+	if err := os.WriteFile(tenants, []byte(`[{"name":"alice","token":"tok-a","apiKey":"k","secret":"s","passphrase":"p","dailyLossLimit":"100"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	cfg.Mode = MODE_MOCK
+	cfg.DataDir = dir
+	cfg.TenantsFile = tenants
+	cfg.AdminToken = "adm"
+	cfg.AdminPort = adminPort
+	cfg.UnifiedAPI = true
+	cfg.BinanceAPI = true
+	cfg.GraphQLAPI = true
+	cfg.RateLimit.PerIP = 100
+	cfg.RateLimit.BanAfter = 10
+	cfg.PushRoutes = []PushRule{{Prefix: "/api/v1/market/", Interval: time.Minute}}
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	p := newTestProxy(t, "")
+	documented := map[string]bool{}
+	for _, route := range servedRoutes(p.patterns) {
+		documented[servedBy(p.patterns, route.Path)] = true
+	}
+	for _, pattern := range p.patterns {
+		if pattern != "/" && !documented[pattern] {
+			t.Errorf("%s is registered but missing from localRoutes", pattern)
+		}
+	}
+}
+
+func TestOpenAPIFollowsRegisteredRoutes(t *testing.T) {
+	paths := func(h http.Handler) map[string]interface{} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+		var doc struct {
+			Paths map[string]interface{} `json:"paths"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+			t.Fatal(err)
+		}
+		return doc.Paths
+	}
+
+	all := paths(newTestProxy(t, ""))
+	for _, path := range []string{"/unified/createOrder", "/binance/fapi/v1/order", "/admin/chaos", "/admin/bans/{ip}", "/sse/poll", "/orders/watch", "/api/v1/trade/order"} {
+		if all[path] == nil {
+			t.Errorf("%s missing from the document", path)
+		}
+	}
+
+	// With an admin listener the document and the local data move there,
+	// and it covers only what that listener serves
+	split := newTestProxy(t, "19999")
+	ops := paths(split.AdminHandler())
+	for _, path := range []string{"/admin/chaos", "/metrics", "/local/candles", "/export/jobs", "/docs"} {
+		if ops[path] == nil {
+			t.Errorf("%s missing from the admin document", path)
+		}
+	}
+	for _, path := range []string{"/health", "/api/v1/trade/order"} {
+		if ops[path] != nil {
+			t.Errorf("%s documented on the admin listener", path)
+		}
+	}
+	for _, path := range []string{"/openapi.json", "/docs", "/local/candles", "/local/fills", "/export/jobs"} {
+		rec := httptest.NewRecorder()
+		split.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s on the public listener = %d, want 404", path, rec.Code)
+		}
+	}
+}
+
+func TestProxyHeadersApply(t *testing.T) {
+	applies := func(name, path string) bool {
+		for _, h := range proxyHeaders {
+			if h.Name == name {
+				return h.appliesTo(path)
+			}
+		}
+		t.Fatalf("no proxy header %s", name)
+		return false
+	}
+	tests := []struct {
+		header, path string
+		want         bool
+	}{
+		{"X-Dry-Run", "/api/v1/trade/order", true},
+		{"X-Dry-Run", "/api/v1/trade/orders-pending", false},
+		{TENANT_HEADER, "/unified/createOrder", true},
+		{TENANT_HEADER, "/health", false},
+		{TIMEOUT_HEADER, "/helpers/leverage", true},
+		{TIMEOUT_HEADER, "/local/candles", false},
+		{DEBUG_HEADER, "/api/v1/account/balance", true},
+		{"traceparent", "/local/candles", true},
+	}
+	for _, tt := range tests {
+		if got := applies(tt.header, tt.path); got != tt.want {
+			t.Errorf("%s on %s = %v, want %v", tt.header, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestDocsPagePolicy(t *testing.T) {
+	rec := httptest.NewRecorder()
+	docsHandler(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if rec.Header().Get("Content-Security-Policy") != docsPolicy {
+		t.Errorf("Content-Security-Policy = %q", rec.Header().Get("Content-Security-Policy"))
+	}
+	if body := rec.Body.String(); strings.Contains(body, "http://") || strings.Contains(body, "https://") {
+		t.Error("the docs page loads something from another host")
+	}
+}
@@ -0,0 +1,226 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Orders and their notional value each sender placed today (UTC),
+// checked against daily caps. Attempts count whether or not BloFin accepts
+// them, so a strategy looping on rejected orders is stopped too.
+type orderCaps struct {
+	mu       sync.Mutex
+	day      string
+	orders   map[string]int
+	notional map[string]*big.Rat
+}
+
+func newOrderCaps() *orderCaps {
+	return &orderCaps{day: quotaDay(time.Now()), orders: map[string]int{}, notional: map[string]*big.Rat{}}
+}
+
+// An order the proxy refuses itself: 429 past a daily cap, 403 outside
+// the tenant's instruments or trading hours or while halted after losses
+type orderRefusal struct {
+	status int
+	msg    string
+	until  time.Time // when it lifts; zero for midnight UTC
+	final  bool      // it never lifts by itself
+}
+
+func (e *orderRefusal) Error() string { return e.msg }
+
+func (e *orderRefusal) setRetryAfter(w http.ResponseWriter) {
+	switch {
+	case e.final:
+		return
+	case e.until.IsZero():
+		retryAtMidnight(w)
+	default:
+		w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(e.until).Seconds())+1))
+	}
+}
+
+// Count orders worth value against sender's caps, all or nothing. Zero
+// maxOrders or nil maxNotional leave that cap off.
+func (c *orderCaps) spend(sender string, orders int, value *big.Rat, maxOrders int, maxNotional *big.Rat) *orderRefusal {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if day := quotaDay(time.Now()); day != c.day {
+		c.day, c.orders, c.notional = day, map[string]int{}, map[string]*big.Rat{}
+	}
+	if maxOrders > 0 && c.orders[sender]+orders > maxOrders {
+		return &orderRefusal{status: http.StatusTooManyRequests, msg: fmt.Sprintf("Daily cap of %d orders reached (%d placed today), it resets at 00:00 UTC", maxOrders, c.orders[sender])}
+	}
+	used := c.notional[sender]
+	if used == nil {
+		used = new(big.Rat)
+	}
+	total := new(big.Rat).Add(used, value)
+	if maxNotional != nil && total.Cmp(maxNotional) > 0 {
+		return &orderRefusal{status: http.StatusTooManyRequests, msg: fmt.Sprintf("Daily cap of %s in order value reached (%s placed today, this order adds %s), it resets at 00:00 UTC", maxNotional.FloatString(2), used.FloatString(2), value.FloatString(2))}
+	}
+	c.orders[sender] += orders
+	c.notional[sender] = total
+	return nil
+}
+
+// Who daily caps are counted for: a tenant, or a client signing with its
+// own key, identified by a hash so keys aren't kept; empty for others
+func orderSender(t *tenant, key string) string {
+	if t != nil {
+		return "tenant:" + t.Name
+	}
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return KEY_ALIAS_PREFIX + hex.EncodeToString(sum[:8])
+}
+
+// The tenant's own caps, else DAILY_ORDER_LIMIT and DAILY_NOTIONAL_LIMIT
+func (s *server) dailyCaps(t *tenant) (int, *big.Rat) {
+	maxOrders, notional := s.cfg.DailyOrderLimit, s.cfg.DailyNotional
+	if t != nil && t.DailyOrders > 0 {
+		maxOrders = t.DailyOrders
+	}
+	if t != nil && t.DailyNotional != "" {
+		notional = t.DailyNotional
+	}
+	maxNotional, _ := parseDecimal(notional)
+	return maxOrders, maxNotional
+}
+
+// Count an order placement body against its sender's daily caps. Orders
+// whose value can't be worked out (unknown instrument or price) count
+// toward the number of orders only.
+func (s *server) spendOrderCaps(sender string, t *tenant, path string, body []byte) *orderRefusal {
+	maxOrders, maxNotional := s.dailyCaps(t)
+	if sender == "" || (maxOrders == 0 && maxNotional == nil) {
+		return nil
+	}
+	orders, err := parseOrders(path, body)
+	if err != nil {
+		return nil
+	}
+	value := new(big.Rat)
+	for _, order := range orders {
+		if v, ok := s.orderValue(order); ok {
+			value.Add(value, v)
+		}
+	}
+	if err := s.caps.spend(sender, len(orders), value, maxOrders, maxNotional); err != nil {
+		log.Printf("🛑 %s: %v", sender, err)
+		return err
+	}
+	return nil
+}
+
+// Notional value of an order in quote currency, at the mark price for
+// market orders
+func (s *server) orderValue(order map[string]interface{}) (*big.Rat, bool) {
+	instID, _ := order["instId"].(string)
+	inst, ok, err := s.instruments.get(instID)
+	if err != nil || !ok {
+		return nil, false
+	}
+	size, ok := decimalField(order, "size")
+	contractValue, cvOK := parseDecimal(inst.ContractValue)
+	price, priceOK := s.orderPrice(order, inst)
+	if !ok || !cvOK || !priceOK {
+		return nil, false
+	}
+	value := new(big.Rat).Mul(size, contractValue)
+	return value.Mul(value, price), true
+}
+
+// Check a placement in r against trading hours, a loss halt and its
+// sender's daily caps; false once answered
+func (s *server) checkDailyLimits(w http.ResponseWriter, r *http.Request, t *tenant) bool {
+	sender := orderSender(t, r.Header.Get("ACCESS-KEY"))
+	maxOrders, maxNotional := s.dailyCaps(t)
+	capped := sender != "" && (maxOrders > 0 || maxNotional != nil)
+	if !capped && !s.losses.halted(t) && (t == nil || len(t.windows) == 0) {
+		return true
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MAX_INSPECT_BODY))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Failed to read request body"})
+		return false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err := s.dailyLimits(sender, t, r.URL.Path, body); err != nil {
+		orderRefused(w, err)
+		return false
+	}
+	return true
+}
+
+// Refusal for a placement body from sender, nil when it may go ahead
+func (s *server) dailyLimits(sender string, t *tenant, path string, body []byte) *orderRefusal {
+	if err := tradingHoursCheck(t, path, body, time.Now()); err != nil {
+		return err
+	}
+	if err := s.losses.check(t, path, body); err != nil {
+		return err
+	}
+	return s.spendOrderCaps(sender, t, path, body)
+}
+
+// Place a single order the proxy signs for t, after checking t's
+// instrument list, daily limits, quota and for a duplicate of it
+func (s *server) placeOrder(ctx context.Context, t *tenant, order interface{}, out interface{}) error {
+	return s.sendOrder(ctx, t, "/api/v1/trade/order", order, out)
+}
+
+// POST an order of any kind to path with placeOrder's checks
+func (s *server) sendOrder(ctx context.Context, t *tenant, path string, order interface{}, out interface{}) error {
+	body, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+	if err := t.allowsInstruments(bodyInstIDs(body)); err != nil {
+		return &orderRefusal{status: http.StatusForbidden, msg: err.Error(), final: true}
+	}
+	var payload interface{} = order
+	if s.checksOrders() && orderRoutes[path] {
+		checked, err := s.checkSentOrder(path, body)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(checked, body) {
+			body, payload = checked, json.RawMessage(checked)
+		}
+	}
+	sender := orderSender(t, "")
+	release, ok := s.claimOrder(sender, path, body)
+	if !ok {
+		log.Printf("♻️ Duplicate order for %s held back", t.Name)
+		return &orderRefusal{status: http.StatusConflict, msg: DUPLICATE_ORDER_MESSAGE, final: true}
+	}
+	defer release()
+	if err := s.dailyLimits(sender, t, path, body); err != nil {
+		return err
+	}
+	if !quotaSpent(ctx) && !s.quotas.spend(t) {
+		return &orderRefusal{status: http.StatusTooManyRequests, msg: quotaExceededMessage(t)}
+	}
+	return s.blofin.do(ctx, http.MethodPost, path, nil, payload, t, out)
+}
+
+// Answer a refused order, with a Retry-After for when the refusal lifts
+func orderRefused(w http.ResponseWriter, e *orderRefusal) {
+	e.setRetryAfter(w)
+	writeJSON(w, e.status, map[string]string{"error": e.msg})
+}
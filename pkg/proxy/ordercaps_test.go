@@ -0,0 +1,154 @@
+package proxy
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOrderCapsSpend(t *testing.T) {
+	rat := func(s string) *big.Rat {
+		r, _ := new(big.Rat).SetString(s)
+		return r
+	}
+	type spend struct {
+		sender  string
+		orders  int
+		value   string
+		refused string
+	}
+	tests := []struct {
+		name        string
+		maxOrders   int
+		maxNotional *big.Rat
+		spends      []spend
+	}{
+		{"order cap", 3, nil, []spend{
+			{"a", 2, "0", ""},
+			{"a", 1, "0", ""},
+			{"a", 1, "0", "Daily cap of 3 orders"},
+			{"b", 3, "0", ""},
+		}},
+		{"batch past the order cap counts nothing", 3, nil, []spend{
+			{"a", 2, "0", ""},
+			{"a", 2, "0", "Daily cap of 3 orders"},
+			{"a", 1, "0", ""},
+		}},
+		{"notional cap", 0, rat("1000"), []spend{
+			{"a", 1, "600", ""},
+			{"a", 1, "500", "Daily cap of 1000.00 in order value"},
+			{"a", 1, "400", ""},
+			{"a", 1, "0.01", "Daily cap of 1000.00 in order value"},
+			{"b", 1, "1000", ""},
+		}},
+		{"no caps", 0, nil, []spend{
+			{"a", 1000, "1000000", ""},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caps := newOrderCaps()
+			for i, s := range tt.spends {
+				err := caps.spend(s.sender, s.orders, rat(s.value), tt.maxOrders, tt.maxNotional)
+				switch {
+				case s.refused == "" && err != nil:
+					t.Fatalf("spend %d refused: %v", i, err)
+				case s.refused != "" && (err == nil || !strings.Contains(err.msg, s.refused)):
+					t.Fatalf("spend %d = %v, want %q", i, err, s.refused)
+				case err != nil && err.status != http.StatusTooManyRequests:
+					t.Fatalf("spend %d status = %d", i, err.status)
+				}
+			}
+		})
+	}
+}
+
+func TestOrderSender(t *testing.T) {
+	if got := orderSender(&tenant{Name: "alice"}, "key"); got != "tenant:alice" {
+		t.Errorf("tenant sender = %q", got)
+	}
+	if got := orderSender(nil, ""); got != "" {
+		t.Errorf("anonymous sender = %q", got)
+	}
+	a, b := orderSender(nil, "key-a"), orderSender(nil, "key-b")
+	if !strings.HasPrefix(a, KEY_ALIAS_PREFIX) || a == b || strings.Contains(a, "key-a") {
+		t.Errorf("key senders = %q, %q", a, b)
+	}
+}
+
+func TestTPSLOrdersAreGated(t *testing.T) {
+	p := newTestProxy(t, "")
+	alice := p.srv.tenants.list[0]
+	send := func(path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set(TENANT_HEADER, "tok-a")
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+		return rec
+	}
+	tpsl := `{"instId":"BTC-USDT","marginMode":"cross","positionSide":"net","side":"sell","size":"1","slTriggerPrice":"40000","slOrderPrice":"-1"}`
+
+	// Outside trading hours
+	now := time.Now().UTC()
+	windows, err := parseTradingHours([]string{now.Add(2*time.Hour).Format("15:04") + "-" + now.Add(3*time.Hour).Format("15:04")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice.windows = windows
+	if rec := send("/api/v1/trade/order-tpsl", tpsl); rec.Code != http.StatusForbidden {
+		t.Errorf("TP/SL outside trading hours = %d %s, want 403", rec.Code, rec.Body)
+	}
+	alice.windows = nil
+
+	// Past the daily order cap
+	alice.DailyOrders = 1
+	if rec := send("/api/v1/trade/order", `{"instId":"BTC-USDT","marginMode":"cross","positionSide":"net","side":"buy","orderType":"market","size":"1"}`); rec.Code != http.StatusOK {
+		t.Fatalf("first order = %d %s", rec.Code, rec.Body)
+	}
+	if rec := send("/api/v1/trade/order-tpsl", strings.Replace(tpsl, "40000", "39000", 1)); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("TP/SL past the order cap = %d %s, want 429", rec.Code, rec.Body)
+	}
+}
+
+func TestOrdersSpendQuota(t *testing.T) {
+	p := newTestProxy(t, "")
+	alice := p.srv.tenants.list[0]
+	alice.DailyQuota = 2
+	used := func() int {
+		p.srv.quotas.mu.Lock()
+		defer p.srv.quotas.mu.Unlock()
+		return p.srv.quotas.used[alice.Name]
+	}
+
+	// A unified order counts once, for the request
+	req := httptest.NewRequest(http.MethodPost, "/unified/createOrder", strings.NewReader(`{"symbol":"BTC/USDT:USDT","type":"market","side":"buy","amount":1}`))
+	req.Header.Set(TENANT_HEADER, "tok-a")
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("createOrder = %d %s", rec.Code, rec.Body)
+	}
+	if got := used(); got != 1 {
+		t.Errorf("quota used after createOrder = %d, want 1", got)
+	}
+
+	// Orders placed by helpers and webhooks count themselves
+	order := map[string]string{"instId": "ETH-USDT", "marginMode": "cross", "positionSide": "net", "side": "buy", "orderType": "market", "size": "1"}
+	var placed []blofinOrderResult
+	if err := p.srv.placeOrder(context.Background(), alice, order, &placed); err != nil {
+		t.Fatal(err)
+	}
+	if got := used(); got != 2 {
+		t.Errorf("quota used after placeOrder = %d, want 2", got)
+	}
+	order["side"] = "sell"
+	err := p.srv.placeOrder(context.Background(), alice, order, &placed)
+	if refusal, ok := err.(*orderRefusal); !ok || refusal.status != http.StatusTooManyRequests {
+		t.Errorf("order past the quota: err = %v, want a 429 refusal", err)
+	}
+}
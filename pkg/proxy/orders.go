@@ -0,0 +1,458 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Order placement routes understood by the order checks
+var orderRoutes = map[string]bool{
+	"/api/v1/trade/order":                   true,
+	"/api/v1/trade/batch-orders":            true,
+	"/api/v1/copytrading/trade/place-order": true,
+}
+
+// Routes that place orders of any kind. Besides orderRoutes these are
+// TP/SL orders and closes, which the duplicate guard, trading hours, the
+// loss limit and the daily caps check as well.
+var placementRoutes = map[string]bool{
+	"/api/v1/trade/order":                                  true,
+	"/api/v1/trade/batch-orders":                           true,
+	"/api/v1/copytrading/trade/place-order":                true,
+	"/api/v1/trade/order-tpsl":                             true,
+	"/api/v1/trade/close-position":                         true,
+	"/api/v1/copytrading/trade/close-position-by-order":    true,
+	"/api/v1/copytrading/trade/close-position-by-contract": true,
+}
+
+// Placement routes that can only close positions
+var closeRoutes = map[string]bool{
+	"/api/v1/trade/close-position":                         true,
+	"/api/v1/copytrading/trade/close-position-by-order":    true,
+	"/api/v1/copytrading/trade/close-position-by-contract": true,
+}
+
+var validOrderTypes = map[string]bool{
+	"market":    true,
+	"limit":     true,
+	"post_only": true,
+	"fok":       true,
+	"ioc":       true,
+}
+
+func isDryRun(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("X-Dry-Run"), "true")
+}
+
+// Parse the orders in a placement body: a single object, or an array for
+// batch routes
+func parseOrders(path string, body []byte) ([]map[string]interface{}, error) {
+	if path == "/api/v1/trade/batch-orders" {
+		var orders []map[string]interface{}
+		if err := json.Unmarshal(body, &orders); err != nil {
+			return nil, fmt.Errorf("body must be a JSON array of orders: %v", err)
+		}
+		return orders, nil
+	}
+	var order map[string]interface{}
+	if err := json.Unmarshal(body, &order); err != nil {
+		return nil, fmt.Errorf("body must be a JSON object: %v", err)
+	}
+	return []map[string]interface{}{order}, nil
+}
+
+// Check an order against its instrument's contract specification
+func checkOrder(prefix string, order map[string]interface{}, instruments *instrumentCache) []validationIssue {
+	var issues []validationIssue
+	fail := func(field, format string, args ...interface{}) {
+		issues = append(issues, validationIssue{Location: "body", Field: prefix + field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	instID, _ := order["instId"].(string)
+	if instID == "" {
+		fail("instId", "missing required field")
+		return issues
+	}
+	inst, ok, err := instruments.get(instID)
+	if err != nil {
+		fail("instId", "instrument specifications are unavailable: %v", err)
+		return issues
+	}
+	if !ok {
+		fail("instId", "unknown instrument %s", instID)
+		return issues
+	}
+	if inst.State != "" && inst.State != "live" {
+		fail("instId", "instrument %s is not live (state %s)", instID, inst.State)
+	}
+
+	if side, _ := order["side"].(string); side != "buy" && side != "sell" {
+		fail("side", "must be buy or sell")
+	}
+	orderType, _ := order["orderType"].(string)
+	if !validOrderTypes[orderType] {
+		fail("orderType", "must be one of market, limit, post_only, fok, ioc")
+	}
+	return append(issues, checkPrecision(prefix, order, inst)...)
+}
+
+// Check an order's size and price against the instrument's minimum, lot,
+// maximum and tick sizes
+func checkPrecision(prefix string, order map[string]interface{}, inst instrument) []validationIssue {
+	var issues []validationIssue
+	fail := func(field, format string, args ...interface{}) {
+		issues = append(issues, validationIssue{Location: "body", Field: prefix + field, Message: fmt.Sprintf(format, args...)})
+	}
+	orderType, _ := order["orderType"].(string)
+
+	size, ok := decimalField(order, "size")
+	switch {
+	case !ok:
+		fail("size", "must be a positive decimal string")
+	case size.Sign() <= 0:
+		fail("size", "must be greater than zero")
+	default:
+		if min, ok := parseDecimal(inst.MinSize); ok && size.Cmp(min) < 0 {
+			fail("size", "%s is below the minimum size %s", order["size"], inst.MinSize)
+		}
+		if lot, ok := parseDecimal(inst.LotSize); ok && !isMultiple(size, lot) {
+			fail("size", "%s is not a multiple of the lot size %s", order["size"], inst.LotSize)
+		}
+		maxSize := inst.MaxLimitSize
+		if orderType == "market" {
+			maxSize = inst.MaxMarketSize
+		}
+		if max, ok := parseDecimal(maxSize); ok && max.Sign() > 0 && size.Cmp(max) > 0 {
+			fail("size", "%s exceeds the maximum %s order size %s", order["size"], orderType, maxSize)
+		}
+	}
+
+	if orderType != "market" {
+		price, ok := decimalField(order, "price")
+		switch {
+		case !ok:
+			fail("price", "must be a positive decimal string for %s orders", orderType)
+		case price.Sign() <= 0:
+			fail("price", "must be greater than zero")
+		default:
+			if tick, ok := parseDecimal(inst.TickSize); ok && !isMultiple(price, tick) {
+				fail("price", "%s is not a multiple of the tick size %s", order["price"], inst.TickSize)
+			}
+		}
+	}
+	return issues
+}
+
+// Notional value of an order in quote currency, when its price is known
+func orderNotional(order map[string]interface{}, inst instrument) (*big.Rat, bool) {
+	size, ok := decimalField(order, "size")
+	if !ok {
+		return nil, false
+	}
+	price, ok := decimalField(order, "price")
+	if !ok {
+		return nil, false
+	}
+	contractValue, ok := parseDecimal(inst.ContractValue)
+	if !ok {
+		return nil, false
+	}
+	notional := new(big.Rat).Mul(size, price)
+	return notional.Mul(notional, contractValue), true
+}
+
+// Exact decimal parsing; BloFin sends and expects numbers as strings
+func parseDecimal(value string) (*big.Rat, bool) {
+	if value == "" {
+		return nil, false
+	}
+	return new(big.Rat).SetString(value)
+}
+
+func decimalField(order map[string]interface{}, field string) (*big.Rat, bool) {
+	value, ok := order[field].(string)
+	if !ok {
+		return nil, false
+	}
+	return parseDecimal(value)
+}
+
+func isMultiple(value, step *big.Rat) bool {
+	if step.Sign() == 0 {
+		return true
+	}
+	return new(big.Rat).Quo(value, step).IsInt()
+}
+
+// The largest multiple of step not above value (value and step positive)
+func floorToStep(value, step *big.Rat) *big.Rat {
+	q := new(big.Rat).Quo(value, step)
+	n := new(big.Int).Quo(q.Num(), q.Denom())
+	return new(big.Rat).Mul(new(big.Rat).SetInt(n), step)
+}
+
+// The multiple of step nearest to value, halves rounding up
+func roundToStep(value, step *big.Rat) *big.Rat {
+	half := new(big.Rat).Quo(step, big.NewRat(2, 1))
+	return floorToStep(new(big.Rat).Add(value, half), step)
+}
+
+// Decimal places of a step size such as "0.001", for formatting its
+// multiples the way BloFin does
+func stepDecimals(step string) int {
+	if i := strings.IndexByte(step, '.'); i >= 0 {
+		return len(step) - i - 1
+	}
+	return 0
+}
+
+var dryRunCounter atomic.Int64
+
+// Validate an order placement and answer with a synthesized BloFin success
+// response instead of forwarding it
+func (s *server) dryRunOrder(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MAX_INSPECT_BODY))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Failed to read request body"})
+		return
+	}
+
+	route := findRoute(r.Method, r.URL.Path)
+	issues := validateRequest(route, r.URL.Query(), body)
+	orders, err := parseOrders(r.URL.Path, body)
+	if err != nil {
+		issues = append(issues, validationIssue{Location: "body", Message: err.Error()})
+	}
+
+	results := []map[string]string{}
+	for i, order := range orders {
+		prefix := ""
+		if route.BodyArray {
+			prefix = fmt.Sprintf("[%d].", i)
+		}
+		issues = append(issues, checkOrder(prefix, order, s.instruments)...)
+		if instID, _ := order["instId"].(string); instID != "" {
+			if inst, ok, _ := s.instruments.get(instID); ok {
+				issues = append(issues, s.checkNotional(prefix, order, inst)...)
+			}
+		}
+
+		clientOrderID, _ := order["clientOrderId"].(string)
+		result := map[string]string{
+			"orderId":       "dryrun-" + strconv.FormatInt(time.Now().UnixMilli(), 10) + "-" + strconv.FormatInt(dryRunCounter.Add(1), 10),
+			"clientOrderId": clientOrderID,
+			"code":          "0",
+			"msg":           "Dry run: order was validated but not sent",
+		}
+		if instID, _ := order["instId"].(string); instID != "" {
+			if inst, ok, _ := s.instruments.get(instID); ok {
+				if notional, ok := orderNotional(order, inst); ok {
+					result["notional"] = notional.FloatString(8)
+				}
+			}
+		}
+		results = append(results, result)
+	}
+
+	w.Header().Set("X-Dry-Run", "true")
+	if len(issues) > 0 {
+		log.Printf("🧪 Dry run rejected %s (%d issues)", r.URL.Path, len(issues))
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"error":  "Dry run rejected the order",
+			"issues": issues,
+		})
+		return
+	}
+	log.Printf("🧪 Dry run accepted %d order(s) on %s", len(results), r.URL.Path)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"code": "0", "msg": "", "data": results})
+}
+
+// Order precision policies for live orders, see enforcePrecision
+const (
+	PRECISION_OFF    = "off"
+	PRECISION_REJECT = "reject"
+	PRECISION_ROUND  = "round"
+)
+
+// Whether live order placements are checked before they are forwarded
+func (s *server) checksOrders() bool {
+	return s.cfg.OrderPrecision != PRECISION_OFF || s.cfg.MinNotional != "" || s.cfg.MaxNotional != ""
+}
+
+// Check a live order placement against its instruments before it is
+// forwarded: sizes and prices per ORDER_PRECISION, and the notional value
+// against MIN_ORDER_NOTIONAL and MAX_ORDER_NOTIONAL. Problems are answered
+// with 422 and per-field issues instead of BloFin's generic parameter
+// error; false once answered. With PRECISION_ROUND, sizes are first
+// rounded down to the lot size and prices to the nearest tick, unless the
+// client signed the body itself. Orders for instruments without known
+// specs are left for BloFin to judge.
+func (s *server) checkLiveOrder(w http.ResponseWriter, r *http.Request) bool {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MAX_INSPECT_BODY))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Failed to read request body"})
+		return false
+	}
+	defer func() {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+	}()
+	orders, err := parseOrders(r.URL.Path, body)
+	if err != nil {
+		return true
+	}
+
+	round := s.cfg.OrderPrecision == PRECISION_ROUND && r.Header.Get("ACCESS-KEY") == ""
+	rounded, issues := s.checkOrders(r.URL.Path, orders, round)
+	if len(issues) > 0 {
+		log.Printf("📏 Order check failed: %s (%d issues)", r.URL.Path, len(issues))
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"error":  "Order does not fit the instrument's specification",
+			"issues": issues,
+		})
+		return false
+	}
+	if rounded {
+		var v interface{} = orders[0]
+		if r.URL.Path == "/api/v1/trade/batch-orders" {
+			v = orders
+		}
+		if encoded, err := json.Marshal(v); err == nil {
+			body = encoded
+		}
+	}
+	return true
+}
+
+// Check orders against their instruments' precision and the notional
+// limits, rounding them first if round; true if any was rounded
+func (s *server) checkOrders(path string, orders []map[string]interface{}, round bool) (bool, []validationIssue) {
+	rounded := false
+	var issues []validationIssue
+	for i, order := range orders {
+		instID, _ := order["instId"].(string)
+		inst, ok, err := s.instruments.get(instID)
+		if err != nil || !ok {
+			continue
+		}
+		if round && roundOrder(order, inst) {
+			rounded = true
+		}
+		prefix := ""
+		if path == "/api/v1/trade/batch-orders" {
+			prefix = fmt.Sprintf("[%d].", i)
+		}
+		if s.cfg.OrderPrecision != PRECISION_OFF {
+			issues = append(issues, checkPrecision(prefix, order, inst)...)
+		}
+		issues = append(issues, s.checkNotional(prefix, order, inst)...)
+	}
+	return rounded, issues
+}
+
+// The live order checks for an order the proxy places itself, refused
+// with 422 like checkLiveOrder answers a client's. The body comes back
+// rounded under ORDER_PRECISION=round.
+func (s *server) checkSentOrder(path string, body []byte) ([]byte, error) {
+	orders, err := parseOrders(path, body)
+	if err != nil {
+		return body, nil
+	}
+	rounded, issues := s.checkOrders(path, orders, s.cfg.OrderPrecision == PRECISION_ROUND)
+	if len(issues) > 0 {
+		log.Printf("📏 Order check failed: %s (%d issues)", path, len(issues))
+		messages := make([]string, len(issues))
+		for i, issue := range issues {
+			messages[i] = issue.Field + ": " + issue.Message
+		}
+		return nil, &orderRefusal{status: http.StatusUnprocessableEntity, msg: "Order does not fit the instrument's specification: " + strings.Join(messages, "; "), final: true}
+	}
+	if rounded {
+		var v interface{} = orders[0]
+		if path == "/api/v1/trade/batch-orders" {
+			v = orders
+		}
+		return json.Marshal(v)
+	}
+	return body, nil
+}
+
+// An order's limit price, or the mark price for market orders
+func (s *server) orderPrice(order map[string]interface{}, inst instrument) (*big.Rat, bool) {
+	price, ok := decimalField(order, "price")
+	if orderType, _ := order["orderType"].(string); orderType == "market" || !ok {
+		marks, _, err := s.marks.current()
+		if price, ok = parseDecimal(marks[inst.InstID].MarkPrice); err != nil || !ok {
+			return nil, false
+		}
+	}
+	return price, true
+}
+
+// Round the order's size down to the lot size and its price to the
+// nearest tick; true if either changed
+func roundOrder(order map[string]interface{}, inst instrument) bool {
+	changed := false
+	snap := func(field, step string, round func(value, step *big.Rat) *big.Rat) {
+		value, ok := decimalField(order, field)
+		unit, unitOK := parseDecimal(step)
+		if !ok || !unitOK || unit.Sign() <= 0 || value.Sign() <= 0 || isMultiple(value, unit) {
+			return
+		}
+		order[field] = round(value, unit).FloatString(stepDecimals(step))
+		changed = true
+	}
+	snap("size", inst.LotSize, floorToStep)
+	if orderType, _ := order["orderType"].(string); orderType != "market" {
+		snap("price", inst.TickSize, roundToStep)
+	}
+	return changed
+}
+
+// Check an order's value in quote currency against MIN_ORDER_NOTIONAL and
+// MAX_ORDER_NOTIONAL. Sizes are in contracts of contractValue base units
+// each, so a base or quote amount sent as the size shows up as a notional
+// far off one of the limits; the issue carries the numbers behind it.
+// Market orders are valued at the mark price.
+func (s *server) checkNotional(prefix string, order map[string]interface{}, inst instrument) []validationIssue {
+	min, hasMin := parseDecimal(s.cfg.MinNotional)
+	max, hasMax := parseDecimal(s.cfg.MaxNotional)
+	if !hasMin && !hasMax {
+		return nil
+	}
+	size, ok := decimalField(order, "size")
+	contractValue, cvOK := parseDecimal(inst.ContractValue)
+	if !ok || !cvOK {
+		return nil
+	}
+	price, ok := s.orderPrice(order, inst)
+	if !ok {
+		return nil
+	}
+	base := new(big.Rat).Mul(size, contractValue)
+	notional := new(big.Rat).Mul(base, price)
+	values := map[string]string{
+		"size":          size.FloatString(stepDecimals(inst.LotSize)),
+		"contractValue": inst.ContractValue,
+		"baseSize":      base.FloatString(8),
+		"price":         price.FloatString(stepDecimals(inst.TickSize)),
+		"notional":      notional.FloatString(8),
+	}
+	breakdown := fmt.Sprintf("%s contracts × %s %s × %s = %s %s", values["size"], inst.ContractValue, inst.BaseCurrency, values["price"], notional.FloatString(2), inst.QuoteCurrency)
+	switch {
+	case hasMin && notional.Cmp(min) < 0:
+		return []validationIssue{{Location: "body", Field: prefix + "size", Message: fmt.Sprintf("notional %s is below the minimum %s %s", breakdown, s.cfg.MinNotional, inst.QuoteCurrency), Values: values}}
+	case hasMax && notional.Cmp(max) > 0:
+		return []validationIssue{{Location: "body", Field: prefix + "size", Message: fmt.Sprintf("notional %s is above the maximum %s %s; size is a number of contracts of %s %s each, not an amount of %s", breakdown, s.cfg.MaxNotional, inst.QuoteCurrency, inst.ContractValue, inst.BaseCurrency, inst.QuoteCurrency), Values: values}}
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	ORDER_STREAM_BUFFER      = 64 // events queued per client before it is dropped
+	ORDER_STREAM_HISTORY_MAX = "20"
+)
+
+// Order event sent to /sse/orders clients
+type orderStreamEvent struct {
+	Type  string          `json:"type"` // order (new or changed state) or fill
+	Order json.RawMessage `json:"order"`
+	Ts    int64           `json:"ts"`
+}
+
+// Fields of an order that decide whether it changed
+type orderSnapshot struct {
+	OrderID    string `json:"orderId"`
+	State      string `json:"state"`
+	FilledSize string `json:"filledSize"`
+}
+
+// Streams each tenant's order updates to its SSE clients. BloFin's private
+// channel isn't available here, so one poller per tenant with at least one
+// client compares open and recently finished orders between polls and
+// fans the differences out to every client of that tenant.
+type orderStreams struct {
+	client   *blofinClient
+	interval time.Duration
+
+	mu      sync.Mutex
+	tenants map[string]*orderStream
+}
+
+type orderStream struct {
+	tenant  *tenant
+	clients map[chan orderStreamEvent]bool
+}
+
+func newOrderStreams(client *blofinClient, interval time.Duration) *orderStreams {
+	return &orderStreams{client: client, interval: interval, tenants: map[string]*orderStream{}}
+}
+
+// Add a client for t, starting the tenant's poller if it's the first
+func (s *orderStreams) join(t *tenant) chan orderStreamEvent {
+	ch := make(chan orderStreamEvent, ORDER_STREAM_BUFFER)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stream, ok := s.tenants[t.Name]
+	if !ok {
+		stream = &orderStream{tenant: t, clients: map[chan orderStreamEvent]bool{}}
+		s.tenants[t.Name] = stream
+		log.Printf("📡 Streaming orders for %s every %s", t.Name, s.interval)
+		go s.run(stream)
+	}
+	stream.clients[ch] = true
+	return ch
+}
+
+func (s *orderStreams) leave(t *tenant, ch chan orderStreamEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stream, ok := s.tenants[t.Name]; ok && stream.clients[ch] {
+		delete(stream.clients, ch)
+		close(ch)
+	}
+}
+
+// Poll until the stream's last client has gone
+func (s *orderStreams) run(stream *orderStream) {
+	var last map[string]orderSnapshot
+	for {
+		s.mu.Lock()
+		if len(stream.clients) == 0 {
+			delete(s.tenants, stream.tenant.Name)
+			s.mu.Unlock()
+			log.Printf("📡 Stopped streaming orders for %s", stream.tenant.Name)
+			return
+		}
+		s.mu.Unlock()
+
+		orders, err := s.fetch(stream.tenant)
+		if err != nil {
+			log.Printf("❌ Order stream poll for %s failed: %v", stream.tenant.Name, err)
+		} else {
+			current := make(map[string]orderSnapshot, len(orders))
+			var events []orderStreamEvent
+			now := time.Now().UnixMilli()
+			for _, raw := range orders {
+				var order orderSnapshot
+				if json.Unmarshal(raw, &order) != nil || order.OrderID == "" {
+					continue
+				}
+				current[order.OrderID] = order
+				// The first poll is only the baseline
+				if last == nil {
+					continue
+				}
+				prev, seen := last[order.OrderID]
+				if !seen || prev.State != order.State {
+					events = append(events, orderStreamEvent{Type: "order", Order: raw, Ts: now})
+				}
+				if order.FilledSize != prev.FilledSize && !isZeroDecimal(order.FilledSize) {
+					events = append(events, orderStreamEvent{Type: "fill", Order: raw, Ts: now})
+				}
+			}
+			last = current
+			if len(events) > 0 {
+				s.broadcast(stream, events)
+			}
+		}
+		time.Sleep(s.interval)
+	}
+}
+
+// Open orders and the most recently finished ones
+func (s *orderStreams) fetch(t *tenant) ([]json.RawMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var pending, history []json.RawMessage
+	if err := s.client.do(ctx, http.MethodGet, "/api/v1/trade/orders-pending", nil, nil, t, &pending); err != nil {
+		return nil, err
+	}
+	query := url.Values{"limit": {ORDER_STREAM_HISTORY_MAX}}
+	if err := s.client.do(ctx, http.MethodGet, "/api/v1/trade/orders-history", query, nil, t, &history); err != nil {
+		return nil, err
+	}
+	return append(pending, history...), nil
+}
+
+// Queue events for every client of stream, dropping clients too slow to
+// keep up rather than blocking the others
+func (s *orderStreams) broadcast(stream *orderStream, events []orderStreamEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range stream.clients {
+		if !queueEvents(ch, events) {
+			log.Printf("⚠️ Dropping a slow order stream client of %s", stream.tenant.Name)
+			delete(stream.clients, ch)
+			close(ch)
+		}
+	}
+}
+
+// false when ch is full
+func queueEvents(ch chan orderStreamEvent, events []orderStreamEvent) bool {
+	for _, event := range events {
+		select {
+		case ch <- event:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func isZeroDecimal(value string) bool {
+	d, ok := parseDecimal(value)
+	return !ok || d.Sign() == 0
+}
+
+// GET /sse/orders streams the tenant's order and fill events as
+// Server-Sent Events for consumers that can't hold a WebSocket
+func (s *orderStreams) handle(w http.ResponseWriter, r *http.Request, t *tenant) {
+	flusher, ok := startSSE(w)
+	if !ok {
+		return
+	}
+	writeSSE(w, "ready", map[string]string{"tenant": t.Name})
+	flusher.Flush()
+
+	ch := s.join(t)
+	defer s.leave(t, ch)
+	heartbeat := time.NewTicker(SSE_HEARTBEAT)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case event, open := <-ch:
+			if !open {
+				return
+			}
+			writeSSE(w, event.Type, event)
+		}
+		flusher.Flush()
+	}
+}
+
+// Resolve the tenant for /sse/orders, which only serves the proxy's own
+// tenants
+func (s *server) handleOrderStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, "GET")
+		return
+	}
+	t, err := s.tenants.fromRequest(r)
+	if err != nil || t == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Order streams need the " + TENANT_HEADER + " of a configured tenant"})
+		return
+	}
+	s.streams.handle(w, r, t)
+}
@@ -0,0 +1,400 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	DEFAULT_ORDER_WATCH_INTERVAL = 2 * time.Second
+	ORDER_WATCH_TTL              = 24 * time.Hour
+	MAX_ORDER_WATCHES            = 100 // per tenant
+	ORDER_CALLBACK_ATTEMPTS      = 3
+)
+
+// Order states after which nothing more will happen to an order
+var terminalOrderStates = map[string]bool{
+	"filled":             true,
+	"canceled":           true,
+	"partially_canceled": true,
+}
+
+// An order being tracked for a client, and where to POST its final state
+type orderWatch struct {
+	ID            string `json:"id"`
+	Tenant        string `json:"tenant"`
+	InstID        string `json:"instId"`
+	OrderID       string `json:"orderId,omitempty"`
+	ClientOrderID string `json:"clientOrderId,omitempty"`
+	Callback      string `json:"callback"`
+	State         string `json:"state,omitempty"` // last state seen
+	CreatedAt     string `json:"createdAt"`
+	ExpiresAt     string `json:"expiresAt"`
+}
+
+// Payload POSTed to a watch's callback
+type orderWatchEvent struct {
+	ID            string          `json:"id"`
+	InstID        string          `json:"instId"`
+	OrderID       string          `json:"orderId,omitempty"`
+	ClientOrderID string          `json:"clientOrderId,omitempty"`
+	State         string          `json:"state"`
+	Order         json.RawMessage `json:"order"`
+	Ts            int64           `json:"ts"`
+}
+
+// Polls the orders clients have registered and POSTs each one's terminal
+// state (filled or canceled) to its callback once, so serverless frontends
+// don't have to keep their own polling loop alive. Watches are dropped
+// once delivered or after a day, and are kept in DATA_DIR/order-watches.json
+// so a restart doesn't lose them. Callbacks are signed with the tenant's
+// proxy token and, unless allowPrivate, may only reach public addresses.
+type orderWatcher struct {
+	client       *blofinClient
+	tenants      *tenantRegistry
+	interval     time.Duration
+	http         *http.Client
+	file         string
+	allowPrivate bool
+
+	once    sync.Once
+	mu      sync.Mutex
+	watches map[string]*orderWatch
+}
+
+func newOrderWatcher(client *blofinClient, tenants *tenantRegistry, interval time.Duration, dataDir string, allowPrivate bool) (*orderWatcher, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("poll interval must be positive")
+	}
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !allowPrivate {
+		// Checked on the resolved address, so a public name pointing at a
+		// private one is refused too
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, _ := net.SplitHostPort(address)
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return fmt.Errorf("callback address %s is not public", host)
+			}
+			return nil
+		}
+	}
+	w := &orderWatcher{
+		client:   client,
+		tenants:  tenants,
+		interval: interval,
+		http: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 5 * time.Second},
+		},
+		file:         filepath.Join(dataDir, "order-watches.json"),
+		allowPrivate: allowPrivate,
+		watches:      map[string]*orderWatch{},
+	}
+	data, err := os.ReadFile(w.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return w, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var watches []*orderWatch
+	if err := json.Unmarshal(data, &watches); err != nil {
+		return nil, fmt.Errorf("%s: %v", w.file, err)
+	}
+	for _, watch := range watches {
+		w.watches[watch.ID] = watch
+	}
+	if len(w.watches) > 0 {
+		w.start()
+	}
+	return w, nil
+}
+
+// Start polling; called once there is an order to watch
+func (w *orderWatcher) start() {
+	w.once.Do(func() {
+		log.Printf("👀 Watching orders every %s", w.interval)
+		go func() {
+			for {
+				w.poll()
+				time.Sleep(w.interval)
+			}
+		}()
+	})
+}
+
+func (w *orderWatcher) poll() {
+	w.mu.Lock()
+	pending := make([]orderWatch, 0, len(w.watches))
+	for _, watch := range w.watches {
+		pending = append(pending, *watch)
+	}
+	w.mu.Unlock()
+
+	now := time.Now()
+	for _, watch := range pending {
+		if expires, err := time.Parse(time.RFC3339, watch.ExpiresAt); err == nil && now.After(expires) {
+			log.Printf("👀 Order watch %s expired before %s finished", watch.ID, watch.orderRef())
+			w.remove(watch.ID)
+			continue
+		}
+		t := w.tenants.named(watch.Tenant)
+		if t == nil {
+			log.Printf("⚠️ Dropping order watch %s: tenant %s is no longer configured", watch.ID, watch.Tenant)
+			w.remove(watch.ID)
+			continue
+		}
+		state, order, err := w.fetch(t, watch)
+		if err != nil {
+			log.Printf("❌ Order watch %s poll failed: %v", watch.ID, err)
+			continue
+		}
+		if state == "" || state == watch.State {
+			continue
+		}
+		if !terminalOrderStates[state] {
+			w.mu.Lock()
+			if current, ok := w.watches[watch.ID]; ok {
+				current.State = state
+				w.saveLocked()
+			}
+			w.mu.Unlock()
+			continue
+		}
+		log.Printf("👀 Order %s is %s, calling back", watch.orderRef(), state)
+		// Delivered at most once, and without holding up other watches
+		w.remove(watch.ID)
+		go w.notify(t, watch, orderWatchEvent{
+			ID:            watch.ID,
+			InstID:        watch.InstID,
+			OrderID:       watch.OrderID,
+			ClientOrderID: watch.ClientOrderID,
+			State:         state,
+			Order:         order,
+			Ts:            time.Now().UnixMilli(),
+		})
+	}
+}
+
+// Current state of a watched order and its full details
+func (w *orderWatcher) fetch(t *tenant, watch orderWatch) (string, json.RawMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	query := url.Values{"instId": {watch.InstID}}
+	if watch.OrderID != "" {
+		query.Set("orderId", watch.OrderID)
+	} else {
+		query.Set("clientOrderId", watch.ClientOrderID)
+	}
+	var raw json.RawMessage
+	if err := w.client.do(ctx, http.MethodGet, "/api/v1/trade/order-detail", query, nil, t, &raw); err != nil {
+		return "", nil, err
+	}
+	// The detail comes back as an object or as a one-element list
+	order := raw
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+		var list []json.RawMessage
+		if err := json.Unmarshal(trimmed, &list); err != nil {
+			return "", nil, err
+		}
+		if len(list) == 0 {
+			return "", nil, nil
+		}
+		order = list[0]
+	}
+	var detail struct {
+		State string `json:"state"`
+	}
+	if err := json.Unmarshal(order, &detail); err != nil {
+		return "", nil, err
+	}
+	return detail.State, order, nil
+}
+
+// POST event to the watch's callback, signed like incoming webhooks:
+// X-Webhook-Signature is the HMAC-SHA256 of "<timestamp>.<body>" under the
+// tenant's proxy token
+func (w *orderWatcher) notify(t *tenant, watch orderWatch, event orderWatchEvent) {
+	body, _ := json.Marshal(event)
+	for attempt := 1; attempt <= ORDER_CALLBACK_ATTEMPTS; attempt++ {
+		req, err := http.NewRequest(http.MethodPost, watch.Callback, bytes.NewReader(body))
+		if err != nil {
+			log.Printf("❌ Order watch %s callback failed: %v", watch.ID, err)
+			return
+		}
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(WEBHOOK_TIMESTAMP_HEADER, timestamp)
+		req.Header.Set(WEBHOOK_SIGNATURE_HEADER, "sha256="+webhookSignature(t.Token, timestamp, body))
+		resp, err := w.http.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 300 {
+				return
+			}
+			err = fmt.Errorf("HTTP %d", resp.StatusCode)
+		}
+		log.Printf("❌ Order watch %s callback failed (attempt %d/%d): %v", watch.ID, attempt, ORDER_CALLBACK_ATTEMPTS, err)
+		if attempt < ORDER_CALLBACK_ATTEMPTS {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+	}
+}
+
+func (w *orderWatcher) remove(id string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.watches[id]; !ok {
+		return false
+	}
+	delete(w.watches, id)
+	w.saveLocked()
+	return true
+}
+
+func (w *orderWatcher) saveLocked() {
+	watches := make([]*orderWatch, 0, len(w.watches))
+	for _, watch := range w.watches {
+		watches = append(watches, watch)
+	}
+	data, err := json.MarshalIndent(watches, "", "  ")
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(w.file), 0o755); err == nil {
+			tmp := w.file + ".tmp"
+			if err = os.WriteFile(tmp, data, 0o600); err == nil {
+				err = os.Rename(tmp, w.file)
+			}
+		}
+	}
+	if err != nil {
+		log.Printf("❌ Failed to save order watches: %v", err)
+	}
+}
+
+func (watch orderWatch) orderRef() string {
+	if watch.OrderID != "" {
+		return watch.InstID + " " + watch.OrderID
+	}
+	return watch.InstID + " " + watch.ClientOrderID
+}
+
+// GET/POST /orders/watch lists or registers the tenant's watches; GET/DELETE
+// /orders/watch/{id} reads or removes one. Watches belong to the tenant
+// whose X-Proxy-Token registered them.
+func (w *orderWatcher) handle(rw http.ResponseWriter, r *http.Request) {
+	t, err := w.tenants.fromRequest(r)
+	if err != nil || t == nil {
+		writeJSON(rw, http.StatusUnauthorized, map[string]string{"error": "Order watches need the " + TENANT_HEADER + " of a configured tenant"})
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/orders/watch"), "/")
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if id == "" {
+		switch r.Method {
+		case http.MethodGet:
+			watches := []orderWatch{}
+			for _, watch := range w.watches {
+				if watch.Tenant == t.Name {
+					watches = append(watches, *watch)
+				}
+			}
+			sort.Slice(watches, func(i, j int) bool { return watches[i].CreatedAt < watches[j].CreatedAt })
+			writeJSON(rw, http.StatusOK, map[string]interface{}{"watches": watches})
+		case http.MethodPost:
+			var watch orderWatch
+			if err := json.NewDecoder(http.MaxBytesReader(rw, r.Body, MAX_INSPECT_BODY)).Decode(&watch); err != nil {
+				writeJSON(rw, http.StatusBadRequest, map[string]string{"error": "Invalid JSON: " + err.Error()})
+				return
+			}
+			if err := watch.validate(w.allowPrivate); err != nil {
+				writeJSON(rw, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			count := 0
+			for _, existing := range w.watches {
+				if existing.Tenant == t.Name {
+					count++
+				}
+			}
+			if count >= MAX_ORDER_WATCHES {
+				writeJSON(rw, http.StatusTooManyRequests, map[string]string{"error": fmt.Sprintf("At most %d orders can be watched at once", MAX_ORDER_WATCHES)})
+				return
+			}
+			now := time.Now().UTC()
+			watch.ID = newJobID()
+			watch.Tenant = t.Name
+			watch.State = ""
+			watch.CreatedAt = now.Format(time.RFC3339)
+			watch.ExpiresAt = now.Add(ORDER_WATCH_TTL).Format(time.RFC3339)
+			w.watches[watch.ID] = &watch
+			w.saveLocked()
+			w.start()
+			log.Printf("👀 Order watch %s added for %s: %s", watch.ID, t.Name, watch.orderRef())
+			writeJSON(rw, http.StatusCreated, watch)
+		default:
+			methodNotAllowed(rw, "GET", "POST")
+		}
+		return
+	}
+
+	watch, ok := w.watches[id]
+	if !ok || watch.Tenant != t.Name {
+		writeJSON(rw, http.StatusNotFound, map[string]string{"error": "Unknown order watch"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(rw, http.StatusOK, watch)
+	case http.MethodDelete:
+		delete(w.watches, id)
+		w.saveLocked()
+		log.Printf("👀 Order watch %s removed", id)
+		rw.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(rw, "GET", "DELETE")
+	}
+}
+
+func (watch *orderWatch) validate(allowPrivate bool) error {
+	if watch.InstID == "" {
+		return fmt.Errorf("instId is required")
+	}
+	if (watch.OrderID == "") == (watch.ClientOrderID == "") {
+		return fmt.Errorf("exactly one of orderId or clientOrderId is required")
+	}
+	u, err := url.Parse(watch.Callback)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("callback must be an http(s) URL")
+	}
+	if !allowPrivate {
+		host := strings.ToLower(u.Hostname())
+		if ip := net.ParseIP(host); (ip != nil && !publicIP(ip)) || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+			return fmt.Errorf("callback must be a public address (see ORDER_CALLBACK_ALLOW_PRIVATE)")
+		}
+	}
+	return nil
+}
+
+// Whether ip is reachable from the internet: not loopback, private,
+// link-local (cloud metadata lives there) or otherwise reserved
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsUnspecified() && !ip.IsMulticast() && !ip.IsInterfaceLocalMulticast()
+}
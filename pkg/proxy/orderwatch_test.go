@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOrderWatchValidate(t *testing.T) {
+	tests := []struct {
+		callback     string
+		allowPrivate bool
+		ok           bool
+	}{
+		{"https://example.com/hooks/order", false, true},
+		{"http://203.0.113.10:8080/order", false, true},
+		{"ftp://example.com/order", false, false},
+		{"http://localhost:3000/order", false, false},
+		{"http://api.localhost/order", false, false},
+		{"http://127.0.0.1/order", false, false},
+		{"http://10.1.2.3/order", false, false},
+		{"http://192.168.1.1/order", false, false},
+		{"http://169.254.169.254/latest/meta-data", false, false},
+		{"http://[::1]:8080/order", false, false},
+		{"http://[fd00::1]/order", false, false},
+		{"http://0.0.0.0/order", false, false},
+		{"http://localhost:3000/order", true, true},
+		{"http://10.1.2.3/order", true, true},
+	}
+	for _, tt := range tests {
+		watch := orderWatch{InstID: "BTC-USDT", OrderID: "1", Callback: tt.callback}
+		if err := watch.validate(tt.allowPrivate); (err == nil) != tt.ok {
+			t.Errorf("validate(%s, private %v) = %v, want ok %v", tt.callback, tt.allowPrivate, err, tt.ok)
+		}
+	}
+	for _, watch := range []orderWatch{
+		{OrderID: "1", Callback: "https://example.com"},
+		{InstID: "BTC-USDT", Callback: "https://example.com"},
+		{InstID: "BTC-USDT", OrderID: "1", ClientOrderID: "a", Callback: "https://example.com"},
+	} {
+		if watch.validate(false) == nil {
+			t.Errorf("validate(%+v) accepted", watch)
+		}
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	for addr, public := range map[string]bool{
+		"8.8.8.8":         true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"172.16.0.1":      false,
+		"169.254.169.254": false,
+		"::ffff:10.0.0.1": false,
+		"fe80::1":         false,
+		"224.0.0.1":       false,
+	} {
+		if got := publicIP(net.ParseIP(addr)); got != public {
+			t.Errorf("publicIP(%s) = %v, want %v", addr, got, public)
+		}
+	}
+}
+
+func TestOrderWatchCallback(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer receiver.Close()
+
+	alice := &tenant{Name: "alice", Token: "tok-a"}
+	watch := orderWatch{ID: "w1", InstID: "BTC-USDT", OrderID: "1", Callback: receiver.URL}
+	event := orderWatchEvent{ID: "w1", InstID: "BTC-USDT", OrderID: "1", State: "filled", Order: json.RawMessage(`{}`)}
+
+	// The receiver is on loopback, so it is only reached when allowed
+	guarded, err := newOrderWatcher(nil, &tenantRegistry{}, time.Second, t.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	guarded.http.Timeout = time.Second
+	guarded.notify(alice, orderWatch{ID: "w0", Callback: receiver.URL}, event)
+	select {
+	case <-received:
+		t.Fatal("callback reached a loopback address")
+	default:
+	}
+
+	w, err := newOrderWatcher(nil, &tenantRegistry{}, time.Second, t.TempDir(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.notify(alice, watch, event)
+	r, body := <-received, <-bodies
+	g := newReplayGuard(time.Minute)
+	if i, status, msg := g.verify(r, body, []string{"tok-a"}); i != 0 {
+		t.Fatalf("callback signature refused: %d %s", status, msg)
+	}
+	if !strings.HasPrefix(r.Header.Get(WEBHOOK_SIGNATURE_HEADER), "sha256=") || r.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("callback headers = %v", r.Header)
+	}
+	var got orderWatchEvent
+	if err := json.Unmarshal(body, &got); err != nil || got.State != "filled" || got.ID != "w1" {
+		t.Errorf("callback body = %s (%v)", body, err)
+	}
+}
@@ -0,0 +1,1119 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_PAPER_BALANCE  = "10000"
+	DEFAULT_PAPER_LEVERAGE = "3"
+	PAPER_TAKER_FEE        = "0.0006"
+	PAPER_MAKER_FEE        = "0.0002"
+	MAX_PAPER_HISTORY      = 1000 // finished orders, TP/SL orders and fills kept per tenant
+	PAPER_PAGE_SIZE        = 20
+	PAPER_BOOK_DEPTH       = 100 // order book levels per side taker orders can fill against
+)
+
+// A paper tenant's simulated futures account, in net position mode with
+// cross margin. Orders, TP/SL orders and fills are kept in BloFin's
+// response shape so they are served as they are.
+type paperAccount struct {
+	Balance     string                    `json:"balance"` // USDT: the starting balance plus realized PnL, less fees
+	Leverage    map[string]string         `json:"leverage,omitempty"`
+	Positions   map[string]*paperPosition `json:"positions,omitempty"`
+	Orders      []map[string]string       `json:"orders,omitempty"` // resting, oldest first
+	TPSL        []map[string]string       `json:"tpsl,omitempty"`
+	History     []map[string]string       `json:"history,omitempty"` // finished, oldest first
+	TPSLHistory []map[string]string       `json:"tpslHistory,omitempty"`
+	Fills       []map[string]string       `json:"fills,omitempty"` // oldest first
+}
+
+type paperPosition struct {
+	Positions    string `json:"positions"` // contracts, negative when short
+	AveragePrice string `json:"averagePrice"`
+	CreateTime   int64  `json:"createTime"`
+	UpdateTime   int64  `json:"updateTime"`
+}
+
+// DATA_DIR/paper.json
+type paperState struct {
+	NextID   int64                    `json:"nextId"`
+	Accounts map[string]*paperAccount `json:"accounts"`
+}
+
+// An instrument's order book as /api/v1/market/books sends it: rows of
+// [price, size in contracts, ...] per side, best first
+type orderBook struct {
+	Asks [][]string `json:"asks"`
+	Bids [][]string `json:"bids"`
+}
+
+// One call on a paper account
+type paperRequest struct {
+	query  url.Values
+	body   []byte
+	prices map[string]ticker
+	books  map[string]*orderBook // by instId; missing books fill at the touch
+	now    time.Time
+}
+
+type paperHandler func(d *paperDesk, a *paperAccount, req paperRequest) (interface{}, error)
+
+// Private routes a paper account answers. Paper tenants get every other
+// private route refused, so nothing they send reaches the real account.
+var paperHandlers = map[string]paperHandler{
+	"GET /api/v1/asset/balances":              func(*paperDesk, *paperAccount, paperRequest) (interface{}, error) { return []interface{}{}, nil },
+	"GET /api/v1/account/balance":             (*paperDesk).balance,
+	"GET /api/v1/account/positions":           (*paperDesk).positions,
+	"GET /api/v1/account/margin-mode":         (*paperDesk).marginMode,
+	"GET /api/v1/account/position-mode":       (*paperDesk).positionMode,
+	"POST /api/v1/account/set-margin-mode":    (*paperDesk).setMarginMode,
+	"POST /api/v1/account/set-position-mode":  (*paperDesk).setPositionMode,
+	"GET /api/v1/account/batch-leverage-info": (*paperDesk).leverageInfo,
+	"POST /api/v1/account/set-leverage":       (*paperDesk).setLeverage,
+	"POST /api/v1/trade/order":                (*paperDesk).placeOrder,
+	"POST /api/v1/trade/batch-orders":         (*paperDesk).placeBatchOrders,
+	"POST /api/v1/trade/cancel-order":         (*paperDesk).cancelOrder,
+	"POST /api/v1/trade/cancel-batch-orders":  (*paperDesk).cancelBatchOrders,
+	"POST /api/v1/trade/close-position":       (*paperDesk).closePosition,
+	"POST /api/v1/trade/order-tpsl":           (*paperDesk).placeTPSL,
+	"POST /api/v1/trade/cancel-tpsl":          (*paperDesk).cancelTPSL,
+	"GET /api/v1/trade/orders-pending":        (*paperDesk).ordersPending,
+	"GET /api/v1/trade/orders-tpsl-pending":   (*paperDesk).tpslPending,
+	"GET /api/v1/trade/order-detail":          (*paperDesk).orderDetail,
+	"GET /api/v1/trade/orders-history":        (*paperDesk).ordersHistory,
+	"GET /api/v1/trade/orders-tpsl-history":   (*paperDesk).tpslHistory,
+	"GET /api/v1/trade/fills-history":         (*paperDesk).fillsHistory,
+
+	"GET /api/v1/copytrading/account/balance":               (*paperDesk).balance,
+	"GET /api/v1/copytrading/account/positions-by-contract": (*paperDesk).positions,
+	"POST /api/v1/copytrading/trade/place-order":            (*paperDesk).placeOrder,
+	"POST /api/v1/copytrading/trade/cancel-order":           (*paperDesk).cancelOrder,
+	"GET /api/v1/copytrading/trade/orders-pending":          (*paperDesk).ordersPending,
+	"GET /api/v1/copytrading/trade/orders-history":          (*paperDesk).ordersHistory,
+}
+
+// Simulated trading for tenants with "paper": true. Their orders are
+// matched against the ticker feed and order book instead of being sent to
+// BloFin: market orders and marketable limit orders take the book's depth
+// level by level, so large orders pay slippage and may fill in part,
+// resting limit orders fill at their price once the last price crosses
+// it, and TP/SL orders close the position at market when triggered. The
+// accounts are kept in DATA_DIR/paper.json.
+type paperDesk struct {
+	prices   func() (map[string]ticker, error)
+	books    func(instID string) (*orderBook, error) // nil fills at the touch
+	contract func(instID string) (instrument, bool, error)
+	start    map[string]string // starting balance by tenant
+	file     string
+
+	mu    sync.Mutex
+	state paperState
+}
+
+// Nil when no tenant trades on paper
+func newPaperDesk(prices func() (map[string]ticker, error), books func(string) (*orderBook, error), contract func(string) (instrument, bool, error), tenants []*tenant, dataDir string) (*paperDesk, error) {
+	d := &paperDesk{
+		prices:   prices,
+		books:    books,
+		contract: contract,
+		start:    map[string]string{},
+		file:     filepath.Join(dataDir, "paper.json"),
+		state:    paperState{NextID: 1, Accounts: map[string]*paperAccount{}},
+	}
+	for _, t := range tenants {
+		if t.Paper {
+			d.start[t.Name] = t.PaperBalance
+			if d.start[t.Name] == "" {
+				d.start[t.Name] = DEFAULT_PAPER_BALANCE
+			}
+		}
+	}
+	if len(d.start) == 0 {
+		return nil, nil
+	}
+	data, err := os.ReadFile(d.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &d.state); err != nil {
+		return nil, fmt.Errorf("%s: %v", d.file, err)
+	}
+	if d.state.Accounts == nil {
+		d.state.Accounts = map[string]*paperAccount{}
+	}
+	return d, nil
+}
+
+// Whether a request from t is answered by its paper account: every private
+// route is, once t trades on paper
+func (d *paperDesk) serves(t *tenant, method, path string) bool {
+	if d == nil || t == nil || !t.Paper {
+		return false
+	}
+	route := findRoute(method, path)
+	return route != nil && route.Private
+}
+
+// Answer a paper tenant's request in BloFin's response shape
+func (d *paperDesk) serve(w http.ResponseWriter, t *tenant, method, path string, query url.Values, body []byte) {
+	data, err := d.call(t, method, path, query, body)
+	var refused *blofinError
+	switch {
+	case errors.As(err, &refused):
+		writeJSON(w, http.StatusOK, map[string]interface{}{"code": refused.Code, "msg": refused.Msg, "data": data})
+	case err != nil:
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+	default:
+		if method == http.MethodPost {
+			log.Printf("📝 Paper %s for %s", path, t.Name)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"code": "0", "msg": "success", "data": data})
+	}
+}
+
+// Run a call on t's paper account. Calls BloFin would refuse return the
+// data along with a *blofinError carrying the code and message.
+func (d *paperDesk) call(t *tenant, method, path string, query url.Values, body []byte) (interface{}, error) {
+	handler, ok := paperHandlers[method+" "+path]
+	if !ok {
+		return nil, fmt.Errorf("%s %s is not available in paper trading", method, path)
+	}
+	// Fetched before locking, since a stale snapshot is polled again
+	prices, err := d.prices()
+	if err != nil {
+		log.Printf("❌ Paper trading has no market data: %v", err)
+	}
+	var books map[string]*orderBook
+	if method == http.MethodPost {
+		books = d.fetchBooks(bodyInstIDs(body))
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, err := handler(d, d.account(t.Name), paperRequest{query: query, body: body, prices: prices, books: books, now: time.Now()})
+	if method == http.MethodPost {
+		d.saveLocked()
+	}
+	return data, err
+}
+
+// Current order books for instIDs; instruments whose book can't be
+// fetched are left out and fill at the touch
+func (d *paperDesk) fetchBooks(instIDs []string) map[string]*orderBook {
+	books := map[string]*orderBook{}
+	if d.books == nil {
+		return books
+	}
+	for _, instID := range instIDs {
+		instID = normalizeInstID(instID)
+		if _, ok := books[instID]; ok {
+			continue
+		}
+		book, err := d.books(instID)
+		if err != nil {
+			log.Printf("❌ Paper trading has no order book for %s, filling at the touch: %v", instID, err)
+			continue
+		}
+		books[instID] = book
+	}
+	return books
+}
+
+// Top levels of instID's order book from BloFin
+func (c *blofinClient) orderBook(instID string) (*orderBook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var books []*orderBook
+	query := url.Values{"instId": {instID}, "size": {strconv.Itoa(PAPER_BOOK_DEPTH)}}
+	if err := c.get(ctx, "/api/v1/market/books", query, &books); err != nil {
+		return nil, err
+	}
+	if len(books) == 0 || books[0] == nil {
+		return nil, fmt.Errorf("empty order book")
+	}
+	return books[0], nil
+}
+
+func (d *paperDesk) account(name string) *paperAccount {
+	a := d.state.Accounts[name]
+	if a == nil {
+		a = &paperAccount{Balance: d.start[name]}
+		d.state.Accounts[name] = a
+	}
+	if a.Leverage == nil {
+		a.Leverage = map[string]string{}
+	}
+	if a.Positions == nil {
+		a.Positions = map[string]*paperPosition{}
+	}
+	return a
+}
+
+func (d *paperDesk) nextID() string {
+	id := d.state.NextID
+	d.state.NextID++
+	return strconv.FormatInt(id, 10)
+}
+
+// Fill resting orders the last price has crossed and fire triggered TP/SL
+// orders; subscribed to the ticker feed
+func (d *paperDesk) match(prices map[string]ticker) {
+	books := d.fetchBooks(d.triggered(prices))
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	changed := false
+	for name, a := range d.state.Accounts {
+		if _, ok := d.start[name]; !ok {
+			continue
+		}
+		resting := a.Orders[:0:0]
+		for _, order := range a.Orders {
+			last, ok := parseDecimal(prices[order["instId"]].Last)
+			price, _ := parseDecimal(order["price"])
+			if !ok || price == nil || (order["side"] == "buy" && last.Cmp(price) > 0) || (order["side"] == "sell" && last.Cmp(price) < 0) {
+				resting = append(resting, order)
+				continue
+			}
+			changed = true
+			if err := d.fill(a, order, paperRemaining(order), price, PAPER_MAKER_FEE, now); err != nil {
+				d.finish(a, order, "canceled", now)
+				log.Printf("📝 Paper order %s for %s canceled: %v", order["orderId"], name, err)
+				continue
+			}
+			log.Printf("📝 Paper order %s for %s filled at %s", order["orderId"], name, order["price"])
+		}
+		a.Orders = resting
+
+		live := a.TPSL[:0:0]
+		for _, tpsl := range a.TPSL {
+			if !tpslTriggered(tpsl, prices[tpsl["instId"]]) {
+				live = append(live, tpsl)
+				continue
+			}
+			changed = true
+			order := map[string]interface{}{"instId": tpsl["instId"], "side": tpsl["side"], "orderType": "market", "size": tpsl["size"], "reduceOnly": "true"}
+			if tpsl["size"] == "-1" || tpsl["size"] == "" {
+				if p := a.Positions[tpsl["instId"]]; p != nil {
+					order["size"] = strings.TrimPrefix(p.Positions, "-")
+				}
+			}
+			tpsl["state"] = "effective"
+			if _, err := d.place(a, order, paperRequest{prices: prices, books: books, now: now}); err != nil {
+				tpsl["state"] = "failed"
+				log.Printf("📝 Paper TP/SL %s for %s failed: %v", tpsl["tpslId"], name, err)
+			}
+			tpsl["updateTime"] = millis(now)
+			a.TPSLHistory = appendCapped(a.TPSLHistory, tpsl)
+		}
+		a.TPSL = live
+	}
+	if changed {
+		d.saveLocked()
+	}
+}
+
+// Instruments with a TP/SL order the last price has triggered, so their
+// books are fetched before matching
+func (d *paperDesk) triggered(prices map[string]ticker) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var instIDs []string
+	for _, a := range d.state.Accounts {
+		for _, tpsl := range a.TPSL {
+			if tpslTriggered(tpsl, prices[tpsl["instId"]]) {
+				instIDs = append(instIDs, tpsl["instId"])
+			}
+		}
+	}
+	return instIDs
+}
+
+// Whether the last price reached a TP/SL order's take-profit or stop-loss
+func tpslTriggered(tpsl map[string]string, tick ticker) bool {
+	last, ok := parseDecimal(tick.Last)
+	if !ok {
+		return false
+	}
+	// Selling closes a long: take profit above, stop loss below
+	direction := 1
+	if tpsl["side"] == "buy" {
+		direction = -1
+	}
+	if tp, ok := parseDecimal(tpsl["tpTriggerPrice"]); ok && last.Cmp(tp)*direction >= 0 {
+		return true
+	}
+	if sl, ok := parseDecimal(tpsl["slTriggerPrice"]); ok && last.Cmp(sl)*direction <= 0 {
+		return true
+	}
+	return false
+}
+
+func appendCapped(list []map[string]string, item map[string]string) []map[string]string {
+	list = append(list, item)
+	if len(list) > MAX_PAPER_HISTORY {
+		list = append(list[:0:0], list[len(list)-MAX_PAPER_HISTORY:]...)
+	}
+	return list
+}
+
+func (d *paperDesk) saveLocked() {
+	data, err := json.MarshalIndent(d.state, "", "  ")
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(d.file), 0o755); err == nil {
+			tmp := d.file + ".tmp"
+			if err = os.WriteFile(tmp, data, 0o600); err == nil {
+				err = os.Rename(tmp, d.file)
+			}
+		}
+	}
+	if err != nil {
+		log.Printf("❌ Failed to save paper accounts: %v", err)
+	}
+}
+
+// Refusal in BloFin's shape
+func paperRefusal(format string, args ...interface{}) error {
+	return &blofinError{Status: http.StatusOK, Code: "1", Msg: fmt.Sprintf(format, args...)}
+}
+
+func (d *paperDesk) leverage(a *paperAccount, instID string) *big.Rat {
+	if lever, ok := parseDecimal(a.Leverage[instID]); ok {
+		return lever
+	}
+	lever, _ := parseDecimal(DEFAULT_PAPER_LEVERAGE)
+	return lever
+}
+
+func (d *paperDesk) contractValue(instID string) (*big.Rat, error) {
+	inst, ok, err := d.contract(instID)
+	if err != nil {
+		return nil, fmt.Errorf("instrument specifications are unavailable: %v", err)
+	}
+	value, valid := parseDecimal(inst.ContractValue)
+	if !ok || !valid {
+		return nil, fmt.Errorf("unknown instrument %s", instID)
+	}
+	return value, nil
+}
+
+// Price a position is valued at: the last trade, or its entry price
+// without market data
+func markOf(p *paperPosition, tick ticker) *big.Rat {
+	if last, ok := parseDecimal(tick.Last); ok {
+		return last
+	}
+	entry, _ := parseDecimal(p.AveragePrice)
+	return entry
+}
+
+// Unrealized PnL and margin of a position at mark
+func (d *paperDesk) valuePosition(a *paperAccount, instID string, p *paperPosition, mark *big.Rat) (pnl, margin *big.Rat) {
+	size, _ := parseDecimal(p.Positions)
+	entry, _ := parseDecimal(p.AveragePrice)
+	value, err := d.contractValue(instID)
+	if err != nil || size == nil || entry == nil {
+		return new(big.Rat), new(big.Rat)
+	}
+	pnl = new(big.Rat).Sub(mark, entry)
+	pnl.Mul(pnl, size).Mul(pnl, value)
+	margin = new(big.Rat).Abs(size)
+	margin.Mul(margin, value).Mul(margin, mark).Quo(margin, d.leverage(a, instID))
+	return pnl, margin
+}
+
+// Equity, and what is left of it after position margin and the margin
+// resting orders hold
+func (d *paperDesk) funds(a *paperAccount, prices map[string]ticker) (equity, available, frozen *big.Rat) {
+	equity, _ = parseDecimal(a.Balance)
+	if equity == nil {
+		equity = new(big.Rat)
+	}
+	used, frozen := new(big.Rat), new(big.Rat)
+	for instID, p := range a.Positions {
+		pnl, margin := d.valuePosition(a, instID, p, markOf(p, prices[instID]))
+		equity.Add(equity, pnl)
+		used.Add(used, margin)
+	}
+	for _, order := range a.Orders {
+		if order["reduceOnly"] == "true" {
+			continue
+		}
+		size := paperRemaining(order)
+		price, _ := parseDecimal(order["price"])
+		value, err := d.contractValue(order["instId"])
+		if price == nil || err != nil {
+			continue
+		}
+		margin := new(big.Rat).Mul(size, price)
+		margin.Mul(margin, value).Quo(margin, d.leverage(a, order["instId"]))
+		frozen.Add(frozen, margin)
+	}
+	available = new(big.Rat).Sub(equity, used)
+	available.Sub(available, frozen)
+	return equity, available, frozen
+}
+
+func (d *paperDesk) balance(a *paperAccount, req paperRequest) (interface{}, error) {
+	equity, available, frozen := d.funds(a, req.prices)
+	ts := millis(req.now)
+	return map[string]interface{}{
+		"ts":             ts,
+		"totalEquity":    decimalString(equity),
+		"isolatedEquity": "0",
+		"details": []map[string]string{{
+			"currency":              "USDT",
+			"equity":                decimalString(equity),
+			"balance":               a.Balance,
+			"ts":                    ts,
+			"isolatedEquity":        "0",
+			"available":             decimalString(available),
+			"availableEquity":       decimalString(available),
+			"frozen":                decimalString(frozen),
+			"orderFrozen":           decimalString(frozen),
+			"equityUsd":             decimalString(equity),
+			"isolatedUnrealizedPnl": "0",
+			"bonus":                 "0",
+		}},
+	}, nil
+}
+
+// instId query as a set; nil matches every instrument
+func instIDFilter(query url.Values) map[string]bool {
+	if query.Get("instId") == "" {
+		return nil
+	}
+	ids := map[string]bool{}
+	for _, id := range strings.Split(query.Get("instId"), ",") {
+		ids[normalizeInstID(strings.TrimSpace(id))] = true
+	}
+	return ids
+}
+
+func (d *paperDesk) positions(a *paperAccount, req paperRequest) (interface{}, error) {
+	filter := instIDFilter(req.query)
+	instIDs := make([]string, 0, len(a.Positions))
+	for instID := range a.Positions {
+		if filter == nil || filter[instID] {
+			instIDs = append(instIDs, instID)
+		}
+	}
+	sort.Strings(instIDs)
+	data := []map[string]string{}
+	for _, instID := range instIDs {
+		p := a.Positions[instID]
+		mark := markOf(p, req.prices[instID])
+		pnl, margin := d.valuePosition(a, instID, p, mark)
+		ratio := new(big.Rat)
+		if margin.Sign() != 0 {
+			ratio.Quo(pnl, margin)
+		}
+		data = append(data, map[string]string{
+			"positionId":         instID,
+			"instId":             instID,
+			"instType":           "SWAP",
+			"marginMode":         "cross",
+			"positionSide":       "net",
+			"adl":                "1",
+			"positions":          p.Positions,
+			"availablePositions": p.Positions,
+			"averagePrice":       p.AveragePrice,
+			"margin":             decimalString(margin),
+			"markPrice":          decimalString(mark),
+			"marginRatio":        "0",
+			"liquidationPrice":   "0",
+			"unrealizedPnl":      decimalString(pnl),
+			"unrealizedPnlRatio": ratio.FloatString(4),
+			"leverage":           decimalString(d.leverage(a, instID)),
+			"createTime":         strconv.FormatInt(p.CreateTime, 10),
+			"updateTime":         strconv.FormatInt(p.UpdateTime, 10),
+		})
+	}
+	return data, nil
+}
+
+func (d *paperDesk) marginMode(*paperAccount, paperRequest) (interface{}, error) {
+	return map[string]string{"marginMode": "cross"}, nil
+}
+
+func (d *paperDesk) positionMode(*paperAccount, paperRequest) (interface{}, error) {
+	return map[string]string{"positionMode": "net_mode"}, nil
+}
+
+func (d *paperDesk) setMarginMode(_ *paperAccount, req paperRequest) (interface{}, error) {
+	var body map[string]string
+	json.Unmarshal(req.body, &body)
+	if body["marginMode"] != "cross" {
+		return nil, paperRefusal("Paper trading only supports cross margin")
+	}
+	return body, nil
+}
+
+func (d *paperDesk) setPositionMode(_ *paperAccount, req paperRequest) (interface{}, error) {
+	var body map[string]string
+	json.Unmarshal(req.body, &body)
+	if body["positionMode"] != "net_mode" {
+		return nil, paperRefusal("Paper trading only supports net_mode")
+	}
+	return body, nil
+}
+
+func (d *paperDesk) leverageInfo(a *paperAccount, req paperRequest) (interface{}, error) {
+	data := []map[string]string{}
+	for instID := range instIDFilter(req.query) {
+		data = append(data, map[string]string{"instId": instID, "leverage": decimalString(d.leverage(a, instID)), "marginMode": "cross", "positionSide": "net"})
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i]["instId"] < data[j]["instId"] })
+	return data, nil
+}
+
+func (d *paperDesk) setLeverage(a *paperAccount, req paperRequest) (interface{}, error) {
+	var body map[string]string
+	if err := json.Unmarshal(req.body, &body); err != nil {
+		return nil, paperRefusal("Invalid request body")
+	}
+	instID := normalizeInstID(body["instId"])
+	inst, ok, err := d.contract(instID)
+	if err != nil || !ok {
+		return nil, paperRefusal("Unknown instrument %s", instID)
+	}
+	lever, valid := parseDecimal(body["leverage"])
+	most, _ := parseDecimal(inst.MaxLeverage)
+	if !valid || !lever.IsInt() || lever.Sign() <= 0 || (most != nil && lever.Cmp(most) > 0) {
+		return nil, paperRefusal("Leverage must be a whole number from 1 to %s", inst.MaxLeverage)
+	}
+	if body["marginMode"] != "" && body["marginMode"] != "cross" {
+		return nil, paperRefusal("Paper trading only supports cross margin")
+	}
+	a.Leverage[instID] = decimalString(lever)
+	return map[string]string{"instId": instID, "leverage": a.Leverage[instID], "marginMode": "cross", "positionSide": "net"}, nil
+}
+
+// Result of one order placement or cancellation, as in BloFin's data array
+func paperResult(order map[string]string, err error) map[string]string {
+	result := map[string]string{"orderId": order["orderId"], "clientOrderId": order["clientOrderId"], "code": "0", "msg": ""}
+	var refused *blofinError
+	if errors.As(err, &refused) {
+		result["code"], result["msg"] = refused.Code, refused.Msg
+	}
+	return result
+}
+
+func (d *paperDesk) placeOrder(a *paperAccount, req paperRequest) (interface{}, error) {
+	var order map[string]interface{}
+	if err := json.Unmarshal(req.body, &order); err != nil {
+		return nil, paperRefusal("Invalid request body")
+	}
+	placed, err := d.place(a, order, req)
+	return []map[string]string{paperResult(placed, err)}, err
+}
+
+func (d *paperDesk) placeBatchOrders(a *paperAccount, req paperRequest) (interface{}, error) {
+	var orders []map[string]interface{}
+	if err := json.Unmarshal(req.body, &orders); err != nil {
+		return nil, paperRefusal("Invalid request body")
+	}
+	data := []map[string]string{}
+	var failed error
+	for _, order := range orders {
+		placed, err := d.place(a, order, req)
+		if err != nil {
+			failed = paperRefusal("Not every order was placed")
+		}
+		data = append(data, paperResult(placed, err))
+	}
+	return data, failed
+}
+
+// Validate, accept and, when it is marketable, fill an order. The returned
+// order carries the IDs even when it is refused.
+func (d *paperDesk) place(a *paperAccount, fields map[string]interface{}, req paperRequest) (map[string]string, error) {
+	order := map[string]string{}
+	for key, value := range fields {
+		if s, ok := value.(string); ok {
+			order[key] = s
+		} else if value != nil {
+			order[key] = fmt.Sprint(value)
+		}
+	}
+	instID := normalizeInstID(order["instId"])
+	size, sizeOK := parseDecimal(order["size"])
+	price, priceOK := parseDecimal(order["price"])
+	switch {
+	case instID == "":
+		return order, paperRefusal("instId is required")
+	case order["side"] != "buy" && order["side"] != "sell":
+		return order, paperRefusal("side must be buy or sell")
+	case !validOrderTypes[order["orderType"]]:
+		return order, paperRefusal("orderType must be one of market, limit, post_only, fok, ioc")
+	case !sizeOK || size.Sign() <= 0:
+		return order, paperRefusal("size must be a positive number")
+	case order["orderType"] != "market" && (!priceOK || price.Sign() <= 0):
+		return order, paperRefusal("price must be a positive number")
+	case order["marginMode"] != "" && order["marginMode"] != "cross":
+		return order, paperRefusal("Paper trading only supports cross margin")
+	case order["positionSide"] != "" && order["positionSide"] != "net":
+		return order, paperRefusal("Paper trading only supports net positions")
+	}
+	value, err := d.contractValue(instID)
+	if err != nil {
+		return order, paperRefusal("%v", err)
+	}
+	tick, ok := req.prices[instID]
+	ask, askOK := parseDecimal(tick.AskPrice)
+	bid, bidOK := parseDecimal(tick.BidPrice)
+	if !ok || !askOK || !bidOK {
+		return order, paperRefusal("No market data for %s", instID)
+	}
+	buy := order["side"] == "buy"
+	if order["reduceOnly"] == "true" && !reduces(a.Positions[instID], buy, size) {
+		return order, paperRefusal("Reduce-only order would open or increase a position")
+	}
+
+	touch := bid
+	if buy {
+		touch = ask
+	}
+	marketable := order["orderType"] == "market" || (buy && ask.Cmp(price) <= 0) || (!buy && bid.Cmp(price) >= 0)
+	if order["orderType"] == "post_only" && marketable {
+		return order, paperRefusal("Post-only order would take liquidity")
+	}
+	// What the order takes from the book now; limit orders rest with the
+	// rest, other orders cancel it, and fok orders fill in full or not at all
+	var taken []paperLevel
+	rest := new(big.Rat).Set(size)
+	if marketable {
+		limit := price
+		if order["orderType"] == "market" {
+			limit = nil
+		}
+		taken = takeLiquidity(req.books[instID], buy, size, limit, touch)
+		for _, level := range taken {
+			rest.Sub(rest, level.size)
+		}
+		if order["orderType"] == "fok" && rest.Sign() > 0 {
+			taken, rest = nil, size
+		}
+	}
+	resting := order["orderType"] == "limit" || order["orderType"] == "post_only"
+	if order["reduceOnly"] != "true" {
+		notional := new(big.Rat)
+		for _, level := range taken {
+			notional.Add(notional, new(big.Rat).Mul(level.size, level.price))
+		}
+		if resting {
+			notional.Add(notional, new(big.Rat).Mul(rest, price))
+		}
+		need := notional.Mul(notional, value).Quo(notional, d.leverage(a, instID))
+		if _, available, _ := d.funds(a, req.prices); available.Cmp(need) < 0 {
+			return order, paperRefusal("Insufficient balance: the order needs %s USDT of margin, %s is available", need.FloatString(2), available.FloatString(2))
+		}
+	}
+
+	now := millis(req.now)
+	order["instId"] = instID
+	order["orderId"] = d.nextID()
+	order["marginMode"] = "cross"
+	order["positionSide"] = "net"
+	order["state"] = "live"
+	order["filledSize"] = "0"
+	order["averagePrice"] = "0"
+	order["fee"] = "0"
+	order["pnl"] = "0"
+	order["leverage"] = decimalString(d.leverage(a, instID))
+	order["orderCategory"] = "normal"
+	order["createTime"] = now
+	order["updateTime"] = now
+	if order["reduceOnly"] == "" {
+		order["reduceOnly"] = "false"
+	}
+	for _, level := range taken {
+		if err := d.fill(a, order, level.size, level.price, PAPER_TAKER_FEE, req.now); err != nil {
+			return order, paperRefusal("%v", err)
+		}
+	}
+	switch {
+	case rest.Sign() == 0:
+	case resting:
+		a.Orders = append(a.Orders, order)
+	default:
+		d.finish(a, order, "canceled", req.now)
+	}
+	return order, nil
+}
+
+// Contracts taken from one order book level
+type paperLevel struct {
+	price, size *big.Rat
+}
+
+// The levels a taker order for size fills against, best first, stopping
+// at limit unless it is nil. Without a book the whole size fills at the
+// touch.
+func takeLiquidity(book *orderBook, buy bool, size, limit, touch *big.Rat) []paperLevel {
+	if book == nil {
+		return []paperLevel{{price: touch, size: size}}
+	}
+	rows := book.Bids
+	if buy {
+		rows = book.Asks
+	}
+	var taken []paperLevel
+	left := new(big.Rat).Set(size)
+	for _, row := range rows {
+		if left.Sign() == 0 || len(row) < 2 {
+			break
+		}
+		price, ok := parseDecimal(row[0])
+		available, sizeOK := parseDecimal(row[1])
+		if !ok || !sizeOK || available.Sign() <= 0 {
+			continue
+		}
+		if limit != nil && ((buy && price.Cmp(limit) > 0) || (!buy && price.Cmp(limit) < 0)) {
+			break
+		}
+		if available.Cmp(left) > 0 {
+			available = new(big.Rat).Set(left)
+		}
+		taken = append(taken, paperLevel{price: price, size: available})
+		left.Sub(left, available)
+	}
+	return taken
+}
+
+// Contracts of an order not filled yet
+func paperRemaining(order map[string]string) *big.Rat {
+	size, ok := parseDecimal(order["size"])
+	if !ok {
+		return new(big.Rat)
+	}
+	if filled, ok := parseDecimal(order["filledSize"]); ok {
+		size.Sub(size, filled)
+	}
+	return size
+}
+
+// Whether a buy or sell of size only shrinks position p
+func reduces(p *paperPosition, buy bool, size *big.Rat) bool {
+	if p == nil {
+		return false
+	}
+	held, ok := parseDecimal(p.Positions)
+	if !ok || held.Sign() == 0 || (held.Sign() > 0) == buy {
+		return false
+	}
+	return size.Cmp(held.Abs(held)) <= 0
+}
+
+// Fill size contracts of an order at price, updating the position and the
+// balance; the order is finished once all of it has filled
+func (d *paperDesk) fill(a *paperAccount, order map[string]string, size, price *big.Rat, feeRate string, now time.Time) error {
+	instID := order["instId"]
+	value, err := d.contractValue(instID)
+	if err != nil {
+		return err
+	}
+	buy := order["side"] == "buy"
+	if order["reduceOnly"] == "true" && !reduces(a.Positions[instID], buy, size) {
+		return fmt.Errorf("reduce-only order would open or increase a position")
+	}
+	delta := new(big.Rat).Set(size)
+	if !buy {
+		delta.Neg(delta)
+	}
+
+	p := a.Positions[instID]
+	held, entry := new(big.Rat), new(big.Rat)
+	if p != nil {
+		held, _ = parseDecimal(p.Positions)
+		entry, _ = parseDecimal(p.AveragePrice)
+	} else {
+		p = &paperPosition{CreateTime: now.UnixMilli()}
+	}
+	realized := new(big.Rat)
+	next := new(big.Rat).Add(held, delta)
+	switch {
+	case held.Sign() == 0 || held.Sign() == delta.Sign():
+		// Opening or adding: the entry becomes the size-weighted average
+		cost := new(big.Rat).Mul(held, entry)
+		cost.Add(cost, new(big.Rat).Mul(delta, price))
+		entry = cost.Quo(cost, next)
+	default:
+		// Reducing, closing or flipping: the closed part realizes PnL
+		closed := new(big.Rat).Abs(delta)
+		if abs := new(big.Rat).Abs(held); closed.Cmp(abs) > 0 {
+			closed = abs
+		}
+		realized.Sub(price, entry).Mul(realized, closed).Mul(realized, value)
+		if held.Sign() < 0 {
+			realized.Neg(realized)
+		}
+		if next.Sign() != 0 && next.Sign() != held.Sign() {
+			entry = new(big.Rat).Set(price)
+			p.CreateTime = now.UnixMilli()
+		}
+	}
+	rate, _ := parseDecimal(feeRate)
+	fee := new(big.Rat).Mul(size, value)
+	fee.Mul(fee, price).Mul(fee, rate)
+	balance, _ := parseDecimal(a.Balance)
+	a.Balance = decimalString(balance.Add(balance, realized).Sub(balance, fee))
+	if next.Sign() == 0 {
+		delete(a.Positions, instID)
+	} else {
+		p.Positions, p.AveragePrice, p.UpdateTime = decimalString(next), decimalString(entry), now.UnixMilli()
+		a.Positions[instID] = p
+	}
+
+	// The order's figures cover all of its fills so far
+	filled, _ := parseDecimal(order["filledSize"])
+	average, _ := parseDecimal(order["averagePrice"])
+	feeSoFar, _ := parseDecimal(order["fee"])
+	pnlSoFar, _ := parseDecimal(order["pnl"])
+	if filled == nil || average == nil || feeSoFar == nil || pnlSoFar == nil {
+		filled, average, feeSoFar, pnlSoFar = new(big.Rat), new(big.Rat), new(big.Rat), new(big.Rat)
+	}
+	average.Mul(average, filled).Add(average, new(big.Rat).Mul(price, size))
+	filled.Add(filled, size)
+	average.Quo(average, filled)
+	order["filledSize"] = decimalString(filled)
+	order["averagePrice"] = decimalString(average)
+	order["fee"] = feeSoFar.Add(feeSoFar, fee).FloatString(8)
+	order["pnl"] = decimalString(pnlSoFar.Add(pnlSoFar, realized))
+	order["updateTime"] = millis(now)
+	if total, _ := parseDecimal(order["size"]); total != nil && filled.Cmp(total) >= 0 {
+		d.finish(a, order, "filled", now)
+	} else {
+		order["state"] = "partially_filled"
+	}
+	a.Fills = appendCapped(a.Fills, map[string]string{
+		"instId":        instID,
+		"tradeId":       d.nextID(),
+		"orderId":       order["orderId"],
+		"clientOrderId": order["clientOrderId"],
+		"fillPrice":     decimalString(price),
+		"fillSize":      decimalString(size),
+		"fillPnl":       decimalString(realized),
+		"positionSide":  "net",
+		"side":          order["side"],
+		"fee":           fee.FloatString(8),
+		"ts":            millis(now),
+		"brokerId":      order["brokerId"],
+	})
+	return nil
+}
+
+func (d *paperDesk) finish(a *paperAccount, order map[string]string, state string, now time.Time) {
+	order["state"] = state
+	order["updateTime"] = millis(now)
+	a.History = appendCapped(a.History, order)
+}
+
+// Index of the resting order with the orderId or clientOrderId in fields
+func findPaperOrder(orders []map[string]string, fields map[string]string) int {
+	for i, order := range orders {
+		if (fields["orderId"] != "" && order["orderId"] == fields["orderId"]) || (fields["clientOrderId"] != "" && order["clientOrderId"] == fields["clientOrderId"]) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (d *paperDesk) cancel(a *paperAccount, fields map[string]string, now time.Time) map[string]string {
+	i := findPaperOrder(a.Orders, fields)
+	if i < 0 {
+		return paperResult(fields, paperRefusal("Order does not exist"))
+	}
+	order := a.Orders[i]
+	a.Orders = append(a.Orders[:i:i], a.Orders[i+1:]...)
+	d.finish(a, order, "canceled", now)
+	return paperResult(order, nil)
+}
+
+func (d *paperDesk) cancelOrder(a *paperAccount, req paperRequest) (interface{}, error) {
+	var fields map[string]string
+	if err := json.Unmarshal(req.body, &fields); err != nil {
+		return nil, paperRefusal("Invalid request body")
+	}
+	result := d.cancel(a, fields, req.now)
+	if result["code"] != "0" {
+		return []map[string]string{result}, paperRefusal("%s", result["msg"])
+	}
+	return []map[string]string{result}, nil
+}
+
+func (d *paperDesk) cancelBatchOrders(a *paperAccount, req paperRequest) (interface{}, error) {
+	var list []map[string]string
+	if err := json.Unmarshal(req.body, &list); err != nil {
+		return nil, paperRefusal("Invalid request body")
+	}
+	data := []map[string]string{}
+	var failed error
+	for _, fields := range list {
+		result := d.cancel(a, fields, req.now)
+		if result["code"] != "0" {
+			failed = paperRefusal("Not every order was canceled")
+		}
+		data = append(data, result)
+	}
+	return data, failed
+}
+
+func (d *paperDesk) closePosition(a *paperAccount, req paperRequest) (interface{}, error) {
+	var body map[string]string
+	if err := json.Unmarshal(req.body, &body); err != nil {
+		return nil, paperRefusal("Invalid request body")
+	}
+	instID := normalizeInstID(body["instId"])
+	p := a.Positions[instID]
+	if p == nil {
+		return nil, paperRefusal("No position in %s", instID)
+	}
+	side := "sell"
+	if strings.HasPrefix(p.Positions, "-") {
+		side = "buy"
+	}
+	order := map[string]interface{}{"instId": instID, "side": side, "orderType": "market", "size": strings.TrimPrefix(p.Positions, "-"), "reduceOnly": "true", "clientOrderId": body["clientOrderId"], "brokerId": body["brokerId"]}
+	if _, err := d.place(a, order, req); err != nil {
+		return nil, err
+	}
+	return map[string]string{"instId": instID, "positionSide": "net", "clientOrderId": body["clientOrderId"]}, nil
+}
+
+func (d *paperDesk) placeTPSL(a *paperAccount, req paperRequest) (interface{}, error) {
+	var tpsl map[string]string
+	if err := json.Unmarshal(req.body, &tpsl); err != nil {
+		return nil, paperRefusal("Invalid request body")
+	}
+	tpsl["instId"] = normalizeInstID(tpsl["instId"])
+	_, hasTP := parseDecimal(tpsl["tpTriggerPrice"])
+	_, hasSL := parseDecimal(tpsl["slTriggerPrice"])
+	switch {
+	case tpsl["side"] != "buy" && tpsl["side"] != "sell":
+		return nil, paperRefusal("side must be buy or sell")
+	case !hasTP && !hasSL:
+		return nil, paperRefusal("tpTriggerPrice or slTriggerPrice is required")
+	}
+	if _, err := d.contractValue(tpsl["instId"]); err != nil {
+		return nil, paperRefusal("%v", err)
+	}
+	now := millis(req.now)
+	tpsl["tpslId"] = d.nextID()
+	tpsl["marginMode"] = "cross"
+	tpsl["positionSide"] = "net"
+	tpsl["state"] = "live"
+	tpsl["createTime"] = now
+	tpsl["updateTime"] = now
+	a.TPSL = append(a.TPSL, tpsl)
+	return map[string]string{"tpslId": tpsl["tpslId"], "clientOrderId": tpsl["clientOrderId"], "code": "0", "msg": ""}, nil
+}
+
+func (d *paperDesk) cancelTPSL(a *paperAccount, req paperRequest) (interface{}, error) {
+	var list []map[string]string
+	if err := json.Unmarshal(req.body, &list); err != nil {
+		return nil, paperRefusal("Invalid request body")
+	}
+	data := []map[string]string{}
+	var failed error
+	for _, fields := range list {
+		result := map[string]string{"tpslId": fields["tpslId"], "clientOrderId": fields["clientOrderId"], "code": "1", "msg": "TP/SL order does not exist"}
+		for i, tpsl := range a.TPSL {
+			if (fields["tpslId"] != "" && tpsl["tpslId"] == fields["tpslId"]) || (fields["clientOrderId"] != "" && tpsl["clientOrderId"] == fields["clientOrderId"]) {
+				a.TPSL = append(a.TPSL[:i:i], a.TPSL[i+1:]...)
+				tpsl["state"], tpsl["updateTime"] = "canceled", millis(req.now)
+				a.TPSLHistory = appendCapped(a.TPSLHistory, tpsl)
+				result = map[string]string{"tpslId": tpsl["tpslId"], "clientOrderId": tpsl["clientOrderId"], "code": "0", "msg": ""}
+				break
+			}
+		}
+		if result["code"] != "0" {
+			failed = paperRefusal("Not every TP/SL order was canceled")
+		}
+		data = append(data, result)
+	}
+	return data, failed
+}
+
+// Items matching the query's instId (and orderId, tpslId, clientOrderId
+// when given), newest first, paged with after and before on idField like
+// BloFin's lists
+func pageNewestFirst(items []map[string]string, query url.Values, idField string) []map[string]string {
+	filter := instIDFilter(query)
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = PAPER_PAGE_SIZE
+	}
+	after, _ := strconv.ParseInt(query.Get("after"), 10, 64)
+	before, _ := strconv.ParseInt(query.Get("before"), 10, 64)
+	begin, _ := strconv.ParseInt(query.Get("begin"), 10, 64)
+	end, _ := strconv.ParseInt(query.Get("end"), 10, 64)
+	data := []map[string]string{}
+	for i := len(items) - 1; i >= 0 && len(data) < limit; i-- {
+		item := items[i]
+		id, _ := strconv.ParseInt(item[idField], 10, 64)
+		stamp := item["ts"] // fills
+		if stamp == "" {
+			stamp = item["updateTime"]
+		}
+		ts, _ := strconv.ParseInt(stamp, 10, 64)
+		switch {
+		case filter != nil && !filter[item["instId"]]:
+		case (after > 0 && id >= after) || (before > 0 && id <= before):
+		case (begin > 0 && ts < begin) || (end > 0 && ts > end):
+		case query.Get("orderId") != "" && item["orderId"] != query.Get("orderId"):
+		case query.Get("tpslId") != "" && item["tpslId"] != query.Get("tpslId"):
+		case query.Get("clientOrderId") != "" && item["clientOrderId"] != query.Get("clientOrderId"):
+		default:
+			data = append(data, item)
+		}
+	}
+	return data
+}
+
+func (d *paperDesk) ordersPending(a *paperAccount, req paperRequest) (interface{}, error) {
+	return pageNewestFirst(a.Orders, req.query, "orderId"), nil
+}
+
+func (d *paperDesk) ordersHistory(a *paperAccount, req paperRequest) (interface{}, error) {
+	return pageNewestFirst(a.History, req.query, "orderId"), nil
+}
+
+func (d *paperDesk) tpslPending(a *paperAccount, req paperRequest) (interface{}, error) {
+	return pageNewestFirst(a.TPSL, req.query, "tpslId"), nil
+}
+
+func (d *paperDesk) tpslHistory(a *paperAccount, req paperRequest) (interface{}, error) {
+	return pageNewestFirst(a.TPSLHistory, req.query, "tpslId"), nil
+}
+
+func (d *paperDesk) fillsHistory(a *paperAccount, req paperRequest) (interface{}, error) {
+	return pageNewestFirst(a.Fills, req.query, "tradeId"), nil
+}
+
+func (d *paperDesk) orderDetail(a *paperAccount, req paperRequest) (interface{}, error) {
+	fields := map[string]string{"orderId": req.query.Get("orderId"), "clientOrderId": req.query.Get("clientOrderId")}
+	if i := findPaperOrder(a.Orders, fields); i >= 0 {
+		return a.Orders[i], nil
+	}
+	if i := findPaperOrder(a.History, fields); i >= 0 {
+		return a.History[i], nil
+	}
+	return nil, paperRefusal("Order does not exist")
+}
@@ -0,0 +1,261 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type paperMarket map[string]ticker
+
+func (m paperMarket) set(instID, bid, ask, last string) {
+	m[instID] = ticker{InstID: instID, BidPrice: bid, AskPrice: ask, Last: last}
+}
+
+func newTestPaperDesk(t *testing.T, dir string) (*paperDesk, paperMarket, *tenant) {
+	t.Helper()
+	reg, err := loadTestTenants(t, `{"name":"alice","token":"tok-a","apiKey":"k","secret":"s","passphrase":"p","paper":true,"paperBalance":"1000"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	market := paperMarket{}
+	market.set("BTC-USDT", "100", "101", "100")
+	contract := func(instID string) (instrument, bool, error) {
+		if instID != "BTC-USDT" {
+			return instrument{}, false, nil
+		}
+		return instrument{InstID: instID, ContractValue: "0.1", MaxLeverage: "50"}, true, nil
+	}
+	prices := func() (map[string]ticker, error) { return market, nil }
+	desk, err := newPaperDesk(prices, nil, contract, reg.list, dir)
+	if err != nil || desk == nil {
+		t.Fatalf("newPaperDesk = %v, %v", desk, err)
+	}
+	return desk, market, reg.list[0]
+}
+
+func paperCall(t *testing.T, d *paperDesk, alice *tenant, method, path, body string) (interface{}, error) {
+	t.Helper()
+	return d.call(alice, method, path, nil, []byte(body))
+}
+
+func paperPositions(t *testing.T, d *paperDesk, alice *tenant) []map[string]string {
+	t.Helper()
+	data, err := d.call(alice, http.MethodGet, "/api/v1/account/positions", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data.([]map[string]string)
+}
+
+func TestPaperDeskOnlyForPaperTenants(t *testing.T) {
+	reg, err := loadTestTenants(t, `{"name":"bob","token":"tok-b","apiKey":"k","secret":"s","passphrase":"p"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desk, err := newPaperDesk(nil, nil, nil, reg.list, t.TempDir())
+	if err != nil || desk != nil {
+		t.Fatalf("without paper tenants = %v, %v; want nil", desk, err)
+	}
+	if desk.serves(reg.list[0], http.MethodPost, "/api/v1/trade/order") {
+		t.Error("a nil desk serves nothing")
+	}
+	if _, err := loadTestTenants(t, `{"name":"bob","token":"tok-b","apiKey":"k","secret":"s","passphrase":"p","paperBalance":"500"}`); err == nil {
+		t.Error("paperBalance without paper should be rejected")
+	}
+
+	paper, _, alice := newTestPaperDesk(t, t.TempDir())
+	if !paper.serves(alice, http.MethodGet, "/api/v1/account/balance") {
+		t.Error("private routes of a paper tenant should be served")
+	}
+	if paper.serves(alice, http.MethodGet, "/api/v1/market/tickers") {
+		t.Error("public routes should still reach BloFin")
+	}
+	if _, err := paperCall(t, paper, alice, http.MethodPost, "/api/v1/asset/transfer", `{}`); err == nil {
+		t.Error("routes without a simulation should be refused")
+	}
+}
+
+func TestPaperMarketOrders(t *testing.T) {
+	desk, market, alice := newTestPaperDesk(t, t.TempDir())
+
+	// 10 contracts of 0.1 BTC at the ask
+	if _, err := paperCall(t, desk, alice, http.MethodPost, "/api/v1/trade/order", `{"instId":"BTCUSDT","side":"buy","orderType":"market","size":"10","clientOrderId":"c1"}`); err != nil {
+		t.Fatal(err)
+	}
+	positions := paperPositions(t, desk, alice)
+	if len(positions) != 1 || positions[0]["positions"] != "10" || positions[0]["averagePrice"] != "101" {
+		t.Fatalf("positions = %+v", positions)
+	}
+
+	market.set("BTC-USDT", "110", "111", "110")
+	if pnl := paperPositions(t, desk, alice)[0]["unrealizedPnl"]; pnl != "9" {
+		t.Errorf("unrealizedPnl = %s, want 9", pnl)
+	}
+	if _, err := paperCall(t, desk, alice, http.MethodPost, "/api/v1/trade/close-position", `{"instId":"BTC-USDT","marginMode":"cross"}`); err != nil {
+		t.Fatal(err)
+	}
+	if positions := paperPositions(t, desk, alice); len(positions) != 0 {
+		t.Fatalf("positions after closing = %+v", positions)
+	}
+	// 9 realized, less 0.0006 of 101 and 110 in fees
+	if balance := desk.account("alice").Balance; balance != "1008.8734" {
+		t.Errorf("balance = %s, want 1008.8734", balance)
+	}
+	fills, _ := desk.call(alice, http.MethodGet, "/api/v1/trade/fills-history", nil, nil)
+	if got := fills.([]map[string]string); len(got) != 2 || got[0]["fillPnl"] != "9" || got[1]["clientOrderId"] != "c1" {
+		t.Errorf("fills = %+v", got)
+	}
+}
+
+func TestPaperRefusals(t *testing.T) {
+	desk, _, alice := newTestPaperDesk(t, t.TempDir())
+	refused := func(body string) string {
+		t.Helper()
+		_, err := paperCall(t, desk, alice, http.MethodPost, "/api/v1/trade/order", body)
+		var blofin *blofinError
+		if !errors.As(err, &blofin) {
+			t.Fatalf("%s was accepted (%v)", body, err)
+		}
+		return blofin.Msg
+	}
+	// 3x leverage on 1000 USDT allows about 300 contracts at 101
+	if msg := refused(`{"instId":"BTC-USDT","side":"buy","orderType":"market","size":"400"}`); !strings.Contains(msg, "Insufficient balance") {
+		t.Errorf("oversized order refused with %q", msg)
+	}
+	refused(`{"instId":"BTC-USDT","side":"sell","orderType":"market","size":"1","reduceOnly":"true"}`)
+	refused(`{"instId":"BTC-USDT","side":"buy","orderType":"post_only","price":"102","size":"1"}`)
+	refused(`{"instId":"ETH-USDT","side":"buy","orderType":"market","size":"1"}`)
+	refused(`{"instId":"BTC-USDT","side":"buy","orderType":"limit","size":"1"}`)
+	if _, err := paperCall(t, desk, alice, http.MethodPost, "/api/v1/account/set-leverage", `{"instId":"BTC-USDT","leverage":"100","marginMode":"cross"}`); err == nil {
+		t.Error("leverage above the instrument's maximum should be refused")
+	}
+}
+
+func TestPaperLimitOrdersAndTPSL(t *testing.T) {
+	dir := t.TempDir()
+	desk, market, alice := newTestPaperDesk(t, dir)
+
+	if _, err := paperCall(t, desk, alice, http.MethodPost, "/api/v1/trade/order", `{"instId":"BTC-USDT","side":"buy","orderType":"limit","price":"95","size":"5"}`); err != nil {
+		t.Fatal(err)
+	}
+	if positions := paperPositions(t, desk, alice); len(positions) != 0 {
+		t.Fatalf("a resting order opened %+v", positions)
+	}
+	market.set("BTC-USDT", "96", "97", "96")
+	desk.match(market)
+	if pending, _ := desk.call(alice, http.MethodGet, "/api/v1/trade/orders-pending", nil, nil); len(pending.([]map[string]string)) != 1 {
+		t.Fatalf("order filled before the price reached it: %+v", pending)
+	}
+	market.set("BTC-USDT", "94", "95", "94")
+	desk.match(market)
+	positions := paperPositions(t, desk, alice)
+	if len(positions) != 1 || positions[0]["averagePrice"] != "95" {
+		t.Fatalf("positions after the limit filled = %+v", positions)
+	}
+
+	if _, err := paperCall(t, desk, alice, http.MethodPost, "/api/v1/trade/order-tpsl", `{"instId":"BTC-USDT","side":"sell","size":"-1","tpTriggerPrice":"105","slTriggerPrice":"90"}`); err != nil {
+		t.Fatal(err)
+	}
+	market.set("BTC-USDT", "104", "105", "104")
+	desk.match(market)
+	if positions := paperPositions(t, desk, alice); len(positions) != 1 {
+		t.Fatal("TP/SL triggered early")
+	}
+	market.set("BTC-USDT", "105", "106", "105")
+	desk.match(market)
+	if positions := paperPositions(t, desk, alice); len(positions) != 0 {
+		t.Fatalf("take profit left %+v", positions)
+	}
+	history, _ := desk.call(alice, http.MethodGet, "/api/v1/trade/orders-tpsl-history", nil, nil)
+	if got := history.([]map[string]string); len(got) != 1 || got[0]["state"] != "effective" {
+		t.Errorf("TP/SL history = %+v", got)
+	}
+
+	// Accounts survive a restart
+	reloaded, _, _ := newTestPaperDesk(t, dir)
+	if a, b := reloaded.account("alice").Balance, desk.account("alice").Balance; a != b {
+		t.Errorf("reloaded balance = %s, want %s", a, b)
+	}
+}
+
+func TestPaperServe(t *testing.T) {
+	desk, _, alice := newTestPaperDesk(t, t.TempDir())
+	rec := httptest.NewRecorder()
+	desk.serve(rec, alice, http.MethodPost, "/api/v1/trade/order", nil, []byte(`{"instId":"BTC-USDT","side":"buy","orderType":"market","size":"400"}`))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"code":"1"`) {
+		t.Errorf("refused order = %d %s", rec.Code, rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	desk.serve(rec, alice, http.MethodPost, "/api/v1/asset/transfer", nil, []byte(`{}`))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("unsupported route = %d, want 403", rec.Code)
+	}
+}
+
+func TestPaperOrderBookFills(t *testing.T) {
+	desk, market, alice := newTestPaperDesk(t, t.TempDir())
+	desk.books = func(instID string) (*orderBook, error) {
+		if instID != "BTC-USDT" {
+			return nil, errors.New("unknown instrument")
+		}
+		return &orderBook{
+			Asks: [][]string{{"101", "4"}, {"102", "4"}, {"104", "10"}},
+			Bids: [][]string{{"100", "5"}, {"99", "5"}},
+		}, nil
+	}
+	place := func(body string) map[string]string {
+		t.Helper()
+		data, err := paperCall(t, desk, alice, http.MethodPost, "/api/v1/trade/order", body)
+		if err != nil {
+			t.Fatalf("%s: %v", body, err)
+		}
+		id := data.([]map[string]string)[0]["orderId"]
+		order, _ := desk.call(alice, http.MethodGet, "/api/v1/trade/order-detail", url.Values{"orderId": {id}}, nil)
+		return order.(map[string]string)
+	}
+	fills := func() int {
+		data, _ := desk.call(alice, http.MethodGet, "/api/v1/trade/fills-history", url.Values{"limit": {"100"}}, nil)
+		return len(data.([]map[string]string))
+	}
+
+	// A market order walks the asks and pays the slippage
+	if order := place(`{"instId":"BTC-USDT","side":"buy","orderType":"market","size":"8"}`); order["state"] != "filled" || order["averagePrice"] != "101.5" {
+		t.Fatalf("market buy = %+v", order)
+	}
+	if n := fills(); n != 2 {
+		t.Errorf("fills = %d, want one per level", n)
+	}
+
+	// A marketable limit order takes what is inside its price and rests
+	// with the remainder
+	order := place(`{"instId":"BTC-USDT","side":"buy","orderType":"limit","price":"102","size":"10"}`)
+	if order["state"] != "partially_filled" || order["filledSize"] != "8" || order["averagePrice"] != "101.5" {
+		t.Fatalf("limit buy = %+v", order)
+	}
+	market.set("BTC-USDT", "101", "102", "102")
+	desk.match(market)
+	if positions := paperPositions(t, desk, alice); positions[0]["positions"] != "18" {
+		t.Fatalf("positions after the rest filled = %+v", positions)
+	}
+
+	// ioc orders cancel what the book can't fill; fok orders fill in full
+	// or not at all
+	market.set("BTC-USDT", "100", "101", "100")
+	if order := place(`{"instId":"BTC-USDT","side":"sell","orderType":"ioc","price":"99.5","size":"20"}`); order["state"] != "canceled" || order["filledSize"] != "5" || order["averagePrice"] != "100" {
+		t.Errorf("ioc sell = %+v", order)
+	}
+	if order := place(`{"instId":"BTC-USDT","side":"sell","orderType":"fok","price":"99","size":"20"}`); order["state"] != "canceled" || order["filledSize"] != "0" {
+		t.Errorf("fok sell = %+v", order)
+	}
+	// Market orders larger than the book fill what is there
+	if order := place(`{"instId":"BTC-USDT","side":"sell","orderType":"market","size":"12"}`); order["state"] != "canceled" || order["filledSize"] != "10" || order["averagePrice"] != "99.5" {
+		t.Errorf("market sell past the book = %+v", order)
+	}
+	if positions := paperPositions(t, desk, alice); positions[0]["positions"] != "3" {
+		t.Errorf("positions = %+v", positions)
+	}
+}
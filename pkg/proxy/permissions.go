@@ -0,0 +1,101 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Permission levels a tenant's key can be declared with, each allowing
+// everything the ones before it do
+const (
+	PERMISSION_READ     = "read"
+	PERMISSION_TRADE    = "trade"
+	PERMISSION_WITHDRAW = "withdraw"
+)
+
+var permissionLevels = map[string]int{
+	PERMISSION_READ:     1,
+	PERMISSION_TRADE:    2,
+	PERMISSION_WITHDRAW: 3,
+}
+
+// Permission a request needs from the key signing it: reads need read,
+// moving funds needs withdraw and every other change needs trade
+func requiredPermission(method, path string) string {
+	switch {
+	case method == http.MethodGet || method == http.MethodHead:
+		return PERMISSION_READ
+	case strings.HasPrefix(path, "/api/v1/asset/"):
+		return PERMISSION_WITHDRAW
+	}
+	return PERMISSION_TRADE
+}
+
+// Error unless the tenant's declared permission covers the request. A
+// tenant without a declared permission may do anything its key can.
+func (t *tenant) allows(method, path string) error {
+	if t == nil || t.Permission == "" {
+		return nil
+	}
+	need := requiredPermission(method, path)
+	if permissionLevels[t.Permission] >= permissionLevels[need] {
+		return nil
+	}
+	return fmt.Errorf("tenant %s has a %s key: %s %s needs %s permission", t.Name, t.Permission, method, path, need)
+}
+
+// Error unless t may trade every one of ids. A tenant without an
+// instrument list may trade anything.
+func (t *tenant) allowsInstruments(ids []string) error {
+	if t == nil || len(t.instruments) == 0 {
+		return nil
+	}
+	for _, id := range ids {
+		if !t.instruments[normalizeInstID(id)] {
+			return fmt.Errorf("tenant %s may only trade %s, not %s", t.Name, strings.Join(t.Instruments, ", "), id)
+		}
+	}
+	return nil
+}
+
+// instIds in a JSON body holding an object or an array of objects
+func bodyInstIDs(body []byte) []string {
+	var objects []map[string]interface{}
+	if err := json.Unmarshal(body, &objects); err != nil {
+		var object map[string]interface{}
+		if json.Unmarshal(body, &object) != nil {
+			return nil
+		}
+		objects = append(objects, object)
+	}
+	var ids []string
+	for _, object := range objects {
+		if id, ok := object["instId"].(string); ok && id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Answer 403 when a tenant's change in r names an instrument outside its
+// list; false once answered
+func (s *server) checkInstruments(w http.ResponseWriter, r *http.Request, t *tenant) bool {
+	if t == nil || len(t.instruments) == 0 || r.Method == http.MethodGet {
+		return true
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MAX_INSPECT_BODY))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Failed to read request body"})
+		return false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err := t.allowsInstruments(bodyInstIDs(body)); err != nil {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestTenantAllows(t *testing.T) {
+	tests := []struct {
+		permission   string
+		method, path string
+		allowed      bool
+	}{
+		{"", http.MethodPost, "/api/v1/asset/transfer", true},
+		{PERMISSION_READ, http.MethodGet, "/api/v1/account/balance", true},
+		{PERMISSION_READ, http.MethodPost, "/api/v1/trade/order", false},
+		{PERMISSION_TRADE, http.MethodPost, "/api/v1/trade/order", true},
+		{PERMISSION_TRADE, http.MethodPost, "/api/v1/asset/transfer", false},
+		{PERMISSION_TRADE, http.MethodGet, "/api/v1/asset/balances", true},
+		{PERMISSION_WITHDRAW, http.MethodPost, "/api/v1/asset/transfer", true},
+	}
+	for _, tt := range tests {
+		holder := &tenant{Name: "alice", Permission: tt.permission}
+		if err := holder.allows(tt.method, tt.path); (err == nil) != tt.allowed {
+			t.Errorf("%q allows %s %s: %v, want allowed %v", tt.permission, tt.method, tt.path, err, tt.allowed)
+		}
+	}
+	var nobody *tenant
+	if err := nobody.allows(http.MethodPost, "/api/v1/asset/transfer"); err != nil {
+		t.Errorf("requests without a tenant refused: %v", err)
+	}
+}
+
+func TestTenantAllowsInstruments(t *testing.T) {
+	limited := testTenant(t, `"instruments":["BTC-USDT","eth/usdt"]`)
+	anything := testTenant(t, "")
+	tests := []struct {
+		tenant  *tenant
+		ids     []string
+		allowed bool
+	}{
+		{limited, []string{"BTC-USDT"}, true},
+		{limited, []string{"ETH-USDT", "btc_usdt"}, true},
+		{limited, []string{"BTCUSDT"}, true},
+		{limited, []string{"BTC-USDT", "SOL-USDT"}, false},
+		{limited, nil, true},
+		{anything, []string{"SOL-USDT"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.tenant.allowsInstruments(tt.ids); (err == nil) != tt.allowed {
+			t.Errorf("%v with %v: %v, want allowed %v", tt.ids, tt.tenant.Instruments, err, tt.allowed)
+		}
+	}
+}
+
+func TestBodyInstIDs(t *testing.T) {
+	tests := map[string][]string{
+		`{"instId":"BTC-USDT","size":"1"}`:                 {"BTC-USDT"},
+		`[{"instId":"BTC-USDT"},{"instId":"ETH-USDT"},{}]`: {"BTC-USDT", "ETH-USDT"},
+		`{"size":"1"}`: nil,
+		`not json`:     nil,
+	}
+	for body, want := range tests {
+		if got := bodyInstIDs([]byte(body)); !reflect.DeepEqual(got, want) {
+			t.Errorf("bodyInstIDs(%s) = %v, want %v", body, got, want)
+		}
+	}
+}
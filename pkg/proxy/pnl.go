@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"log"
+	"math/big"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_PNL_PERIOD = 24 * time.Hour
+	MAX_PNL_PERIOD     = 366 * 24 * time.Hour
+)
+
+// PnL of one instrument, or of the whole account
+type pnlFigures struct {
+	InstID     string `json:"instId,omitempty"`
+	Fills      int    `json:"fills"`
+	Realized   string `json:"realizedPnl"`
+	Fees       string `json:"fees"`
+	Unrealized string `json:"unrealizedPnl"`
+	Net        string `json:"netPnl"` // realized + unrealized - fees
+}
+
+type pnlSum struct {
+	fills                      int
+	realized, fees, unrealized big.Rat
+}
+
+func (p *pnlSum) add(other *pnlSum) {
+	p.fills += other.fills
+	p.realized.Add(&p.realized, &other.realized)
+	p.fees.Add(&p.fees, &other.fees)
+	p.unrealized.Add(&p.unrealized, &other.unrealized)
+}
+
+func (p *pnlSum) figures(instID string) pnlFigures {
+	net := new(big.Rat).Add(&p.realized, &p.unrealized)
+	net.Sub(net, &p.fees)
+	return pnlFigures{
+		InstID:     instID,
+		Fills:      p.fills,
+		Realized:   decimalString(&p.realized),
+		Fees:       decimalString(&p.fees),
+		Unrealized: decimalString(&p.unrealized),
+		Net:        decimalString(net),
+	}
+}
+
+// A Go duration, or a whole number of days such as 7d
+func parsePnLPeriod(value string) (time.Duration, bool) {
+	if value == "" {
+		return DEFAULT_PNL_PERIOD, true
+	}
+	period, err := time.ParseDuration(value)
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		period = time.Duration(n) * 24 * time.Hour
+	}
+	return period, err == nil && period > 0 && period <= MAX_PNL_PERIOD
+}
+
+// Realized PnL and fees of the fills since then, and the unrealized PnL
+// of positions, by instrument. Fees count as a cost whatever sign BloFin
+// gives them.
+func sumPnL(fills []storedFill, positions []map[string]string, since time.Time) map[string]*pnlSum {
+	byInst := map[string]*pnlSum{}
+	sum := func(instID string) *pnlSum {
+		if byInst[instID] == nil {
+			byInst[instID] = &pnlSum{}
+		}
+		return byInst[instID]
+	}
+	for _, f := range fills {
+		if f.ts() < since.UnixMilli() {
+			continue
+		}
+		inst := sum(f.InstID)
+		inst.fills++
+		if pnl, ok := parseDecimal(f.FillPnl); ok {
+			inst.realized.Add(&inst.realized, pnl)
+		}
+		if fee, ok := parseDecimal(f.Fee); ok {
+			inst.fees.Add(&inst.fees, fee.Abs(fee))
+		}
+	}
+	for _, p := range positions {
+		if pnl, ok := parseDecimal(p["unrealizedPnl"]); ok {
+			inst := sum(p["instId"])
+			inst.unrealized.Add(&inst.unrealized, pnl)
+		}
+	}
+	return byInst
+}
+
+// GET /analytics/pnl?period=24h sums the tenant's realized PnL and fees
+// from the fills stored by the sync-fills job over the period, adds the
+// unrealized PnL of its open positions now, and breaks both down by
+// instrument.
+func (s *server) handlePnL(w http.ResponseWriter, r *http.Request) {
+	t, err := s.tenants.fromRequest(r)
+	if err != nil || t == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "PnL needs the " + TENANT_HEADER + " of a configured tenant"})
+		return
+	}
+	period, ok := parsePnLPeriod(r.URL.Query().Get("period"))
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "period must be a duration up to a year, such as 24h or 7d"})
+		return
+	}
+	fills, err := s.fills.load(t)
+	if err != nil {
+		log.Printf("❌ Failed to read fills for %s: %v", t.Name, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to read the fill history"})
+		return
+	}
+	if fills == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "No fills stored yet, schedule the sync-fills job"})
+		return
+	}
+	var positions []map[string]string
+	if err := s.blofin.do(r.Context(), http.MethodGet, "/api/v1/account/positions", nil, nil, t, &positions); err != nil {
+		helperFailed(w, r, "Position lookup failed", err)
+		return
+	}
+
+	since := time.Now().Add(-period)
+	byInst := sumPnL(fills, positions, since)
+	total := &pnlSum{}
+	instIDs := make([]string, 0, len(byInst))
+	for instID, inst := range byInst {
+		total.add(inst)
+		instIDs = append(instIDs, instID)
+	}
+	sort.Strings(instIDs)
+	instruments := make([]pnlFigures, len(instIDs))
+	for i, instID := range instIDs {
+		instruments[i] = byInst[instID].figures(instID)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"tenant":      t.Name,
+		"period":      period.String(),
+		"since":       since.UnixMilli(),
+		"total":       total.figures(""),
+		"instruments": instruments,
+	})
+}
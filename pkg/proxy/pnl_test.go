@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParsePnLPeriod(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", DEFAULT_PNL_PERIOD, true},
+		{"24h", 24 * time.Hour, true},
+		{"90m", 90 * time.Minute, true},
+		{"7d", 7 * 24 * time.Hour, true},
+		{"0", 0, false},
+		{"-1h", 0, false},
+		{"1.5d", 0, false},
+		{"400d", 0, false},
+		{"week", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parsePnLPeriod(tt.value)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("parsePnLPeriod(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestHandlePnL(t *testing.T) {
+	store, _, alice := newTestFillStore(t)
+	s := &server{tenants: store.tenants, fills: store, blofin: store.client}
+	get := func(query string) (int, map[string]json.RawMessage) {
+		req := httptest.NewRequest(http.MethodGet, "/analytics/pnl"+query, nil)
+		req.Header.Set(TENANT_HEADER, "tok-a")
+		rec := httptest.NewRecorder()
+		s.handlePnL(rec, req)
+		var resp map[string]json.RawMessage
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+
+	if code, _ := get(""); code != http.StatusNotFound {
+		t.Fatalf("before the first sync = %d, want 404", code)
+	}
+	now := time.Now()
+	recent, old := now.Add(-time.Hour).UnixMilli(), now.Add(-48*time.Hour).UnixMilli()
+	fills := fmt.Sprintf(`[
+		{"instId":"ETH-USDT","tradeId":"1","fillPnl":"100","fee":"1","ts":"%d"},
+		{"instId":"BTC-USDT","tradeId":"2","fillPnl":"10","fee":"-0.5","ts":"%d"},
+		{"instId":"BTC-USDT","tradeId":"3","fillPnl":"-4","fee":"0.25","ts":"%d"},
+		{"instId":"ETH-USDT","tradeId":"4","fillPnl":"2","fee":"0.1","ts":"%d"}
+	]`, old, recent, recent, recent)
+	if err := os.MkdirAll(store.dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(store.path(alice), []byte(fills), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	code, resp := get("?period=24h")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	var instruments []pnlFigures
+	json.Unmarshal(resp["instruments"], &instruments)
+	if len(instruments) != 2 || instruments[0].InstID != "BTC-USDT" || instruments[1].InstID != "ETH-USDT" {
+		t.Fatalf("instruments = %+v", instruments)
+	}
+	btc, eth := instruments[0], instruments[1]
+	if btc.Fills != 2 || btc.Realized != "6" || btc.Fees != "0.75" {
+		t.Errorf("BTC-USDT = %+v, want 2 fills, 6 realized, 0.75 fees", btc)
+	}
+	// The mock holds an open BTC-USDT position only
+	unrealized, ok := parseDecimal(btc.Unrealized)
+	if !ok || unrealized.Sign() == 0 || eth.Unrealized != "0" {
+		t.Errorf("unrealized PnL: BTC-USDT %q, ETH-USDT %q", btc.Unrealized, eth.Unrealized)
+	}
+	if eth.Fills != 1 || eth.Realized != "2" || eth.Net != "1.9" {
+		t.Errorf("ETH-USDT = %+v, want only the recent fill", eth)
+	}
+	var total pnlFigures
+	json.Unmarshal(resp["total"], &total)
+	want, _ := parseDecimal("7.15")
+	want.Add(want, unrealized)
+	if total.Fills != 3 || total.Realized != "8" || total.Fees != "0.85" || total.Net != decimalString(want) {
+		t.Errorf("total = %+v, want net %s", total, decimalString(want))
+	}
+
+	if _, resp := get("?period=7d"); string(resp["period"]) != `"168h0m0s"` {
+		t.Errorf("period = %s", resp["period"])
+	}
+	if code, _ := get("?period=soon"); code != http.StatusBadRequest {
+		t.Errorf("period=soon = %d, want 400", code)
+	}
+}
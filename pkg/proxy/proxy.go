@@ -0,0 +1,790 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"time"
+
+	"github.com/joshthetrader/blofin-proxy/pkg/cors"
+	"github.com/joshthetrader/blofin-proxy/pkg/middleware"
+	"github.com/joshthetrader/blofin-proxy/pkg/ratelimit"
+)
+
+const (
+	BLOFIN_API_BASE = "https://openapi.blofin.com"
+	DEFAULT_PORT    = "8080"
+)
+
+// Middleware that can be switched on and off with MIDDLEWARE
+const (
+	MIDDLEWARE_RECOVER = "recover"
+	MIDDLEWARE_LOGGING = "logging"
+	MIDDLEWARE_CORS    = "cors"
+)
+
+// Limit on request bodies buffered for inspection
+const MAX_INSPECT_BODY = 1 << 20
+
+// Proxy is an http.Handler serving the BloFin CORS proxy and its local
+// endpoints. Create one with New.
+type Proxy struct {
+	srv *server
+	mux *http.ServeMux
+	ops *http.ServeMux // admin, metrics, local data and docs routes when ADMIN_PORT is set
+	tls *tlsReloader
+	// Patterns registered on mux and ops. /openapi.json documents the
+	// listener it is served on.
+	patterns    []string
+	opsPatterns []string
+	// Saved periodically and once more by Close
+	limits *limitStore
+}
+
+type server struct {
+	cfg         Config
+	mock        *mockExchange
+	vcr         *vcr
+	chaos       *chaosMonkey
+	maintenance *maintenanceMode
+	shadow      *shadowMirror
+	blofin      *blofinClient
+	hosts       *hostSelector
+	instruments *instrumentCache
+	candles     *candleStore
+	backfill    *candleBackfiller
+	exports     *exporter
+	scheduler   *scheduler
+	tickers     *tickerFeed
+	funding     *fundingFeed
+	marks       *markFeed
+	alerts      *alertEngine
+	tenants     *tenantRegistry
+	forwarder   *httputil.ReverseProxy
+	hooks       hookChain
+	broker      *brokerTagger
+	metrics     *metricsRegistry
+	requests    *requestCounters
+	upstreams   []*upstream
+	limiter     *ratelimit.Limiter
+	quotas      *tenantQuotas
+	caps        *orderCaps
+	losses      *lossGuard
+	cache       *responseCache
+	accounts    *responseCache // tenant account data
+	balances    *balanceWatcher
+	affiliates  *affiliateStore
+	fills       *fillStore
+	paper       *paperDesk
+	orders      *orderWatcher
+	streams     *orderStreams
+	push        *pushBridge
+	perTenant   *tenantMetrics
+	duplicates  *duplicateGuard
+	replays     *replayGuard
+	templates   map[string]*orderTemplate
+}
+
+// New builds a proxy from cfg and starts its background jobs
+func New(cfg Config) (*Proxy, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	srv := &server{cfg: cfg, chaos: newChaosMonkey(cfg.Chaos), maintenance: newMaintenanceMode(cfg.Maintenance), hooks: cfg.Hooks, metrics: newMetricsRegistry()}
+	srv.requests = newRequestCounters(srv.metrics)
+	srv.limiter = newRateLimiter(cfg.RateLimit, srv.metrics)
+	switch cfg.Mode {
+	case MODE_PROXY:
+	case MODE_MOCK:
+		srv.mock = newMockExchange()
+	case MODE_RECORD, MODE_REPLAY:
+		cassettes, err := newVCR(cfg.CassetteDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open cassette directory %s: %v", cfg.CassetteDir, err)
+		}
+		srv.vcr = cassettes
+	default:
+		return nil, fmt.Errorf("unknown mode %q (expected %s, %s, %s or %s)", cfg.Mode, MODE_PROXY, MODE_MOCK, MODE_RECORD, MODE_REPLAY)
+	}
+	var dns *dnsCache
+	if cfg.Transport.DNSCacheTTL > 0 && (cfg.Mode == MODE_PROXY || cfg.Mode == MODE_RECORD) {
+		dns = newDNSCache(cfg.Transport.DNSCacheTTL, srv.metrics)
+	}
+	blofin := newUpstream(UPSTREAM_BLOFIN, cfg.Transport, dns, cfg.Breaker, cfg.SlowThreshold)
+	srv.upstreams = []*upstream{blofin}
+	hosts, err := newHostSelector(cfg.UpstreamHosts, cfg.ProbeInterval, blofin.transport)
+	if err != nil {
+		return nil, fmt.Errorf("invalid BloFin hosts: %v", err)
+	}
+	srv.hosts = hosts
+	if dns != nil {
+		dns.start(hosts.hostnames()...)
+	}
+	headers := cfg.StaticHeaders
+	if cfg.BrokerID != "" {
+		headers = append([]StaticHeader{{Prefix: "/", Name: "BROKER-ID", Value: cfg.BrokerID}}, headers...)
+	}
+	broker, err := newBrokerTagger(cfg.BrokerID, cfg.BrokerTagging)
+	if err != nil {
+		return nil, fmt.Errorf("invalid broker tagging: %v", err)
+	}
+	srv.broker = broker
+	srv.duplicates = newDuplicateGuard(cfg.DuplicateWindow)
+	srv.replays = newReplayGuard(cfg.WebhookMaxAge)
+	srv.blofin = newBlofinClient(srv.mock, hosts, blofin, headers, broker, newSigningClock(srv.metrics))
+	srv.forwarder = srv.newForwarder(blofin)
+	if cfg.Mode == MODE_PROXY {
+		// Record mode needs every request to reach BloFin
+		srv.cache = newResponseCache("public", cfg.Cache, cfg.CacheStale, srv.metrics)
+		// Account data is never served past its TTL
+		var rules []CacheRule
+		if cfg.AccountCacheTTL > 0 {
+			for _, prefix := range cfg.AccountCacheRoutes {
+				rules = append(rules, CacheRule{Prefix: prefix, TTL: cfg.AccountCacheTTL})
+			}
+		}
+		if cfg.AffiliateCacheTTL > 0 {
+			rules = append(rules, CacheRule{Prefix: AFFILIATE_PREFIX, TTL: cfg.AffiliateCacheTTL})
+		}
+		srv.accounts = newResponseCache("account", rules, 0, srv.metrics)
+	}
+	tenants, err := loadTenants(cfg.TenantsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tenants file: %v", err)
+	}
+	srv.tenants = tenants
+	if srv.templates, err = loadOrderTemplates(cfg.OrderTemplatesFile); err != nil {
+		return nil, fmt.Errorf("failed to load order templates file: %v", err)
+	}
+	srv.quotas = newTenantQuotas()
+	srv.caps = newOrderCaps()
+	srv.perTenant = newTenantMetrics(srv.metrics, tenants)
+	srv.affiliates = newAffiliateStore(srv.blofin, tenants, cfg.DataDir)
+	srv.fills = newFillStore(srv.blofin, tenants, cfg.DataDir)
+	if cfg.BalanceWebhook != "" {
+		if len(tenants.list) == 0 {
+			return nil, fmt.Errorf("BALANCE_WEBHOOK needs tenants to watch, see TENANTS_FILE")
+		}
+		srv.balances, err = newBalanceWatcher(srv.blofin, tenants.list, cfg.BalanceWebhook, cfg.BalanceInterval, cfg.BalanceThreshold, cfg.DataDir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid balance watcher settings: %v", err)
+		}
+	}
+	if srv.losses, err = newLossGuard(srv.blofin, srv.fills, tenants.list, cfg.DailyLossLimit, cfg.BalanceInterval, cfg.DataDir); err != nil {
+		return nil, fmt.Errorf("failed to load daily losses: %v", err)
+	}
+	if len(tenants.list) > 0 {
+		if srv.orders, err = newOrderWatcher(srv.blofin, tenants, cfg.OrderWatchInterval, cfg.DataDir, cfg.OrderCallbackPrivate); err != nil {
+			return nil, fmt.Errorf("failed to load order watches: %v", err)
+		}
+		srv.streams = newOrderStreams(srv.blofin, cfg.OrderWatchInterval)
+	}
+	if srv.push = newPushBridge(srv.blofin, cfg.PushRoutes); srv.push != nil {
+		if err := srv.push.validate(); err != nil {
+			return nil, fmt.Errorf("invalid push routes: %v", err)
+		}
+	}
+	var limits *limitStore
+	if srv.limiter != nil || tenants.hasQuotas() || tenants.hasOrderCaps() || cfg.DailyOrderLimit > 0 || cfg.DailyNotional != "" {
+		if limits, err = newLimitStore(cfg.DataDir, cfg.LimitSaveInterval, srv.limiter, srv.quotas, srv.caps); err != nil {
+			return nil, fmt.Errorf("failed to load rate limit state: %v", err)
+		}
+	}
+	srv.instruments = newInstrumentCache(srv.loadInstruments, cfg.InstrumentsTTL)
+	candles, err := newCandleStore(cfg.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open data directory %s: %v", cfg.DataDir, err)
+	}
+	srv.candles = candles
+	exports, err := newExporter(candles, cfg.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open export directory: %v", err)
+	}
+	srv.exports = exports
+	srv.backfill = &candleBackfiller{
+		client:   srv.blofin,
+		store:    candles,
+		targets:  cfg.BackfillTargets,
+		lookback: cfg.BackfillLookback,
+		pacing:   cfg.BackfillPacing,
+	}
+	srv.tickers = newTickerFeed(srv.blofin, cfg.TickerPollInterval)
+	prices := func() (map[string]ticker, error) {
+		latest, _, err := srv.tickers.current()
+		return latest, err
+	}
+	srv.paper, err = newPaperDesk(prices, srv.blofin.orderBook, srv.instruments.get, tenants.list, cfg.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load paper accounts: %v", err)
+	}
+	srv.blofin.paper = srv.paper
+	srv.marks = newMarkFeed(srv.blofin, cfg.MarkPriceInterval)
+	srv.funding, err = newFundingFeed(srv.blofin, cfg.FundingInterval, cfg.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load funding history: %v", err)
+	}
+	srv.alerts, err = newAlertEngine(srv.tickers, cfg.DataDir, cfg.TelegramBotToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load alerts: %v", err)
+	}
+	srv.scheduler, err = newScheduler(cfg.Schedule, srv.scheduledJobs())
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule: %v", err)
+	}
+	if cfg.ShadowUpstream != "" {
+		// The shadow is usually an internal host, so it skips the egress proxy
+		settings := cfg.Transport
+		settings.Proxy = ""
+		mirror := newUpstream(UPSTREAM_SHADOW, settings, nil, cfg.Breaker, cfg.SlowThreshold)
+		shadow, err := newShadowMirror(cfg.ShadowUpstream, cfg.ShadowPercent, cfg.ShadowMethods, cfg.ShadowIgnoreFields, mirror)
+		if err != nil {
+			return nil, fmt.Errorf("invalid shadow upstream %q: %v", cfg.ShadowUpstream, err)
+		}
+		srv.shadow = shadow
+		srv.upstreams = append(srv.upstreams, mirror)
+	}
+	registerUpstreamMetrics(srv.metrics, srv.upstreams)
+
+	p := &Proxy{srv: srv, mux: http.NewServeMux()}
+	if cfg.AdminPort != "" {
+		p.ops = http.NewServeMux()
+	}
+	if cfg.TLS.enabled() {
+		if p.tls, err = newTLSReloader(cfg.TLS); err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %v", err)
+		}
+		p.tls.start()
+	}
+	p.routes()
+	srv.logSettings()
+	srv.scheduler.start()
+	if cfg.Mode == MODE_PROXY || cfg.Mode == MODE_RECORD {
+		srv.hosts.start()
+	}
+	if p.limits = limits; limits != nil {
+		limits.start()
+	}
+	if srv.balances != nil {
+		srv.balances.start()
+	}
+	if srv.losses != nil {
+		srv.losses.start()
+	}
+	if srv.paper != nil {
+		srv.tickers.subscribe(srv.paper.match)
+	}
+	return p, nil
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mux.ServeHTTP(w, r)
+}
+
+// Close saves the rate limit, ban, quota and order cap state one last
+// time, so a restart resumes from the moment the proxy stopped. Call it
+// once the servers have shut down.
+func (p *Proxy) Close() error {
+	if p.limits == nil {
+		return nil
+	}
+	if err := p.limits.save(); err != nil {
+		return fmt.Errorf("failed to save rate limit state: %v", err)
+	}
+	return nil
+}
+
+// TLSConfig is the config for serving HTTPS on Config.Port, nil when no
+// certificate is configured. It follows changes to the certificate, key and
+// client CA files.
+func (p *Proxy) TLSConfig() *tls.Config {
+	if p.tls == nil {
+		return nil
+	}
+	return p.tls.config()
+}
+
+// AdminHandler serves the admin API, metrics, locally stored data, exports
+// and the API docs when Config.AdminPort is set, leaving them off the
+// public handler; nil otherwise
+func (p *Proxy) AdminHandler() http.Handler {
+	if p.ops == nil {
+		return nil
+	}
+	return p.ops
+}
+
+func (p *Proxy) routes() {
+	srv, mux := p.srv, p.mux
+	public := standardChain().Only(srv.cfg.Middleware).Append(middleware.Named{Name: "trace", Wrap: propagateTrace})
+	admin := public.Append(middleware.Named{Name: "auth", Wrap: srv.requireAdmin})
+	limited := public.Append(middleware.Named{Name: "ratelimit", Wrap: srv.rateLimit})
+	// BloFin-bound routes, counted per tenant and held back while in maintenance
+	gated := public.Append(
+		middleware.Named{Name: "tenantmetrics", Wrap: srv.perTenant.count},
+		middleware.Named{Name: "ratelimit", Wrap: srv.rateLimit},
+		middleware.Named{Name: "maintenance", Wrap: srv.maintenanceGate},
+		middleware.Named{Name: "deadline", Wrap: srv.clientDeadline},
+	)
+	// Non-admin routes, listed by / and in 404 responses
+	var endpoints []string
+	handle := func(pattern string, h http.HandlerFunc) {
+		p.patterns = append(p.patterns, pattern)
+		if !strings.HasPrefix(pattern, "/admin/") && !strings.HasSuffix(pattern, "/") {
+			endpoints = append(endpoints, pattern)
+		} else if pattern == "/unified/" || pattern == "/binance/" {
+			endpoints = append(endpoints, pattern+"*")
+		}
+		counter := srv.requests.route(pattern)
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			counter.inc()
+			h(w, r)
+		})
+	}
+	// Operator routes, moved to their own listener when ADMIN_PORT is set
+	// so the public one only passes requests through to BloFin
+	internal := handle
+	if p.ops != nil {
+		internal = func(pattern string, h http.HandlerFunc) {
+			p.opsPatterns = append(p.opsPatterns, pattern)
+			counter := srv.requests.route(pattern)
+			p.ops.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+				counter.inc()
+				h(w, r)
+			})
+		}
+		p.ops.HandleFunc("/", public.Then(func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "Not found: " + r.URL.Path})
+		}))
+	}
+
+	// Health check endpoint
+	handle("/health", public.Then(allowMethods(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"status":"ok","timestamp":"%s"}`, time.Now().UTC().Format(time.RFC3339))
+	}, http.MethodGet, http.MethodHead)))
+
+	// API documentation
+	documented := &p.patterns
+	if p.ops != nil {
+		documented = &p.opsPatterns
+	}
+	internal("/openapi.json", public.Then(allowMethods(openAPIHandler(documented), http.MethodGet)))
+	internal("/metrics", public.Then(srv.metrics.handle))
+	internal("/stats/upstreams", public.Then(allowMethods(srv.hosts.handleStats, http.MethodGet)))
+	internal("/docs", public.Then(allowMethods(docsHandler, http.MethodGet)))
+
+	// Locally stored market data
+	internal("/local/candles", public.Then(allowMethods(srv.candles.handleLocal, http.MethodGet)))
+	internal("/local/instruments", public.Then(allowMethods(srv.instruments.handleLocal, http.MethodGet)))
+	internal("/local/funding", public.Then(allowMethods(srv.funding.handleLocal, http.MethodGet)))
+	internal("/local/mark-price", public.Then(allowMethods(srv.marks.handleLocal, http.MethodGet)))
+	handle("/aggregate/tickers", public.Then(allowMethods(srv.tickers.handleAggregate, http.MethodGet)))
+	handle("/analytics/indicators", public.Then(allowMethods(srv.candles.handleIndicators, http.MethodGet)))
+	internal("/export/candles", public.Then(allowMethods(srv.exports.handleCandles, http.MethodGet)))
+	internal("/export/jobs", public.Then(srv.exports.handleJobs))
+	internal("/export/jobs/", public.Then(srv.exports.handleJobs))
+
+	// CCXT-style unified API
+	if srv.cfg.UnifiedAPI {
+		handle("/unified/", gated.Then(srv.handleUnified))
+	}
+	if srv.cfg.BinanceAPI {
+		handle("/binance/", gated.Then(srv.handleBinance))
+	}
+	if srv.cfg.GraphQLAPI {
+		handle("/graphql", gated.Then(allowMethods(srv.handleGraphQL, http.MethodGet, http.MethodPost)))
+	}
+
+	// Multi-step order helpers for tenants
+	handle("/helpers/order-with-tpsl", gated.Then(srv.handleOrderWithTPSL))
+	handle("/helpers/close-position", gated.Then(srv.handleClosePosition))
+	handle("/helpers/leverage", gated.Then(srv.handleLeverage))
+
+	// Orders from TradingView alerts
+	handle("/webhooks/tradingview", gated.Then(allowMethods(srv.handleTradingView, http.MethodPost)))
+
+	// Checks whether a BloFin key works from here
+	handle("/utils/key-check", limited.Then(allowMethods(srv.handleKeyCheck, http.MethodGet)))
+
+	// Affiliate snapshots for tenants
+	internal("/local/affiliate/invitees", public.Then(allowMethods(srv.affiliates.handleLocal, http.MethodGet)))
+
+	// Fill history and PnL for tenants
+	internal("/local/fills", public.Then(allowMethods(srv.fills.handleLocal, http.MethodGet)))
+	handle("/analytics/pnl", gated.Then(allowMethods(srv.handlePnL, http.MethodGet)))
+
+	// Changes of polled endpoints, pushed as Server-Sent Events
+	if srv.push != nil {
+		handle("/sse/poll", limited.Then(srv.push.handle))
+	}
+
+	// Order callbacks for tenants
+	if srv.orders != nil {
+		handle("/orders/watch", limited.Then(srv.orders.handle))
+		handle("/orders/watch/", limited.Then(srv.orders.handle))
+		handle("/sse/orders", limited.Then(srv.handleOrderStream))
+	}
+
+	// Admin API
+	internal("/admin/chaos", admin.Then(srv.chaos.handleAdmin))
+	internal("/admin/maintenance", admin.Then(srv.maintenance.handleAdmin))
+	internal("/admin/backfill", admin.Then(srv.backfill.handleAdmin))
+	internal("/admin/alerts", admin.Then(srv.alerts.handleAdmin))
+	internal("/admin/alerts/", admin.Then(srv.alerts.handleAdmin))
+	internal("/admin/jobs", admin.Then(srv.scheduler.handleAdmin))
+	internal("/admin/jobs/", admin.Then(srv.scheduler.handleAdmin))
+	if srv.limiter.Bans() != nil {
+		internal("/admin/bans", admin.Then(srv.handleBans))
+		internal("/admin/bans/", admin.Then(srv.handleBans))
+	}
+	if srv.losses != nil {
+		internal("/admin/losses", admin.Then(srv.losses.handleAdmin))
+		internal("/admin/losses/", admin.Then(srv.losses.handleAdmin))
+	}
+	if srv.shadow != nil {
+		internal("/admin/shadow", admin.Then(srv.shadow.diffs.handleAdmin))
+	}
+
+	// Root endpoint for debugging
+	endpoints = append(endpoints, "/api/*")
+	p.patterns = append(p.patterns, "/")
+	srv.requests.route("/")
+	mux.HandleFunc("/", gated.Then(func(w http.ResponseWriter, r *http.Request) {
+		// /api routes are counted by path, anything unknown as other
+		srv.requests.inc(r.URL.Path)
+		if r.URL.Path == "/" {
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"message":   "Blofin CORS Proxy",
+				"version":   "1.0",
+				"endpoints": endpoints,
+				"timestamp": time.Now().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		// Handle all /api/* routes
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			log.Printf("🔍 API route detected: %s", r.URL.Path)
+			srv.blofinProxy(w, r)
+			return
+		}
+		// 404 for other paths
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"error":     "Not found: " + r.URL.Path,
+			"endpoints": endpoints,
+		})
+	}))
+}
+
+// Middleware applied to every route, outermost first
+func standardChain() middleware.Chain {
+	return middleware.New(
+		middleware.Named{Name: MIDDLEWARE_RECOVER, Wrap: middleware.Recover},
+		middleware.Named{Name: MIDDLEWARE_LOGGING, Wrap: middleware.Logger},
+		middleware.Named{Name: MIDDLEWARE_CORS, Wrap: cors.Middleware},
+	)
+}
+
+func (s *server) logSettings() {
+	cfg := s.cfg
+	if s.mock != nil {
+		log.Printf("🎭 Mock mode: serving canned responses, BloFin is never contacted")
+	} else if cfg.Mode == MODE_REPLAY {
+		log.Printf("📼 Replay mode: serving cassettes from %s, BloFin is never contacted", cfg.CassetteDir)
+	} else {
+		if cfg.Mode == MODE_RECORD {
+			log.Printf("📼 Record mode: writing cassettes to %s", cfg.CassetteDir)
+		}
+		log.Printf("🔗 Proxying requests to: %s", strings.Join(s.hosts.bases(), ", "))
+		if egress, _ := cfg.Transport.proxyURL(); egress != nil {
+			log.Printf("🧦 Reaching BloFin through %s", egress.Redacted())
+		}
+	}
+	if s.cache != nil {
+		for _, rule := range s.cache.rules {
+			log.Printf("🗄️ Caching %s for %v, serving stale for up to %v while revalidating", rule.Prefix, rule.TTL, cfg.CacheStale)
+		}
+	}
+	if s.accounts != nil {
+		for _, rule := range s.accounts.rules {
+			log.Printf("🗄️ Caching tenant data under %s for %v", rule.Prefix, rule.TTL)
+		}
+	}
+	if cfg.ValidateRequests {
+		log.Printf("🧪 Request validation enabled")
+	}
+	if s.broker != nil {
+		log.Printf("🏷️ Tagging tenant orders with broker %s (%v)", cfg.BrokerID, cfg.BrokerTagging)
+	}
+	if cfg.RateLimit.PerIP > 0 {
+		log.Printf("🚦 Limiting each client IP to %g requests/s", cfg.RateLimit.PerIP)
+	}
+	if cfg.RateLimit.MaxConcurrent > 0 {
+		log.Printf("🚦 Limiting each client IP or tenant to %d concurrent requests", cfg.RateLimit.MaxConcurrent)
+	}
+	if cfg.RateLimit.PerOrigin > 0 {
+		log.Printf("🚦 Limiting each Origin to %g requests/s", cfg.RateLimit.PerOrigin)
+	}
+	for _, g := range cfg.RateLimit.Groups {
+		log.Printf("🚦 Limiting each client IP to %g requests/s on %s", g.Rate, g.Prefix)
+	}
+	if s.limiter.Bans() != nil {
+		log.Printf("🔨 Banning client IPs for %v after %d rate-limited requests within %v", cfg.RateLimit.BanDuration, cfg.RateLimit.BanAfter, cfg.RateLimit.BanWindow)
+	}
+	if len(s.tenants.list) > 0 {
+		log.Printf("🔑 Signing requests for %d tenant(s)", len(s.tenants.list))
+	}
+	if cfg.UnifiedAPI {
+		log.Printf("🔀 Unified API enabled under /unified/")
+	}
+	if cfg.BinanceAPI {
+		log.Printf("🔀 Binance Futures compatibility enabled under /binance/")
+	}
+	if cfg.GraphQLAPI {
+		log.Printf("🔀 GraphQL API enabled under /graphql")
+	}
+	if s.shadow != nil {
+		log.Printf("👥 Mirroring %g%% of %v requests to %s", cfg.ShadowPercent, cfg.ShadowMethods, cfg.ShadowUpstream)
+	}
+	if cfg.Maintenance.Enabled {
+		log.Printf("🚧 Maintenance mode on: answering BloFin routes with 503")
+	}
+	if cfg.Chaos.Percent > 0 {
+		log.Printf("🐒 Chaos mode: injecting %v into %g%% of requests", cfg.Chaos.Faults, cfg.Chaos.Percent)
+	}
+}
+
+func (s *server) blofinProxy(w http.ResponseWriter, r *http.Request) {
+	// Keep the full path including /api prefix (BloFin expects it)
+	apiPath := r.URL.Path
+
+	// Responses to rewrite are buffered, and fetched uncompressed
+	if shape := s.shapeFor(apiPath); shape != nil {
+		shaped := newShapedWriter(w, shape)
+		defer shaped.finish()
+		w = shaped
+		r.Header.Del("Accept-Encoding")
+	}
+
+	// A known endpoint called with the wrong method would only get BloFin's
+	// own error back
+	if findRoute(r.Method, apiPath) == nil {
+		if allowed := routeMethods(apiPath); len(allowed) > 0 {
+			methodNotAllowed(w, allowed...)
+			return
+		}
+	}
+
+	// BTCUSDT, btc/usdt, ... become BTC-USDT; a client's own signature
+	// would no longer match a rewritten request
+	if !s.cfg.StrictSymbols && r.Header.Get("ACCESS-KEY") == "" && !normalizeSymbols(w, r) {
+		return
+	}
+
+	// Reject requests that can't match the BloFin schema before forwarding
+	if s.cfg.ValidateRequests {
+		route, issues := validateRoute(r.Method, apiPath)
+		if route != nil {
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MAX_INSPECT_BODY))
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Failed to read request body"})
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			issues = validateRequest(route, r.URL.Query(), body)
+		}
+		if len(issues) > 0 {
+			log.Printf("🚫 Validation failed: %s %s (%d issues)", r.Method, apiPath, len(issues))
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"error":  "Request does not match the BloFin API schema",
+				"issues": issues,
+			})
+			return
+		}
+	}
+
+	// Dry runs are answered locally and never reach BloFin
+	if r.Method == http.MethodPost && orderRoutes[apiPath] && isDryRun(r) {
+		s.dryRunOrder(w, r)
+		return
+	}
+
+	if s.checksOrders() && r.Method == http.MethodPost && orderRoutes[apiPath] && !s.checkLiveOrder(w, r) {
+		return
+	}
+
+	if s.chaos.intercept(w, r) {
+		return
+	}
+
+	// Requests carrying a tenant token are signed here, which is also what
+	// makes it safe to tag their orders with the broker code
+	t, err := s.tenants.fromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unknown " + TENANT_HEADER})
+		return
+	}
+	if err := t.allows(r.Method, apiPath); err != nil {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+		return
+	}
+	if !s.checkInstruments(w, r, t) {
+		return
+	}
+	if r.Method == http.MethodPost && placementRoutes[apiPath] {
+		release, ok := s.holdOrder(w, r, t)
+		if !ok {
+			return
+		}
+		defer release()
+		if !s.checkDailyLimits(w, r, t) {
+			return
+		}
+	}
+	if !s.quotas.spend(t) {
+		quotaExceeded(w, t)
+		return
+	}
+	var reqBody []byte
+	if t != nil {
+		reqBody, err = io.ReadAll(http.MaxBytesReader(w, r.Body, MAX_INSPECT_BODY))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Failed to read request body"})
+			return
+		}
+		if r.Method == http.MethodPost && orderRoutes[apiPath] {
+			if s.cfg.AutoClientOrderID {
+				reqBody = fillClientOrderIDs(s.cfg.ClientOrderPrefix, reqBody)
+			}
+			reqBody = s.broker.tag(reqBody)
+			if s.cfg.AutoClientOrderID {
+				setClientOrderIDHeader(w, apiPath, reqBody)
+			}
+		}
+		// Paper tenants never reach BloFin, nor its cached responses
+		if s.paper.serves(t, r.Method, apiPath) {
+			s.paper.serve(w, t, r.Method, apiPath, r.URL.Query(), reqBody)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+	if s.fanOut(w, r, t) {
+		return
+	}
+	if s.serveCached(w, r, t) {
+		return
+	}
+	// Only batches the proxy signs can be split; a client's signature
+	// covers the whole body
+	if t != nil && r.Method == http.MethodPost && batchRoutes[apiPath] && s.sendChunked(w, r, t, reqBody) {
+		return
+	}
+
+	if s.mock != nil {
+		s.mock.serve(w, r)
+		return
+	}
+	if s.cfg.Mode == MODE_REPLAY {
+		s.vcr.replay(w, r)
+		return
+	}
+	state := &forwardState{
+		record: s.cfg.Mode == MODE_RECORD,
+		mirror: s.shadow != nil && s.shadow.sample(r),
+		debug:  s.debugRequested(r),
+		tenant: t,
+		body:   reqBody,
+	}
+
+	// Buffer the body when it has to be sent, signed or stored more than once
+	if state.record || state.mirror || state.debug || t != nil {
+		if t == nil {
+			state.body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, MAX_INSPECT_BODY))
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Failed to read request body"})
+				return
+			}
+		}
+		r.Body = io.NopCloser(bytes.NewReader(state.body))
+		r.ContentLength = int64(len(state.body))
+	}
+
+	timeout := s.cfg.UpstreamTimeout
+	if requested, _ := s.clientTimeout(r); requested > 0 {
+		timeout = requested
+	}
+	ctx, cancel := context.WithTimeout(context.WithValue(r.Context(), forwardKey{}, state), timeout)
+	defer cancel()
+	s.forwarder.ServeHTTP(w, r.WithContext(ctx))
+	if state.debug {
+		logDebugCapture(r, state)
+	}
+	if state.resp == nil {
+		return
+	}
+
+	if state.complete && state.record {
+		s.vcr.record(r, state.body, state.resp, state.recorded.Bytes())
+	}
+	if state.mirror {
+		s.shadow.mirror(r, state.body, state.resp.StatusCode, state.recorded.Bytes())
+	}
+
+	// Log requests for debugging (like Netlify proxy)
+	log.Printf("🔗 %s %s -> %s (Status: %d)", r.Method, r.URL.Path, state.resp.Request.URL.String(), state.resp.StatusCode)
+}
+
+// HTTP hop-by-hop headers that should not be forwarded
+var hopByHopHeaders = map[string]bool{
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Te":                  true,
+	"Trailers":            true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+}
+
+func isHopByHopHeader(header string) bool {
+	return hopByHopHeaders[http.CanonicalHeaderKey(header)]
+}
+
+// True once the client has disconnected; nothing written reaches it then
+func clientGone(r *http.Request) bool {
+	return errors.Is(r.Context().Err(), context.Canceled)
+}
+
+// 405 with the Allow header set, listing the methods the route does take
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeJSON(w, http.StatusMethodNotAllowed, map[string]interface{}{"error": "Method not allowed", "allowed": allowed})
+}
+
+// Pass only the given methods through to h
+func allowMethods(h http.HandlerFunc, methods ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, method := range methods {
+			if r.Method == method {
+				h(w, r)
+				return
+			}
+		}
+		methodNotAllowed(w, methods...)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("❌ Failed to write JSON response: %v", err)
+	}
+}
@@ -0,0 +1,292 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	MIN_PUSH_INTERVAL = time.Second
+	PUSH_BUFFER       = 16 // events queued per client before it is dropped
+)
+
+// Public GET endpoints under Prefix can be subscribed to at /sse/poll and
+// are polled every Interval while anyone is
+type PushRule struct {
+	Prefix   string
+	Interval time.Duration
+}
+
+// Parse "/path/prefix interval" entries
+func parsePushRules(entries []string) ([]PushRule, error) {
+	var rules []PushRule
+	for _, entry := range entries {
+		fields := strings.Fields(entry)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%q: expected a path prefix and an interval", entry)
+		}
+		interval, err := time.ParseDuration(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%q: invalid interval %q", entry, fields[1])
+		}
+		rules = append(rules, PushRule{Prefix: fields[0], Interval: interval})
+	}
+	return rules, nil
+}
+
+// Event sent to /sse/poll clients. A snapshot carries every item; an update
+// carries only the items that are new or changed and the keys of the ones
+// that disappeared.
+type pushEvent struct {
+	Type    string            `json:"type"` // snapshot or update
+	Path    string            `json:"path"`
+	Data    []json.RawMessage `json:"data"`
+	Removed []string          `json:"removed,omitempty"`
+	Ts      int64             `json:"ts"`
+}
+
+// Polls REST endpoints BloFin has no WebSocket channel for on behalf of
+// SSE subscribers and pushes only what changed. Each distinct request has
+// one poller, running while it has at least one subscriber. Items of list
+// responses are told apart by instId, falling back to their position.
+type pushBridge struct {
+	client *blofinClient
+	rules  []PushRule // longest prefix first
+
+	mu    sync.Mutex
+	feeds map[string]*pushFeed
+}
+
+type pushFeed struct {
+	key      string
+	path     string
+	query    url.Values
+	interval time.Duration
+	clients  map[chan pushEvent]bool
+	items    map[string]json.RawMessage // nil until the first poll succeeds
+}
+
+// nil when no rules are configured
+func newPushBridge(client *blofinClient, rules []PushRule) *pushBridge {
+	if len(rules) == 0 {
+		return nil
+	}
+	rules = append([]PushRule(nil), rules...)
+	sort.SliceStable(rules, func(i, j int) bool { return len(rules[i].Prefix) > len(rules[j].Prefix) })
+	return &pushBridge{client: client, rules: rules, feeds: map[string]*pushFeed{}}
+}
+
+func (b *pushBridge) validate() error {
+	for _, rule := range b.rules {
+		if !strings.HasPrefix(rule.Prefix, "/api/") {
+			return fmt.Errorf("%s: only /api/ paths can be polled", rule.Prefix)
+		}
+		if rule.Interval < MIN_PUSH_INTERVAL {
+			return fmt.Errorf("%s: interval must be at least %s", rule.Prefix, MIN_PUSH_INTERVAL)
+		}
+	}
+	return nil
+}
+
+func (b *pushBridge) intervalFor(path string) (time.Duration, bool) {
+	for _, rule := range b.rules {
+		if strings.HasPrefix(path, rule.Prefix) {
+			return rule.Interval, true
+		}
+	}
+	return 0, false
+}
+
+// Add a client for path and query, starting its poller if it's the first.
+// A client joining a feed that has already polled gets the snapshot at once.
+func (b *pushBridge) join(path string, query url.Values, interval time.Duration) (*pushFeed, chan pushEvent) {
+	key := path
+	if len(query) > 0 {
+		key += "?" + query.Encode()
+	}
+	ch := make(chan pushEvent, PUSH_BUFFER)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	feed, ok := b.feeds[key]
+	if !ok {
+		feed = &pushFeed{key: key, path: path, query: query, interval: interval, clients: map[chan pushEvent]bool{}}
+		b.feeds[key] = feed
+		log.Printf("📡 Polling %s every %s for push subscribers", key, interval)
+		go b.run(feed)
+	}
+	feed.clients[ch] = true
+	if feed.items != nil {
+		ch <- feed.snapshot()
+	}
+	return feed, ch
+}
+
+func (b *pushBridge) leave(feed *pushFeed, ch chan pushEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if feed.clients[ch] {
+		delete(feed.clients, ch)
+		close(ch)
+	}
+}
+
+// Poll until the feed's last client has gone
+func (b *pushBridge) run(feed *pushFeed) {
+	for {
+		b.mu.Lock()
+		if len(feed.clients) == 0 {
+			delete(b.feeds, feed.key)
+			b.mu.Unlock()
+			log.Printf("📡 Stopped polling %s", feed.key)
+			return
+		}
+		b.mu.Unlock()
+
+		items, err := b.fetch(feed)
+		if err != nil {
+			log.Printf("❌ Push poll of %s failed: %v", feed.key, err)
+		} else {
+			b.mu.Lock()
+			var event pushEvent
+			if feed.items == nil {
+				feed.items = items
+				event = feed.snapshot()
+			} else {
+				event = feed.diff(items)
+				feed.items = items
+			}
+			if event.Type == "snapshot" || len(event.Data) > 0 || len(event.Removed) > 0 {
+				for ch := range feed.clients {
+					select {
+					case ch <- event:
+					default:
+						log.Printf("⚠️ Dropping a slow push subscriber of %s", feed.key)
+						delete(feed.clients, ch)
+						close(ch)
+					}
+				}
+			}
+			b.mu.Unlock()
+		}
+		time.Sleep(feed.interval)
+	}
+}
+
+// The response's items by key
+func (b *pushBridge) fetch(feed *pushFeed) (map[string]json.RawMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var data json.RawMessage
+	if err := b.client.get(ctx, feed.path, feed.query, &data); err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '[' {
+		// A single object is one item
+		return map[string]json.RawMessage{"": data}, nil
+	}
+	var list []json.RawMessage
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	items := make(map[string]json.RawMessage, len(list))
+	for i, item := range list {
+		var keyed struct {
+			InstID string `json:"instId"`
+		}
+		key := strconv.Itoa(i)
+		if json.Unmarshal(item, &keyed) == nil && keyed.InstID != "" {
+			key = keyed.InstID
+		}
+		items[key] = item
+	}
+	return items, nil
+}
+
+func (feed *pushFeed) snapshot() pushEvent {
+	keys := make([]string, 0, len(feed.items))
+	for key := range feed.items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	data := make([]json.RawMessage, 0, len(keys))
+	for _, key := range keys {
+		data = append(data, feed.items[key])
+	}
+	return pushEvent{Type: "snapshot", Path: feed.key, Data: data, Ts: time.Now().UnixMilli()}
+}
+
+// Items that are new or changed in items, and keys that are gone
+func (feed *pushFeed) diff(items map[string]json.RawMessage) pushEvent {
+	event := pushEvent{Type: "update", Path: feed.key, Data: []json.RawMessage{}, Ts: time.Now().UnixMilli()}
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if prev, ok := feed.items[key]; !ok || !bytes.Equal(prev, items[key]) {
+			event.Data = append(event.Data, items[key])
+		}
+	}
+	for key := range feed.items {
+		if _, ok := items[key]; !ok {
+			event.Removed = append(event.Removed, key)
+		}
+	}
+	sort.Strings(event.Removed)
+	return event
+}
+
+// GET /sse/poll?path=/api/v1/market/funding-rate&instId=BTC-USDT streams
+// a snapshot of the endpoint's data and then only its changes; any query
+// parameters besides path are passed on to BloFin
+func (b *pushBridge) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, "GET")
+		return
+	}
+	query := r.URL.Query()
+	path := query.Get("path")
+	query.Del("path")
+	interval, ok := b.intervalFor(path)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "path must be one of the endpoints in PUSH_ROUTES"})
+		return
+	}
+	flusher, ok := startSSE(w)
+	if !ok {
+		return
+	}
+	writeSSE(w, "ready", map[string]interface{}{"path": path, "interval": interval.String()})
+	flusher.Flush()
+
+	feed, ch := b.join(path, query, interval)
+	defer b.leave(feed, ch)
+	heartbeat := time.NewTicker(SSE_HEARTBEAT)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case event, open := <-ch:
+			if !open {
+				return
+			}
+			writeSSE(w, event.Type, event)
+		}
+		flusher.Flush()
+	}
+}
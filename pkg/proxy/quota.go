@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Requests each tenant has made today (UTC), checked against their
+// dailyQuota. Tenants without a quota aren't counted.
+type tenantQuotas struct {
+	mu   sync.Mutex
+	day  string
+	used map[string]int
+}
+
+func newTenantQuotas() *tenantQuotas {
+	return &tenantQuotas{day: quotaDay(time.Now()), used: map[string]int{}}
+}
+
+func quotaDay(now time.Time) string {
+	return now.UTC().Format("2006-01-02")
+}
+
+// Count a request for t, false once the tenant's quota is used up
+func (q *tenantQuotas) spend(t *tenant) bool {
+	if t == nil || t.DailyQuota == 0 {
+		return true
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if day := quotaDay(time.Now()); day != q.day {
+		q.day, q.used = day, map[string]int{}
+	}
+	if q.used[t.Name] >= t.DailyQuota {
+		return false
+	}
+	q.used[t.Name]++
+	return true
+}
+
+// Answer 429 until the quota resets at midnight UTC
+func quotaExceeded(w http.ResponseWriter, t *tenant) {
+	retryAtMidnight(w)
+	writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": quotaExceededMessage(t)})
+}
+
+func quotaExceededMessage(t *tenant) string {
+	return fmt.Sprintf("Daily quota of %d requests used up, it resets at 00:00 UTC", t.DailyQuota)
+}
+
+// Marks the context of a request already counted against its tenant's
+// quota, so orders placed while serving it aren't counted again
+type quotaSpentKey struct{}
+
+func withQuotaSpent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, quotaSpentKey{}, true)
+}
+
+func quotaSpent(ctx context.Context) bool {
+	spent, _ := ctx.Value(quotaSpentKey{}).(bool)
+	return spent
+}
+
+func retryAtMidnight(w http.ResponseWriter) {
+	now := time.Now().UTC()
+	reset := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	w.Header().Set("Retry-After", strconv.Itoa(int(reset.Sub(now).Seconds())+1))
+}
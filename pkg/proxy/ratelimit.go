@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/joshthetrader/blofin-proxy/pkg/ratelimit"
+)
+
+// The limiter's settings, under the names Config has always used
+type (
+	RateLimitSettings = ratelimit.Settings
+	RateLimitGroup    = ratelimit.Group
+)
+
+// nil when no limit is configured
+func newRateLimiter(settings RateLimitSettings, metrics *metricsRegistry) *ratelimit.Limiter {
+	limiter := ratelimit.New(settings)
+	if limiter == nil {
+		return nil
+	}
+	metrics.register("blofin_proxy_rate_limited_total", METRIC_COUNTER, "Requests rejected by the proxy's own rate limits, by scope")
+	if limiter.Bans() != nil {
+		metrics.register("blofin_proxy_bans_total", METRIC_COUNTER, "Client IPs temporarily banned for ignoring 429s")
+	}
+	return limiter
+}
+
+// Middleware answering 429 once a client is over its limit
+func (s *server) rateLimit(next http.HandlerFunc) http.HandlerFunc {
+	if s.limiter == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		bans := s.limiter.Bans()
+		ip := s.limiter.ClientIP(r)
+		if bans != nil {
+			if until, banned := bans.Banned(ip, time.Now()); banned {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(until).Seconds()))))
+				writeJSON(w, http.StatusForbidden, map[string]string{
+					"error": "Temporarily banned for ignoring rate limits",
+					"until": until.UTC().Format(time.RFC3339),
+				})
+				return
+			}
+		}
+		scope, group, retry, ok := s.limiter.Allow(r)
+		inflight := s.limiter.Inflight()
+		if ok {
+			// Only requests held open on BloFin's behalf count; SSE streams
+			// would keep their slot for good
+			if inflight == nil || !blofinBound(r.URL.Path) {
+				next(w, r)
+				return
+			}
+			client := ip
+			if t, _ := s.tenants.fromRequest(r); t != nil {
+				client = "tenant:" + t.Name
+			}
+			if inflight.Acquire(client) {
+				defer inflight.Release(client)
+				next(w, r)
+				return
+			}
+			s.metrics.add("blofin_proxy_rate_limited_total", labels("scope", ratelimit.LIMIT_CONCURRENT), 1)
+			log.Printf("🚦 Too many concurrent requests from %s: %s %s", client, r.Method, r.URL.Path)
+			w.Header().Set("Retry-After", "1")
+			writeJSON(w, http.StatusTooManyRequests, map[string]string{
+				"error": fmt.Sprintf("Too many concurrent requests, at most %d may be in flight at once", inflight.Max()),
+			})
+			return
+		}
+		// Only the client's own limit counts toward a ban; an origin or path
+		// group limit can be hit by a busy neighbour
+		if bans != nil && scope == ratelimit.LIMIT_IP && bans.Strike(ip, time.Now()) {
+			s.metrics.add("blofin_proxy_bans_total", "", 1)
+			log.Printf("🔨 Banned %s for %v after %d rate-limited requests", ip, bans.Duration(), bans.After())
+		}
+		if scope == ratelimit.LIMIT_PATH {
+			s.metrics.add("blofin_proxy_rate_limited_total", labels("scope", scope, "group", group), 1)
+			log.Printf("🚦 Rate limited %s %s (%s group limit)", r.Method, r.URL.Path, group)
+		} else {
+			s.metrics.add("blofin_proxy_rate_limited_total", labels("scope", scope), 1)
+			log.Printf("🚦 Rate limited %s %s (%s limit)", r.Method, r.URL.Path, scope)
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "Too many requests, " + scope + " rate limit exceeded"})
+	}
+}
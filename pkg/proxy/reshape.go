@@ -0,0 +1,272 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Key styles responses can be rewritten to
+const (
+	KEYS_CAMEL = "camel"
+	KEYS_SNAKE = "snake"
+)
+
+// Timestamp formats responses can be rewritten to
+const (
+	TIMES_RFC3339 = "rfc3339"
+	TIMES_EPOCH   = "epoch"
+)
+
+// RFC3339 keeping BloFin's millisecond precision
+const RFC3339_MILLIS = "2006-01-02T15:04:05.000Z07:00"
+
+// Fields holding millisecond-epoch timestamps in BloFin responses
+const DEFAULT_TIMESTAMP_FIELDS = "ts,cTime,uTime,createTime,updateTime,fundingTime,nextFundingTime,listTime,expireTime,triggerTime,fillTime"
+
+// Identifiers that look numeric but must stay strings when numbers are
+// parsed; matched whatever the key style
+const DEFAULT_STRING_FIELDS = "code,orderId,clientOrderId,tpslId,algoId,positionId,tradeId,billId,transferId,uid,brokerId,instId"
+
+// What encoding/json and JavaScript both accept as a number literal
+var jsonNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// A response setting for /api paths starting with Prefix; the longest
+// matching prefix wins
+type ResponseRule struct {
+	Prefix string
+	Value  string
+}
+
+// Parse "[/path/prefix ]value" entries
+func parseResponseRules(entries []string) ([]ResponseRule, error) {
+	var rules []ResponseRule
+	for _, entry := range entries {
+		rule := ResponseRule{Prefix: "/", Value: entry}
+		if strings.HasPrefix(entry, "/") {
+			fields := strings.Fields(entry)
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("%q: expected a value after the path prefix", entry)
+			}
+			rule = ResponseRule{Prefix: fields[0], Value: fields[1]}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func ruleFor(rules []ResponseRule, path string) (string, bool) {
+	best := -1
+	for i, rule := range rules {
+		if strings.HasPrefix(path, rule.Prefix) && (best < 0 || len(rule.Prefix) > len(rules[best].Prefix)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return "", false
+	}
+	return rules[best].Value, true
+}
+
+// How a route's JSON responses are rewritten before they reach the client
+type responseShape struct {
+	keys    string          // KEYS_CAMEL or KEYS_SNAKE; empty leaves keys alone
+	numbers bool            // turn numeric strings into JSON numbers
+	keep    map[string]bool // fields never turned into numbers, by wordKey
+	times   string          // TIMES_RFC3339 or TIMES_EPOCH; empty leaves timestamps alone
+	stamps  map[string]bool // timestamp fields, by wordKey
+}
+
+// The shape for path, or nil when its responses pass through untouched
+func (s *server) shapeFor(path string) *responseShape {
+	shape := &responseShape{}
+	shape.keys, _ = ruleFor(s.cfg.KeyStyles, path)
+	if numbers, ok := ruleFor(s.cfg.NumberStyles, path); ok {
+		shape.numbers, _ = strconv.ParseBool(numbers)
+	}
+	shape.times, _ = ruleFor(s.cfg.TimeStyles, path)
+	if shape.keys == "" && !shape.numbers && shape.times == "" {
+		return nil
+	}
+	shape.keep = wordKeys(s.cfg.StringFields)
+	shape.stamps = wordKeys(s.cfg.TimestampFields)
+	return shape
+}
+
+// Key is the field v is the value of; empty inside arrays
+func (shape *responseShape) apply(key string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			out[shape.key(key)] = shape.apply(key, value)
+		}
+		return out
+	case []interface{}:
+		for i, item := range v {
+			v[i] = shape.apply(key, item)
+		}
+		return v
+	case json.Number:
+		if shape.times == TIMES_RFC3339 && shape.stamps[wordKey(key)] {
+			return shape.time(v.String())
+		}
+	case string:
+		if shape.times != "" && shape.stamps[wordKey(key)] {
+			v = shape.time(v)
+		}
+		if shape.numbers && !shape.keep[wordKey(key)] && exactNumber(v) {
+			return json.Number(v)
+		}
+		return v
+	}
+	return v
+}
+
+// Convert a timestamp to the shape's format; anything that isn't a
+// timestamp in the other format comes back unchanged
+func (shape *responseShape) time(v string) string {
+	switch shape.times {
+	case TIMES_RFC3339:
+		if ms, err := strconv.ParseInt(v, 10, 64); err == nil && ms > 0 {
+			return time.UnixMilli(ms).UTC().Format(RFC3339_MILLIS)
+		}
+	case TIMES_EPOCH:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return strconv.FormatInt(t.UnixMilli(), 10)
+		}
+	}
+	return v
+}
+
+// Whether s reads as a number that survives a float64 round trip, so a
+// JavaScript client gets exactly the value BloFin sent; longer IDs and
+// high-precision amounts stay strings
+func exactNumber(s string) bool {
+	if !jsonNumber.MatchString(s) {
+		return false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return false
+	}
+	want, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return false
+	}
+	got, ok := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64))
+	return ok && got.Cmp(want) == 0
+}
+
+func wordKeys(fields []string) map[string]bool {
+	keys := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		keys[wordKey(field)] = true
+	}
+	return keys
+}
+
+// A key's words run together, so orderId, order_id and ORDER_ID compare
+// equal
+func wordKey(key string) string {
+	return strings.Join(splitWords(key), "")
+}
+
+func (shape *responseShape) key(key string) string {
+	words := splitWords(key)
+	if len(words) == 0 {
+		return key
+	}
+	switch shape.keys {
+	case KEYS_CAMEL:
+		for i := range words {
+			if i > 0 {
+				words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+			}
+		}
+		return strings.Join(words, "")
+	case KEYS_SNAKE:
+		return strings.Join(words, "_")
+	}
+	return key
+}
+
+// Lowercase words of a camelCase, PascalCase, snake_case or kebab-case
+// key; a run of capitals is one word, so orderID and ORDER_ID both give
+// order, id
+func splitWords(key string) []string {
+	var words []string
+	var word []rune
+	runes := []rune(key)
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+	for i, r := range runes {
+		switch {
+		case r == '_' || r == '-' || r == ' ':
+			flush()
+			continue
+		case unicode.IsUpper(r) && i > 0:
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+		word = append(word, r)
+	}
+	flush()
+	return words
+}
+
+// Buffers a response so its JSON can be reshaped once it is complete
+type shapedWriter struct {
+	http.ResponseWriter
+	shape  *responseShape
+	status int
+	buf    bytes.Buffer
+}
+
+func newShapedWriter(w http.ResponseWriter, shape *responseShape) *shapedWriter {
+	return &shapedWriter{ResponseWriter: w, shape: shape, status: http.StatusOK}
+}
+
+func (w *shapedWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *shapedWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+// Flush is a no-op: nothing can be sent before the whole body is in
+func (w *shapedWriter) Flush() {}
+
+// Send the buffered response, reshaped if it is uncompressed JSON
+func (w *shapedWriter) finish() {
+	body := w.buf.Bytes()
+	header := w.ResponseWriter.Header()
+	if strings.Contains(header.Get("Content-Type"), "json") && header.Get("Content-Encoding") == "" {
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		var parsed interface{}
+		if err := dec.Decode(&parsed); err == nil {
+			if shaped, err := json.Marshal(w.shape.apply("", parsed)); err == nil {
+				body = shaped
+			}
+		}
+	}
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(body)
+}
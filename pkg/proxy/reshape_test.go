@@ -0,0 +1,161 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestResponseRules(t *testing.T) {
+	rules, err := parseResponseRules([]string{"snake", "/api/v1/market camel", "/api/v1/market/books snake"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{
+		"/api/v1/trade/order":        "snake",
+		"/api/v1/market/tickers":     "camel",
+		"/api/v1/market/books":       "snake",
+		"/api/v1/market/books/lite":  "snake",
+		"/api/v1/marketplace/offers": "camel", // prefixes are plain string prefixes
+	} {
+		if got, _ := ruleFor(rules, path); got != want {
+			t.Errorf("ruleFor(%s) = %q, want %q", path, got, want)
+		}
+	}
+	if _, ok := ruleFor([]ResponseRule{{Prefix: "/api/v1/trade", Value: "snake"}}, "/api/v1/market/tickers"); ok {
+		t.Error("rule applied outside its prefix")
+	}
+	if _, err := parseResponseRules([]string{"/api/v1/market"}); err == nil {
+		t.Error("prefix without a value accepted")
+	}
+}
+
+func TestKeyStyles(t *testing.T) {
+	for key, want := range map[string][2]string{
+		"instId":         {"instId", "inst_id"},
+		"inst_id":        {"instId", "inst_id"},
+		"ORDER_ID":       {"orderId", "order_id"},
+		"orderID":        {"orderId", "order_id"},
+		"HTTPStatusCode": {"httpStatusCode", "http_status_code"},
+		"fee-ccy":        {"feeCcy", "fee_ccy"},
+		"vol24h":         {"vol24h", "vol24h"},
+		"":               {"", ""},
+	} {
+		camel := (&responseShape{keys: KEYS_CAMEL}).key(key)
+		snake := (&responseShape{keys: KEYS_SNAKE}).key(key)
+		if camel != want[0] || snake != want[1] {
+			t.Errorf("key(%q) = %q, %q, want %q", key, camel, snake, want)
+		}
+	}
+}
+
+func TestReshapedResponses(t *testing.T) {
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"code":"0","msg":"","data":[{"instId":"BTC-USDT","bidPrice":"64250.1","ts":"1700000000000","nested":{"lastSize":"2"}}]}`)
+	})
+	p := newUpstreamProxy(t, upstream, "", func(cfg *Config) {
+		cfg.KeyStyles = []ResponseRule{{Prefix: "/api/v1/market", Value: KEYS_SNAKE}}
+	})
+	get := func(path string) string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Length") != "" && rec.Header().Get("Content-Length") != strconv.Itoa(rec.Body.Len()) {
+			t.Errorf("%s = %d, Content-Length %s for %d bytes", path, rec.Code, rec.Header().Get("Content-Length"), rec.Body.Len())
+		}
+		return rec.Body.String()
+	}
+
+	if got := get("/api/v1/market/tickers"); !strings.Contains(got, `"inst_id":"BTC-USDT"`) || !strings.Contains(got, `"bid_price":"64250.1"`) || !strings.Contains(got, `"last_size":"2"`) {
+		t.Errorf("snake_case response = %s", got)
+	}
+	// Routes without a rule pass through untouched
+	if got := get("/api/v1/public/instruments"); !strings.Contains(got, `"instId":"BTC-USDT"`) {
+		t.Errorf("untouched response = %s", got)
+	}
+}
+
+func TestExactNumber(t *testing.T) {
+	for s, want := range map[string]bool{
+		"0":                      true,
+		"-1.5":                   true,
+		"64250.1":                true,
+		"1e-8":                   true,
+		"0.000123":               true,
+		"9007199254740993":       false, // past float64's exact integers
+		"0.10000000000000000001": false,
+		"01":                     false,
+		".5":                     false,
+		"1.":                     false,
+		"NaN":                    false,
+		"":                       false,
+		"BTC-USDT":               false,
+	} {
+		if got := exactNumber(s); got != want {
+			t.Errorf("exactNumber(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestNumberStyles(t *testing.T) {
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"code":"0","msg":"","data":[{"instId":"BTC-USDT","orderId":"1234","last":"64250.1","size":"12345678901234567890","state":"live","ts":1700000000000}]}`)
+	})
+	p := newUpstreamProxy(t, upstream, "", func(cfg *Config) {
+		cfg.NumberStyles = []ResponseRule{{Prefix: "/", Value: "true"}, {Prefix: "/api/v1/public", Value: "false"}}
+		cfg.KeyStyles = []ResponseRule{{Prefix: "/api/v1/market", Value: KEYS_SNAKE}}
+	})
+	get := func(path string) string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Body.String()
+	}
+
+	want := `{"code":"0","data":[{"inst_id":"BTC-USDT","last":64250.1,"order_id":"1234","size":"12345678901234567890","state":"live","ts":1700000000000}],"msg":""}`
+	if got := get("/api/v1/market/tickers"); got != want {
+		t.Errorf("numbers = %s\nwant %s", got, want)
+	}
+	if got := get("/api/v1/public/instruments"); !strings.Contains(got, `"last":"64250.1"`) {
+		t.Errorf("numbers turned off = %s", got)
+	}
+}
+
+func TestTimeStyles(t *testing.T) {
+	stamps := wordKeys(strings.Split(DEFAULT_TIMESTAMP_FIELDS, ","))
+	rfc := &responseShape{times: TIMES_RFC3339, stamps: stamps}
+	epoch := &responseShape{times: TIMES_EPOCH, stamps: stamps}
+	for _, tt := range []struct {
+		shape *responseShape
+		key   string
+		in    interface{}
+		want  interface{}
+	}{
+		{rfc, "ts", "1700000000123", "2023-11-14T22:13:20.123Z"},
+		{rfc, "c_time", json.Number("1700000000000"), "2023-11-14T22:13:20.000Z"},
+		{rfc, "orderId", "1700000000000", "1700000000000"}, // not a timestamp field
+		{rfc, "ts", "", ""},
+		{rfc, "ts", "0", "0"},
+		{epoch, "uTime", "2023-11-14T22:13:20.123Z", "1700000000123"},
+		{epoch, "uTime", "2023-11-15T00:13:20+02:00", "1700000000000"},
+		{epoch, "ts", "1700000000000", "1700000000000"},
+		{epoch, "ts", json.Number("1700000000000"), json.Number("1700000000000")},
+	} {
+		if got := tt.shape.apply(tt.key, tt.in); got != tt.want {
+			t.Errorf("%s apply(%s, %v) = %v, want %v", tt.shape.times, tt.key, tt.in, got, tt.want)
+		}
+	}
+
+	// Timestamps are converted before numbers are parsed, so an epoch
+	// conversion still comes out as a number
+	both := &responseShape{times: TIMES_EPOCH, stamps: stamps, numbers: true}
+	if got := both.apply("ts", "2023-11-14T22:13:20.123Z"); got != json.Number("1700000000123") {
+		t.Errorf("epoch with numbers = %#v", got)
+	}
+}
@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// BloFin's error envelopes are small; longer bodies aren't inspected
+const MAX_TIMESTAMP_ERROR_BODY = 2048
+
+// Local time corrected by the offset to BloFin's clock, used for the
+// ACCESS-TIMESTAMP of requests the proxy signs. The offset is learned
+// from the Date header of responses rejecting a timestamp.
+type signingClock struct {
+	offset  atomic.Int64 // milliseconds
+	metrics *metricsRegistry
+}
+
+func newSigningClock(metrics *metricsRegistry) *signingClock {
+	metrics.register("blofin_proxy_resigned_total", METRIC_COUNTER, "Signed requests BloFin rejected for their timestamp and that were re-signed and retried, by result")
+	return &signingClock{metrics: metrics}
+}
+
+func (c *signingClock) now() time.Time {
+	return time.Now().Add(time.Duration(c.offset.Load()) * time.Millisecond)
+}
+
+// Called with each response to a request the proxy signed. Reports
+// whether to sign it again and retry: only the first time, and only when
+// BloFin rejected its timestamp. The retry's outcome goes to metrics.
+func (c *signingClock) resign(header http.Header, body []byte, retried bool) bool {
+	rejected := isTimestampError(body)
+	if retried {
+		result := "ok"
+		if rejected {
+			result = "rejected"
+		}
+		c.metrics.add("blofin_proxy_resigned_total", labels("result", result), 1)
+		return false
+	}
+	if rejected {
+		c.correct(header)
+	}
+	return rejected
+}
+
+// Adopt the offset implied by a response's Date header. It only has
+// whole seconds, so the middle of that second is assumed.
+func (c *signingClock) correct(header http.Header) {
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		log.Printf("🕰️ BloFin rejected a request's timestamp; retrying with a fresh one")
+		return
+	}
+	offset := date.Add(500 * time.Millisecond).Sub(time.Now()).Round(time.Millisecond)
+	c.offset.Store(offset.Milliseconds())
+	log.Printf("🕰️ BloFin rejected a request's timestamp; signing %s off local time from now on", offset)
+}
+
+// Whether body is a BloFin error about the ACCESS-TIMESTAMP header:
+// expired, too far ahead or malformed
+func isTimestampError(body []byte) bool {
+	var envelope blofinResponse
+	if len(body) == 0 || json.Unmarshal(body, &envelope) != nil {
+		return false
+	}
+	return envelope.Code != "" && envelope.Code != "0" && strings.Contains(strings.ToLower(envelope.Msg), "timestamp")
+}
+
+// The whole body of a short uncompressed response, nil for others. The
+// body is put back for whoever reads it next.
+func peekBody(resp *http.Response) []byte {
+	if resp.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+	head := make([]byte, MAX_TIMESTAMP_ERROR_BODY+1)
+	n, err := io.ReadFull(resp.Body, head)
+	head = head[:n]
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), resp.Body), resp.Body}
+	if err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil
+	}
+	return head
+}
@@ -0,0 +1,296 @@
+package proxy
+
+import "strings"
+
+// Known BloFin REST endpoints. The proxy forwards any /api/* path, but this
+// table drives the OpenAPI document and anything else that needs to know
+// what a route expects.
+type apiRoute struct {
+	Method    string
+	Path      string
+	Tag       string
+	Summary   string
+	Private   bool // requires ACCESS-* signature headers
+	Admin     bool // requires the admin token
+	Query     []apiParam
+	Body      []apiParam
+	BodyArray bool // body is a JSON array of objects shaped like Body
+}
+
+type apiParam struct {
+	Name        string
+	Type        string // string, integer, boolean
+	Required    bool
+	Description string
+}
+
+// Headers understood by the proxy itself, on top of the BloFin ones
+type proxyHeader struct {
+	Name        string
+	Description string
+	Paths       []string // routes the header applies to, prefixes when ending in /; empty means all
+}
+
+var proxyHeaders = []proxyHeader{
+	{
+		Name:        "X-Dry-Run",
+		Description: "When true, the order is validated against the instrument specification and a synthesized success response is returned without contacting BloFin",
+		Paths:       []string{"/api/v1/trade/order", "/api/v1/trade/batch-orders", "/api/v1/copytrading/trade/place-order"},
+	},
+	{
+		Name:        TENANT_HEADER,
+		Description: "Token of a tenant configured in TENANTS_FILE; the proxy signs the request with that tenant's BloFin credentials",
+		Paths:       []string{"/api/", "/unified/", "/binance/", "/helpers/", "/orders/watch", "/orders/watch/", "/sse/orders", "/local/affiliate/invitees", "/utils/key-check"},
+	},
+	{
+		Name:        DEBUG_HEADER,
+		Description: "When true and sent with the admin token, the request as sent to BloFin and its response are logged in full, with secrets redacted",
+		Paths:       []string{"/api/"},
+	},
+	{
+		Name:        TIMEOUT_HEADER,
+		Description: "Milliseconds the proxy may spend on the request, capped at MAX_CLIENT_TIMEOUT; a 504 is returned once it runs out",
+		Paths:       blofinBoundPrefixes,
+	},
+	{
+		Name:        "traceparent",
+		Description: "W3C Trace Context, passed through to BloFin and echoed on the response (tracestate too)",
+	},
+	{
+		Name:        "b3",
+		Description: "Zipkin B3 trace context, passed through to BloFin and echoed on the response (X-B3-* headers too)",
+	},
+}
+
+func (h proxyHeader) appliesTo(path string) bool {
+	if len(h.Paths) == 0 {
+		return true
+	}
+	for _, p := range h.Paths {
+		if p == path || (strings.HasSuffix(p, "/") && strings.HasPrefix(path, p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// BloFin authentication headers forwarded untouched on private routes
+var blofinAuthHeaders = []string{
+	"ACCESS-KEY",
+	"ACCESS-SIGN",
+	"ACCESS-TIMESTAMP",
+	"ACCESS-NONCE",
+	"ACCESS-PASSPHRASE",
+}
+
+var (
+	instIdParam   = apiParam{Name: "instId", Type: "string", Description: "Instrument ID, e.g. BTC-USDT"}
+	instIdReq     = apiParam{Name: "instId", Type: "string", Required: true, Description: "Instrument ID, e.g. BTC-USDT"}
+	afterParam    = apiParam{Name: "after", Type: "string", Description: "Pagination: return records earlier than this ID/timestamp"}
+	beforeParam   = apiParam{Name: "before", Type: "string", Description: "Pagination: return records newer than this ID/timestamp"}
+	limitParam    = apiParam{Name: "limit", Type: "string", Description: "Number of results per request"}
+	marginMode    = apiParam{Name: "marginMode", Type: "string", Required: true, Description: "cross or isolated"}
+	positionSide  = apiParam{Name: "positionSide", Type: "string", Description: "net, long or short"}
+	clientOrderId = apiParam{Name: "clientOrderId", Type: "string", Description: "Client-supplied order ID"}
+	brokerId      = apiParam{Name: "brokerId", Type: "string", Description: "Broker ID provided by BloFin"}
+)
+
+var orderBody = []apiParam{
+	instIdReq,
+	marginMode,
+	positionSide,
+	{Name: "side", Type: "string", Required: true, Description: "buy or sell"},
+	{Name: "orderType", Type: "string", Required: true, Description: "market, limit, post_only, fok, ioc"},
+	{Name: "price", Type: "string", Description: "Order price, required for non-market orders"},
+	{Name: "size", Type: "string", Required: true, Description: "Number of contracts"},
+	{Name: "reduceOnly", Type: "string", Description: "true or false"},
+	clientOrderId,
+	{Name: "tpTriggerPrice", Type: "string", Description: "Take-profit trigger price"},
+	{Name: "tpOrderPrice", Type: "string", Description: "Take-profit order price, -1 for market"},
+	{Name: "slTriggerPrice", Type: "string", Description: "Stop-loss trigger price"},
+	{Name: "slOrderPrice", Type: "string", Description: "Stop-loss order price, -1 for market"},
+	brokerId,
+}
+
+var tpslBody = []apiParam{
+	instIdReq,
+	marginMode,
+	positionSide,
+	{Name: "side", Type: "string", Required: true, Description: "buy or sell"},
+	{Name: "tpTriggerPrice", Type: "string", Description: "Take-profit trigger price"},
+	{Name: "tpOrderPrice", Type: "string", Description: "Take-profit order price, -1 for market"},
+	{Name: "slTriggerPrice", Type: "string", Description: "Stop-loss trigger price"},
+	{Name: "slOrderPrice", Type: "string", Description: "Stop-loss order price, -1 for market"},
+	{Name: "size", Type: "string", Required: true, Description: "Number of contracts, -1 for the entire position"},
+	{Name: "reduceOnly", Type: "string", Description: "true or false"},
+	clientOrderId,
+	brokerId,
+}
+
+var cancelBody = []apiParam{
+	{Name: "orderId", Type: "string", Description: "Order ID"},
+	instIdParam,
+	clientOrderId,
+}
+
+var orderListQuery = []apiParam{
+	instIdParam,
+	{Name: "orderType", Type: "string", Description: "Order type filter"},
+	{Name: "state", Type: "string", Description: "Order state filter"},
+	afterParam,
+	beforeParam,
+	limitParam,
+}
+
+var blofinRoutes = []apiRoute{
+	// Public market data
+	{Method: "GET", Path: "/api/v1/market/instruments", Tag: "Market", Summary: "Get instruments",
+		Query: []apiParam{instIdParam}},
+	{Method: "GET", Path: "/api/v1/market/tickers", Tag: "Market", Summary: "Get tickers",
+		Query: []apiParam{instIdParam}},
+	{Method: "GET", Path: "/api/v1/market/books", Tag: "Market", Summary: "Get order book",
+		Query: []apiParam{instIdReq, {Name: "size", Type: "string", Description: "Order book depth per side"}}},
+	{Method: "GET", Path: "/api/v1/market/trades", Tag: "Market", Summary: "Get recent trades",
+		Query: []apiParam{instIdReq, limitParam}},
+	{Method: "GET", Path: "/api/v1/market/mark-price", Tag: "Market", Summary: "Get mark and index price",
+		Query: []apiParam{instIdParam}},
+	{Method: "GET", Path: "/api/v1/market/funding-rate", Tag: "Market", Summary: "Get current funding rate",
+		Query: []apiParam{instIdParam}},
+	{Method: "GET", Path: "/api/v1/market/funding-rate-history", Tag: "Market", Summary: "Get funding rate history",
+		Query: []apiParam{instIdReq, beforeParam, afterParam, limitParam}},
+	{Method: "GET", Path: "/api/v1/market/candles", Tag: "Market", Summary: "Get candlesticks",
+		Query: []apiParam{instIdReq, {Name: "bar", Type: "string", Description: "Bar size, e.g. 1m, 1H, 1D"}, afterParam, beforeParam, limitParam}},
+
+	// Asset and account
+	{Method: "GET", Path: "/api/v1/asset/balances", Tag: "Account", Summary: "Get asset balances", Private: true,
+		Query: []apiParam{{Name: "accountType", Type: "string", Required: true, Description: "funding, futures, copy_trading or spot"}, {Name: "currency", Type: "string", Description: "Currency filter"}}},
+	{Method: "POST", Path: "/api/v1/asset/transfer", Tag: "Account", Summary: "Transfer funds between accounts", Private: true,
+		Body: []apiParam{
+			{Name: "currency", Type: "string", Required: true, Description: "Currency"},
+			{Name: "amount", Type: "string", Required: true, Description: "Amount to transfer"},
+			{Name: "fromAccount", Type: "string", Required: true, Description: "Source account type"},
+			{Name: "toAccount", Type: "string", Required: true, Description: "Destination account type"},
+			{Name: "clientId", Type: "string", Description: "Client-supplied transfer ID"},
+		}},
+	{Method: "GET", Path: "/api/v1/asset/bills", Tag: "Account", Summary: "Get funds transfer history", Private: true,
+		Query: []apiParam{{Name: "currency", Type: "string", Description: "Currency filter"}, {Name: "fromAccount", Type: "string", Description: "Source account type"}, {Name: "toAccount", Type: "string", Description: "Destination account type"}, beforeParam, afterParam, limitParam}},
+	{Method: "GET", Path: "/api/v1/asset/withdrawal-history", Tag: "Account", Summary: "Get withdrawal history", Private: true,
+		Query: []apiParam{{Name: "currency", Type: "string", Description: "Currency filter"}, {Name: "withdrawId", Type: "string", Description: "Withdrawal ID"}, {Name: "txId", Type: "string", Description: "Transaction hash"}, {Name: "state", Type: "string", Description: "Withdrawal state"}, beforeParam, afterParam, limitParam}},
+	{Method: "GET", Path: "/api/v1/asset/deposit-history", Tag: "Account", Summary: "Get deposit history", Private: true,
+		Query: []apiParam{{Name: "currency", Type: "string", Description: "Currency filter"}, {Name: "depositId", Type: "string", Description: "Deposit ID"}, {Name: "txId", Type: "string", Description: "Transaction hash"}, {Name: "state", Type: "string", Description: "Deposit state"}, beforeParam, afterParam, limitParam}},
+	{Method: "GET", Path: "/api/v1/account/balance", Tag: "Account", Summary: "Get futures account balance", Private: true},
+	{Method: "GET", Path: "/api/v1/account/positions", Tag: "Account", Summary: "Get open positions", Private: true,
+		Query: []apiParam{instIdParam}},
+	{Method: "GET", Path: "/api/v1/account/margin-mode", Tag: "Account", Summary: "Get margin mode", Private: true},
+	{Method: "POST", Path: "/api/v1/account/set-margin-mode", Tag: "Account", Summary: "Set margin mode", Private: true,
+		Body: []apiParam{marginMode}},
+	{Method: "GET", Path: "/api/v1/account/position-mode", Tag: "Account", Summary: "Get position mode", Private: true},
+	{Method: "POST", Path: "/api/v1/account/set-position-mode", Tag: "Account", Summary: "Set position mode", Private: true,
+		Body: []apiParam{{Name: "positionMode", Type: "string", Required: true, Description: "net_mode or long_short_mode"}}},
+	{Method: "GET", Path: "/api/v1/account/batch-leverage-info", Tag: "Account", Summary: "Get leverage for instruments", Private: true,
+		Query: []apiParam{{Name: "instId", Type: "string", Required: true, Description: "Comma-separated instrument IDs"}, marginMode}},
+	{Method: "POST", Path: "/api/v1/account/set-leverage", Tag: "Account", Summary: "Set leverage", Private: true,
+		Body: []apiParam{instIdReq, {Name: "leverage", Type: "string", Required: true, Description: "Leverage"}, marginMode, positionSide}},
+	{Method: "GET", Path: "/api/v1/user/query-apikey", Tag: "Account", Summary: "Get API key information", Private: true},
+
+	// Trading
+	{Method: "POST", Path: "/api/v1/trade/order", Tag: "Trading", Summary: "Place order", Private: true,
+		Body: orderBody},
+	{Method: "POST", Path: "/api/v1/trade/batch-orders", Tag: "Trading", Summary: "Place multiple orders", Private: true,
+		Body: orderBody, BodyArray: true},
+	{Method: "POST", Path: "/api/v1/trade/order-tpsl", Tag: "Trading", Summary: "Place TP/SL order", Private: true,
+		Body: tpslBody},
+	{Method: "POST", Path: "/api/v1/trade/cancel-order", Tag: "Trading", Summary: "Cancel order", Private: true,
+		Body: cancelBody},
+	{Method: "POST", Path: "/api/v1/trade/cancel-batch-orders", Tag: "Trading", Summary: "Cancel multiple orders", Private: true,
+		Body: cancelBody, BodyArray: true},
+	{Method: "POST", Path: "/api/v1/trade/cancel-tpsl", Tag: "Trading", Summary: "Cancel TP/SL orders", Private: true,
+		Body: []apiParam{instIdParam, {Name: "tpslId", Type: "string", Description: "TP/SL order ID"}, clientOrderId}, BodyArray: true},
+	{Method: "POST", Path: "/api/v1/trade/close-position", Tag: "Trading", Summary: "Close position", Private: true,
+		Body: []apiParam{instIdReq, marginMode, positionSide, clientOrderId, brokerId}},
+	{Method: "GET", Path: "/api/v1/trade/orders-pending", Tag: "Trading", Summary: "Get active orders", Private: true,
+		Query: orderListQuery},
+	{Method: "GET", Path: "/api/v1/trade/orders-tpsl-pending", Tag: "Trading", Summary: "Get active TP/SL orders", Private: true,
+		Query: []apiParam{instIdParam, {Name: "tpslId", Type: "string", Description: "TP/SL order ID"}, clientOrderId, afterParam, beforeParam, limitParam}},
+	{Method: "GET", Path: "/api/v1/trade/order-detail", Tag: "Trading", Summary: "Get order detail", Private: true,
+		Query: []apiParam{instIdReq, {Name: "orderId", Type: "string", Description: "Order ID"}, clientOrderId}},
+	{Method: "GET", Path: "/api/v1/trade/orders-history", Tag: "Trading", Summary: "Get order history", Private: true,
+		Query: orderListQuery},
+	{Method: "GET", Path: "/api/v1/trade/orders-tpsl-history", Tag: "Trading", Summary: "Get TP/SL order history", Private: true,
+		Query: []apiParam{instIdParam, {Name: "tpslId", Type: "string", Description: "TP/SL order ID"}, clientOrderId, {Name: "state", Type: "string", Description: "Order state filter"}, afterParam, beforeParam, limitParam}},
+	{Method: "GET", Path: "/api/v1/trade/fills-history", Tag: "Trading", Summary: "Get trade fills", Private: true,
+		Query: []apiParam{instIdParam, {Name: "orderId", Type: "string", Description: "Order ID"}, afterParam, beforeParam, {Name: "begin", Type: "string", Description: "Start timestamp (ms)"}, {Name: "end", Type: "string", Description: "End timestamp (ms)"}, limitParam}},
+	{Method: "GET", Path: "/api/v1/trade/order-price-range", Tag: "Trading", Summary: "Get order price limits", Private: true,
+		Query: []apiParam{instIdReq, {Name: "side", Type: "string", Required: true, Description: "buy or sell"}}},
+
+	// Copy trading, for lead traders
+	{Method: "GET", Path: "/api/v1/copytrading/instruments", Tag: "Copy trading", Summary: "Get instruments enabled for copy trading", Private: true},
+	{Method: "GET", Path: "/api/v1/copytrading/config", Tag: "Copy trading", Summary: "Get copy trading account configuration", Private: true},
+	{Method: "GET", Path: "/api/v1/copytrading/account/balance", Tag: "Copy trading", Summary: "Get copy trading account balance", Private: true},
+	{Method: "GET", Path: "/api/v1/copytrading/account/positions-by-order", Tag: "Copy trading", Summary: "Get open positions by lead order", Private: true,
+		Query: []apiParam{instIdParam, {Name: "orderId", Type: "string", Description: "Lead order ID"}, afterParam, beforeParam, limitParam}},
+	{Method: "GET", Path: "/api/v1/copytrading/account/positions-by-contract", Tag: "Copy trading", Summary: "Get open positions by contract", Private: true,
+		Query: []apiParam{instIdParam}},
+	{Method: "GET", Path: "/api/v1/copytrading/account/position-mode", Tag: "Copy trading", Summary: "Get copy trading position mode", Private: true},
+	{Method: "GET", Path: "/api/v1/copytrading/account/leverage-info", Tag: "Copy trading", Summary: "Get copy trading leverage", Private: true,
+		Query: []apiParam{instIdReq, marginMode}},
+	{Method: "POST", Path: "/api/v1/copytrading/account/set-leverage", Tag: "Copy trading", Summary: "Set copy trading leverage", Private: true,
+		Body: []apiParam{instIdReq, {Name: "leverage", Type: "string", Required: true, Description: "Leverage"}, marginMode, positionSide}},
+	{Method: "POST", Path: "/api/v1/copytrading/trade/place-order", Tag: "Copy trading", Summary: "Place lead order", Private: true,
+		Body: []apiParam{
+			instIdReq,
+			marginMode,
+			positionSide,
+			{Name: "side", Type: "string", Required: true, Description: "buy or sell"},
+			{Name: "orderType", Type: "string", Required: true, Description: "market, limit, post_only, fok, ioc"},
+			{Name: "price", Type: "string", Description: "Order price, required for non-market orders"},
+			{Name: "size", Type: "string", Required: true, Description: "Number of contracts"},
+			{Name: "reduceOnly", Type: "string", Description: "true or false"},
+			brokerId,
+		}},
+	{Method: "POST", Path: "/api/v1/copytrading/trade/cancel-order", Tag: "Copy trading", Summary: "Cancel lead order", Private: true,
+		Body: []apiParam{{Name: "orderId", Type: "string", Required: true, Description: "Order ID"}}},
+	{Method: "POST", Path: "/api/v1/copytrading/trade/close-position-by-order", Tag: "Copy trading", Summary: "Close a position opened by a lead order", Private: true,
+		Body: []apiParam{instIdReq, {Name: "orderId", Type: "string", Required: true, Description: "Lead order ID"}, {Name: "size", Type: "string", Description: "Contracts to close, all when omitted"}, brokerId}},
+	{Method: "POST", Path: "/api/v1/copytrading/trade/close-position-by-contract", Tag: "Copy trading", Summary: "Close every position in a contract", Private: true,
+		Body: []apiParam{instIdReq, marginMode, positionSide, {Name: "closeType", Type: "string", Required: true, Description: "fixedRatio or all"}, {Name: "size", Type: "string", Description: "Contracts to close"}, brokerId}},
+	{Method: "GET", Path: "/api/v1/copytrading/trade/orders-pending", Tag: "Copy trading", Summary: "Get active lead orders", Private: true,
+		Query: orderListQuery},
+	{Method: "GET", Path: "/api/v1/copytrading/trade/orders-history", Tag: "Copy trading", Summary: "Get lead order history", Private: true,
+		Query: orderListQuery},
+	{Method: "GET", Path: "/api/v1/copytrading/trade/position-history-by-order", Tag: "Copy trading", Summary: "Get closed positions by lead order", Private: true,
+		Query: []apiParam{instIdParam, {Name: "orderId", Type: "string", Description: "Lead order ID"}, afterParam, beforeParam, limitParam}},
+
+	// Affiliate program
+	{Method: "GET", Path: "/api/v1/affiliate/basic", Tag: "Affiliate", Summary: "Get affiliate account summary", Private: true},
+	{Method: "GET", Path: "/api/v1/affiliate/referral-code", Tag: "Affiliate", Summary: "Get referral codes", Private: true},
+	{Method: "GET", Path: "/api/v1/affiliate/invitees", Tag: "Affiliate", Summary: "Get direct invitees", Private: true,
+		Query: []apiParam{{Name: "uid", Type: "string", Description: "Invitee UID"}, afterParam, beforeParam, {Name: "begin", Type: "string", Description: "Registered at or after (ms)"}, {Name: "end", Type: "string", Description: "Registered at or before (ms)"}, limitParam}},
+	{Method: "GET", Path: "/api/v1/affiliate/sub-invitees", Tag: "Affiliate", Summary: "Get invitees of sub-affiliates", Private: true,
+		Query: []apiParam{{Name: "uid", Type: "string", Description: "Invitee UID"}, afterParam, beforeParam, limitParam}},
+	{Method: "GET", Path: "/api/v1/affiliate/sub-affiliates", Tag: "Affiliate", Summary: "Get sub-affiliates", Private: true,
+		Query: []apiParam{{Name: "uid", Type: "string", Description: "Sub-affiliate UID"}, afterParam, beforeParam, limitParam}},
+	{Method: "GET", Path: "/api/v1/affiliate/invitees/daily", Tag: "Affiliate", Summary: "Get daily commission and volume of invitees", Private: true,
+		Query: []apiParam{{Name: "uid", Type: "string", Description: "Invitee UID"}, {Name: "begin", Type: "string", Description: "Start timestamp (ms)"}, {Name: "end", Type: "string", Description: "End timestamp (ms)"}, afterParam, beforeParam, limitParam}},
+}
+
+// Find the route table entry for a method and path
+func findRoute(method, path string) *apiRoute {
+	for i := range blofinRoutes {
+		if blofinRoutes[i].Method == method && blofinRoutes[i].Path == path {
+			return &blofinRoutes[i]
+		}
+	}
+	return nil
+}
+
+// Methods the route table knows for path
+func routeMethods(path string) []string {
+	var methods []string
+	for _, route := range blofinRoutes {
+		if route.Path == path {
+			methods = append(methods, route.Method)
+		}
+	}
+	return methods
+}
@@ -0,0 +1,313 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// When a job should run: either a fixed interval (@every) or a five-field
+// cron expression evaluated in UTC
+type schedule struct {
+	spec     string
+	interval time.Duration
+	fields   [5]map[int]bool // minute, hour, day of month, month, day of week
+	domStar  bool
+	dowStar  bool
+}
+
+var cronFieldRanges = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+
+var cronAliases = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+func parseSchedule(spec string) (*schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || interval < time.Second {
+			return nil, fmt.Errorf("invalid interval in %q", spec)
+		}
+		return &schedule{spec: spec, interval: interval}, nil
+	}
+	expr := spec
+	if alias, ok := cronAliases[spec]; ok {
+		expr = alias
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields", spec)
+	}
+	s := &schedule{spec: spec, domStar: parts[2] == "*", dowStar: parts[4] == "*"}
+	for i, part := range parts {
+		values, err := parseCronField(part, cronFieldRanges[i][0], cronFieldRanges[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %v", spec, err)
+		}
+		s.fields[i] = values
+	}
+	// Sunday may be written as 7
+	if s.fields[4][7] {
+		s.fields[4][0] = true
+	}
+	return s, nil
+}
+
+// Parse one field: *, */n, a, a-b, a-b/n and comma-separated lists of those
+func parseCronField(field string, low, high int) (map[int]bool, error) {
+	values := map[int]bool{}
+	for _, item := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid step in %q", item)
+			}
+			step = n
+		}
+		start, end := low, high
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if start, err = strconv.Atoi(from); err != nil {
+				return nil, fmt.Errorf("invalid value %q", item)
+			}
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(to); err != nil {
+					return nil, fmt.Errorf("invalid range %q", item)
+				}
+			} else if hasStep {
+				end = high
+			}
+		}
+		// Day of week accepts 7 as an alias for Sunday
+		maxValue := high
+		if low == 0 && high == 6 {
+			maxValue = 7
+		}
+		if start < low || end > maxValue || start > end {
+			return nil, fmt.Errorf("%q is outside %d-%d", item, low, high)
+		}
+		for v := start; v <= end; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+// First run time strictly after t
+func (s *schedule) next(t time.Time) time.Time {
+	if s.interval > 0 {
+		return t.Add(s.interval)
+	}
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !s.fields[3][int(t.Month())] || !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.fields[1][t.Hour()] {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if !s.fields[0][t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return limit
+}
+
+// Standard cron rule: when both day fields are restricted, either may match
+func (s *schedule) dayMatches(t time.Time) bool {
+	dom := s.fields[2][t.Day()]
+	dow := s.fields[4][int(t.Weekday())]
+	switch {
+	case s.domStar && s.dowStar:
+		return true
+	case s.domStar:
+		return dow
+	case s.dowStar:
+		return dom
+	}
+	return dom || dow
+}
+
+// Work the scheduler knows how to run, by name
+type jobFunc func(ctx context.Context) error
+
+// Jobs that can be named in SCHEDULE
+func (s *server) scheduledJobs() map[string]jobFunc {
+	return map[string]jobFunc{
+		"refresh-instruments": func(ctx context.Context) error {
+			return s.instruments.refresh()
+		},
+		"backfill-candles": func(ctx context.Context) error {
+			if len(s.backfill.targets) == 0 {
+				return fmt.Errorf("no targets configured, set BACKFILL_TARGETS")
+			}
+			if !s.backfill.start(s.backfill.targets, s.backfill.lookback) {
+				return fmt.Errorf("a backfill is already running")
+			}
+			return nil
+		},
+		"prune-exports": func(ctx context.Context) error {
+			s.exports.prune()
+			return nil
+		},
+		"snapshot-affiliates": s.affiliates.snapshot,
+		"sync-fills":          s.fills.sync,
+	}
+}
+
+// Status of one scheduled job, as reported by /admin/jobs
+type jobStatus struct {
+	Name       string `json:"name"`
+	Schedule   string `json:"schedule"`
+	Running    bool   `json:"running"`
+	NextRun    string `json:"nextRun,omitempty"`
+	LastRun    string `json:"lastRun,omitempty"`
+	LastResult string `json:"lastResult,omitempty"`
+	LastError  string `json:"lastError,omitempty"`
+}
+
+type scheduledJob struct {
+	name     string
+	schedule *schedule
+	run      jobFunc
+
+	mu     sync.Mutex
+	status jobStatus
+}
+
+// Small in-process cron: each configured job runs on its own schedule and
+// is skipped rather than overlapped if the previous run is still going
+type scheduler struct {
+	jobs []*scheduledJob
+}
+
+// Build the scheduler from "name=spec" entries against the available jobs
+func newScheduler(entries []string, available map[string]jobFunc) (*scheduler, error) {
+	s := &scheduler{}
+	for _, entry := range entries {
+		name, spec, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("schedule entry %q must look like name=spec", entry)
+		}
+		run, ok := available[name]
+		if !ok {
+			names := make([]string, 0, len(available))
+			for n := range available {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown job %q (available: %s)", name, strings.Join(names, ", "))
+		}
+		sched, err := parseSchedule(spec)
+		if err != nil {
+			return nil, err
+		}
+		s.jobs = append(s.jobs, &scheduledJob{
+			name:     name,
+			schedule: sched,
+			run:      run,
+			status:   jobStatus{Name: name, Schedule: sched.spec},
+		})
+	}
+	return s, nil
+}
+
+func (s *scheduler) start() {
+	for _, job := range s.jobs {
+		go job.loop()
+		log.Printf("⏰ Scheduled job %s (%s)", job.name, job.schedule.spec)
+	}
+}
+
+func (j *scheduledJob) loop() {
+	for {
+		next := j.schedule.next(time.Now())
+		j.mu.Lock()
+		j.status.NextRun = next.UTC().Format(time.RFC3339)
+		j.mu.Unlock()
+		time.Sleep(time.Until(next))
+		j.trigger()
+	}
+}
+
+// Run the job in the background unless it is already running
+func (j *scheduledJob) trigger() bool {
+	j.mu.Lock()
+	if j.status.Running {
+		j.mu.Unlock()
+		log.Printf("⏰ Job %s still running, skipping this run", j.name)
+		return false
+	}
+	j.status.Running = true
+	j.status.LastRun = time.Now().UTC().Format(time.RFC3339)
+	j.mu.Unlock()
+
+	go func() {
+		started := time.Now()
+		err := j.run(context.Background())
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.status.Running = false
+		if err != nil {
+			j.status.LastResult, j.status.LastError = "failed", err.Error()
+			log.Printf("❌ Job %s failed: %v", j.name, err)
+			return
+		}
+		j.status.LastResult, j.status.LastError = "ok", ""
+		log.Printf("⏰ Job %s finished in %s", j.name, time.Since(started).Round(time.Millisecond))
+	}()
+	return true
+}
+
+// GET /admin/jobs lists jobs; POST /admin/jobs/{name} runs one now
+func (s *scheduler) handleAdmin(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/jobs"), "/")
+	switch {
+	case name == "" && r.Method == http.MethodGet:
+		statuses := []jobStatus{}
+		for _, job := range s.jobs {
+			job.mu.Lock()
+			statuses = append(statuses, job.status)
+			job.mu.Unlock()
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"jobs": statuses})
+	case name != "" && r.Method == http.MethodPost:
+		for _, job := range s.jobs {
+			if job.name == name {
+				if !job.trigger() {
+					writeJSON(w, http.StatusConflict, map[string]string{"error": "Job is already running"})
+					return
+				}
+				writeJSON(w, http.StatusAccepted, map[string]string{"job": name, "status": "started"})
+				return
+			}
+		}
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Unknown or unscheduled job " + name})
+	case name == "":
+		methodNotAllowed(w, "GET")
+	default:
+		methodNotAllowed(w, "POST")
+	}
+}
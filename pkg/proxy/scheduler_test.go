@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2024, 5, 15, 10, 30, 20, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"@every 90s", "2024-05-15T10:31:50Z"},
+		{"* * * * *", "2024-05-15T10:31:00Z"},
+		{"*/15 * * * *", "2024-05-15T10:45:00Z"},
+		{"0 * * * *", "2024-05-15T11:00:00Z"},
+		{"@daily", "2024-05-16T00:00:00Z"},
+		{"30 9-17/4 * * *", "2024-05-15T13:30:00Z"},
+		{"0 0 * * 7", "2024-05-19T00:00:00Z"},   // Sunday as 7
+		{"0 0 * * 1,5", "2024-05-17T00:00:00Z"}, // next Friday
+		{"0 0 1 * *", "2024-06-01T00:00:00Z"},   // @monthly
+		{"0 0 31 * *", "2024-05-31T00:00:00Z"},
+		{"0 0 13 * 5", "2024-05-17T00:00:00Z"},     // either day field matches
+		{"0 12 29 2 *", "2028-02-29T12:00:00Z"},    // leap day, years ahead
+		{"15 10 15 5 *", "2025-05-15T10:15:00Z"},   // just missed this year
+		{"30 10 * * *", "2024-05-16T10:30:00Z"},    // strictly after
+		{"5,10 0 1 1 *", "2025-01-01T00:05:00Z"},   // lists
+		{"0-5/5 23 * * 3", "2024-05-15T23:00:00Z"}, // range with a step
+	}
+	for _, tt := range tests {
+		s, err := parseSchedule(tt.spec)
+		if err != nil {
+			t.Errorf("parseSchedule(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := s.next(from).Format(time.RFC3339); got != tt.want {
+			t.Errorf("next(%q) = %s, want %s", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, spec := range []string{
+		"@every 500ms", "@every soon", "@yearly", "* * * *", "60 * * * *", "* 24 * * *",
+		"* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *", "1-x * * * *",
+	} {
+		if _, err := parseSchedule(spec); err == nil {
+			t.Errorf("parseSchedule(%q) accepted", spec)
+		}
+	}
+}
+
+func TestSchedulerJobs(t *testing.T) {
+	release := make(chan struct{})
+	done := make(chan struct{}, 2)
+	available := map[string]jobFunc{
+		"slow": func(ctx context.Context) error {
+			<-release
+			done <- struct{}{}
+			return nil
+		},
+		"broken": func(ctx context.Context) error {
+			done <- struct{}{}
+			return errors.New("it broke")
+		},
+	}
+	for _, entries := range [][]string{{"slow"}, {"=@hourly"}, {"missing=@hourly"}, {"slow=@never"}} {
+		if _, err := newScheduler(entries, available); err == nil {
+			t.Errorf("newScheduler(%q) accepted", entries)
+		}
+	}
+	s, err := newScheduler([]string{"slow=@hourly", " broken = @every 1h"}, available)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := func(method, path string) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		s.handleAdmin(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+	statuses := func() map[string]jobStatus {
+		t.Helper()
+		var resp struct {
+			Jobs []jobStatus `json:"jobs"`
+		}
+		json.Unmarshal(call(http.MethodGet, "/admin/jobs").Body.Bytes(), &resp)
+		byName := map[string]jobStatus{}
+		for _, status := range resp.Jobs {
+			byName[status.Name] = status
+		}
+		return byName
+	}
+
+	// A run still going isn't overlapped
+	if rec := call(http.MethodPost, "/admin/jobs/slow"); rec.Code != http.StatusAccepted {
+		t.Fatalf("run = %d %s", rec.Code, rec.Body)
+	}
+	if rec := call(http.MethodPost, "/admin/jobs/slow"); rec.Code != http.StatusConflict {
+		t.Errorf("overlapping run = %d, want 409", rec.Code)
+	}
+	if status := statuses()["slow"]; !status.Running || status.Schedule != "@hourly" || status.LastRun == "" {
+		t.Errorf("running job = %+v", status)
+	}
+	close(release)
+	<-done
+
+	call(http.MethodPost, "/admin/jobs/broken")
+	<-done
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		all := statuses()
+		if slow, broken := all["slow"], all["broken"]; !slow.Running && !broken.Running {
+			if slow.LastResult != "ok" || broken.LastResult != "failed" || broken.LastError != "it broke" {
+				t.Errorf("finished jobs = %+v", all)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("jobs never finished")
+		}
+	}
+
+	if rec := call(http.MethodPost, "/admin/jobs/unknown"); rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "Unknown or unscheduled job") {
+		t.Errorf("unknown job = %d %s", rec.Code, rec.Body)
+	}
+	if rec := call(http.MethodPost, "/admin/jobs"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /admin/jobs = %d, want 405", rec.Code)
+	}
+	if rec := call(http.MethodGet, "/admin/jobs/slow"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /admin/jobs/slow = %d, want 405", rec.Code)
+	}
+}
@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Listener defaults. Writes aren't limited because SSE streams and
+// exports stay open for as long as the client wants them.
+const (
+	DEFAULT_READ_HEADER_TIMEOUT = 10 * time.Second
+	DEFAULT_READ_TIMEOUT        = time.Minute
+	DEFAULT_WRITE_TIMEOUT       = 0
+	DEFAULT_IDLE_TIMEOUT        = 2 * time.Minute
+	DEFAULT_MAX_HEADER_BYTES    = http.DefaultMaxHeaderBytes
+	// Within Docker's default 10s stop grace period, leaving time to save
+	DEFAULT_SHUTDOWN_TIMEOUT = 5 * time.Second
+)
+
+// Timeouts and limits of the proxy's own listeners; 0 disables a timeout
+type ServerSettings struct {
+	ReadHeaderTimeout time.Duration // closes slowloris connections
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	ShutdownTimeout   time.Duration // how long in-flight requests get to finish on SIGTERM
+}
+
+func (s ServerSettings) validate() error {
+	if s.ReadHeaderTimeout < 0 || s.ReadTimeout < 0 || s.WriteTimeout < 0 || s.IdleTimeout < 0 || s.ShutdownTimeout < 0 {
+		return fmt.Errorf("timeouts must not be negative")
+	}
+	if s.MaxHeaderBytes < 0 {
+		return fmt.Errorf("max header bytes must not be negative")
+	}
+	return nil
+}
+
+// HTTPServer returns a server for h on addr with the configured timeouts
+// and limits, for the public and admin listeners alike
+func (cfg Config) HTTPServer(addr string, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+	}
+}
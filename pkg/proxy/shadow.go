@@ -0,0 +1,101 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Mirrors a sample of proxied requests to a secondary upstream (a demo
+// environment or a staging build of this proxy). Mirrors run in the
+// background after the primary response and never affect the client;
+// their responses are only diffed against the primary's.
+type shadowMirror struct {
+	base    *url.URL
+	percent float64
+	methods map[string]bool
+	client  *http.Client
+	slots   chan struct{} // bounds concurrent mirrors; extras are dropped
+	diffs   *responseDiffer
+}
+
+// Cap on concurrent mirror requests
+const MAX_SHADOW_INFLIGHT = 32
+
+func newShadowMirror(upstream string, percent float64, methods, ignoreFields []string, transport http.RoundTripper) (*shadowMirror, error) {
+	base, err := url.Parse(strings.TrimRight(upstream, "/"))
+	if err != nil {
+		return nil, err
+	}
+	allowed := map[string]bool{}
+	for _, method := range methods {
+		allowed[strings.ToUpper(method)] = true
+	}
+	return &shadowMirror{
+		base:    base,
+		percent: percent,
+		methods: allowed,
+		client:  &http.Client{Transport: transport, Timeout: 30 * time.Second},
+		slots:   make(chan struct{}, MAX_SHADOW_INFLIGHT),
+		diffs:   newResponseDiffer(ignoreFields),
+	}, nil
+}
+
+// Decide whether this request gets mirrored
+func (m *shadowMirror) sample(r *http.Request) bool {
+	return m.methods[r.Method] && rand.Float64()*100 < m.percent
+}
+
+// Replay the request against the shadow upstream in the background
+func (m *shadowMirror) mirror(r *http.Request, body []byte, primaryStatus int, primaryBody []byte) {
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		log.Printf("👥 Shadow: dropping mirror of %s %s, too many in flight", r.Method, r.URL.Path)
+		return
+	}
+
+	target := *m.base
+	target.Path = m.base.Path + r.URL.Path
+	target.RawQuery = r.URL.RawQuery
+	method, path := r.Method, r.URL.Path
+	headers := r.Header.Clone()
+
+	go func() {
+		defer func() { <-m.slots }()
+
+		req, err := http.NewRequest(method, target.String(), bytes.NewReader(body))
+		if err != nil {
+			log.Printf("❌ Shadow: failed to build request: %v", err)
+			return
+		}
+		for name, values := range headers {
+			if isHopByHopHeader(name) {
+				continue
+			}
+			for _, value := range values {
+				req.Header.Add(name, value)
+			}
+		}
+		// Let the transport negotiate compression so the body can be diffed
+		req.Header.Del("Accept-Encoding")
+
+		resp, err := m.client.Do(req)
+		if err != nil {
+			log.Printf("❌ Shadow: %s %s failed: %v", method, path, err)
+			return
+		}
+		defer resp.Body.Close()
+		shadowBody, err := io.ReadAll(io.LimitReader(resp.Body, MAX_INSPECT_BODY*8))
+		if err != nil {
+			log.Printf("❌ Shadow: failed to read response for %s %s: %v", method, path, err)
+			return
+		}
+		m.diffs.compare(method+" "+path, primaryStatus, primaryBody, resp.StatusCode, shadowBody)
+	}()
+}
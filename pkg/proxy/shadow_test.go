@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Wait for a route's first comparison and return its statistics
+func waitForDiff(t *testing.T, d *responseDiffer, route string) *routeDiffStats {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if stats := routeStats(d, route); stats != nil {
+			return stats
+		}
+	}
+	t.Fatalf("%s was never compared", route)
+	return nil
+}
+
+// A copy of a route's mismatch statistics
+func routeStats(d *responseDiffer, route string) *routeDiffStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stats, ok := d.routes[route]
+	if !ok {
+		return nil
+	}
+	copied := *stats
+	copied.Fields = map[string]int64{}
+	for path, n := range stats.Fields {
+		copied.Fields[path] = n
+	}
+	return &copied
+}
+
+func TestShadowMirror(t *testing.T) {
+	type mirrored struct{ method, uri, key, body string }
+	got := make(chan mirrored, 1)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- mirrored{r.Method, r.URL.RequestURI(), r.Header.Get("ACCESS-KEY"), string(body)}
+		io.WriteString(w, `{"code":"0","data":[{"instId":"BTC-USDT","last":"2","ts":"2"}]}`)
+	}))
+	defer shadow.Close()
+
+	m, err := newShadowMirror(shadow.URL+"/staging/", 100, []string{"get", "post"}, []string{"ts"}, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/trade/order?x=1", nil)
+	r.Header.Set("ACCESS-KEY", "k")
+	if !m.sample(r) || m.sample(httptest.NewRequest(http.MethodDelete, "/", nil)) {
+		t.Error("sample should follow SHADOW_METHODS")
+	}
+	m.mirror(r, []byte(`{"size":"1"}`), http.StatusOK, []byte(`{"code":"0","data":[{"instId":"BTC-USDT","last":"1","ts":"1"}]}`))
+	if req := <-got; req != (mirrored{"POST", "/staging/api/v1/trade/order?x=1", "k", `{"size":"1"}`}) {
+		t.Errorf("mirrored request = %+v", req)
+	}
+	stats := waitForDiff(t, m.diffs, "POST /api/v1/trade/order")
+	if stats.Compared != 1 || stats.Mismatched != 1 || stats.Fields["data[0].last"] != 1 || len(stats.Fields) != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestShadowFailureLeavesPrimaryAlone(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"code":"0","msg":"","data":[]}`)
+	}))
+	defer primary.Close()
+	release := make(chan struct{})
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		http.Error(w, "broken", http.StatusInternalServerError)
+	}))
+	defer shadow.Close()
+	defer close(release)
+
+	dir, err := os.MkdirTemp("", "blofin-proxy-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	cfg := DefaultConfig()
+	cfg.Mode = MODE_PROXY
+	cfg.DataDir = dir
+	cfg.UpstreamHosts = []string{primary.URL}
+	cfg.ShadowUpstream = shadow.URL
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The shadow hangs, then fails; the client is answered by the primary
+	// without waiting for it
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/market/tickers", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"data":[]`) {
+		t.Errorf("primary response = %d %s", rec.Code, rec.Body)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("the client waited %v for the shadow", elapsed)
+	}
+	release <- struct{}{}
+	if stats := waitForDiff(t, p.srv.shadow.diffs, "GET /api/v1/market/tickers"); stats.StatusMismatches != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
@@ -0,0 +1,33 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Interval of the comments that keep idle streams from being closed by
+// load balancers
+const SSE_HEARTBEAT = 15 * time.Second
+
+// Send the headers of a Server-Sent Events response; false, with an error
+// written, when the connection can't stream
+func startSSE(w http.ResponseWriter) (http.Flusher, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Streaming is not supported"})
+		return nil, false
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	return flusher, true
+}
+
+// Write one event with v as its JSON data
+func writeSSE(w http.ResponseWriter, event string, v interface{}) {
+	data, _ := json.Marshal(v)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// BloFin's spelling of an instrument: BTCUSDT, btc/usdt, BTC_USDT and
+// CCXT's BTC/USDT:USDT all become BTC-USDT
+func normalizeInstID(symbol string) string {
+	symbol, _, _ = strings.Cut(strings.ToUpper(strings.TrimSpace(symbol)), ":")
+	symbol = strings.NewReplacer("/", "-", "_", "-").Replace(symbol)
+	if !strings.Contains(symbol, "-") {
+		if instID, err := binanceInstID(symbol); err == nil {
+			return instID
+		}
+	}
+	return symbol
+}
+
+// Normalize each of a comma-separated list of instruments
+func normalizeInstIDs(list string) string {
+	ids := strings.Split(list, ",")
+	for i, id := range ids {
+		ids[i] = normalizeInstID(id)
+	}
+	return strings.Join(ids, ",")
+}
+
+// Rewrite the instId of r's query and of the order (or orders) in its
+// body to BloFin's spelling; false once r has been answered with an
+// error. Only call for requests the client didn't sign.
+func normalizeSymbols(w http.ResponseWriter, r *http.Request) bool {
+	query := r.URL.Query()
+	if instID := query.Get("instId"); instID != "" {
+		if normalized := normalizeInstIDs(instID); normalized != instID {
+			query.Set("instId", normalized)
+			r.URL.RawQuery = query.Encode()
+		}
+	}
+	if r.Method != http.MethodPost || r.Body == nil || r.Body == http.NoBody {
+		return true
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MAX_INSPECT_BODY))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Failed to read request body"})
+		return false
+	}
+	if normalized, ok := normalizeBodySymbols(body); ok {
+		body = normalized
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	return true
+}
+
+// The body with every top-level instId normalized; false when nothing
+// changed, or it isn't JSON and is left for BloFin to reject
+func normalizeBodySymbols(body []byte) ([]byte, bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var parsed interface{}
+	if err := dec.Decode(&parsed); err != nil {
+		return nil, false
+	}
+	items := []interface{}{parsed}
+	if list, ok := parsed.([]interface{}); ok {
+		items = list
+	}
+	changed := false
+	for _, item := range items {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if instID, ok := fields["instId"].(string); ok {
+			if normalized := normalizeInstID(instID); normalized != instID {
+				fields["instId"] = normalized
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return nil, false
+	}
+	out, err := json.Marshal(parsed)
+	if err != nil {
+		log.Printf("❌ Failed to encode body with normalized symbols: %v", err)
+		return nil, false
+	}
+	return out, true
+}
@@ -0,0 +1,194 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// How an order template sizes its orders
+const (
+	SIZING_FIXED  = "fixed"  // size contracts
+	SIZING_EQUITY = "equity" // worth percent of the tenant's equity
+	SIZING_RISK   = "risk"   // losing percent of the tenant's equity at the stop
+)
+
+// A named order recipe webhook alerts refer to with "template", so a
+// strategy's sizing and exits are changed in one place instead of in
+// every alert. Take-profit and stop-loss are percentages away from the
+// entry price and close at market once triggered.
+type orderTemplate struct {
+	InstID       string `json:"instId,omitempty"` // when the alert names none
+	OrderType    string `json:"orderType,omitempty"`
+	MarginMode   string `json:"marginMode,omitempty"`
+	PositionSide string `json:"positionSide,omitempty"`
+	Sizing       string `json:"sizing"`
+	Size         string `json:"size,omitempty"`    // contracts, for fixed sizing
+	Percent      string `json:"percent,omitempty"` // of equity, for equity and risk sizing
+	TakeProfit   string `json:"takeProfit,omitempty"`
+	StopLoss     string `json:"stopLoss,omitempty"`
+}
+
+// Templates from ORDER_TEMPLATES_FILE, a JSON object of them by name
+func loadOrderTemplates(path string) (map[string]*orderTemplate, error) {
+	templates := map[string]*orderTemplate{}
+	if path == "" {
+		return templates, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for name, tmpl := range templates {
+		if tmpl == nil {
+			return nil, fmt.Errorf("template %q is empty", name)
+		}
+		if err := tmpl.validate(); err != nil {
+			return nil, fmt.Errorf("template %q: %v", name, err)
+		}
+	}
+	return templates, nil
+}
+
+func (tmpl *orderTemplate) validate() error {
+	positive := func(field, value string) error {
+		if n, ok := parseDecimal(value); value != "" && (!ok || n.Sign() <= 0) {
+			return fmt.Errorf("%s must be a positive number", field)
+		}
+		return nil
+	}
+	for _, field := range [][2]string{{"size", tmpl.Size}, {"percent", tmpl.Percent}, {"takeProfit", tmpl.TakeProfit}, {"stopLoss", tmpl.StopLoss}} {
+		if err := positive(field[0], field[1]); err != nil {
+			return err
+		}
+	}
+	stop, _ := parseDecimal(tmpl.StopLoss)
+	switch {
+	case tmpl.OrderType != "" && !validOrderTypes[tmpl.OrderType]:
+		return fmt.Errorf("orderType must be one of market, limit, post_only, fok, ioc")
+	case tmpl.Sizing == SIZING_FIXED && tmpl.Size == "":
+		return fmt.Errorf("fixed sizing needs a size")
+	case (tmpl.Sizing == SIZING_EQUITY || tmpl.Sizing == SIZING_RISK) && tmpl.Percent == "":
+		return fmt.Errorf("%s sizing needs a percent", tmpl.Sizing)
+	case tmpl.Sizing == SIZING_RISK && tmpl.StopLoss == "":
+		return fmt.Errorf("risk sizing needs a stopLoss")
+	case tmpl.Sizing != SIZING_FIXED && tmpl.Sizing != SIZING_EQUITY && tmpl.Sizing != SIZING_RISK:
+		return fmt.Errorf("sizing must be %s, %s or %s", SIZING_FIXED, SIZING_EQUITY, SIZING_RISK)
+	case stop != nil && stop.Cmp(big.NewRat(100, 1)) >= 0:
+		return fmt.Errorf("stopLoss must be below 100%%")
+	}
+	return nil
+}
+
+// The order an alert asks for through template name: the alert gives the
+// side and may name the instrument and a limit price, the template the
+// rest. Sizing by equity looks up the tenant's balance; sizes round down
+// to the lot size.
+func (s *server) templateOrder(ctx context.Context, t *tenant, name string, alert unifiedArgs) (map[string]string, error) {
+	tmpl := s.templates[name]
+	if tmpl == nil {
+		return nil, &helperRefusal{msg: fmt.Sprintf("unknown order template %q", name)}
+	}
+	tv := t.TradingView
+	instID := firstNonEmpty(alert.str("instId"), alert.str("ticker"), tmpl.InstID, tv.InstID)
+	side := strings.ToLower(alert.str("action"))
+	orderType := firstNonEmpty(tmpl.OrderType, "market")
+	switch {
+	case instID == "":
+		return nil, &helperRefusal{msg: "the alert names no ticker or instId and neither the template nor the tenant has a default instId"}
+	case side != "buy" && side != "sell":
+		return nil, &helperRefusal{msg: fmt.Sprintf("action must be buy or sell, got %q", alert.str("action"))}
+	case orderType != "market" && alert.str("price") == "":
+		return nil, &helperRefusal{msg: fmt.Sprintf("template %q places %s orders, so the alert needs a price", name, orderType)}
+	}
+	instID = tradingViewInstID(instID)
+	inst, ok, err := s.instruments.get(instID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, &helperRefusal{msg: fmt.Sprintf("unknown instrument %s", instID)}
+	}
+	order := map[string]string{
+		"instId":       instID,
+		"marginMode":   firstNonEmpty(tmpl.MarginMode, tv.MarginMode, "cross"),
+		"positionSide": firstNonEmpty(tmpl.PositionSide, tv.PositionSide, "net"),
+		"side":         side,
+		"orderType":    orderType,
+	}
+	if orderType != "market" {
+		order["price"] = alert.str("price")
+	}
+	if id := alert.str("clientOrderId"); id != "" {
+		order["clientOrderId"] = id
+	}
+	values := map[string]string{}
+	var price *big.Rat
+	if tmpl.Sizing != SIZING_FIXED || tmpl.TakeProfit != "" || tmpl.StopLoss != "" {
+		if price, ok = s.orderPrice(map[string]interface{}{"orderType": orderType, "price": order["price"]}, inst); !ok {
+			return nil, &helperRefusal{msg: fmt.Sprintf("no price to size or offset the order from for %s", instID)}
+		}
+		values["price"] = price.FloatString(stepDecimals(inst.TickSize))
+	}
+
+	size, _ := parseDecimal(tmpl.Size)
+	if tmpl.Sizing != SIZING_FIXED {
+		_, equity, err := s.blofin.totalEquity(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		contractValue, cvOK := parseDecimal(inst.ContractValue)
+		if !cvOK || contractValue.Sign() == 0 || price.Sign() == 0 {
+			return nil, &helperRefusal{msg: fmt.Sprintf("%s has no usable contract value or price", instID)}
+		}
+		percent, _ := parseDecimal(tmpl.Percent)
+		budget := new(big.Rat).Mul(equity, new(big.Rat).Quo(percent, big.NewRat(100, 1)))
+		perContract := new(big.Rat).Mul(price, contractValue)
+		if tmpl.Sizing == SIZING_RISK {
+			stop, _ := parseDecimal(tmpl.StopLoss)
+			perContract.Mul(perContract, new(big.Rat).Quo(stop, big.NewRat(100, 1)))
+		}
+		size = new(big.Rat).Quo(budget, perContract)
+		values["equity"], values["budget"] = equity.FloatString(2), budget.FloatString(2)
+	}
+	if lot, ok := parseDecimal(inst.LotSize); ok && lot.Sign() > 0 {
+		size = floorToStep(size, lot)
+	}
+	order["size"] = size.FloatString(stepDecimals(inst.LotSize))
+	values["size"] = order["size"]
+	if min, ok := parseDecimal(inst.MinSize); size.Sign() <= 0 || (ok && size.Cmp(min) < 0) {
+		return nil, &helperRefusal{msg: fmt.Sprintf("template %q sizes the order at %s contracts, below the minimum of %s", name, order["size"], inst.MinSize), values: values}
+	}
+	if max, ok := parseDecimal(tv.MaxSize); ok && size.Cmp(max) > 0 {
+		return nil, &helperRefusal{msg: fmt.Sprintf("size %s is above the configured maximum of %s", order["size"], tv.MaxSize), values: values}
+	}
+
+	// Exits sit above and below the entry, mirrored for shorts
+	below := "sl"
+	if side == "sell" {
+		below = "tp"
+	}
+	for _, exit := range []struct{ prefix, percent string }{{"tp", tmpl.TakeProfit}, {"sl", tmpl.StopLoss}} {
+		percent, ok := parseDecimal(exit.percent)
+		if !ok {
+			continue
+		}
+		offset := new(big.Rat).Quo(percent, big.NewRat(100, 1))
+		if exit.prefix == below {
+			offset.Neg(offset)
+		}
+		trigger := new(big.Rat).Mul(price, new(big.Rat).Add(big.NewRat(1, 1), offset))
+		if tick, ok := parseDecimal(inst.TickSize); ok && tick.Sign() > 0 {
+			trigger = roundToStep(trigger, tick)
+		}
+		order[exit.prefix+"TriggerPrice"] = trigger.FloatString(stepDecimals(inst.TickSize))
+		order[exit.prefix+"OrderPrice"] = "-1"
+	}
+	return order, nil
+}
@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+const (
+	MAX_KEY_ALIASES  = 100 // distinct hashed API keys labeled before the rest share "other"
+	ALIAS_ANONYMOUS  = "anonymous"
+	ALIAS_OTHER      = "other"
+	ALIAS_UNKNOWN    = "unknown" // an X-Proxy-Token matching no tenant
+	KEY_ALIAS_PREFIX = "key-"
+)
+
+// Labels BloFin-bound requests in /metrics with who sent them, so volume,
+// errors and rate-limit hits can be attributed per user. Tenants are
+// labeled by name; clients signing their own requests by a short hash of
+// their API key, which never exposes the key and stays bounded in number.
+type tenantMetrics struct {
+	metrics *metricsRegistry
+	tenants *tenantRegistry
+
+	mu      sync.Mutex
+	aliases map[string]string // API key to alias
+}
+
+func newTenantMetrics(metrics *metricsRegistry, tenants *tenantRegistry) *tenantMetrics {
+	metrics.register("blofin_proxy_tenant_requests_total", METRIC_COUNTER, "BloFin-bound requests by tenant or hashed API key, and status class")
+	metrics.register("blofin_proxy_tenant_rate_limited_total", METRIC_COUNTER, "BloFin-bound requests answered with 429 by the proxy's limits, tenant quotas or BloFin, by tenant or hashed API key")
+	return &tenantMetrics{metrics: metrics, tenants: tenants, aliases: map[string]string{}}
+}
+
+// Metric label for the sender of r
+func (m *tenantMetrics) alias(r *http.Request) string {
+	t, err := m.tenants.fromRequest(r)
+	if err != nil {
+		return ALIAS_UNKNOWN
+	}
+	if t != nil {
+		return t.Name
+	}
+	key := r.Header.Get("ACCESS-KEY")
+	if key == "" {
+		key = r.Header.Get("X-MBX-APIKEY")
+	}
+	if key == "" {
+		return ALIAS_ANONYMOUS
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if alias, ok := m.aliases[key]; ok {
+		return alias
+	}
+	if len(m.aliases) >= MAX_KEY_ALIASES {
+		return ALIAS_OTHER
+	}
+	sum := sha256.Sum256([]byte(key))
+	alias := KEY_ALIAS_PREFIX + hex.EncodeToString(sum[:4])
+	m.aliases[key] = alias
+	return alias
+}
+
+type metricsSenderKey struct{}
+
+// Count r under t when only the handler can tell who sent it, as with
+// webhooks identified by their body
+func attributeRequest(r *http.Request, t *tenant) {
+	if alias, ok := r.Context().Value(metricsSenderKey{}).(*string); ok {
+		*alias = t.Name
+	}
+}
+
+// Middleware counting each request under its sender once it is answered;
+// runs outside the rate limiter so its 429s are counted too. Requests
+// whose client went away are counted as "aborted" rather than by the
+// status the proxy never got to deliver.
+func (m *tenantMetrics) count(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !blofinBound(r.URL.Path) {
+			next(w, r)
+			return
+		}
+		alias := m.alias(r)
+		r = r.WithContext(context.WithValue(r.Context(), metricsSenderKey{}, &alias))
+		rec := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		// Deferred so a response abandoned mid-stream is counted too
+		defer func() {
+			status := strconv.Itoa(rec.status/100) + "xx"
+			if clientGone(r) {
+				status = "aborted"
+			}
+			m.metrics.add("blofin_proxy_tenant_requests_total", labels("tenant", alias, "status", status), 1)
+			if rec.status == http.StatusTooManyRequests {
+				m.metrics.add("blofin_proxy_tenant_rate_limited_total", labels("tenant", alias), 1)
+			}
+		}()
+		next(rec, r)
+	}
+}
+
+// Remembers the status written through it
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush keeps streamed responses working
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets handlers behind the wrapper take over the connection, as
+// the chaos reset fault does
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, fmt.Errorf("%T does not support hijacking", w.ResponseWriter)
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController
+func (w *statusWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
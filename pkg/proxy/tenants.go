@@ -0,0 +1,212 @@
+package proxy
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Header clients send to act as a configured tenant
+const TENANT_HEADER = "X-Proxy-Token"
+
+var errUnknownTenant = errors.New("unknown proxy token")
+
+// A client whose BloFin credentials the proxy holds, so requests can be
+// signed server-side and the secret never leaves the proxy
+type tenant struct {
+	Name       string `json:"name"`
+	Token      string `json:"token"`
+	APIKey     string `json:"apiKey"`
+	Secret     string `json:"secret"`
+	Passphrase string `json:"passphrase"`
+	DailyQuota int    `json:"dailyQuota,omitempty"` // requests per UTC day, 0 for no limit
+	Permission string `json:"permission,omitempty"` // read, trade or withdraw; empty for no limit
+
+	// Daily order caps, overriding DAILY_ORDER_LIMIT and DAILY_NOTIONAL_LIMIT
+	DailyOrders   int    `json:"dailyOrders,omitempty"`
+	DailyNotional string `json:"dailyNotional,omitempty"` // in quote currency
+
+	// Equity drop in a UTC day that halts new positions, overriding
+	// DAILY_LOSS_LIMIT
+	DailyLossLimit string `json:"dailyLossLimit,omitempty"`
+
+	// UTC windows ("HH:MM-HH:MM") new orders are accepted in; any time
+	// when empty
+	TradingHours []string `json:"tradingHours,omitempty"`
+	windows      []tradingWindow
+
+	// Instruments the tenant may trade; any when empty
+	Instruments []string `json:"instruments,omitempty"`
+	instruments map[string]bool
+
+	// Turns TradingView alerts into orders for this tenant
+	TradingView *tradingViewSettings `json:"tradingView,omitempty"`
+
+	// Trades against a simulated account instead of BloFin, starting with
+	// PaperBalance USDT
+	Paper        bool   `json:"paper,omitempty"`
+	PaperBalance string `json:"paperBalance,omitempty"`
+}
+
+// Tenants loaded from TENANTS_FILE, looked up by a hash of their token
+type tenantRegistry struct {
+	byToken map[[sha256.Size]byte]*tenant
+	list    []*tenant
+}
+
+func loadTenants(path string) (*tenantRegistry, error) {
+	reg := &tenantRegistry{byToken: map[[sha256.Size]byte]*tenant{}}
+	if path == "" {
+		return reg, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &reg.list); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	names := map[string]bool{}
+	for i, t := range reg.list {
+		if t.Name == "" || t.Token == "" || t.APIKey == "" || t.Secret == "" || t.Passphrase == "" {
+			return nil, fmt.Errorf("tenant %d: name, token, apiKey, secret and passphrase are required", i)
+		}
+		if t.DailyQuota < 0 {
+			return nil, fmt.Errorf("tenant %q: dailyQuota must not be negative", t.Name)
+		}
+		if _, ok := permissionLevels[t.Permission]; t.Permission != "" && !ok {
+			return nil, fmt.Errorf("tenant %q: permission must be read, trade or withdraw", t.Name)
+		}
+		if t.DailyOrders < 0 {
+			return nil, fmt.Errorf("tenant %q: dailyOrders must not be negative", t.Name)
+		}
+		if value, ok := parseDecimal(t.DailyNotional); t.DailyNotional != "" && (!ok || value.Sign() <= 0) {
+			return nil, fmt.Errorf("tenant %q: dailyNotional must be a positive number", t.Name)
+		}
+		if value, ok := parseDecimal(t.DailyLossLimit); t.DailyLossLimit != "" && (!ok || value.Sign() <= 0) {
+			return nil, fmt.Errorf("tenant %q: dailyLossLimit must be a positive number", t.Name)
+		}
+		windows, err := parseTradingHours(t.TradingHours)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %q: %v", t.Name, err)
+		}
+		t.windows = windows
+		if t.TradingView != nil {
+			if err := t.TradingView.validate(); err != nil {
+				return nil, fmt.Errorf("tenant %q: %v", t.Name, err)
+			}
+		}
+		if value, ok := parseDecimal(t.PaperBalance); t.PaperBalance != "" && (!t.Paper || !ok || value.Sign() <= 0) {
+			return nil, fmt.Errorf("tenant %q: paperBalance must be a positive number and needs paper", t.Name)
+		}
+		if len(t.Instruments) > 0 {
+			t.instruments = map[string]bool{}
+			for i, id := range t.Instruments {
+				t.Instruments[i] = normalizeInstID(id)
+				t.instruments[t.Instruments[i]] = true
+			}
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf("duplicate tenant name %q", t.Name)
+		}
+		names[t.Name] = true
+		key := sha256.Sum256([]byte(t.Token))
+		if _, dup := reg.byToken[key]; dup {
+			return nil, fmt.Errorf("tenant %q reuses another tenant's token", t.Name)
+		}
+		reg.byToken[key] = t
+	}
+	return reg, nil
+}
+
+func (reg *tenantRegistry) hasQuotas() bool {
+	for _, t := range reg.list {
+		if t.DailyQuota > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (reg *tenantRegistry) hasOrderCaps() bool {
+	for _, t := range reg.list {
+		if t.DailyOrders > 0 || t.DailyNotional != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Tenant named by the request's X-Proxy-Token header; nil without one
+func (reg *tenantRegistry) fromRequest(r *http.Request) (*tenant, error) {
+	return reg.lookup(r.Header.Get(TENANT_HEADER))
+}
+
+// Tenant with the given name, nil if there is none
+func (reg *tenantRegistry) named(name string) *tenant {
+	for _, t := range reg.list {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+func (reg *tenantRegistry) lookup(token string) (*tenant, error) {
+	if token == "" {
+		return nil, nil
+	}
+	t, ok := reg.byToken[sha256.Sum256([]byte(token))]
+	if !ok {
+		return nil, errUnknownTenant
+	}
+	return t, nil
+}
+
+// Tenant names are free-form, so they're escaped to stay one file or
+// directory name under DATA_DIR
+func (t *tenant) fileName() string {
+	name := url.PathEscape(t.Name)
+	if name == "." || name == ".." {
+		name = strings.ReplaceAll(name, ".", "%2E")
+	}
+	return name
+}
+
+// Add BloFin's authentication headers. The signature covers the request
+// path with query, method, timestamp, nonce and body.
+func (t *tenant) sign(req *http.Request, body []byte, now time.Time) {
+	timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+	nonce := newNonce()
+	mac := hmac.New(sha256.New, []byte(t.Secret))
+	mac.Write([]byte(req.URL.RequestURI() + req.Method + timestamp + nonce))
+	mac.Write(body)
+	signature := base64.StdEncoding.EncodeToString([]byte(hex.EncodeToString(mac.Sum(nil))))
+
+	req.Header.Set("ACCESS-KEY", t.APIKey)
+	req.Header.Set("ACCESS-SIGN", signature)
+	req.Header.Set("ACCESS-TIMESTAMP", timestamp)
+	req.Header.Set("ACCESS-NONCE", nonce)
+	req.Header.Set("ACCESS-PASSPHRASE", t.Passphrase)
+}
+
+// Random UUID-formatted nonce
+func newNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
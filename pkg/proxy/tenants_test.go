@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Load a tenants file holding tenants (JSON objects joined with commas)
+func loadTestTenants(t *testing.T, tenants string) (*tenantRegistry, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	if err := os.WriteFile(path, []byte("["+tenants+"]"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return loadTenants(path)
+}
+
+// A tenant named alice with settings (JSON fields) added; a "name" among
+// them renames it
+func testTenant(t *testing.T, settings string) *tenant {
+	t.Helper()
+	if settings != "" {
+		settings = "," + settings
+	}
+	reg, err := loadTestTenants(t, `{"name":"alice","token":"tok-a","apiKey":"k","secret":"s","passphrase":"p"`+settings+`}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reg.list[0]
+}
+
+func TestLoadTenants(t *testing.T) {
+	const alice = `"name":"alice","token":"tok-a","apiKey":"k","secret":"s","passphrase":"p"`
+	tests := []struct {
+		name    string
+		tenants string
+		err     string
+	}{
+		{"valid", `{` + alice + `,"permission":"trade","tradingHours":["13:30-20:00"],"instruments":["btcusdt"]}`, ""},
+		{"missing secret", `{"name":"alice","token":"tok-a","apiKey":"k","passphrase":"p"}`, "are required"},
+		{"bad permission", `{` + alice + `,"permission":"admin"}`, "permission must be"},
+		{"negative quota", `{` + alice + `,"dailyQuota":-1}`, "dailyQuota"},
+		{"bad notional", `{` + alice + `,"dailyNotional":"lots"}`, "dailyNotional"},
+		{"bad loss limit", `{` + alice + `,"dailyLossLimit":"0"}`, "dailyLossLimit"},
+		{"bad trading hours", `{` + alice + `,"tradingHours":["9-5"]}`, "HH:MM-HH:MM"},
+		{"tradingView without secret", `{` + alice + `,"tradingView":{"instId":"BTC-USDT"}}`, "tradingView.secret"},
+		{"duplicate name", `{` + alice + `},{"name":"alice","token":"tok-b","apiKey":"k","secret":"s","passphrase":"p"}`, "duplicate tenant name"},
+		{"shared token", `{` + alice + `},{"name":"bob","token":"tok-a","apiKey":"k","secret":"s","passphrase":"p"}`, "reuses another tenant's token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadTestTenants(t, tt.tenants)
+			if tt.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DEFAULT_TICKER_POLL_INTERVAL = 2 * time.Second
+
+// Ticker as returned by /api/v1/market/tickers
+type ticker struct {
+	InstID         string `json:"instId"`
+	Last           string `json:"last"`
+	LastSize       string `json:"lastSize"`
+	AskPrice       string `json:"askPrice"`
+	AskSize        string `json:"askSize"`
+	BidPrice       string `json:"bidPrice"`
+	BidSize        string `json:"bidSize"`
+	High24h        string `json:"high24h"`
+	Open24h        string `json:"open24h"`
+	Low24h         string `json:"low24h"`
+	VolCurrency24h string `json:"volCurrency24h"`
+	Vol24h         string `json:"vol24h"`
+	Ts             string `json:"ts"`
+}
+
+// Polls all tickers from BloFin, keeps the latest snapshot and hands each
+// one to subscribers. Polling only starts once something needs tickers.
+type tickerFeed struct {
+	client   *blofinClient
+	interval time.Duration
+
+	once        sync.Once
+	pollMu      sync.Mutex // one poll at a time
+	mu          sync.Mutex
+	latest      map[string]ticker
+	updatedAt   time.Time
+	subscribers []func(map[string]ticker)
+}
+
+func newTickerFeed(client *blofinClient, interval time.Duration) *tickerFeed {
+	return &tickerFeed{client: client, interval: interval}
+}
+
+// Register fn to be called with every new snapshot
+func (f *tickerFeed) subscribe(fn func(map[string]ticker)) {
+	f.mu.Lock()
+	f.subscribers = append(f.subscribers, fn)
+	f.mu.Unlock()
+	f.start()
+}
+
+func (f *tickerFeed) start() {
+	f.once.Do(func() {
+		log.Printf("📈 Polling tickers every %s", f.interval)
+		go f.run()
+	})
+}
+
+func (f *tickerFeed) run() {
+	for {
+		if err := f.poll(); err != nil {
+			log.Printf("❌ Ticker poll failed: %v", err)
+		}
+		time.Sleep(f.interval)
+	}
+}
+
+func (f *tickerFeed) poll() error {
+	f.pollMu.Lock()
+	defer f.pollMu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var list []ticker
+	if err := f.client.get(ctx, "/api/v1/market/tickers", nil, &list); err != nil {
+		return err
+	}
+	snapshot := make(map[string]ticker, len(list))
+	for _, t := range list {
+		snapshot[t.InstID] = t
+	}
+
+	f.mu.Lock()
+	f.latest = snapshot
+	f.updatedAt = time.Now()
+	subscribers := append([]func(map[string]ticker){}, f.subscribers...)
+	f.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(snapshot)
+	}
+	return nil
+}
+
+// Latest snapshot and when it was taken. Starts polling on first use and
+// fetches synchronously if the cached snapshot is missing or stale.
+func (f *tickerFeed) current() (map[string]ticker, time.Time, error) {
+	f.start()
+	fresh := func() (map[string]ticker, time.Time, bool) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.latest, f.updatedAt, time.Since(f.updatedAt) <= 2*f.interval
+	}
+	if latest, at, ok := fresh(); ok {
+		return latest, at, nil
+	}
+	// Wait for any poll in flight rather than starting a second one
+	f.pollMu.Lock()
+	f.pollMu.Unlock()
+	if latest, at, ok := fresh(); ok {
+		return latest, at, nil
+	}
+	if err := f.poll(); err != nil {
+		return nil, time.Time{}, err
+	}
+	latest, at, _ := fresh()
+	return latest, at, nil
+}
+
+// GET /aggregate/tickers?instIds=BTC-USDT,ETH-USDT answers from the cached
+// snapshot with just the requested instruments
+func (f *tickerFeed) handleAggregate(w http.ResponseWriter, r *http.Request) {
+	var instIDs []string
+	for _, id := range strings.Split(r.URL.Query().Get("instIds"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			instIDs = append(instIDs, id)
+		}
+	}
+	if len(instIDs) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "instIds is required, e.g. instIds=BTC-USDT,ETH-USDT"})
+		return
+	}
+	latest, updatedAt, err := f.current()
+	if err != nil {
+		log.Printf("❌ Failed to load tickers: %v", err)
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "Tickers are unavailable"})
+		return
+	}
+	data := []ticker{}
+	missing := []string{}
+	for _, id := range instIDs {
+		if t, ok := latest[id]; ok {
+			data = append(data, t)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	response := map[string]interface{}{
+		"code":      "0",
+		"msg":       "",
+		"data":      data,
+		"updatedAt": updatedAt.UnixMilli(),
+	}
+	if len(missing) > 0 {
+		response["missing"] = missing
+	}
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,244 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const DEFAULT_TLS_RELOAD_INTERVAL = 30 * time.Second
+
+// HTTPS listener settings. With a certificate and key the proxy serves
+// HTTPS on PORT; with a client CA too, clients must present a certificate
+// it signed (mTLS).
+type TLSSettings struct {
+	CertFile       string
+	KeyFile        string
+	ClientCAFile   string
+	ReloadInterval time.Duration // how often the files are checked for changes
+	MinVersion     string        // 1.2 or 1.3
+	CipherSuites   []string      // Go names of TLS 1.2 suites; empty for Go's defaults
+	Curves         []string      // key exchange preference order; empty for Go's defaults
+}
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsCurves = map[string]tls.CurveID{
+	"X25519": tls.X25519,
+	"P256":   tls.CurveP256,
+	"P384":   tls.CurveP384,
+	"P521":   tls.CurveP521,
+}
+
+// The listener's TLS baseline, from the configured names
+type tlsPolicy struct {
+	minVersion   uint16
+	cipherSuites []uint16
+	curves       []tls.CurveID
+}
+
+func (t TLSSettings) policy() (tlsPolicy, error) {
+	var p tlsPolicy
+	minVersion := t.MinVersion
+	if minVersion == "" {
+		minVersion = "1.2"
+	}
+	version, ok := tlsVersions[minVersion]
+	if !ok {
+		return p, fmt.Errorf("unknown minimum version %q (expected 1.2 or 1.3)", t.MinVersion)
+	}
+	p.minVersion = version
+	if len(t.CipherSuites) > 0 && version == tls.VersionTLS13 {
+		return p, fmt.Errorf("cipher suites only apply to TLS 1.2, TLS 1.3 suites are not configurable")
+	}
+	// Only the suites Go considers secure can be picked
+	suites := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		suites[suite.Name] = suite.ID
+	}
+	for _, name := range t.CipherSuites {
+		id, ok := suites[name]
+		if !ok {
+			return p, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		p.cipherSuites = append(p.cipherSuites, id)
+	}
+	if len(p.cipherSuites) > 0 && !containsSuite(p.cipherSuites, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256) && !containsSuite(p.cipherSuites, tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256) {
+		return p, fmt.Errorf("HTTP/2 needs TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 or TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 among the cipher suites")
+	}
+	for _, name := range t.Curves {
+		id, ok := tlsCurves[name]
+		if !ok {
+			return p, fmt.Errorf("unknown curve %q (expected X25519, P256, P384 or P521)", name)
+		}
+		p.curves = append(p.curves, id)
+	}
+	return p, nil
+}
+
+func (t TLSSettings) enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+func (t TLSSettings) validate() error {
+	if !t.enabled() {
+		if t.ClientCAFile != "" {
+			return fmt.Errorf("a client CA needs a certificate and key")
+		}
+		return nil
+	}
+	if t.CertFile == "" || t.KeyFile == "" {
+		return fmt.Errorf("both a certificate and a key file are required")
+	}
+	if t.ReloadInterval <= 0 {
+		return fmt.Errorf("reload interval must be positive")
+	}
+	if _, err := t.policy(); err != nil {
+		return err
+	}
+	_, err := loadTLSFiles(t)
+	return err
+}
+
+func containsSuite(suites []uint16, id uint16) bool {
+	for _, suite := range suites {
+		if suite == id {
+			return true
+		}
+	}
+	return false
+}
+
+// What the listener currently serves
+type tlsFiles struct {
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+	contents [][]byte // raw file contents, to notice changes
+}
+
+func loadTLSFiles(t TLSSettings) (*tlsFiles, error) {
+	certPEM, err := os.ReadFile(t.CertFile)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := os.ReadFile(t.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", t.CertFile, err)
+	}
+	files := &tlsFiles{cert: &cert, contents: [][]byte{certPEM, keyPEM}}
+	if t.ClientCAFile != "" {
+		caPEM, err := os.ReadFile(t.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		files.clientCA = x509.NewCertPool()
+		if !files.clientCA.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("%s: no certificates found", t.ClientCAFile)
+		}
+		files.contents = append(files.contents, caPEM)
+	}
+	return files, nil
+}
+
+// Serves the certificate and client CA from disk, picking up replacements
+// (such as a certbot renewal) without a restart. A change that fails to
+// load is logged and the previous files stay in use.
+type tlsReloader struct {
+	settings TLSSettings
+	policy   tlsPolicy
+
+	mu    sync.RWMutex
+	files *tlsFiles
+}
+
+func newTLSReloader(settings TLSSettings) (*tlsReloader, error) {
+	policy, err := settings.policy()
+	if err != nil {
+		return nil, err
+	}
+	files, err := loadTLSFiles(settings)
+	if err != nil {
+		return nil, err
+	}
+	return &tlsReloader{settings: settings, policy: policy, files: files}, nil
+}
+
+func (r *tlsReloader) start() {
+	go func() {
+		for {
+			time.Sleep(r.settings.ReloadInterval)
+			r.reload()
+		}
+	}()
+}
+
+func (r *tlsReloader) reload() {
+	files, err := loadTLSFiles(r.settings)
+	if err != nil {
+		// Certbot writes the files one at a time; the next check will see both
+		log.Printf("⚠️ Keeping the current TLS certificate: %v", err)
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if equalContents(files.contents, r.files.contents) {
+		return
+	}
+	r.files = files
+	log.Printf("🔒 Reloaded TLS certificate from %s", r.settings.CertFile)
+}
+
+func equalContents(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *tlsReloader) current() *tlsFiles {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.files
+}
+
+// Listener config resolving the certificate and client CA per handshake,
+// so reloads apply to new connections straight away
+func (r *tlsReloader) config() *tls.Config {
+	base := &tls.Config{
+		MinVersion:       r.policy.minVersion,
+		CipherSuites:     r.policy.cipherSuites,
+		CurvePreferences: r.policy.curves,
+		NextProtos:       []string{"h2", "http/1.1"},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return r.current().cert, nil
+		},
+	}
+	if r.settings.ClientCAFile == "" {
+		return base
+	}
+	withCA := base.Clone()
+	withCA.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cfg := base.Clone()
+		cfg.ClientCAs = r.current().clientCA
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		return cfg, nil
+	}
+	return withCA
+}
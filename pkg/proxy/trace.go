@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Phases of an upstream round trip, as seen through httptrace
+const (
+	PHASE_DNS = iota
+	PHASE_CONNECT
+	PHASE_TLS
+	PHASE_SERVER // request written to first response byte: BloFin's own time
+	PHASE_TOTAL  // start to response headers
+	PHASE_COUNT
+)
+
+var phaseNames = [PHASE_COUNT]string{"dns", "connect", "tls", "server", "total"}
+
+type phaseStat struct {
+	sum   atomic.Int64 // nanoseconds
+	count atomic.Uint64
+}
+
+// Timestamps collected while a request is in flight. Hooks can fire on
+// the transport's dialing goroutine, even after the round trip returned,
+// so every access goes through mu.
+type roundTripTiming struct {
+	mu                        sync.Mutex
+	start                     time.Time
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	wrote, firstByte          time.Time
+	end                       time.Time
+	gotConn                   bool
+	reused                    bool
+}
+
+// Record now into field unless an earlier event already set it
+func (t *roundTripTiming) mark(field *time.Time) {
+	t.mu.Lock()
+	if field.IsZero() {
+		*field = time.Now()
+	}
+	t.mu.Unlock()
+}
+
+func (t *roundTripTiming) trace() *httptrace.ClientTrace {
+	t.start = time.Now()
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.mark(&t.dnsDone) },
+		// Several addresses may be tried; time from the first attempt to
+		// the first connection
+		ConnectStart:      func(string, string) { t.mark(&t.connectStart) },
+		ConnectDone:       func(string, string, error) { t.mark(&t.connectDone) },
+		TLSHandshakeStart: func() { t.mark(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { t.mark(&t.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.gotConn, t.reused = true, info.Reused
+			t.mu.Unlock()
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.mark(&t.wrote) },
+		GotFirstResponseByte: func() { t.mark(&t.firstByte) },
+	}
+}
+
+func (t *roundTripTiming) finish() {
+	t.mark(&t.end)
+}
+
+func span(from, to time.Time) time.Duration {
+	if from.IsZero() || to.IsZero() || to.Before(from) {
+		return 0
+	}
+	return to.Sub(from)
+}
+
+// Duration of each phase; zero for phases the request skipped (a reused
+// connection has no dns, connect or tls)
+func (t *roundTripTiming) phases() [PHASE_COUNT]time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return [PHASE_COUNT]time.Duration{
+		PHASE_DNS:     span(t.dnsStart, t.dnsDone),
+		PHASE_CONNECT: span(t.connectStart, t.connectDone),
+		PHASE_TLS:     span(t.tlsStart, t.tlsDone),
+		PHASE_SERVER:  span(t.wrote, t.firstByte),
+		PHASE_TOTAL:   span(t.start, t.end),
+	}
+}
+
+// Whether a connection was obtained, and if it came from the idle pool
+func (t *roundTripTiming) conn() (got, reused bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.gotConn, t.reused
+}
+
+func (t *roundTripTiming) String() string {
+	p := t.phases()
+	_, reused := t.conn()
+	return fmt.Sprintf("dns=%s connect=%s tls=%s server=%s reused=%t",
+		p[PHASE_DNS].Round(time.Millisecond), p[PHASE_CONNECT].Round(time.Millisecond),
+		p[PHASE_TLS].Round(time.Millisecond), p[PHASE_SERVER].Round(time.Millisecond), reused)
+}
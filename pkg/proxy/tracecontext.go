@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+)
+
+// Trace context headers passed through untouched: W3C Trace Context plus
+// Zipkin's single and multi-header B3 formats
+var traceHeaders = []string{
+	"traceparent",
+	"tracestate",
+	"b3",
+	"X-B3-TraceId",
+	"X-B3-SpanId",
+	"X-B3-ParentSpanId",
+	"X-B3-Sampled",
+	"X-B3-Flags",
+}
+
+// Longer values aren't trace context and aren't worth echoing
+const MAX_TRACE_HEADER = 512
+
+type traceKey struct{}
+
+// Trace headers of the client request behind ctx, nil when it sent none
+func traceFrom(ctx context.Context) http.Header {
+	h, _ := ctx.Value(traceKey{}).(http.Header)
+	return h
+}
+
+// Copy the trace headers saved in ctx onto an outbound request
+func applyTrace(ctx context.Context, h http.Header) {
+	for name, values := range traceFrom(ctx) {
+		h[name] = values
+	}
+}
+
+// Middleware keeping the proxy a transparent hop in existing tracing
+// setups: the client's trace headers are echoed on the response and saved
+// in the context so calls the proxy makes to BloFin on its behalf carry
+// them too. The reverse proxy forwards them on /api/* by itself.
+func propagateTrace(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var trace http.Header
+		for _, name := range traceHeaders {
+			value := r.Header.Get(name)
+			if value == "" || len(value) > MAX_TRACE_HEADER {
+				continue
+			}
+			if trace == nil {
+				trace = http.Header{}
+			}
+			trace.Set(name, value)
+			w.Header().Set(name, value)
+		}
+		if trace != nil {
+			r = r.WithContext(context.WithValue(r.Context(), traceKey{}, trace))
+		}
+		next(w, r)
+	}
+}
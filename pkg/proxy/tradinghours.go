@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// A daily UTC window in minutes after midnight; end before start wraps
+// past midnight
+type tradingWindow struct {
+	start, end int
+}
+
+// Parse "HH:MM-HH:MM" windows
+func parseTradingHours(windows []string) ([]tradingWindow, error) {
+	var parsed []tradingWindow
+	for _, window := range windows {
+		from, to, ok := strings.Cut(window, "-")
+		start, startErr := time.Parse("15:04", strings.TrimSpace(from))
+		end, endErr := time.Parse("15:04", strings.TrimSpace(to))
+		if !ok || startErr != nil || endErr != nil || start.Equal(end) {
+			return nil, fmt.Errorf("trading hours %q: expected HH:MM-HH:MM in UTC", window)
+		}
+		parsed = append(parsed, tradingWindow{start.Hour()*60 + start.Minute(), end.Hour()*60 + end.Minute()})
+	}
+	return parsed, nil
+}
+
+func (tw tradingWindow) contains(minute int) bool {
+	if tw.start < tw.end {
+		return minute >= tw.start && minute < tw.end
+	}
+	return minute >= tw.start || minute < tw.end
+}
+
+// When the next window opens after now, or zero if now is inside one
+func nextTradingWindow(windows []tradingWindow, now time.Time) time.Time {
+	now = now.UTC()
+	minute := now.Hour()*60 + now.Minute()
+	wait := -1
+	for _, tw := range windows {
+		if tw.contains(minute) {
+			return time.Time{}
+		}
+		if w := (tw.start - minute + 24*60) % (24 * 60); wait < 0 || w < wait {
+			wait = w
+		}
+	}
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return midnight.Add(time.Duration(minute+wait) * time.Minute)
+}
+
+// Refusal for a placement body outside t's trading hours, unless every
+// order in it is reduce-only
+func tradingHoursCheck(t *tenant, path string, body []byte, now time.Time) *orderRefusal {
+	if t == nil || len(t.windows) == 0 {
+		return nil
+	}
+	opens := nextTradingWindow(t.windows, now)
+	if opens.IsZero() || reduceOnlyBody(path, body) {
+		return nil
+	}
+	return &orderRefusal{
+		status: http.StatusForbidden,
+		msg:    fmt.Sprintf("Outside %s's trading hours (%s UTC): only reduce-only orders and closes are accepted until %s", t.Name, strings.Join(t.TradingHours, ", "), opens.Format("15:04 UTC")),
+		until:  opens,
+	}
+}
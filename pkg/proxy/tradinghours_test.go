@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseTradingHours(t *testing.T) {
+	tests := []struct {
+		windows []string
+		want    []tradingWindow
+		ok      bool
+	}{
+		{[]string{"13:30-20:00"}, []tradingWindow{{810, 1200}}, true},
+		{[]string{"22:00 - 02:00", "09:00-10:00"}, []tradingWindow{{1320, 120}, {540, 600}}, true},
+		{[]string{"09:00-09:00"}, nil, false},
+		{[]string{"09:00"}, nil, false},
+		{[]string{"9am-5pm"}, nil, false},
+		{[]string{"24:00-01:00"}, nil, false},
+	}
+	for _, tt := range tests {
+		got, err := parseTradingHours(tt.windows)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseTradingHours(%q) error = %v, want ok %v", tt.windows, err, tt.ok)
+			continue
+		}
+		if tt.ok && len(got) != len(tt.want) {
+			t.Errorf("parseTradingHours(%q) = %v, want %v", tt.windows, got, tt.want)
+			continue
+		}
+		for i := range tt.want {
+			if got[i] != tt.want[i] {
+				t.Errorf("parseTradingHours(%q) = %v, want %v", tt.windows, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestNextTradingWindow(t *testing.T) {
+	windows, err := parseTradingHours([]string{"09:00-17:00", "22:00-02:00"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := func(hour, minute int) time.Time { return time.Date(2024, 3, 1, hour, minute, 0, 0, time.UTC) }
+	tests := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{day(9, 0), time.Time{}},
+		{day(16, 59), time.Time{}},
+		{day(23, 0), time.Time{}},
+		{day(1, 59), time.Time{}},
+		{day(17, 0), day(22, 0)},
+		{day(2, 0), day(9, 0)},
+		{day(8, 30), day(9, 0)},
+		{time.Date(2024, 3, 1, 12, 0, 0, 0, time.FixedZone("UTC+8", 8*3600)), day(9, 0)}, // 04:00 UTC
+	}
+	for _, tt := range tests {
+		if got := nextTradingWindow(windows, tt.now); !got.Equal(tt.want) {
+			t.Errorf("nextTradingWindow(%s) = %s, want %s", tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestTradingHoursCheck(t *testing.T) {
+	limited := testTenant(t, `"tradingHours":["09:00-17:00"]`)
+	open := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	closed := time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC)
+	const order = "/api/v1/trade/order"
+	const batch = "/api/v1/trade/batch-orders"
+	tests := []struct {
+		name    string
+		tenant  *tenant
+		path    string
+		body    string
+		now     time.Time
+		refused bool
+	}{
+		{"open", limited, order, `{"instId":"BTC-USDT"}`, open, false},
+		{"closed", limited, order, `{"instId":"BTC-USDT"}`, closed, true},
+		{"reduce-only while closed", limited, order, `{"instId":"BTC-USDT","reduceOnly":"true"}`, closed, false},
+		{"batch, all reduce-only", limited, batch, `[{"reduceOnly":"true"},{"reduceOnly":"true"}]`, closed, false},
+		{"batch, one opening", limited, batch, `[{"reduceOnly":"true"},{"reduceOnly":"false"}]`, closed, true},
+		{"no trading hours", testTenant(t, ""), order, `{}`, closed, false},
+		{"no tenant", nil, order, `{}`, closed, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tradingHoursCheck(tt.tenant, tt.path, []byte(tt.body), tt.now)
+			if (err != nil) != tt.refused {
+				t.Fatalf("tradingHoursCheck = %v, want refused %v", err, tt.refused)
+			}
+			if err != nil && (err.status != http.StatusForbidden || !err.until.Equal(time.Date(2024, 3, 2, 9, 0, 0, 0, time.UTC))) {
+				t.Errorf("refusal = %d until %s", err.status, err.until)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Connection pooling defaults for upstream requests, tunable through the
+// UPSTREAM_* variables
+const (
+	DEFAULT_UPSTREAM_TIMEOUT        = 30 * time.Second
+	DEFAULT_MAX_IDLE_CONNS          = 100
+	DEFAULT_MAX_IDLE_CONNS_PER_HOST = 100
+	DEFAULT_IDLE_CONN_TIMEOUT       = 90 * time.Second
+	DEFAULT_TLS_HANDSHAKE_TIMEOUT   = 10 * time.Second
+	DIAL_TIMEOUT                    = 10 * time.Second
+	DIAL_KEEP_ALIVE                 = 30 * time.Second
+)
+
+// Connection pool settings for the shared upstream transport
+type TransportSettings struct {
+	MaxIdleConns        int // across all hosts, 0 for no limit
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int // dialing, active and idle; 0 for no limit
+	IdleConnTimeout     time.Duration
+	TLSHandshakeTimeout time.Duration
+	HTTP2               bool
+	Proxy               string        // egress proxy URL; empty falls back to the environment
+	DNSCacheTTL         time.Duration // 0 disables the DNS cache
+}
+
+func (t TransportSettings) validate() error {
+	if t.MaxIdleConns < 0 || t.MaxIdleConnsPerHost < 0 || t.MaxConnsPerHost < 0 {
+		return fmt.Errorf("connection limits must not be negative")
+	}
+	if t.IdleConnTimeout < 0 || t.TLSHandshakeTimeout < 0 || t.DNSCacheTTL < 0 {
+		return fmt.Errorf("timeouts must not be negative")
+	}
+	_, err := t.proxyURL()
+	return err
+}
+
+// Egress proxy for upstream requests: the configured URL, else ALL_PROXY
+// when HTTP(S)_PROXY aren't set. nil means use HTTP(S)_PROXY/NO_PROXY as
+// usual.
+func (t TransportSettings) proxyURL() (*url.URL, error) {
+	raw := t.Proxy
+	if raw == "" && os.Getenv("HTTPS_PROXY") == "" && os.Getenv("https_proxy") == "" &&
+		os.Getenv("HTTP_PROXY") == "" && os.Getenv("http_proxy") == "" {
+		raw = os.Getenv("ALL_PROXY")
+		if raw == "" {
+			raw = os.Getenv("all_proxy")
+		}
+	}
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %v", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q (expected http, https, socks5 or socks5h)", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy URL %q has no host", raw)
+	}
+	return u, nil
+}
+
+// Transport shared by everything that talks to BloFin
+func newUpstreamTransport(settings TransportSettings, dns *dnsCache) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   DIAL_TIMEOUT,
+		KeepAlive: DIAL_KEEP_ALIVE,
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     settings.HTTP2,
+		MaxIdleConns:          settings.MaxIdleConns,
+		MaxIdleConnsPerHost:   settings.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       settings.MaxConnsPerHost,
+		IdleConnTimeout:       settings.IdleConnTimeout,
+		TLSHandshakeTimeout:   settings.TLSHandshakeTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if !settings.HTTP2 {
+		// A non-nil empty map is what switches HTTP/2 off for good
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	if dns != nil {
+		transport.DialContext = dns.dialContext(dialer)
+	}
+	// Already checked by validate
+	if egress, _ := settings.proxyURL(); egress != nil {
+		transport.Proxy = http.ProxyURL(egress)
+	}
+	return transport
+}
@@ -0,0 +1,654 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CCXT timeframes and the BloFin bar each maps to
+var unifiedTimeframes = map[string]string{
+	"1m": "1m", "3m": "3m", "5m": "5m", "15m": "15m", "30m": "30m",
+	"1h": "1H", "2h": "2H", "4h": "4H", "6h": "6H", "8h": "8H", "12h": "12H",
+	"1d": "1D", "3d": "3D", "1w": "1W",
+}
+
+// Arguments of a unified call, from the query string for GET and the JSON
+// body for POST
+type unifiedArgs map[string]interface{}
+
+func (a unifiedArgs) str(name string) string {
+	switch v := a[name].(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (a unifiedArgs) integer(name string, fallback int) (int, error) {
+	value := a.str(name)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, unifiedBadRequest("%s must be a non-negative integer", name)
+	}
+	return n, nil
+}
+
+// The symbol argument as a BloFin instId
+func (a unifiedArgs) instID() (string, error) {
+	symbol := a.str("symbol")
+	if symbol == "" {
+		return "", unifiedBadRequest("symbol is required")
+	}
+	return normalizeInstID(symbol), nil
+}
+
+// Error the caller can fix, reported as a 400
+type unifiedError struct{ msg string }
+
+func (e *unifiedError) Error() string { return e.msg }
+
+func unifiedBadRequest(format string, args ...interface{}) error {
+	return &unifiedError{fmt.Sprintf(format, args...)}
+}
+
+func unifiedSymbol(instID string) string {
+	base, quote, ok := strings.Cut(instID, "-")
+	if !ok {
+		return instID
+	}
+	return base + "/" + quote + ":" + quote
+}
+
+// BloFin sends numbers as strings; CCXT structures use numbers or null
+func unifiedNumber(value string) interface{} {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil
+	}
+	return f
+}
+
+func unifiedTimestamp(ms string) (interface{}, interface{}) {
+	n, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil || n == 0 {
+		return nil, nil
+	}
+	return n, time.UnixMilli(n).UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+type unifiedMethod struct {
+	httpMethod string
+	private    bool
+	call       func(s *server, ctx context.Context, args unifiedArgs, t *tenant) (interface{}, error)
+	summary    string // for the OpenAPI document
+}
+
+var unifiedMethods = map[string]unifiedMethod{
+	"fetchMarkets":     {http.MethodGet, false, (*server).unifiedFetchMarkets, "All markets in CCXT's market structure"},
+	"fetchTicker":      {http.MethodGet, false, (*server).unifiedFetchTicker, "Ticker for symbol, e.g. BTC/USDT:USDT"},
+	"fetchTickers":     {http.MethodGet, false, (*server).unifiedFetchTickers, "Tickers for a comma-separated list of symbols, or all of them"},
+	"fetchOrderBook":   {http.MethodGet, false, (*server).unifiedFetchOrderBook, "Order book for symbol, up to limit levels"},
+	"fetchOHLCV":       {http.MethodGet, false, (*server).unifiedFetchOHLCV, "Candles for symbol and timeframe, from since, up to limit"},
+	"fetchTrades":      {http.MethodGet, false, (*server).unifiedFetchTrades, "Recent trades for symbol, up to limit"},
+	"fetchFundingRate": {http.MethodGet, false, (*server).unifiedFetchFundingRate, "Current funding rate for symbol"},
+	"fetchBalance":     {http.MethodGet, true, (*server).unifiedFetchBalance, "The tenant's futures balance (needs X-Proxy-Token)"},
+	"fetchPositions":   {http.MethodGet, true, (*server).unifiedFetchPositions, "The tenant's open positions, optionally only symbols (needs X-Proxy-Token)"},
+	"fetchOpenOrders":  {http.MethodGet, true, (*server).unifiedFetchOpenOrders, "The tenant's open orders, optionally for one symbol (needs X-Proxy-Token)"},
+	"createOrder":      {http.MethodPost, true, (*server).unifiedCreateOrder, "Place an order from symbol, type, side, amount and price (needs X-Proxy-Token)"},
+	"cancelOrder":      {http.MethodPost, true, (*server).unifiedCancelOrder, "Cancel order id on symbol (needs X-Proxy-Token)"},
+}
+
+// /unified/{method} translates CCXT-style calls into BloFin REST calls.
+// Private methods are signed with the credentials of the tenant named by
+// X-Proxy-Token.
+func (s *server) handleUnified(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/unified/")
+	method, ok := unifiedMethods[name]
+	if !ok {
+		names := make([]string, 0, len(unifiedMethods))
+		for n := range unifiedMethods {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"error": "Unknown unified method " + name, "methods": names})
+		return
+	}
+	if r.Method != method.httpMethod {
+		methodNotAllowed(w, method.httpMethod)
+		return
+	}
+
+	t, err := s.tenants.fromRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Invalid " + TENANT_HEADER})
+		return
+	}
+	// Mock mode never contacts BloFin, so it needs no credentials
+	if method.private && t == nil && s.mock == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": name + " needs the " + TENANT_HEADER + " of a configured tenant"})
+		return
+	}
+	if err := t.allows(r.Method, r.URL.Path); err != nil {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+		return
+	}
+	if !s.quotas.spend(t) {
+		quotaExceeded(w, t)
+		return
+	}
+	r = r.WithContext(withQuotaSpent(r.Context()))
+
+	args := unifiedArgs{}
+	if r.Method == http.MethodGet {
+		for key, values := range r.URL.Query() {
+			args[key] = values[0]
+		}
+	} else {
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, MAX_INSPECT_BODY))
+		decoder.UseNumber()
+		if err := decoder.Decode(&args); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON: " + err.Error()})
+			return
+		}
+	}
+
+	result, err := method.call(s, r.Context(), args, t)
+	if err != nil {
+		if clientGone(r) {
+			log.Printf("🔌 Client went away before BloFin answered: unified %s", name)
+			return
+		}
+		switch e := err.(type) {
+		case *unifiedError:
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": e.msg})
+		case *orderRefusal:
+			orderRefused(w, e)
+		case *blofinError:
+			status := http.StatusBadGateway
+			switch {
+			case isRateLimited(e):
+				status = http.StatusTooManyRequests
+			case e.Code != "":
+				status = http.StatusBadRequest
+			}
+			writeJSON(w, status, map[string]string{"error": e.Error(), "blofinCode": e.Code})
+		default:
+			log.Printf("❌ Unified %s failed: %v", name, err)
+			writeJSON(w, http.StatusBadGateway, map[string]string{"error": "BloFin request failed"})
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *server) unifiedFetchMarkets(ctx context.Context, _ unifiedArgs, _ *tenant) (interface{}, error) {
+	var list []instrument
+	if err := s.blofin.get(ctx, "/api/v1/market/instruments", nil, &list); err != nil {
+		return nil, err
+	}
+	markets := []map[string]interface{}{}
+	for _, inst := range list {
+		markets = append(markets, map[string]interface{}{
+			"id":           inst.InstID,
+			"symbol":       unifiedSymbol(inst.InstID),
+			"base":         inst.BaseCurrency,
+			"quote":        inst.QuoteCurrency,
+			"settle":       inst.QuoteCurrency,
+			"type":         "swap",
+			"swap":         true,
+			"contract":     true,
+			"linear":       inst.ContractType != "inverse",
+			"inverse":      inst.ContractType == "inverse",
+			"active":       inst.State == "live",
+			"contractSize": unifiedNumber(inst.ContractValue),
+			"precision": map[string]interface{}{
+				"amount": unifiedNumber(inst.LotSize),
+				"price":  unifiedNumber(inst.TickSize),
+			},
+			"limits": map[string]interface{}{
+				"amount":   map[string]interface{}{"min": unifiedNumber(inst.MinSize), "max": unifiedNumber(inst.MaxLimitSize)},
+				"leverage": map[string]interface{}{"max": unifiedNumber(inst.MaxLeverage)},
+			},
+			"info": inst,
+		})
+	}
+	return markets, nil
+}
+
+func unifiedTicker(t ticker) map[string]interface{} {
+	timestamp, datetime := unifiedTimestamp(t.Ts)
+	return map[string]interface{}{
+		"symbol":      unifiedSymbol(t.InstID),
+		"timestamp":   timestamp,
+		"datetime":    datetime,
+		"high":        unifiedNumber(t.High24h),
+		"low":         unifiedNumber(t.Low24h),
+		"bid":         unifiedNumber(t.BidPrice),
+		"bidVolume":   unifiedNumber(t.BidSize),
+		"ask":         unifiedNumber(t.AskPrice),
+		"askVolume":   unifiedNumber(t.AskSize),
+		"open":        unifiedNumber(t.Open24h),
+		"close":       unifiedNumber(t.Last),
+		"last":        unifiedNumber(t.Last),
+		"baseVolume":  unifiedNumber(t.VolCurrency24h),
+		"quoteVolume": nil,
+		"info":        t,
+	}
+}
+
+func (s *server) unifiedFetchTicker(ctx context.Context, args unifiedArgs, _ *tenant) (interface{}, error) {
+	instID, err := args.instID()
+	if err != nil {
+		return nil, err
+	}
+	var list []ticker
+	if err := s.blofin.get(ctx, "/api/v1/market/tickers", url.Values{"instId": {instID}}, &list); err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, unifiedBadRequest("unknown symbol %s", args.str("symbol"))
+	}
+	return unifiedTicker(list[0]), nil
+}
+
+// Tickers keyed by unified symbol, optionally limited to symbols=A,B,...
+func (s *server) unifiedFetchTickers(ctx context.Context, args unifiedArgs, _ *tenant) (interface{}, error) {
+	var list []ticker
+	if err := s.blofin.get(ctx, "/api/v1/market/tickers", nil, &list); err != nil {
+		return nil, err
+	}
+	wanted := map[string]bool{}
+	for _, symbol := range strings.Split(args.str("symbols"), ",") {
+		if symbol = strings.TrimSpace(symbol); symbol != "" {
+			wanted[normalizeInstID(symbol)] = true
+		}
+	}
+	tickers := map[string]interface{}{}
+	for _, t := range list {
+		if len(wanted) == 0 || wanted[t.InstID] {
+			tickers[unifiedSymbol(t.InstID)] = unifiedTicker(t)
+		}
+	}
+	return tickers, nil
+}
+
+func (s *server) unifiedFetchOrderBook(ctx context.Context, args unifiedArgs, _ *tenant) (interface{}, error) {
+	instID, err := args.instID()
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"instId": {instID}}
+	if limit := args.str("limit"); limit != "" {
+		query.Set("size", limit)
+	}
+	var books []struct {
+		Asks [][]string `json:"asks"`
+		Bids [][]string `json:"bids"`
+		Ts   string     `json:"ts"`
+	}
+	if err := s.blofin.get(ctx, "/api/v1/market/books", query, &books); err != nil {
+		return nil, err
+	}
+	if len(books) == 0 {
+		return nil, unifiedBadRequest("unknown symbol %s", args.str("symbol"))
+	}
+	levels := func(rows [][]string) [][]interface{} {
+		out := [][]interface{}{}
+		for _, row := range rows {
+			if len(row) >= 2 {
+				out = append(out, []interface{}{unifiedNumber(row[0]), unifiedNumber(row[1])})
+			}
+		}
+		return out
+	}
+	timestamp, datetime := unifiedTimestamp(books[0].Ts)
+	return map[string]interface{}{
+		"symbol":    unifiedSymbol(instID),
+		"bids":      levels(books[0].Bids),
+		"asks":      levels(books[0].Asks),
+		"timestamp": timestamp,
+		"datetime":  datetime,
+		"nonce":     nil,
+	}, nil
+}
+
+// Candles oldest first as [timestamp, open, high, low, close, volume]. With
+// since, returns the limit candles starting at since like CCXT does.
+func (s *server) unifiedFetchOHLCV(ctx context.Context, args unifiedArgs, _ *tenant) (interface{}, error) {
+	instID, err := args.instID()
+	if err != nil {
+		return nil, err
+	}
+	timeframe := args.str("timeframe")
+	if timeframe == "" {
+		timeframe = "1m"
+	}
+	bar, ok := unifiedTimeframes[timeframe]
+	if !ok {
+		return nil, unifiedBadRequest("unsupported timeframe %s", timeframe)
+	}
+	limit, err := args.integer("limit", 100)
+	if err != nil {
+		return nil, err
+	}
+	limit = min(max(limit, 1), CANDLE_PAGE_LIMIT)
+	since, err := args.integer("since", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{"instId": {instID}, "bar": {bar}, "limit": {strconv.Itoa(limit)}}
+	if since > 0 {
+		// BloFin pages backwards from "after", so ask for the window's end
+		end := int64(since) + int64(limit)*barDurations[bar].Milliseconds()
+		query.Set("after", strconv.FormatInt(end, 10))
+	}
+	var rows []candle
+	if err := s.blofin.get(ctx, "/api/v1/market/candles", query, &rows); err != nil {
+		return nil, err
+	}
+	ohlcv := [][]interface{}{}
+	for i := len(rows) - 1; i >= 0; i-- {
+		row := rows[i]
+		if len(row) < 7 || row.ts() < int64(since) {
+			continue
+		}
+		ohlcv = append(ohlcv, []interface{}{
+			row.ts(), unifiedNumber(row[1]), unifiedNumber(row[2]), unifiedNumber(row[3]), unifiedNumber(row[4]), unifiedNumber(row[6]),
+		})
+	}
+	return ohlcv, nil
+}
+
+func (s *server) unifiedFetchTrades(ctx context.Context, args unifiedArgs, _ *tenant) (interface{}, error) {
+	instID, err := args.instID()
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"instId": {instID}}
+	if limit := args.str("limit"); limit != "" {
+		query.Set("limit", limit)
+	}
+	var list []struct {
+		TradeID string `json:"tradeId"`
+		InstID  string `json:"instId"`
+		Price   string `json:"price"`
+		Size    string `json:"size"`
+		Side    string `json:"side"`
+		Ts      string `json:"ts"`
+	}
+	if err := s.blofin.get(ctx, "/api/v1/market/trades", query, &list); err != nil {
+		return nil, err
+	}
+	trades := []map[string]interface{}{}
+	for i := len(list) - 1; i >= 0; i-- {
+		trade := list[i]
+		timestamp, datetime := unifiedTimestamp(trade.Ts)
+		trades = append(trades, map[string]interface{}{
+			"id":        trade.TradeID,
+			"symbol":    unifiedSymbol(trade.InstID),
+			"timestamp": timestamp,
+			"datetime":  datetime,
+			"side":      trade.Side,
+			"price":     unifiedNumber(trade.Price),
+			"amount":    unifiedNumber(trade.Size),
+			"info":      trade,
+		})
+	}
+	return trades, nil
+}
+
+func (s *server) unifiedFetchFundingRate(ctx context.Context, args unifiedArgs, _ *tenant) (interface{}, error) {
+	instID, err := args.instID()
+	if err != nil {
+		return nil, err
+	}
+	var list []struct {
+		InstID      string `json:"instId"`
+		FundingRate string `json:"fundingRate"`
+		FundingTime string `json:"fundingTime"`
+	}
+	if err := s.blofin.get(ctx, "/api/v1/market/funding-rate", url.Values{"instId": {instID}}, &list); err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, unifiedBadRequest("unknown symbol %s", args.str("symbol"))
+	}
+	timestamp, datetime := unifiedTimestamp(list[0].FundingTime)
+	return map[string]interface{}{
+		"symbol":           unifiedSymbol(list[0].InstID),
+		"fundingRate":      unifiedNumber(list[0].FundingRate),
+		"fundingTimestamp": timestamp,
+		"fundingDatetime":  datetime,
+		"info":             list[0],
+	}, nil
+}
+
+func (s *server) unifiedFetchBalance(ctx context.Context, _ unifiedArgs, t *tenant) (interface{}, error) {
+	var account struct {
+		Ts      string `json:"ts"`
+		Details []struct {
+			Currency  string `json:"currency"`
+			Equity    string `json:"equity"`
+			Available string `json:"available"`
+			Frozen    string `json:"frozen"`
+		} `json:"details"`
+	}
+	if err := s.blofin.do(ctx, http.MethodGet, "/api/v1/account/balance", nil, nil, t, &account); err != nil {
+		return nil, err
+	}
+	timestamp, datetime := unifiedTimestamp(account.Ts)
+	balance := map[string]interface{}{"info": account, "timestamp": timestamp, "datetime": datetime}
+	free, used, total := map[string]interface{}{}, map[string]interface{}{}, map[string]interface{}{}
+	for _, detail := range account.Details {
+		free[detail.Currency] = unifiedNumber(detail.Available)
+		used[detail.Currency] = unifiedNumber(detail.Frozen)
+		total[detail.Currency] = unifiedNumber(detail.Equity)
+		balance[detail.Currency] = map[string]interface{}{
+			"free":  free[detail.Currency],
+			"used":  used[detail.Currency],
+			"total": total[detail.Currency],
+		}
+	}
+	balance["free"], balance["used"], balance["total"] = free, used, total
+	return balance, nil
+}
+
+func (s *server) unifiedFetchPositions(ctx context.Context, args unifiedArgs, t *tenant) (interface{}, error) {
+	path := "/api/v1/account/positions"
+	if args.str("type") == "copy_trading" {
+		path = "/api/v1/copytrading/account/positions-by-contract"
+	}
+	var list []map[string]string
+	if err := s.blofin.do(ctx, http.MethodGet, path, nil, nil, t, &list); err != nil {
+		return nil, err
+	}
+	wanted := map[string]bool{}
+	for _, symbol := range strings.Split(args.str("symbols"), ",") {
+		if symbol = strings.TrimSpace(symbol); symbol != "" {
+			wanted[normalizeInstID(symbol)] = true
+		}
+	}
+	positions := []map[string]interface{}{}
+	for _, p := range list {
+		if len(wanted) > 0 && !wanted[p["instId"]] {
+			continue
+		}
+		contracts, _ := strconv.ParseFloat(p["positions"], 64)
+		side := p["positionSide"]
+		if side == "net" || side == "" {
+			side = "long"
+			if contracts < 0 {
+				side = "short"
+			}
+		}
+		timestamp, datetime := unifiedTimestamp(p["updateTime"])
+		positions = append(positions, map[string]interface{}{
+			"id":               p["positionId"],
+			"symbol":           unifiedSymbol(p["instId"]),
+			"timestamp":        timestamp,
+			"datetime":         datetime,
+			"side":             side,
+			"contracts":        math.Abs(contracts),
+			"entryPrice":       unifiedNumber(p["averagePrice"]),
+			"markPrice":        unifiedNumber(p["markPrice"]),
+			"liquidationPrice": unifiedNumber(p["liquidationPrice"]),
+			"unrealizedPnl":    unifiedNumber(p["unrealizedPnl"]),
+			"leverage":         unifiedNumber(p["leverage"]),
+			"marginMode":       p["marginMode"],
+			"initialMargin":    unifiedNumber(p["margin"]),
+			"info":             p,
+		})
+	}
+	return positions, nil
+}
+
+// BloFin order states in CCXT terms
+var unifiedOrderStatus = map[string]string{
+	"live":             "open",
+	"partially_filled": "open",
+	"filled":           "closed",
+	"canceled":         "canceled",
+}
+
+func (s *server) unifiedFetchOpenOrders(ctx context.Context, args unifiedArgs, t *tenant) (interface{}, error) {
+	query := url.Values{}
+	if args.str("symbol") != "" {
+		instID, _ := args.instID()
+		query.Set("instId", instID)
+	}
+	var list []map[string]interface{}
+	if err := s.blofin.do(ctx, http.MethodGet, "/api/v1/trade/orders-pending", query, nil, t, &list); err != nil {
+		return nil, err
+	}
+	orders := []map[string]interface{}{}
+	for _, o := range list {
+		field := func(name string) string { v, _ := o[name].(string); return v }
+		amount, _ := strconv.ParseFloat(field("size"), 64)
+		filled, _ := strconv.ParseFloat(field("filledSize"), 64)
+		timestamp, datetime := unifiedTimestamp(field("createTime"))
+		orders = append(orders, map[string]interface{}{
+			"id":            field("orderId"),
+			"clientOrderId": field("clientOrderId"),
+			"timestamp":     timestamp,
+			"datetime":      datetime,
+			"symbol":        unifiedSymbol(field("instId")),
+			"type":          field("orderType"),
+			"side":          field("side"),
+			"price":         unifiedNumber(field("price")),
+			"average":       unifiedNumber(field("averagePrice")),
+			"amount":        amount,
+			"filled":        filled,
+			"remaining":     amount - filled,
+			"status":        unifiedOrderStatus[field("state")],
+			"reduceOnly":    field("reduceOnly") == "true",
+			"info":          o,
+		})
+	}
+	return orders, nil
+}
+
+// Result of a BloFin order or cancel call
+type blofinOrderResult struct {
+	OrderID       string `json:"orderId"`
+	ClientOrderID string `json:"clientOrderId"`
+	Code          string `json:"code"`
+	Msg           string `json:"msg"`
+}
+
+// {"symbol","type","side","amount","price","params":{...}}; params are
+// passed through as extra BloFin order fields (marginMode, reduceOnly, ...)
+func (s *server) unifiedCreateOrder(ctx context.Context, args unifiedArgs, t *tenant) (interface{}, error) {
+	instID, err := args.instID()
+	if err != nil {
+		return nil, err
+	}
+	orderType, side, amount, price := args.str("type"), args.str("side"), args.str("amount"), args.str("price")
+	if orderType == "" || side == "" || amount == "" {
+		return nil, unifiedBadRequest("type, side and amount are required")
+	}
+	order := map[string]interface{}{
+		"instId":       instID,
+		"marginMode":   "cross",
+		"positionSide": "net",
+		"side":         side,
+		"orderType":    orderType,
+		"size":         amount,
+	}
+	if price != "" {
+		order["price"] = price
+	}
+	if params, ok := args["params"].(map[string]interface{}); ok {
+		for key := range params {
+			order[key] = unifiedArgs(params).str(key)
+		}
+	}
+	if id, _ := order["clientOrderId"].(string); id == "" && s.cfg.AutoClientOrderID {
+		order["clientOrderId"] = s.cfg.ClientOrderPrefix + newULID(time.Now())
+	}
+
+	var results []blofinOrderResult
+	if err := s.placeOrder(ctx, t, order, &results); err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("empty order response")
+	}
+	if results[0].Code != "" && results[0].Code != "0" {
+		return nil, &blofinError{Status: http.StatusOK, Code: results[0].Code, Msg: results[0].Msg}
+	}
+	now := time.Now().UTC()
+	return map[string]interface{}{
+		"id":            results[0].OrderID,
+		"clientOrderId": results[0].ClientOrderID,
+		"timestamp":     now.UnixMilli(),
+		"datetime":      now.Format("2006-01-02T15:04:05.000Z"),
+		"symbol":        unifiedSymbol(instID),
+		"type":          orderType,
+		"side":          side,
+		"amount":        unifiedNumber(amount),
+		"price":         unifiedNumber(price),
+		"status":        "open",
+		"info":          results[0],
+	}, nil
+}
+
+// {"id","symbol"}
+func (s *server) unifiedCancelOrder(ctx context.Context, args unifiedArgs, t *tenant) (interface{}, error) {
+	id := args.str("id")
+	if id == "" {
+		return nil, unifiedBadRequest("id is required")
+	}
+	body := map[string]string{"orderId": id}
+	if args.str("symbol") != "" {
+		body["instId"], _ = args.instID()
+	}
+	var results []blofinOrderResult
+	if err := s.blofin.do(ctx, http.MethodPost, "/api/v1/trade/cancel-order", nil, body, t, &results); err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("empty cancel response")
+	}
+	if results[0].Code != "" && results[0].Code != "0" {
+		return nil, &blofinError{Status: http.StatusOK, Code: results[0].Code, Msg: results[0].Msg}
+	}
+	return map[string]interface{}{"id": results[0].OrderID, "status": "canceled", "info": results[0]}, nil
+}
@@ -0,0 +1,312 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Upstreams the proxy talks to, each with its own connection pool and
+// circuit breaker so a slow one can't starve the others
+const (
+	UPSTREAM_BLOFIN = "blofin"
+	UPSTREAM_SHADOW = "shadow"
+)
+
+const (
+	DEFAULT_BREAKER_FAILURES = 5
+	DEFAULT_BREAKER_COOLDOWN = 30 * time.Second
+	DEFAULT_SLOW_REQUEST     = 2 * time.Second
+)
+
+var errCircuitOpen = errors.New("circuit breaker open")
+
+// Consecutive failures (transport errors or 5xx) that open an upstream's
+// breaker, and how long it stays open before a trial request is let
+// through. Failures of 0 disables the breaker.
+type BreakerSettings struct {
+	Failures int
+	Cooldown time.Duration
+}
+
+type upstream struct {
+	name      string
+	transport *http.Transport
+	breaker   *circuitBreaker
+	slow      time.Duration // round trips slower than this are logged; 0 disables
+
+	inflight atomic.Int64 // until the response body is closed
+	conns    atomic.Int64
+	ok       atomic.Uint64
+	failed   atomic.Uint64
+	rejected atomic.Uint64 // refused while the breaker was open
+	aborted  atomic.Uint64 // the client went away first
+	reused   atomic.Uint64
+	fresh    atomic.Uint64
+	slowHits atomic.Uint64
+	phases   [PHASE_COUNT]phaseStat
+}
+
+func newUpstream(name string, settings TransportSettings, dns *dnsCache, breaker BreakerSettings, slow time.Duration) *upstream {
+	u := &upstream{name: name, transport: newUpstreamTransport(settings, dns), slow: slow}
+	if breaker.Failures > 0 {
+		u.breaker = &circuitBreaker{threshold: breaker.Failures, cooldown: breaker.Cooldown}
+	}
+	dial := u.transport.DialContext
+	u.transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		u.conns.Add(1)
+		return &countedConn{Conn: conn, upstream: u}, nil
+	}
+	return u
+}
+
+func (u *upstream) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !u.breaker.allow() {
+		u.rejected.Add(1)
+		return nil, errCircuitOpen
+	}
+	u.inflight.Add(1)
+	timing := &roundTripTiming{}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), timing.trace()))
+	resp, err := u.transport.RoundTrip(req)
+	timing.finish()
+	u.observe(timing)
+	if total := timing.phases()[PHASE_TOTAL]; u.slow > 0 && total > u.slow {
+		u.slowHits.Add(1)
+		log.Printf("🐢 Slow %s round trip: %s %s took %s (%s)", u.name, req.Method, req.URL.Path, total.Round(time.Millisecond), timing)
+	}
+	if err != nil {
+		u.inflight.Add(-1)
+	} else {
+		resp.Body = &trackedBody{ReadCloser: resp.Body, done: func() { u.inflight.Add(-1) }}
+	}
+	switch {
+	case err != nil && errors.Is(req.Context().Err(), context.Canceled):
+		// The caller gave up; says nothing about the upstream's health
+		u.aborted.Add(1)
+		u.breaker.release()
+	case err != nil && req.Context().Err() != nil:
+		// Out of time: a failure to report, but too short a deadline
+		// shouldn't open the breaker
+		u.failed.Add(1)
+		u.breaker.release()
+	case err != nil || resp.StatusCode >= 500:
+		u.failed.Add(1)
+		u.breaker.record(false)
+	default:
+		u.ok.Add(1)
+		u.breaker.record(true)
+	}
+	return resp, err
+}
+
+func (u *upstream) observe(t *roundTripTiming) {
+	got, reused := t.conn()
+	if !got {
+		return
+	}
+	if reused {
+		u.reused.Add(1)
+	} else {
+		u.fresh.Add(1)
+	}
+	for phase, d := range t.phases() {
+		if d > 0 {
+			u.phases[phase].sum.Add(int64(d))
+			u.phases[phase].count.Add(1)
+		}
+	}
+}
+
+// Calls done once, when the body is closed or read to the end
+type trackedBody struct {
+	io.ReadCloser
+	done func()
+	once sync.Once
+}
+
+func (b *trackedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.done)
+	}
+	return n, err
+}
+
+func (b *trackedBody) Close() error {
+	b.once.Do(b.done)
+	return b.ReadCloser.Close()
+}
+
+// Decrements the upstream's open connection count once, on close
+type countedConn struct {
+	net.Conn
+	upstream *upstream
+	once     sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(func() { c.upstream.conns.Add(-1) })
+	return c.Conn.Close()
+}
+
+const (
+	BREAKER_CLOSED = iota
+	BREAKER_OPEN
+	BREAKER_HALF_OPEN
+)
+
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    int
+	failures int
+	openedAt time.Time
+	trial    bool // a half-open trial request is in flight
+}
+
+// A nil breaker allows everything
+func (b *circuitBreaker) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BREAKER_OPEN:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = BREAKER_HALF_OPEN
+		b.trial = true
+		return true
+	case BREAKER_HALF_OPEN:
+		// Only the one trial request until it reports back
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	}
+	return true
+}
+
+func (b *circuitBreaker) record(ok bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if ok {
+		b.state, b.failures = BREAKER_CLOSED, 0
+		return
+	}
+	b.failures++
+	if b.state == BREAKER_HALF_OPEN || b.failures >= b.threshold {
+		b.state, b.openedAt = BREAKER_OPEN, time.Now()
+	}
+}
+
+// Free a half-open trial slot without counting a result
+func (b *circuitBreaker) release() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.trial = false
+	b.mu.Unlock()
+}
+
+func (b *circuitBreaker) current() int {
+	if b == nil {
+		return BREAKER_CLOSED
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Register per-upstream pool, request and breaker metrics
+func registerUpstreamMetrics(metrics *metricsRegistry, upstreams []*upstream) {
+	collect := func(value func(u *upstream) map[string]float64) func() map[string]float64 {
+		return func() map[string]float64 {
+			values := map[string]float64{}
+			for _, u := range upstreams {
+				for key, v := range value(u) {
+					values[key] = v
+				}
+			}
+			return values
+		}
+	}
+	metrics.registerFunc("blofin_upstream_connections", METRIC_GAUGE, "Open connections to each upstream, idle or in use",
+		collect(func(u *upstream) map[string]float64 {
+			return map[string]float64{labels("upstream", u.name): float64(u.conns.Load())}
+		}))
+	metrics.registerFunc("blofin_upstream_idle_connections", METRIC_GAUGE, "Open connections not carrying a request (exact for HTTP/1.1, an estimate under HTTP/2)",
+		collect(func(u *upstream) map[string]float64 {
+			idle := u.conns.Load() - u.inflight.Load()
+			if idle < 0 {
+				idle = 0
+			}
+			return map[string]float64{labels("upstream", u.name): float64(idle)}
+		}))
+	metrics.registerFunc("blofin_upstream_conn_acquisitions_total", METRIC_COUNTER, "Connections taken for a request, by whether an idle one was reused",
+		collect(func(u *upstream) map[string]float64 {
+			return map[string]float64{
+				labels("upstream", u.name, "reused", "true"):  float64(u.reused.Load()),
+				labels("upstream", u.name, "reused", "false"): float64(u.fresh.Load()),
+			}
+		}))
+	metrics.registerFunc("blofin_upstream_phase_seconds_total", METRIC_COUNTER, "Time spent per round-trip phase; divide by the observation count for the mean",
+		collect(func(u *upstream) map[string]float64 {
+			values := map[string]float64{}
+			for phase, name := range phaseNames {
+				values[labels("upstream", u.name, "phase", name)] = time.Duration(u.phases[phase].sum.Load()).Seconds()
+			}
+			return values
+		}))
+	metrics.registerFunc("blofin_upstream_phase_observations_total", METRIC_COUNTER, "Round trips that went through each phase",
+		collect(func(u *upstream) map[string]float64 {
+			values := map[string]float64{}
+			for phase, name := range phaseNames {
+				values[labels("upstream", u.name, "phase", name)] = float64(u.phases[phase].count.Load())
+			}
+			return values
+		}))
+	metrics.registerFunc("blofin_upstream_inflight_requests", METRIC_GAUGE, "Requests currently waiting on or streaming from each upstream",
+		collect(func(u *upstream) map[string]float64 {
+			return map[string]float64{labels("upstream", u.name): float64(u.inflight.Load())}
+		}))
+	metrics.registerFunc("blofin_upstream_requests_total", METRIC_COUNTER, "Upstream requests by result",
+		collect(func(u *upstream) map[string]float64 {
+			return map[string]float64{
+				labels("upstream", u.name, "result", "ok"):       float64(u.ok.Load()),
+				labels("upstream", u.name, "result", "error"):    float64(u.failed.Load()),
+				labels("upstream", u.name, "result", "rejected"): float64(u.rejected.Load()),
+				labels("upstream", u.name, "result", "aborted"):  float64(u.aborted.Load()),
+			}
+		}))
+	metrics.registerFunc("blofin_upstream_slow_requests_total", METRIC_COUNTER, "Round trips slower than SLOW_REQUEST_THRESHOLD",
+		collect(func(u *upstream) map[string]float64 {
+			return map[string]float64{labels("upstream", u.name): float64(u.slowHits.Load())}
+		}))
+	metrics.registerFunc("blofin_upstream_breaker_state", METRIC_GAUGE, "Circuit breaker state: 0 closed, 1 open, 2 half-open",
+		collect(func(u *upstream) map[string]float64 {
+			return map[string]float64{labels("upstream", u.name): float64(u.breaker.current())}
+		}))
+}
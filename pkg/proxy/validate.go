@@ -0,0 +1,203 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// A single problem found while checking a request against the route table
+type validationIssue struct {
+	Location string            `json:"location"` // path, query or body
+	Field    string            `json:"field,omitempty"`
+	Message  string            `json:"message"`
+	Values   map[string]string `json:"values,omitempty"` // numbers behind the message
+}
+
+// Check the method and path against the route table. Unknown paths that are
+// not close to any known route pass through, since the table isn't exhaustive.
+func validateRoute(method, path string) (*apiRoute, []validationIssue) {
+	var sameMethod []string
+	for i := range blofinRoutes {
+		route := &blofinRoutes[i]
+		if route.Path == path {
+			if route.Method == method {
+				return route, nil
+			}
+			continue
+		}
+		if route.Method == method {
+			sameMethod = append(sameMethod, route.Path)
+		}
+	}
+
+	if allowed := routeMethods(path); len(allowed) > 0 {
+		return nil, []validationIssue{{
+			Location: "path",
+			Message:  fmt.Sprintf("%s is not supported on %s, use %s", method, path, strings.Join(allowed, " or ")),
+		}}
+	}
+
+	if suggestion := closestMatch(path, sameMethod, 3); suggestion != "" {
+		return nil, []validationIssue{{
+			Location: "path",
+			Message:  fmt.Sprintf("unknown endpoint %s, did you mean %s?", path, suggestion),
+		}}
+	}
+	return nil, nil
+}
+
+// Check query parameters and JSON body against the route's expectations
+func validateRequest(route *apiRoute, query url.Values, body []byte) []validationIssue {
+	var issues []validationIssue
+
+	known := paramNames(route.Query)
+	for _, p := range route.Query {
+		if p.Required && query.Get(p.Name) == "" {
+			issues = append(issues, validationIssue{Location: "query", Field: p.Name, Message: "missing required parameter"})
+		}
+	}
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !known[name] {
+			issues = append(issues, unknownField("query", name, route.Query))
+		}
+	}
+
+	if len(route.Body) == 0 {
+		return issues
+	}
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return append(issues, validationIssue{Location: "body", Message: "request body is required"})
+	}
+
+	if route.BodyArray {
+		var items []map[string]interface{}
+		if err := json.Unmarshal(body, &items); err != nil {
+			return append(issues, validationIssue{Location: "body", Message: "body must be a JSON array of objects: " + err.Error()})
+		}
+		if len(items) == 0 {
+			return append(issues, validationIssue{Location: "body", Message: "body must contain at least one item"})
+		}
+		for i, item := range items {
+			issues = append(issues, validateObject(fmt.Sprintf("[%d].", i), item, route.Body)...)
+		}
+		return issues
+	}
+
+	var obj map[string]interface{}
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return append(issues, validationIssue{Location: "body", Message: "body must be a JSON object: " + err.Error()})
+	}
+	return append(issues, validateObject("", obj, route.Body)...)
+}
+
+func validateObject(prefix string, obj map[string]interface{}, params []apiParam) []validationIssue {
+	var issues []validationIssue
+	known := paramNames(params)
+
+	for _, p := range params {
+		value, ok := obj[p.Name]
+		if !ok || value == nil || value == "" {
+			if p.Required {
+				issues = append(issues, validationIssue{Location: "body", Field: prefix + p.Name, Message: "missing required field"})
+			}
+			continue
+		}
+		if got := jsonType(value); got != p.Type {
+			issues = append(issues, validationIssue{
+				Location: "body",
+				Field:    prefix + p.Name,
+				Message:  fmt.Sprintf("expected %s, got %s", p.Type, got),
+			})
+		}
+	}
+
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !known[name] {
+			issue := unknownField("body", name, params)
+			issue.Field = prefix + name
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+func unknownField(location, name string, params []apiParam) validationIssue {
+	candidates := make([]string, 0, len(params))
+	for _, p := range params {
+		candidates = append(candidates, p.Name)
+	}
+	message := "unknown parameter"
+	if suggestion := closestMatch(name, candidates, 3); suggestion != "" {
+		message = fmt.Sprintf("unknown parameter, did you mean %q?", suggestion)
+	}
+	return validationIssue{Location: location, Field: name, Message: message}
+}
+
+func paramNames(params []apiParam) map[string]bool {
+	names := make(map[string]bool, len(params))
+	for _, p := range params {
+		names[p.Name] = true
+	}
+	return names
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "null"
+}
+
+// Closest candidate within maxDistance edits, compared case-insensitively
+func closestMatch(name string, candidates []string, maxDistance int) string {
+	best, bestDistance := "", maxDistance+1
+	for _, candidate := range candidates {
+		d := levenshtein(strings.ToLower(name), strings.ToLower(candidate))
+		if d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestValidateRoute(t *testing.T) {
+	tests := []struct {
+		name, method, path string
+		found              bool
+		issue              string
+	}{
+		{"known", http.MethodPost, "/api/v1/trade/order", true, ""},
+		{"wrong method", http.MethodGet, "/api/v1/trade/order", false, "GET is not supported on /api/v1/trade/order, use POST"},
+		{"typo", http.MethodGet, "/api/v1/market/tikers", false, "did you mean /api/v1/market/tickers?"},
+		{"unknown", http.MethodGet, "/api/v2/something/else", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route, issues := validateRoute(tt.method, tt.path)
+			if (route != nil) != tt.found {
+				t.Errorf("route = %v, want found %v", route, tt.found)
+			}
+			if tt.issue == "" {
+				if len(issues) != 0 {
+					t.Errorf("issues = %+v, want none", issues)
+				}
+				return
+			}
+			if len(issues) != 1 || issues[0].Location != "path" || !strings.Contains(issues[0].Message, tt.issue) {
+				t.Errorf("issues = %+v, want %q", issues, tt.issue)
+			}
+		})
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	order, _ := validateRoute(http.MethodPost, "/api/v1/trade/order")
+	batch, _ := validateRoute(http.MethodPost, "/api/v1/trade/batch-orders")
+	candles, _ := validateRoute(http.MethodGet, "/api/v1/market/candles")
+	valid := `{"instId":"BTC-USDT","marginMode":"cross","side":"buy","orderType":"market","size":"1"}`
+
+	tests := []struct {
+		name   string
+		route  *apiRoute
+		query  url.Values
+		body   string
+		fields []string // Location/Field of each expected issue
+		issue  string   // part of the first issue's message
+	}{
+		{"valid order", order, nil, valid, nil, ""},
+		{"missing body", order, nil, " ", []string{"body/"}, "request body is required"},
+		{"not an object", order, nil, `[1]`, []string{"body/"}, "must be a JSON object"},
+		{"missing fields", order, nil, `{"instId":"BTC-USDT","marginMode":"cross"}`, []string{"body/side", "body/orderType", "body/size"}, "missing required field"},
+		{"wrong type", order, nil, strings.Replace(valid, `"size":"1"`, `"size":1`, 1), []string{"body/size"}, "expected string, got number"},
+		{"unknown field", order, nil, strings.Replace(valid, `}`, `,"reduceOnyl":"true"}`, 1), []string{"body/reduceOnyl"}, `did you mean "reduceOnly"?`},
+		{"empty batch", batch, nil, `[]`, []string{"body/"}, "at least one item"},
+		{"batch item", batch, nil, `[` + valid + `,{"instId":"BTC-USDT","marginMode":"cross","side":"buy","orderType":"market"}]`, []string{"body/[1].size"}, "missing required field"},
+		{"missing query", candles, url.Values{}, "", []string{"query/instId"}, "missing required parameter"},
+		{"unknown query", candles, url.Values{"instId": {"BTC-USDT"}, "bars": {"1m"}}, "", []string{"query/bars"}, `did you mean "bar"?`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := validateRequest(tt.route, tt.query, []byte(tt.body))
+			var fields []string
+			for _, issue := range issues {
+				fields = append(fields, issue.Location+"/"+issue.Field)
+			}
+			if strings.Join(fields, " ") != strings.Join(tt.fields, " ") {
+				t.Fatalf("issues = %+v, want %v", issues, tt.fields)
+			}
+			if tt.issue != "" && !strings.Contains(issues[0].Message, tt.issue) {
+				t.Errorf("message = %q, want %q", issues[0].Message, tt.issue)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Headers and JSON fields never written to a cassette
+var redactedHeaders = map[string]bool{
+	"Access-Key":        true,
+	"Access-Sign":       true,
+	"Access-Passphrase": true,
+	"Access-Nonce":      true,
+	"Access-Timestamp":  true,
+	"Authorization":     true,
+	"Cookie":            true,
+	"Set-Cookie":        true,
+	"X-Proxy-Token":     true,
+	"X-Mbx-Apikey":      true,
+}
+
+var redactedFields = map[string]bool{
+	"apiKey":     true,
+	"secret":     true,
+	"secretKey":  true,
+	"passphrase": true,
+	"sign":       true,
+	"signature":  true,
+}
+
+const REDACTED = "[REDACTED]"
+
+// One recorded request/response pair
+type cassette struct {
+	RecordedAt string           `json:"recordedAt"`
+	Request    cassetteRequest  `json:"request"`
+	Response   cassetteResponse `json:"response"`
+}
+
+type cassetteRequest struct {
+	Method  string      `json:"method"`
+	Path    string      `json:"path"`
+	Query   string      `json:"query,omitempty"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+type cassetteResponse struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// Cassette store for MODE=record and MODE=replay. Each distinct request
+// (method, path, query and body) maps to one file, so replays are
+// deterministic and re-recording overwrites the previous take.
+type vcr struct {
+	dir string
+}
+
+func newVCR(dir string) (*vcr, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &vcr{dir: dir}, nil
+}
+
+var unsafePathChars = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+func (v *vcr) filename(method, path, rawQuery string, body []byte) string {
+	// Sort the query so parameter order doesn't produce different cassettes
+	query, err := url.ParseQuery(rawQuery)
+	if err == nil {
+		rawQuery = query.Encode()
+	}
+	sum := sha256.Sum256([]byte(method + " " + path + "?" + rawQuery + "\n" + string(body)))
+	name := strings.Trim(unsafePathChars.ReplaceAllString(strings.TrimPrefix(path, "/api/"), "_"), "_")
+	return filepath.Join(v.dir, fmt.Sprintf("%s_%s_%s.json", strings.ToLower(method), name, hex.EncodeToString(sum[:6])))
+}
+
+func (v *vcr) record(r *http.Request, reqBody []byte, resp *http.Response, respBody []byte) {
+	c := cassette{
+		RecordedAt: time.Now().UTC().Format(time.RFC3339),
+		Request: cassetteRequest{
+			Method:  r.Method,
+			Path:    r.URL.Path,
+			Query:   r.URL.RawQuery,
+			Headers: redactHeaders(r.Header),
+			Body:    redactBody(reqBody),
+		},
+		Response: cassetteResponse{
+			Status:  resp.StatusCode,
+			Headers: redactHeaders(resp.Header),
+			Body:    redactBody(respBody),
+		},
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		log.Printf("❌ Failed to encode cassette: %v", err)
+		return
+	}
+	name := v.filename(r.Method, r.URL.Path, r.URL.RawQuery, reqBody)
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		log.Printf("❌ Failed to write cassette %s: %v", name, err)
+		return
+	}
+	log.Printf("📼 Recorded %s %s -> %s", r.Method, r.URL.Path, name)
+}
+
+func (v *vcr) replay(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MAX_INSPECT_BODY))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Failed to read request body"})
+		return
+	}
+
+	name := v.filename(r.Method, r.URL.Path, r.URL.RawQuery, body)
+	data, err := os.ReadFile(name)
+	if err != nil {
+		log.Printf("📼 No cassette for %s %s", r.Method, r.URL.Path)
+		writeJSON(w, http.StatusNotFound, map[string]string{
+			"error":    fmt.Sprintf("No recorded response for %s %s", r.Method, r.URL.RequestURI()),
+			"cassette": filepath.Base(name),
+		})
+		return
+	}
+	var c cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		log.Printf("❌ Corrupt cassette %s: %v", name, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Corrupt cassette"})
+		return
+	}
+
+	for name, values := range c.Response.Headers {
+		if isHopByHopHeader(name) || name == "Content-Length" {
+			continue
+		}
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	w.WriteHeader(c.Response.Status)
+	io.WriteString(w, c.Response.Body)
+	log.Printf("📼 Replayed %s %s (Status: %d)", r.Method, r.URL.Path, c.Response.Status)
+}
+
+func redactHeaders(headers http.Header) http.Header {
+	out := http.Header{}
+	for name, values := range headers {
+		if redactedHeaders[http.CanonicalHeaderKey(name)] {
+			out[name] = []string{REDACTED}
+			continue
+		}
+		out[name] = append([]string(nil), values...)
+	}
+	return out
+}
+
+// Blank out secret-looking fields anywhere in a JSON body; non-JSON bodies
+// are stored as-is
+func redactBody(body []byte) string {
+	doc, err := decodeJSON(body)
+	if len(body) == 0 || err != nil {
+		return string(body)
+	}
+	redacted, err := json.Marshal(redactValue(doc))
+	if err != nil {
+		return string(body)
+	}
+	return string(redacted)
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if redactedFields[key] {
+				v[key] = REDACTED
+			} else {
+				v[key] = redactValue(child)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactValue(child)
+		}
+	}
+	return value
+}
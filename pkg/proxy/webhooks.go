@@ -0,0 +1,314 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_WEBHOOK_MAX_AGE = 5 * time.Minute
+
+	WEBHOOK_SIGNATURE_HEADER = "X-Webhook-Signature"
+	WEBHOOK_TIMESTAMP_HEADER = "X-Webhook-Timestamp"
+)
+
+// Signatures of webhooks already acted on, kept until they are too old to
+// be accepted anyway, so a captured request can't be sent again
+type replayGuard struct {
+	maxAge time.Duration
+
+	mu   sync.Mutex
+	seen map[string]time.Time // signature or message hash -> when it expires
+}
+
+func newReplayGuard(maxAge time.Duration) *replayGuard {
+	return &replayGuard{maxAge: maxAge, seen: map[string]time.Time{}}
+}
+
+// Record key until then, false if it is already recorded
+func (g *replayGuard) first(key string, now, until time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for k, expires := range g.seen {
+		if now.After(expires) {
+			delete(g.seen, k)
+		}
+	}
+	if _, ok := g.seen[key]; ok {
+		return false
+	}
+	g.seen[key] = until
+	return true
+}
+
+// Hex HMAC-SHA256 of "<timestamp>.<body>" under secret
+func webhookSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Check a signed webhook: the signature must match one of secrets, the
+// timestamp (Unix seconds or milliseconds) must be within maxAge of now,
+// and the signature must not have been used before. Returns the index of
+// the secret that matched, or the status and message to refuse it with.
+func (g *replayGuard) verify(r *http.Request, body []byte, secrets []string) (int, int, string) {
+	signature := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(r.Header.Get(WEBHOOK_SIGNATURE_HEADER))), "sha256=")
+	timestamp := strings.TrimSpace(r.Header.Get(WEBHOOK_TIMESTAMP_HEADER))
+	if signature == "" || timestamp == "" {
+		return -1, http.StatusUnauthorized, fmt.Sprintf("%s and %s are required", WEBHOOK_SIGNATURE_HEADER, WEBHOOK_TIMESTAMP_HEADER)
+	}
+	match := -1
+	for i, secret := range secrets {
+		if hmac.Equal([]byte(webhookSignature(secret, timestamp, body)), []byte(signature)) {
+			match = i
+			break
+		}
+	}
+	if match < 0 {
+		return -1, http.StatusUnauthorized, "Invalid webhook signature"
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return -1, http.StatusUnauthorized, WEBHOOK_TIMESTAMP_HEADER + " must be a Unix time"
+	}
+	sent := time.Unix(ts, 0)
+	if ts > 1e12 {
+		sent = time.UnixMilli(ts)
+	}
+	now := time.Now()
+	if age := now.Sub(sent); age > g.maxAge || age < -g.maxAge {
+		return -1, http.StatusUnauthorized, fmt.Sprintf("Webhook timestamp is %s off the proxy's clock (at most %s allowed)", age.Round(time.Second), g.maxAge)
+	}
+	// Timestamps may be ahead by maxAge too
+	if !g.first(signature, now, now.Add(2*g.maxAge)) {
+		return -1, http.StatusConflict, "This webhook was already received"
+	}
+	return match, 0, ""
+}
+
+// How a tenant's TradingView alerts become orders. Alerts may name the
+// instrument, side and size themselves; these fill in the rest.
+type tradingViewSettings struct {
+	Secret       string `json:"secret,omitempty"`       // alerts must include it as "secret"
+	SigningKey   string `json:"signingKey,omitempty"`   // alerts must be signed with it instead
+	InstID       string `json:"instId,omitempty"`       // when the alert names none
+	Size         string `json:"size,omitempty"`         // contracts, when the alert gives none
+	MaxSize      string `json:"maxSize,omitempty"`      // larger alerts are refused
+	OrderType    string `json:"orderType,omitempty"`    // default market
+	MarginMode   string `json:"marginMode,omitempty"`   // default cross
+	PositionSide string `json:"positionSide,omitempty"` // default net
+}
+
+func (tv *tradingViewSettings) validate() error {
+	if tv.Secret == "" && tv.SigningKey == "" {
+		return fmt.Errorf("tradingView.secret or tradingView.signingKey is required")
+	}
+	for field, value := range map[string]string{"size": tv.Size, "maxSize": tv.MaxSize} {
+		if n, ok := parseDecimal(value); value != "" && (!ok || n.Sign() <= 0) {
+			return fmt.Errorf("tradingView.%s must be a positive number", field)
+		}
+	}
+	if tv.OrderType != "" && !validOrderTypes[tv.OrderType] {
+		return fmt.Errorf("tradingView.orderType must be one of market, limit, post_only, fok, ioc")
+	}
+	return nil
+}
+
+// Tenant whose TradingView secret matches, nil if none does. Tenants with
+// a signing key only accept signed alerts.
+func (reg *tenantRegistry) byTradingViewSecret(secret string) *tenant {
+	if secret == "" {
+		return nil
+	}
+	for _, t := range reg.list {
+		if tv := t.TradingView; tv != nil && tv.SigningKey == "" && subtle.ConstantTimeCompare([]byte(tv.Secret), []byte(secret)) == 1 {
+			return t
+		}
+	}
+	return nil
+}
+
+// Tenants with a TradingView signing key, and their keys
+func (reg *tenantRegistry) tradingViewSigners() ([]*tenant, []string) {
+	var signers []*tenant
+	var keys []string
+	for _, t := range reg.list {
+		if t.TradingView != nil && t.TradingView.SigningKey != "" {
+			signers = append(signers, t)
+			keys = append(keys, t.TradingView.SigningKey)
+		}
+	}
+	return signers, keys
+}
+
+// TradingView tickers carry an exchange prefix and a .P suffix for
+// perpetuals: BLOFIN:BTCUSDT.P -> BTC-USDT
+func tradingViewInstID(ticker string) string {
+	if i := strings.LastIndex(ticker, ":"); i >= 0 && !strings.Contains(ticker[:i], "/") {
+		ticker = ticker[i+1:]
+	}
+	return normalizeInstID(strings.TrimSuffix(strings.ToUpper(ticker), ".P"))
+}
+
+// The BloFin order an alert asks for, filled in from the tenant's settings
+func (tv *tradingViewSettings) order(alert unifiedArgs) (map[string]string, error) {
+	instID := alert.str("instId")
+	if instID == "" {
+		instID = alert.str("ticker")
+	}
+	if instID == "" {
+		instID = tv.InstID
+	}
+	side := strings.ToLower(alert.str("action"))
+	size := alert.str("size")
+	if size == "" {
+		size = tv.Size
+	}
+	orderType := firstNonEmpty(alert.str("orderType"), tv.OrderType, "market")
+	switch {
+	case instID == "":
+		return nil, fmt.Errorf("the alert names no ticker or instId and no default instId is configured")
+	case side != "buy" && side != "sell":
+		return nil, fmt.Errorf("action must be buy or sell, got %q", alert.str("action"))
+	case size == "":
+		return nil, fmt.Errorf("the alert has no size and no default size is configured")
+	case !validOrderTypes[orderType]:
+		return nil, fmt.Errorf("orderType must be one of market, limit, post_only, fok, ioc")
+	case orderType != "market" && alert.str("price") == "":
+		return nil, fmt.Errorf("price is required for %s orders", orderType)
+	}
+	value, ok := parseDecimal(size)
+	if !ok || value.Sign() <= 0 {
+		return nil, fmt.Errorf("size must be a positive number of contracts, got %q", size)
+	}
+	if max, ok := parseDecimal(tv.MaxSize); ok && value.Cmp(max) > 0 {
+		return nil, fmt.Errorf("size %s is above the configured maximum of %s", size, tv.MaxSize)
+	}
+	order := map[string]string{
+		"instId":       tradingViewInstID(instID),
+		"marginMode":   firstNonEmpty(tv.MarginMode, "cross"),
+		"positionSide": firstNonEmpty(tv.PositionSide, "net"),
+		"side":         side,
+		"orderType":    orderType,
+		"size":         size,
+	}
+	if orderType != "market" {
+		order["price"] = alert.str("price")
+	}
+	if strings.EqualFold(alert.str("reduceOnly"), "true") {
+		order["reduceOnly"] = "true"
+	}
+	if id := alert.str("clientOrderId"); id != "" {
+		order["clientOrderId"] = id
+	}
+	return order, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// POST /webhooks/tradingview: place the order a TradingView alert asks
+// for, as the tenant whose secret the alert carries. TradingView sends the
+// alert message as the body, so it must be JSON with placeholders such as
+// {"secret": "...", "ticker": "{{ticker}}", "action": "{{strategy.order.action}}", "size": "{{strategy.order.contracts}}"}.
+// Alerts relayed by something that can sign them carry X-Webhook-Signature
+// instead of the secret.
+func (s *server) handleTradingView(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MAX_INSPECT_BODY))
+	if err != nil {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "Alert message too large"})
+		return
+	}
+	alert := unifiedArgs{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&alert); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "The alert message must be JSON: " + err.Error()})
+		return
+	}
+	var t *tenant
+	if r.Header.Get(WEBHOOK_SIGNATURE_HEADER) != "" {
+		signers, keys := s.tenants.tradingViewSigners()
+		i, status, msg := s.replays.verify(r, body, keys)
+		if i < 0 {
+			log.Printf("📺 TradingView alert from %s refused: %s", r.RemoteAddr, msg)
+			writeJSON(w, status, map[string]string{"error": msg})
+			return
+		}
+		t = signers[i]
+		attributeRequest(r, t)
+	} else {
+		if t = s.tenants.byTradingViewSecret(alert.str("secret")); t == nil {
+			log.Printf("📺 TradingView alert with an unknown secret from %s", r.RemoteAddr)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unknown webhook secret"})
+			return
+		}
+		attributeRequest(r, t)
+		// Without a signature to go by, a message is a replay when it
+		// repeats one received within the max age
+		sum := sha256.Sum256(body)
+		now := time.Now()
+		if !s.replays.first(t.Name+" "+hex.EncodeToString(sum[:]), now, now.Add(s.replays.maxAge)) {
+			log.Printf("📺 Repeated TradingView alert for %s refused", t.Name)
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "This alert was already received; include {{timenow}} in the message to send identical alerts"})
+			return
+		}
+	}
+	if err := t.allows(http.MethodPost, "/api/v1/trade/order"); err != nil {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+		return
+	}
+	var order map[string]string
+	if name := alert.str("template"); name != "" {
+		order, err = s.templateOrder(r.Context(), t, name, alert)
+	} else if order, err = t.TradingView.order(alert); err != nil {
+		err = &helperRefusal{msg: err.Error()}
+	}
+	if err != nil {
+		log.Printf("📺 TradingView alert for %s refused: %v", t.Name, err)
+		e, ok := err.(*helperRefusal)
+		if !ok {
+			helperFailed(w, r, "Sizing the order failed", err)
+			return
+		}
+		response := map[string]interface{}{"error": e.msg}
+		if len(e.values) > 0 {
+			response["values"] = e.values
+		}
+		writeJSON(w, http.StatusBadRequest, response)
+		return
+	}
+	if order["clientOrderId"] == "" && s.cfg.AutoClientOrderID {
+		order["clientOrderId"] = s.cfg.ClientOrderPrefix + newULID(time.Now())
+	}
+
+	var placed []blofinOrderResult
+	err = s.placeOrder(r.Context(), t, order, &placed)
+	if err = orderOutcome(placed, err); err != nil {
+		log.Printf("📺 TradingView order for %s failed: %v", t.Name, err)
+		helperFailed(w, r, "Order failed", err)
+		return
+	}
+	log.Printf("📺 TradingView alert for %s: %s %s %s, order %s", t.Name, order["side"], order["size"], order["instId"], placed[0].OrderID)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"order": placed[0], "request": order})
+}
@@ -0,0 +1,221 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signedAlert(key, timestamp, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/webhooks/tradingview", strings.NewReader(body))
+	if timestamp != "" {
+		r.Header.Set(WEBHOOK_TIMESTAMP_HEADER, timestamp)
+	}
+	if key != "" {
+		r.Header.Set(WEBHOOK_SIGNATURE_HEADER, webhookSignature(key, timestamp, []byte(body)))
+	}
+	return r
+}
+
+func TestReplayGuardVerify(t *testing.T) {
+	body := `{"action":"buy"}`
+	now := time.Now()
+	seconds := strconv.FormatInt(now.Unix(), 10)
+	keys := []string{"key-a", "key-b"}
+
+	tests := []struct {
+		name    string
+		request func() *http.Request
+		match   int
+		status  int
+	}{
+		{"valid", func() *http.Request { return signedAlert("key-a", seconds, body) }, 0, 0},
+		{"second key", func() *http.Request { return signedAlert("key-b", seconds, body) }, 1, 0},
+		{"milliseconds", func() *http.Request {
+			return signedAlert("key-a", strconv.FormatInt(now.UnixMilli(), 10), body)
+		}, 0, 0},
+		{"sha256 prefix", func() *http.Request {
+			r := signedAlert("", seconds, body+" ")
+			r.Header.Set(WEBHOOK_SIGNATURE_HEADER, "sha256="+strings.ToUpper(webhookSignature("key-a", seconds, []byte(body+" "))))
+			return r
+		}, 0, 0},
+		{"unknown key", func() *http.Request { return signedAlert("key-c", seconds, body) }, -1, http.StatusUnauthorized},
+		{"tampered body", func() *http.Request {
+			r := signedAlert("key-a", seconds, body)
+			r.Header.Set(WEBHOOK_SIGNATURE_HEADER, webhookSignature("key-a", seconds, []byte(`{"action":"sell"}`)))
+			return r
+		}, -1, http.StatusUnauthorized},
+		{"tampered timestamp", func() *http.Request {
+			r := signedAlert("key-a", seconds, body+"  ")
+			r.Header.Set(WEBHOOK_TIMESTAMP_HEADER, strconv.FormatInt(now.Unix()+1, 10))
+			return r
+		}, -1, http.StatusUnauthorized},
+		{"no signature", func() *http.Request { return signedAlert("", seconds, body) }, -1, http.StatusUnauthorized},
+		{"no timestamp", func() *http.Request {
+			r := signedAlert("key-a", seconds, body)
+			r.Header.Del(WEBHOOK_TIMESTAMP_HEADER)
+			return r
+		}, -1, http.StatusUnauthorized},
+		{"not a number", func() *http.Request { return signedAlert("key-a", "yesterday", body) }, -1, http.StatusUnauthorized},
+		{"stale", func() *http.Request {
+			return signedAlert("key-a", strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10), body)
+		}, -1, http.StatusUnauthorized},
+		{"from the future", func() *http.Request {
+			return signedAlert("key-a", strconv.FormatInt(now.Add(10*time.Minute).Unix(), 10), body)
+		}, -1, http.StatusUnauthorized},
+		{"replayed", func() *http.Request { return signedAlert("key-a", seconds, body) }, -1, http.StatusConflict},
+	}
+	g := newReplayGuard(5 * time.Minute)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.request()
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			match, status, msg := g.verify(r, body, keys)
+			if match != tt.match || status != tt.status {
+				t.Errorf("verify = %d, %d (%s), want %d, %d", match, status, msg, tt.match, tt.status)
+			}
+		})
+	}
+}
+
+func TestReplayGuardForgetsExpired(t *testing.T) {
+	g := newReplayGuard(time.Minute)
+	now := time.Now()
+	if !g.first("a", now, now.Add(time.Minute)) {
+		t.Fatal("first sighting refused")
+	}
+	if g.first("a", now.Add(30*time.Second), now.Add(time.Minute)) {
+		t.Fatal("repeat within the window accepted")
+	}
+	if !g.first("a", now.Add(2*time.Minute), now.Add(3*time.Minute)) {
+		t.Fatal("repeat after expiry refused")
+	}
+}
+
+func TestTradingViewSecretLookup(t *testing.T) {
+	reg := &tenantRegistry{list: []*tenant{
+		{Name: "plain", TradingView: &tradingViewSettings{Secret: "s1"}},
+		{Name: "signed", TradingView: &tradingViewSettings{Secret: "s2", SigningKey: "k2"}},
+		{Name: "none"},
+	}}
+	tests := []struct {
+		secret string
+		want   string
+	}{
+		{"s1", "plain"},
+		{"s2", ""}, // tenants with a signing key only accept signed alerts
+		{"", ""},
+		{"nope", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if tenant := reg.byTradingViewSecret(tt.secret); tenant != nil {
+			got = tenant.Name
+		}
+		if got != tt.want {
+			t.Errorf("byTradingViewSecret(%q) = %q, want %q", tt.secret, got, tt.want)
+		}
+	}
+	signers, keys := reg.tradingViewSigners()
+	if len(signers) != 1 || signers[0].Name != "signed" || keys[0] != "k2" {
+		t.Errorf("tradingViewSigners() = %v, %v", signers, keys)
+	}
+}
+
+func TestTradingViewInstID(t *testing.T) {
+	tests := map[string]string{
+		"BLOFIN:BTCUSDT.P": "BTC-USDT",
+		"BTCUSDT.P":        "BTC-USDT",
+		"ETH-USDT":         "ETH-USDT",
+		"sol/usdt:usdt":    "SOL-USDT",
+	}
+	for ticker, want := range tests {
+		if got := tradingViewInstID(ticker); got != want {
+			t.Errorf("tradingViewInstID(%q) = %q, want %q", ticker, got, want)
+		}
+	}
+}
+
+func TestTradingViewOrder(t *testing.T) {
+	tv := &tradingViewSettings{Secret: "s", InstID: "BTC-USDT", Size: "1", MaxSize: "5"}
+	tests := []struct {
+		name  string
+		alert unifiedArgs
+		want  map[string]string
+		err   string
+	}{
+		{"defaults", unifiedArgs{"action": "Buy"}, map[string]string{"instId": "BTC-USDT", "side": "buy", "size": "1", "orderType": "market", "marginMode": "cross", "positionSide": "net"}, ""},
+		{"alert fields", unifiedArgs{"action": "sell", "ticker": "BLOFIN:ETHUSDT.P", "size": "2", "orderType": "limit", "price": "3000", "reduceOnly": "true"}, map[string]string{"instId": "ETH-USDT", "side": "sell", "size": "2", "orderType": "limit", "price": "3000", "reduceOnly": "true"}, ""},
+		{"bad action", unifiedArgs{"action": "hold"}, nil, "action must be buy or sell"},
+		{"over max", unifiedArgs{"action": "buy", "size": "6"}, nil, "above the configured maximum"},
+		{"negative", unifiedArgs{"action": "buy", "size": "-1"}, nil, "positive number"},
+		{"limit without price", unifiedArgs{"action": "buy", "orderType": "limit"}, nil, "price is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, err := tv.order(tt.alert)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, want := range tt.want {
+				if order[key] != want {
+					t.Errorf("%s = %q, want %q", key, order[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestTradingViewOrderChecks(t *testing.T) {
+	p := newTestProxy(t, "")
+	s := p.srv
+	s.tenants.list[0].TradingView = &tradingViewSettings{Secret: "s", InstID: "BTC-USDT", Size: "1", MaxSize: "1000"}
+	s.cfg.MaxNotional = "1000"
+	send := func(alert string) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		s.handleTradingView(rec, httptest.NewRequest(http.MethodPost, "/webhooks/tradingview", strings.NewReader(alert)))
+		return rec
+	}
+
+	// 1 contract of 0.001 BTC at 64000 is 64 USDT, 100 are 6400
+	if rec := send(`{"secret":"s","action":"buy","size":"1","orderType":"limit","price":"64000"}`); rec.Code != http.StatusOK {
+		t.Fatalf("order within the notional limit = %d %s", rec.Code, rec.Body)
+	}
+	rec := send(`{"secret":"s","action":"buy","size":"100","orderType":"limit","price":"64000"}`)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "above the maximum 1000") {
+		t.Errorf("order above MAX_ORDER_NOTIONAL = %d %s, want 422", rec.Code, rec.Body)
+	}
+
+	// BTC-USDT's tick size is 0.1
+	s.cfg.OrderPrecision = PRECISION_REJECT
+	rec = send(`{"secret":"s","action":"buy","size":"2","orderType":"limit","price":"64000.04"}`)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "price") {
+		t.Errorf("order off the tick size = %d %s, want 422", rec.Code, rec.Body)
+	}
+	s.cfg.OrderPrecision = PRECISION_ROUND
+	if rec := send(`{"secret":"s","action":"buy","size":"3","orderType":"limit","price":"64000.04"}`); rec.Code != http.StatusOK {
+		t.Fatalf("rounded order = %d %s", rec.Code, rec.Body)
+	}
+	var pending []map[string]interface{}
+	if err := s.blofin.do(context.Background(), http.MethodGet, "/api/v1/trade/orders-pending", nil, nil, s.tenants.list[0], &pending); err != nil {
+		t.Fatal(err)
+	}
+	if last := pending[len(pending)-1]; last["size"] != "3" || last["price"] != "64000.0" {
+		t.Errorf("rounded order reached BloFin as %v", last)
+	}
+}
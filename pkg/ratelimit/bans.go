@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_BAN_WINDOW   = time.Minute
+	DEFAULT_BAN_DURATION = 15 * time.Minute
+)
+
+// Temporary bans for clients that keep hammering after being rate limited:
+// the after'th strike within window bans the client IP for duration
+type BanList struct {
+	after    int
+	window   time.Duration
+	duration time.Duration
+
+	mu      sync.Mutex
+	strikes map[string]*banStrikes
+	bans    map[string]time.Time // client IP -> banned until
+	swept   time.Time
+}
+
+type banStrikes struct {
+	n     int
+	since time.Time
+}
+
+// A ban in force, as listed by the admin API
+type Ban struct {
+	IP    string `json:"ip"`
+	Until string `json:"until"`
+}
+
+// NewBanList returns nil when after is 0
+func NewBanList(after int, window, duration time.Duration) *BanList {
+	if after == 0 {
+		return nil
+	}
+	return &BanList{
+		after:    after,
+		window:   window,
+		duration: duration,
+		strikes:  map[string]*banStrikes{},
+		bans:     map[string]time.Time{},
+	}
+}
+
+func (b *BanList) After() int              { return b.after }
+func (b *BanList) Duration() time.Duration { return b.duration }
+
+// Banned reports when the ban on ip ends, if there is one
+func (b *BanList) Banned(ip string, now time.Time) (time.Time, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	until, ok := b.bans[ip]
+	if ok && !now.Before(until) {
+		delete(b.bans, ip)
+		return time.Time{}, false
+	}
+	return until, ok
+}
+
+// Strike counts a 429 for ip; true when it starts a ban
+func (b *BanList) Strike(ip string, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now.Sub(b.swept) > SWEEP_INTERVAL {
+		for key, s := range b.strikes {
+			if now.Sub(s.since) > b.window {
+				delete(b.strikes, key)
+			}
+		}
+		b.swept = now
+	}
+	s, ok := b.strikes[ip]
+	if !ok || now.Sub(s.since) > b.window {
+		s = &banStrikes{since: now}
+		b.strikes[ip] = s
+	}
+	s.n++
+	if s.n < b.after {
+		return false
+	}
+	delete(b.strikes, ip)
+	b.bans[ip] = now.Add(b.duration)
+	return true
+}
+
+// Lift the ban on ip and forget its strikes; false if it wasn't banned
+func (b *BanList) Lift(ip string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.bans[ip]
+	delete(b.bans, ip)
+	delete(b.strikes, ip)
+	return ok
+}
+
+// List the bans still in force, soonest to end first
+func (b *BanList) List(now time.Time) []Ban {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	entries := []Ban{}
+	for ip, until := range b.bans {
+		if now.Before(until) {
+			entries = append(entries, Ban{IP: ip, Until: until.UTC().Format(time.RFC3339)})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Until < entries[j].Until })
+	return entries
+}
+
+// Snapshot returns when each ban ends, for persisting
+func (b *BanList) Snapshot() map[string]time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	saved := make(map[string]time.Time, len(b.bans))
+	for ip, until := range b.bans {
+		saved[ip] = until
+	}
+	return saved
+}
+
+func (b *BanList) Restore(saved map[string]time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ip, until := range saved {
+		b.bans[ip] = until
+	}
+}
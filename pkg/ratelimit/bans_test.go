@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBanListStrikes(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	b := NewBanList(3, time.Minute, 15*time.Minute)
+	steps := []struct {
+		ip     string
+		after  time.Duration
+		banned bool
+	}{
+		{"1.1.1.1", 0, false},
+		{"1.1.1.1", 10 * time.Second, false},
+		{"2.2.2.2", 20 * time.Second, false}, // strikes are per IP
+		{"1.1.1.1", 30 * time.Second, true},
+		{"2.2.2.2", 90 * time.Second, false}, // the window ran out, so this starts a new one
+		{"2.2.2.2", 100 * time.Second, false},
+		{"2.2.2.2", 110 * time.Second, true},
+	}
+	for i, step := range steps {
+		if got := b.Strike(step.ip, start.Add(step.after)); got != step.banned {
+			t.Fatalf("strike %d for %s = %v, want %v", i, step.ip, got, step.banned)
+		}
+	}
+
+	until, ok := b.Banned("1.1.1.1", start.Add(time.Minute))
+	if !ok || !until.Equal(start.Add(30*time.Second+15*time.Minute)) {
+		t.Fatalf("Banned = %s, %v", until, ok)
+	}
+	if _, ok := b.Banned("3.3.3.3", start); ok {
+		t.Fatal("unstruck IP banned")
+	}
+	if list := b.List(start.Add(time.Minute)); len(list) != 2 || list[0].IP != "1.1.1.1" {
+		t.Fatalf("List = %v", list)
+	}
+	if _, ok := b.Banned("1.1.1.1", start.Add(time.Hour)); ok {
+		t.Fatal("ban outlived its duration")
+	}
+	if list := b.List(start.Add(time.Hour)); len(list) != 0 {
+		t.Fatalf("expired bans listed: %v", list)
+	}
+}
+
+func TestBanListLift(t *testing.T) {
+	now := time.Now()
+	b := NewBanList(2, time.Minute, time.Hour)
+	b.Strike("1.1.1.1", now)
+	b.Strike("1.1.1.1", now)
+	if !b.Lift("1.1.1.1") {
+		t.Fatal("Lift of a ban reported none")
+	}
+	if _, ok := b.Banned("1.1.1.1", now); ok {
+		t.Fatal("ban survived Lift")
+	}
+	if b.Lift("1.1.1.1") {
+		t.Fatal("Lift without a ban reported one")
+	}
+	// Lifting forgets strikes too
+	b.Strike("2.2.2.2", now)
+	b.Lift("2.2.2.2")
+	if b.Strike("2.2.2.2", now) {
+		t.Fatal("strike before Lift still counted")
+	}
+}
+
+func TestBanListSnapshotRestore(t *testing.T) {
+	now := time.Now()
+	b := NewBanList(1, time.Minute, time.Hour)
+	b.Strike("1.1.1.1", now)
+
+	restored := NewBanList(1, time.Minute, time.Hour)
+	restored.Restore(b.Snapshot())
+	if _, ok := restored.Banned("1.1.1.1", now); !ok {
+		t.Fatal("ban lost across restore")
+	}
+	if NewBanList(0, time.Minute, time.Hour) != nil {
+		t.Fatal("a threshold of 0 should disable bans")
+	}
+}
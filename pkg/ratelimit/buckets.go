@@ -0,0 +1,130 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Buckets for one scope, keyed by IP, origin or anything else
+type Buckets struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	swept   time.Time
+}
+
+// A bucket as persisted between restarts
+type SavedBucket struct {
+	Tokens float64   `json:"tokens"`
+	Last   time.Time `json:"last"`
+}
+
+// NewBuckets returns nil when rate is 0; burst 0 means one second's worth
+func NewBuckets(rate float64, burst int) *Buckets {
+	if rate == 0 {
+		return nil
+	}
+	if burst == 0 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+	return &Buckets{rate: rate, burst: float64(burst), buckets: map[string]*tokenBucket{}}
+}
+
+// Take a token for key, or report how long until one is available
+func (b *Buckets) Take(key string, now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now.Sub(b.swept) > SWEEP_INTERVAL {
+		b.sweep(now)
+	}
+	bucket, ok := b.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: b.burst, last: now}
+		b.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(b.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*b.rate)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / b.rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// Drop buckets that have refilled completely; they'd be recreated full anyway
+func (b *Buckets) sweep(now time.Time) {
+	full := time.Duration(b.burst / b.rate * float64(time.Second))
+	for key, bucket := range b.buckets {
+		if now.Sub(bucket.last) > full {
+			delete(b.buckets, key)
+		}
+	}
+	b.swept = now
+}
+
+func (b *Buckets) Snapshot() map[string]SavedBucket {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	saved := make(map[string]SavedBucket, len(b.buckets))
+	for key, bucket := range b.buckets {
+		saved[key] = SavedBucket{Tokens: bucket.tokens, Last: bucket.last}
+	}
+	return saved
+}
+
+// Restore saved buckets; they refill from their saved time, so downtime
+// still counts
+func (b *Buckets) Restore(saved map[string]SavedBucket) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for key, bucket := range saved {
+		b.buckets[key] = &tokenBucket{tokens: bucket.Tokens, last: bucket.Last}
+	}
+}
+
+// Caps the requests each client has in flight at once, independent of its
+// rate, so a burst of slow downloads from one client can't tie up the
+// upstream connection pool
+type InflightCaps struct {
+	max int
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// NewInflightCaps returns nil when max is 0
+func NewInflightCaps(max int) *InflightCaps {
+	if max == 0 {
+		return nil
+	}
+	return &InflightCaps{max: max, counts: map[string]int{}}
+}
+
+func (c *InflightCaps) Max() int { return c.max }
+
+// Acquire a slot for client, false when it already has max in flight
+func (c *InflightCaps) Acquire(client string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts[client] >= c.max {
+		return false
+	}
+	c.counts[client]++
+	return true
+}
+
+func (c *InflightCaps) Release(client string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts[client]--; c.counts[client] <= 0 {
+		delete(c.counts, client)
+	}
+}
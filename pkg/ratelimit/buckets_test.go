@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucketsTake(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	b := NewBuckets(2, 3)
+	steps := []struct {
+		key   string
+		after time.Duration
+		ok    bool
+		retry time.Duration
+	}{
+		{"a", 0, true, 0},
+		{"a", 0, true, 0},
+		{"a", 0, true, 0},
+		{"a", 0, false, 500 * time.Millisecond},
+		{"b", 0, true, 0}, // keys don't share a bucket
+		{"a", 250 * time.Millisecond, false, 250 * time.Millisecond},
+		{"a", 500 * time.Millisecond, true, 0},
+		{"a", 500 * time.Millisecond, false, 500 * time.Millisecond},
+		{"a", time.Hour, true, 0}, // refills up to the burst only
+		{"a", time.Hour, true, 0},
+		{"a", time.Hour, true, 0},
+		{"a", time.Hour, false, 500 * time.Millisecond},
+	}
+	for i, step := range steps {
+		ok, retry := b.Take(step.key, start.Add(step.after))
+		if ok != step.ok || retry.Round(time.Millisecond) != step.retry {
+			t.Fatalf("take %d = %v, %s; want %v, %s", i, ok, retry, step.ok, step.retry)
+		}
+	}
+}
+
+func TestBucketsDefaultBurst(t *testing.T) {
+	if NewBuckets(0, 10) != nil {
+		t.Fatal("a rate of 0 should disable the scope")
+	}
+	now := time.Now()
+	for rate, burst := range map[float64]int{0.5: 1, 1: 1, 2.5: 3, 10: 10} {
+		b := NewBuckets(rate, 0)
+		for i := 0; i < burst; i++ {
+			if ok, _ := b.Take("a", now); !ok {
+				t.Fatalf("rate %v: take %d refused", rate, i)
+			}
+		}
+		if ok, _ := b.Take("a", now); ok {
+			t.Fatalf("rate %v: burst above %d", rate, burst)
+		}
+	}
+}
+
+func TestBucketsSnapshotRestore(t *testing.T) {
+	now := time.Now()
+	b := NewBuckets(1, 2)
+	b.Take("a", now)
+	b.Take("a", now)
+
+	restored := NewBuckets(1, 2)
+	restored.Restore(b.Snapshot())
+	if ok, _ := restored.Take("a", now); ok {
+		t.Fatal("restored bucket should be empty")
+	}
+	// Time spent down refills it
+	if ok, _ := restored.Take("a", now.Add(time.Second)); !ok {
+		t.Fatal("restored bucket didn't refill")
+	}
+}
+
+func TestInflightCaps(t *testing.T) {
+	if NewInflightCaps(0) != nil {
+		t.Fatal("a cap of 0 should disable it")
+	}
+	c := NewInflightCaps(2)
+	if !c.Acquire("a") || !c.Acquire("a") {
+		t.Fatal("acquire under the cap refused")
+	}
+	if c.Acquire("a") {
+		t.Fatal("acquire over the cap allowed")
+	}
+	if !c.Acquire("b") {
+		t.Fatal("clients share a cap")
+	}
+	c.Release("a")
+	if !c.Acquire("a") {
+		t.Fatal("released slot not reusable")
+	}
+	c.Release("a")
+	c.Release("a")
+	c.Release("b")
+	if len(c.counts) != 0 {
+		t.Fatalf("counts left behind: %v", c.counts)
+	}
+}
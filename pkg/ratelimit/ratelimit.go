@@ -0,0 +1,218 @@
+// Package ratelimit holds the token-bucket limits, concurrency caps and
+// temporary bans the proxy puts in front of BloFin, for services that
+// want the same protection on their own endpoints.
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rate limit scopes, also the proxy's metric label
+const (
+	LIMIT_IP         = "ip"
+	LIMIT_ORIGIN     = "origin"
+	LIMIT_PATH       = "path"
+	LIMIT_CONCURRENT = "concurrency"
+)
+
+// How often idle buckets and stale strikes are dropped
+const SWEEP_INTERVAL = time.Minute
+
+// Token-bucket limits on requests. PerIP throttles each client address;
+// PerOrigin throttles each browser Origin as a unit, so one web app spread
+// over many user IPs can't starve other frontends sharing the service. A
+// rate of 0 disables that limit.
+type Settings struct {
+	PerIP          float64 // requests per second
+	IPBurst        int     // 0 means one second's worth
+	PerOrigin      float64
+	OriginBurst    int
+	TrustForwarded bool // take the client IP from X-Forwarded-For, for deployments behind a load balancer
+	Groups         []Group
+	BanAfter       int // per-IP 429s within BanWindow that get a client IP banned, 0 to never ban
+	BanWindow      time.Duration
+	BanDuration    time.Duration
+	MaxConcurrent  int // in-flight requests per client, 0 for no cap
+}
+
+// Limit for each client IP on paths starting with Prefix, checked on top of
+// PerIP the way BloFin buckets its own limits per endpoint. When groups
+// overlap the longest prefix wins.
+type Group struct {
+	Prefix string
+	Rate   float64
+	Burst  int
+}
+
+func (s Settings) Validate() error {
+	if s.PerIP < 0 || s.PerOrigin < 0 || s.IPBurst < 0 || s.OriginBurst < 0 {
+		return fmt.Errorf("rates and bursts must not be negative")
+	}
+	if s.MaxConcurrent < 0 {
+		return fmt.Errorf("concurrency cap must not be negative")
+	}
+	if s.BanAfter < 0 || (s.BanAfter > 0 && (s.BanWindow <= 0 || s.BanDuration <= 0)) {
+		return fmt.Errorf("ban threshold must not be negative, and bans need a positive window and duration")
+	}
+	if s.BanAfter > 0 && s.PerIP == 0 {
+		return fmt.Errorf("bans count per-IP 429s, so they need a per-IP rate")
+	}
+	for _, g := range s.Groups {
+		if !strings.HasPrefix(g.Prefix, "/") {
+			return fmt.Errorf("group prefix %q must start with /", g.Prefix)
+		}
+		if g.Rate <= 0 || g.Burst < 0 {
+			return fmt.Errorf("group %s: rate must be positive and burst not negative", g.Prefix)
+		}
+	}
+	return nil
+}
+
+// Parse "/path/prefix rate[/burst]" entries
+func ParseGroups(entries []string) ([]Group, error) {
+	var groups []Group
+	for _, entry := range entries {
+		fields := strings.Fields(entry)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%q: expected a path prefix and a rate", entry)
+		}
+		rate, burst, _ := strings.Cut(fields[1], "/")
+		g := Group{Prefix: fields[0]}
+		var err error
+		if g.Rate, err = strconv.ParseFloat(rate, 64); err != nil {
+			return nil, fmt.Errorf("%q: invalid rate %q", entry, rate)
+		}
+		if burst != "" {
+			if g.Burst, err = strconv.Atoi(burst); err != nil {
+				return nil, fmt.Errorf("%q: invalid burst %q", entry, burst)
+			}
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// Limiter checks requests against every configured scope
+type Limiter struct {
+	ip             *Buckets
+	origin         *Buckets
+	groups         []pathLimit // longest prefix first
+	bans           *BanList
+	inflight       *InflightCaps
+	trustForwarded bool
+}
+
+type pathLimit struct {
+	prefix  string
+	buckets *Buckets
+}
+
+// New returns nil when no limit is configured
+func New(settings Settings) *Limiter {
+	if settings.PerIP == 0 && settings.PerOrigin == 0 && len(settings.Groups) == 0 && settings.MaxConcurrent == 0 {
+		return nil
+	}
+	l := &Limiter{
+		ip:             NewBuckets(settings.PerIP, settings.IPBurst),
+		origin:         NewBuckets(settings.PerOrigin, settings.OriginBurst),
+		bans:           NewBanList(settings.BanAfter, settings.BanWindow, settings.BanDuration),
+		inflight:       NewInflightCaps(settings.MaxConcurrent),
+		trustForwarded: settings.TrustForwarded,
+	}
+	for _, g := range settings.Groups {
+		l.groups = append(l.groups, pathLimit{prefix: g.Prefix, buckets: NewBuckets(g.Rate, g.Burst)})
+	}
+	sort.SliceStable(l.groups, func(i, j int) bool { return len(l.groups[i].prefix) > len(l.groups[j].prefix) })
+	return l
+}
+
+// Group for path, nil when none matches
+func (l *Limiter) group(path string) *pathLimit {
+	for i := range l.groups {
+		if strings.HasPrefix(path, l.groups[i].prefix) {
+			return &l.groups[i]
+		}
+	}
+	return nil
+}
+
+// Allow checks the request against each configured scope, narrowest first
+// so a request refused by its path group or origin doesn't also use up the
+// client's per-IP allowance. group is the matched prefix for LIMIT_PATH.
+func (l *Limiter) Allow(r *http.Request) (scope, group string, retry time.Duration, ok bool) {
+	now := time.Now()
+	ip := l.ClientIP(r)
+	if g := l.group(r.URL.Path); g != nil {
+		if ok, retry := g.buckets.Take(ip, now); !ok {
+			return LIMIT_PATH, g.prefix, retry, false
+		}
+	}
+	if origin := strings.ToLower(r.Header.Get("Origin")); l.origin != nil && origin != "" {
+		if ok, retry := l.origin.Take(origin, now); !ok {
+			return LIMIT_ORIGIN, "", retry, false
+		}
+	}
+	if l.ip != nil {
+		if ok, retry := l.ip.Take(ip, now); !ok {
+			return LIMIT_IP, "", retry, false
+		}
+	}
+	return "", "", 0, true
+}
+
+// ClientIP is the address requests are limited and banned by
+func (l *Limiter) ClientIP(r *http.Request) string {
+	if l.trustForwarded {
+		// The load balancer appends the address it saw last
+		forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+		if ip := strings.TrimSpace(forwarded[len(forwarded)-1]); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Bans is nil when bans are disabled
+func (l *Limiter) Bans() *BanList {
+	if l == nil {
+		return nil
+	}
+	return l.bans
+}
+
+// Inflight is nil when concurrency isn't capped
+func (l *Limiter) Inflight() *InflightCaps {
+	if l == nil {
+		return nil
+	}
+	return l.inflight
+}
+
+// Scopes returns the buckets by persisted scope name; unset scopes are
+// left out
+func (l *Limiter) Scopes() map[string]*Buckets {
+	scopes := map[string]*Buckets{}
+	if l == nil {
+		return scopes
+	}
+	if l.ip != nil {
+		scopes[LIMIT_IP] = l.ip
+	}
+	if l.origin != nil {
+		scopes[LIMIT_ORIGIN] = l.origin
+	}
+	for _, g := range l.groups {
+		scopes[LIMIT_PATH+" "+g.prefix] = g.buckets
+	}
+	return scopes
+}
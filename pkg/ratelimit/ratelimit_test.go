@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		err      string
+	}{
+		{"off", Settings{}, ""},
+		{"per IP with bans", Settings{PerIP: 5, BanAfter: 3, BanWindow: time.Minute, BanDuration: time.Hour}, ""},
+		{"negative rate", Settings{PerIP: -1}, "must not be negative"},
+		{"negative burst", Settings{PerOrigin: 1, OriginBurst: -1}, "must not be negative"},
+		{"negative concurrency", Settings{MaxConcurrent: -1}, "concurrency"},
+		{"bans without a window", Settings{PerIP: 5, BanAfter: 3, BanDuration: time.Hour}, "positive window"},
+		{"bans without a per-IP rate", Settings{PerOrigin: 5, BanAfter: 3, BanWindow: time.Minute, BanDuration: time.Hour}, "per-IP rate"},
+		{"relative group", Settings{Groups: []Group{{Prefix: "api", Rate: 1}}}, "must start with /"},
+		{"zero group rate", Settings{Groups: []Group{{Prefix: "/api", Rate: 0}}}, "rate must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.settings.Validate()
+			if tt.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseGroups(t *testing.T) {
+	groups, err := ParseGroups([]string{"/api/v1/trade 5", " /api/v1/market  20/40 "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Group{{Prefix: "/api/v1/trade", Rate: 5}, {Prefix: "/api/v1/market", Rate: 20, Burst: 40}}
+	if !reflect.DeepEqual(groups, want) {
+		t.Fatalf("ParseGroups = %v, want %v", groups, want)
+	}
+	for _, entry := range []string{"/api", "/api fast", "/api 5/many", "/api 5 10"} {
+		if _, err := ParseGroups([]string{entry}); err == nil {
+			t.Errorf("ParseGroups(%q) accepted", entry)
+		}
+	}
+}
+
+func request(ip, path, origin string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	r.RemoteAddr = ip + ":40000"
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	return r
+}
+
+func TestLimiterAllow(t *testing.T) {
+	if New(Settings{BanAfter: 3}) != nil {
+		t.Fatal("a limiter without limits should be nil")
+	}
+	l := New(Settings{
+		PerIP:       1,
+		IPBurst:     3,
+		PerOrigin:   1,
+		OriginBurst: 2,
+		Groups: []Group{
+			{Prefix: "/api/", Rate: 1, Burst: 10},
+			{Prefix: "/api/v1/trade/", Rate: 1, Burst: 1},
+		},
+	})
+	steps := []struct {
+		r     *http.Request
+		scope string
+		group string
+	}{
+		// The longest prefix wins, and a refusal there leaves the IP's allowance alone
+		{request("10.0.0.1", "/api/v1/trade/order", ""), "", ""},
+		{request("10.0.0.1", "/api/v1/trade/order", ""), LIMIT_PATH, "/api/v1/trade/"},
+		{request("10.0.0.1", "/api/v1/market/tickers", ""), "", ""},
+		{request("10.0.0.1", "/health", ""), "", ""},
+		{request("10.0.0.1", "/health", ""), LIMIT_IP, ""},
+		// An origin is limited as a unit across IPs
+		{request("10.0.0.2", "/health", "https://App.example"), "", ""},
+		{request("10.0.0.3", "/health", "https://app.example"), "", ""},
+		{request("10.0.0.4", "/health", "https://app.example"), LIMIT_ORIGIN, ""},
+		{request("10.0.0.4", "/health", ""), "", ""},
+	}
+	for i, step := range steps {
+		scope, group, retry, ok := l.Allow(step.r)
+		if scope != step.scope || group != step.group || ok != (step.scope == "") {
+			t.Fatalf("request %d = %q %q %v, want %q %q", i, scope, group, ok, step.scope, step.group)
+		}
+		if !ok && retry <= 0 {
+			t.Fatalf("request %d refused without a retry time", i)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	r := request("10.0.0.1", "/", "")
+	r.Header.Set("X-Forwarded-For", "1.2.3.4, 5.6.7.8")
+	if ip := New(Settings{PerIP: 1}).ClientIP(r); ip != "10.0.0.1" {
+		t.Errorf("untrusted X-Forwarded-For used: %s", ip)
+	}
+	trusting := New(Settings{PerIP: 1, TrustForwarded: true})
+	if ip := trusting.ClientIP(r); ip != "5.6.7.8" {
+		t.Errorf("trusted ClientIP = %s, want the address the load balancer appended", ip)
+	}
+	if ip := trusting.ClientIP(request("10.0.0.1", "/", "")); ip != "10.0.0.1" {
+		t.Errorf("ClientIP without X-Forwarded-For = %s", ip)
+	}
+}